@@ -0,0 +1,783 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// apiDefaultLimit is the page size used when a request has no limit.
+	apiDefaultLimit = 50
+
+	// apiMaxLimit caps the page size a client can request.
+	apiMaxLimit = 500
+)
+
+// API error codes returned in APIError bodies.
+const (
+	ErrCodeBadRequest = "bad_request"
+	ErrCodeNotFound   = "not_found"
+	ErrCodeForbidden  = "forbidden"
+	ErrCodeConflict   = "conflict"
+	ErrCodeInternal   = "internal"
+
+	// ErrCodeUnauthorized is returned to requests without a valid
+	// bearer token or session.
+	ErrCodeUnauthorized = "unauthorized"
+)
+
+// APIError is the body of every non-2xx response from the versioned API.
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+// APIErrorDetail describes an API error.
+type APIErrorDetail struct {
+	// Code is a stable, machine-readable error code.
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the error.
+	Message string `json:"message"`
+}
+
+// APIPage describes the slice of a collection returned in a response.
+type APIPage struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+
+	// NextOffset is the offset of the next page, if there is one.
+	NextOffset *int `json:"nextOffset,omitempty"`
+}
+
+// APIProject is a project with one page of its sessions.
+type APIProject struct {
+	Project
+
+	// Sessions shadows Project.Sessions with the requested page of
+	// sessions, including their task info.
+	Sessions []SessionViewEntry `json:"sessions"`
+	Page     APIPage            `json:"page"`
+}
+
+// APISession is a session with the project it belongs to.
+type APISession struct {
+	SessionViewEntry
+	ProjectDir    string `json:"projectDir"`
+	HasTranscript bool   `json:"hasTranscript"`
+}
+
+// APITaskList is a task list, optionally filtered by status.
+type APITaskList struct {
+	ListID   string                     `json:"listId"`
+	Filter   string                     `json:"filter,omitempty"`
+	Archived bool                       `json:"archived"`
+	Counts   TaskCounts                 `json:"counts"`
+	Tasks    []claudeagent.TaskListItem `json:"tasks"`
+}
+
+// APITask is a single task along with the tasks it depends on and blocks.
+type APITask struct {
+	ListID   string                     `json:"listId"`
+	Archived bool                       `json:"archived"`
+	Task     *claudeagent.TaskListItem  `json:"task"`
+	Blockers []claudeagent.TaskListItem `json:"blockers"`
+	Blocking []claudeagent.TaskListItem `json:"blocking"`
+}
+
+// registerAPIRoutes sets up the versioned JSON API under /api/v1/.
+func (h *HTTPServer) registerAPIRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.json", h.handleAPIOpenAPI)
+
+	mux.HandleFunc("GET /api/v1/project-groups", h.handleAPIProjectGroups)
+	mux.HandleFunc("GET /api/v1/projects", h.handleAPIProjects)
+	mux.HandleFunc("GET /api/v1/projects/{projectID}", h.handleAPIProject)
+	mux.HandleFunc(
+		"GET /api/v1/projects/{projectID}/usage", h.handleAPIProjectUsage,
+	)
+	mux.HandleFunc(
+		"GET /api/v1/projects/{projectID}/sessions/tree",
+		h.handleAPISessionTree,
+	)
+
+	mux.HandleFunc("GET /api/v1/sessions/{sessionID}", h.handleAPISession)
+	mux.HandleFunc(
+		"GET /api/v1/sessions/{sessionID}/usage", h.handleAPISessionUsage,
+	)
+	mux.HandleFunc("GET /api/v1/usage", h.handleAPIUsage)
+	mux.HandleFunc(
+		"GET /api/v1/sessions/{sessionID}/transcript",
+		h.handleAPITranscript,
+	)
+
+	mux.HandleFunc("GET /api/v1/lists", h.handleAPILists)
+	mux.HandleFunc("GET /api/v1/lists/{listID}/tasks", h.handleAPITasks)
+	mux.HandleFunc(
+		"GET /api/v1/lists/{listID}/tasks/{taskID}", h.handleAPITask,
+	)
+	mux.HandleFunc(
+		"POST /api/v1/lists/{listID}/tasks", h.handleAPICreateTask,
+	)
+	mux.HandleFunc(
+		"PATCH /api/v1/lists/{listID}/tasks/{taskID}",
+		h.handleAPIEditTask,
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/lists/{listID}/tasks/{taskID}",
+		h.handleAPIDeleteTask,
+	)
+	mux.HandleFunc("GET /api/v1/lists/{listID}/graph", h.handleGraphData)
+	mux.HandleFunc(
+		"GET /api/v1/lists/{listID}/history", h.handleAPIListHistory,
+	)
+	mux.HandleFunc(
+		"GET /api/v1/lists/{listID}/timing", h.handleAPIListTiming,
+	)
+	mux.HandleFunc(
+		"GET /api/v1/lists/{listID}/tasks/{taskID}/history",
+		h.handleAPITaskHistory,
+	)
+
+	mux.HandleFunc("GET /api/v1/instances", h.handleInstancesAPI)
+	mux.HandleFunc("GET /api/v1/stalled", h.handleAPIStalled)
+
+	mux.HandleFunc("GET /api/v1/events/stats", h.handleAPIEventStats)
+
+	mux.HandleFunc("GET /api/v1/search", h.handleAPISearch)
+
+	mux.HandleFunc("GET /api/v1/fleet", h.handleAPIFleet)
+	mux.HandleFunc(
+		"GET "+snapshotPath, h.handleAPIFederationSnapshot,
+	)
+	mux.HandleFunc("POST "+pushPath, h.handleAPIFederationPush)
+
+	mux.HandleFunc(
+		"GET /api/v1/notifications/deliveries",
+		h.handleAPINotificationDeliveries,
+	)
+
+	// Anything else under the API prefix gets a JSON 404 rather than the
+	// HTML error page.
+	mux.HandleFunc("GET /api/v1/", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"no such endpoint: "+r.URL.Path,
+		)
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes an APIError response.
+func writeAPIError(w http.ResponseWriter, code int, errCode, msg string) {
+	writeJSON(w, code, APIError{
+		Error: APIErrorDetail{Code: errCode, Message: msg},
+	})
+}
+
+// parsePage reads the offset and limit query parameters.
+func parsePage(r *http.Request) (int, int, error) {
+	offset, limit := 0, apiDefaultLimit
+
+	if s := r.URL.Query().Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a non-negative " +
+				"integer")
+		}
+		offset = n
+	}
+
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > apiMaxLimit {
+			return 0, 0, errors.New("limit must be between 1 and " +
+				strconv.Itoa(apiMaxLimit))
+		}
+		limit = n
+	}
+
+	return offset, limit, nil
+}
+
+// handleAPIOpenAPI serves the OpenAPI document describing the API.
+func (h *HTTPServer) handleAPIOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := staticFS.ReadFile("static/openapi.json")
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+// handleAPIProjectGroups returns all projects grouped by base repository.
+func (h *HTTPServer) handleAPIProjectGroups(w http.ResponseWriter,
+	r *http.Request) {
+
+	groups, err := h.projectIndexer.ListProjectGroups()
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to list projects: "+err.Error(),
+		)
+		return
+	}
+
+	if groups == nil {
+		groups = []ProjectGroup{}
+	}
+
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// handleAPIProjects returns a summary of every project.
+func (h *HTTPServer) handleAPIProjects(w http.ResponseWriter,
+	r *http.Request) {
+
+	summaries, err := h.projectIndexer.ListProjectSummaries()
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to list projects: "+err.Error(),
+		)
+		return
+	}
+
+	if summaries == nil {
+		summaries = []ProjectSummary{}
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// handleAPIProject returns a project with a page of its sessions.
+func (h *HTTPServer) handleAPIProject(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := parsePage(r)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusBadRequest, ErrCodeBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	dirName := r.PathValue("projectID")
+	project, err := h.projectIndexer.GetProject(dirName)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"project not found: "+dirName,
+		)
+		return
+	}
+
+	total := len(project.Sessions)
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	sessions := make([]SessionViewEntry, 0, end-start)
+	for _, s := range project.Sessions[start:end] {
+		sessions = append(sessions, h.sessionViewEntry(s))
+	}
+
+	resp := APIProject{
+		Project:  project,
+		Sessions: sessions,
+		Page: APIPage{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}
+	if end < total {
+		resp.Page.NextOffset = &end
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAPISession returns a session's metadata and task info.
+func (h *HTTPServer) handleAPISession(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+
+	session, projectDir, ok := h.projectIndexer.GetSession(sessionID)
+	if !ok {
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"session not found: "+sessionID,
+		)
+		return
+	}
+
+	_, hasTranscript := h.projectIndexer.TranscriptPath(sessionID)
+
+	writeJSON(w, http.StatusOK, APISession{
+		SessionViewEntry: h.sessionViewEntry(session),
+		ProjectDir:       projectDir,
+		HasTranscript:    hasTranscript,
+	})
+}
+
+// handleAPITranscript returns a session's parsed transcript.
+func (h *HTTPServer) handleAPITranscript(w http.ResponseWriter,
+	r *http.Request) {
+
+	sessionID := r.PathValue("sessionID")
+
+	path, ok := h.projectIndexer.TranscriptPath(sessionID)
+	if !ok {
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"transcript not found for session "+sessionID,
+		)
+		return
+	}
+
+	transcript, err := LoadTranscript(path)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to read transcript: "+err.Error(),
+		)
+		return
+	}
+
+	if transcript.Messages == nil {
+		transcript.Messages = []TranscriptMessage{}
+	}
+
+	writeJSON(w, http.StatusOK, transcript)
+}
+
+// handleAPILists returns every task list that has task files on disk.
+func (h *HTTPServer) handleAPILists(w http.ResponseWriter, r *http.Request) {
+	activeLists, err := h.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to list active task lists: "+err.Error(),
+		)
+		return
+	}
+	h.watchdog.annotateLists(activeLists)
+
+	writeJSON(w, http.StatusOK, activeLists)
+}
+
+// handleAPITasks returns the tasks of a list, filtered by the same status
+// filters as the UI.
+func (h *HTTPServer) handleAPITasks(w http.ResponseWriter, r *http.Request) {
+	listID := r.PathValue("listID")
+
+	filter := r.URL.Query().Get("filter")
+	if !validTaskFilter(filter) {
+		writeAPIError(
+			w, http.StatusBadRequest, ErrCodeBadRequest,
+			"unknown filter: "+filter,
+		)
+		return
+	}
+
+	tasks, archived, err := h.listTasks(r.Context(), listID)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to load tasks: "+err.Error(),
+		)
+		return
+	}
+
+	filtered := filterTasks(tasks, filter)
+	if filtered == nil {
+		filtered = []claudeagent.TaskListItem{}
+	}
+
+	writeJSON(w, http.StatusOK, APITaskList{
+		ListID:   listID,
+		Filter:   filter,
+		Archived: archived,
+		Counts:   countTasks(tasks),
+		Tasks:    filtered,
+	})
+}
+
+// handleAPITask returns a single task with its blockers and the tasks it
+// blocks.
+func (h *HTTPServer) handleAPITask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	listID := r.PathValue("listID")
+	taskID := r.PathValue("taskID")
+
+	task, archived, err := h.getTask(ctx, listID, taskID)
+	switch {
+	case errors.Is(err, errTaskNotFound):
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"failed to load task: "+err.Error(),
+		)
+		return
+
+	case err != nil:
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to load task: "+err.Error(),
+		)
+		return
+	}
+
+	allTasks, _, _ := h.listTasks(ctx, listID)
+	taskMap := make(map[string]claudeagent.TaskListItem, len(allTasks))
+	for _, t := range allTasks {
+		taskMap[t.ID] = t
+	}
+
+	resp := APITask{
+		ListID:   listID,
+		Archived: archived,
+		Task:     task,
+		Blockers: []claudeagent.TaskListItem{},
+		Blocking: []claudeagent.TaskListItem{},
+	}
+	for _, id := range task.BlockedBy {
+		if t, ok := taskMap[id]; ok {
+			resp.Blockers = append(resp.Blockers, t)
+		}
+	}
+	for _, id := range task.Blocks {
+		if t, ok := taskMap[id]; ok {
+			resp.Blocking = append(resp.Blocking, t)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAPIListHistory returns every recorded change to a list's tasks,
+// oldest first.
+func (h *HTTPServer) handleAPIListHistory(w http.ResponseWriter,
+	r *http.Request) {
+
+	history, err := h.taskArchive.History(r.PathValue("listID"))
+	writeHistory(w, history, err)
+}
+
+// handleAPITaskHistory returns the recorded changes to a single task, oldest
+// first.
+func (h *HTTPServer) handleAPITaskHistory(w http.ResponseWriter,
+	r *http.Request) {
+
+	history, err := h.taskArchive.TaskHistory(
+		r.PathValue("listID"), r.PathValue("taskID"),
+	)
+	writeHistory(w, history, err)
+}
+
+// writeHistory writes a history response.
+func writeHistory(w http.ResponseWriter, history []TaskChange, err error) {
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	if history == nil {
+		history = []TaskChange{}
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// handleAPIListTiming returns the time each task of a list has spent in each
+// status, along with the list's throughput and burndown.
+func (h *HTTPServer) handleAPIListTiming(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+
+	tasks, archived, err := h.listTasks(r.Context(), listID)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to load tasks: "+err.Error(),
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.listTiming(listID, tasks, archived))
+}
+
+// handleAPIUsage returns the token usage and estimated cost across every
+// project, by project, project group and day.
+func (h *HTTPServer) handleAPIUsage(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.usageTracker.Overview()
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// handleAPIProjectUsage returns the token usage and estimated cost of a
+// project and each of its sessions.
+func (h *HTTPServer) handleAPIProjectUsage(w http.ResponseWriter,
+	r *http.Request) {
+
+	dirName := r.PathValue("projectID")
+	if _, err := h.projectIndexer.GetProject(dirName); err != nil {
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"project not found: "+dirName,
+		)
+		return
+	}
+
+	usage, err := h.usageTracker.ProjectUsage(dirName)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// handleAPISessionTree returns a project's sessions arranged into trees of
+// sidechains under the sessions that started them.
+func (h *HTTPServer) handleAPISessionTree(w http.ResponseWriter,
+	r *http.Request) {
+
+	dirName := r.PathValue("projectID")
+	project, err := h.projectIndexer.GetProject(dirName)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"project not found: "+dirName,
+		)
+		return
+	}
+
+	sessions := make([]SessionViewEntry, len(project.Sessions))
+	for i, s := range project.Sessions {
+		sessions[i] = h.sessionViewEntry(s)
+	}
+
+	// The tree is still useful without usage, so a project whose
+	// transcripts cannot be read is served without it.
+	var usage *ProjectUsage
+	if u, err := h.usageTracker.ProjectUsage(dirName); err == nil {
+		usage = &u
+	}
+
+	tree := buildSessionTree(sessions, usage)
+	if tree == nil {
+		tree = []*SessionNode{}
+	}
+
+	writeJSON(w, http.StatusOK, tree)
+}
+
+// handleAPISessionUsage returns the token usage and estimated cost of a
+// session, including the subagents it ran.
+func (h *HTTPServer) handleAPISessionUsage(w http.ResponseWriter,
+	r *http.Request) {
+
+	sessionID := r.PathValue("sessionID")
+	usage, err := h.usageTracker.SessionUsage(sessionID)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		writeAPIError(
+			w, http.StatusNotFound, ErrCodeNotFound,
+			"transcript not found: "+sessionID,
+		)
+		return
+
+	case err != nil:
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// handleAPIStalled returns the sessions the watchdog has flagged as stalled,
+// longest stalled first.
+func (h *HTTPServer) handleAPIStalled(w http.ResponseWriter, r *http.Request) {
+	stalled := h.watchdog.Stalled()
+	if stalled == nil {
+		stalled = []StallInfo{}
+	}
+
+	writeJSON(w, http.StatusOK, stalled)
+}
+
+// writeEditError writes the API error for a failed edit.
+func (h *HTTPServer) writeEditError(w http.ResponseWriter, err error) {
+	code, errCode := editStatus(err)
+	if code == http.StatusInternalServerError {
+		h.log.Errorf("Task edit failed: %v", err)
+	}
+
+	writeAPIError(w, code, errCode, err.Error())
+}
+
+// decodeEdit decodes a JSON request body, rejecting unknown fields so that
+// a misspelled field is not silently ignored.
+func decodeEdit(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidEdit("malformed request body: %v", err)
+	}
+
+	return nil
+}
+
+// handleAPICreateTask creates a task in a running session's list.
+func (h *HTTPServer) handleAPICreateTask(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+	if err := h.checkWritable(r, listID); err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	var draft TaskDraft
+	if err := decodeEdit(w, r, &draft); err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	task, err := h.createTask(r.Context(), listID, draft)
+	if err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, task)
+}
+
+// handleAPIEditTask applies a partial update to a task.
+func (h *HTTPServer) handleAPIEditTask(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+	if err := h.checkWritable(r, listID); err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	var edit TaskEdit
+	if err := decodeEdit(w, r, &edit); err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	task, err := h.editTask(
+		r.Context(), listID, r.PathValue("taskID"), edit,
+	)
+	if err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, task)
+}
+
+// handleAPIDeleteTask deletes a task and the dependency edges referring to
+// it.
+func (h *HTTPServer) handleAPIDeleteTask(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+	if err := h.checkWritable(r, listID); err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	err := h.deleteTask(r.Context(), listID, r.PathValue("taskID"))
+	if err != nil {
+		h.writeEditError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPISearch runs a full-text search across sessions, tasks and, if
+// enabled, transcripts.
+func (h *HTTPServer) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSearchQuery(r)
+	if err == nil && q.Text == "" {
+		err = errors.New("missing query parameter q")
+	}
+	if err != nil {
+		writeAPIError(
+			w, http.StatusBadRequest, ErrCodeBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	results, err := h.searchIndex.Search(q)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusBadRequest, ErrCodeBadRequest,
+			err.Error(),
+		)
+		return
+	}
+	if results.Results == nil {
+		results.Results = []SearchResult{}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleAPINotificationDeliveries returns the log of recent webhook
+// deliveries, newest first.
+func (h *HTTPServer) handleAPINotificationDeliveries(w http.ResponseWriter,
+	r *http.Request) {
+
+	deliveries := h.notifier.Deliveries()
+	if deliveries == nil {
+		deliveries = []Delivery{}
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// handleAPIEventStats returns the activity of the event bus.
+func (h *HTTPServer) handleAPIEventStats(w http.ResponseWriter,
+	r *http.Request) {
+
+	writeJSON(w, http.StatusOK, h.eventBus.Stats())
+}
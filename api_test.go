@@ -0,0 +1,123 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btclog/v2"
+)
+
+// newTestHTTPServer returns a server over a task store, with the routes
+// registered on the returned mux. The tasks directory holds a live list s1.
+func newTestHTTPServer(t *testing.T, store *fakeTaskStore) (*HTTPServer,
+	*http.ServeMux) {
+
+	t.Helper()
+
+	root := t.TempDir()
+	projectsDir := filepath.Join(root, "projects")
+	tasksDir := filepath.Join(root, "tasks")
+	dirs := []string{projectsDir, filepath.Join(tasksDir, "s1")}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("unable to create %s: %v", dir, err)
+		}
+	}
+
+	projectIndexer := NewProjectIndexer(projectsDir, tasksDir)
+	instanceTracker := NewInstanceTracker(projectIndexer, nil)
+	eventBus := NewEventBus(
+		store, projectIndexer, instanceTracker, btclog.Disabled,
+	)
+	t.Cleanup(func() {
+		eventBus.Stop()
+	})
+
+	h, err := NewHTTPServer(
+		&HTTPConfig{}, store, projectIndexer, instanceTracker, nil,
+		eventBus, nil, nil, nil, nil, btclog.Disabled,
+	)
+	if err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	h.registerRoutes(mux)
+
+	return h, mux
+}
+
+// TestAPITaskErrors checks that only a missing task is reported as not
+// found, and other task store failures as internal errors.
+func TestAPITaskErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		getErr   error
+		taskID   string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "found",
+			taskID:   "1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "missing",
+			taskID:   "9",
+			wantCode: http.StatusNotFound,
+			wantErr:  ErrCodeNotFound,
+		},
+		{
+			name:     "missing with store error",
+			getErr:   errors.New("read failed"),
+			taskID:   "9",
+			wantCode: http.StatusNotFound,
+			wantErr:  ErrCodeNotFound,
+		},
+		{
+			name:     "store error",
+			getErr:   errors.New("read failed"),
+			taskID:   "1",
+			wantCode: http.StatusInternalServerError,
+			wantErr:  ErrCodeInternal,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeTaskStore()
+			store.set("s1", testTask("1"))
+			store.getErr = test.getErr
+			_, mux := newTestHTTPServer(t, store)
+
+			rec := httptest.NewRecorder()
+			url := "/api/v1/lists/s1/tasks/" + test.taskID
+			mux.ServeHTTP(rec, httptest.NewRequest(
+				http.MethodGet, url, nil,
+			))
+			if rec.Code != test.wantCode {
+				t.Fatalf("got status %d, want %d: %s", rec.Code,
+					test.wantCode, rec.Body)
+			}
+			if test.wantErr == "" {
+				return
+			}
+
+			var apiErr APIError
+			err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			if err != nil {
+				t.Fatalf("unable to decode error: %v", err)
+			}
+			if apiErr.Error.Code != test.wantErr {
+				t.Fatalf("got code %q, want %q",
+					apiErr.Error.Code, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,596 @@
+package taskviewer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// archiveSweepInterval is how often the archive looks for newly active task
+// lists to subscribe to.
+const archiveSweepInterval = 5 * time.Second
+
+// archiveShrinkDelay is how long a list must go on existing after losing
+// tasks before the smaller board is recorded. Claude empties a finished
+// session's task directory well within it.
+const archiveShrinkDelay = 30 * time.Second
+
+// archiveMaxSnapshots is how many snapshots a list's archive file may hold
+// before it is compacted down to the latest one.
+const archiveMaxSnapshots = 64
+
+// ArchivedList is a point-in-time snapshot of a task list as persisted by the
+// archive.
+type ArchivedList struct {
+	ListID      string                     `json:"listId"`
+	ProjectName string                     `json:"projectName,omitempty"`
+	ProjectPath string                     `json:"projectPath,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	FirstPrompt string                     `json:"firstPrompt,omitempty"`
+	SnapshotAt  time.Time                  `json:"snapshotAt"`
+	Tasks       []claudeagent.TaskListItem `json:"tasks"`
+}
+
+// TaskArchive durably records every task list the daemon sees, so that task
+// boards survive Claude deleting ~/.claude/tasks/{sessionID}/ when a session
+// ends.
+//
+// Each list is stored as an append-only JSONL file in the archive directory,
+// one snapshot per line. A new line is only written when the list's contents
+// change, and only the latest snapshot is kept in memory. Once a file holds
+// maxSnapshots lines it is rewritten with just the latest one, so files stay
+// small and quick to load. The changes between consecutive snapshots are
+// journaled under history/.
+type TaskArchive struct {
+	dir            string
+	taskStore      claudeagent.TaskStore
+	projectIndexer *ProjectIndexer
+
+	// maxSnapshots is the number of snapshots at which a list's file is
+	// compacted. It is a field so that tests can shorten it.
+	maxSnapshots int
+
+	// mu guards latest, digests, counts and subs.
+	mu      sync.RWMutex
+	latest  map[string]*ArchivedList
+	digests map[string][sha256.Size]byte
+
+	// counts holds the number of snapshots in each list's file.
+	counts map[string]int
+
+	// subs holds the cancel functions of the live subscriptions, keyed
+	// by list ID.
+	subs map[string]context.CancelFunc
+
+	// writeMu serializes appends to the archive files.
+	writeMu sync.Mutex
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	log btclog.Logger
+}
+
+// NewTaskArchive opens (creating if needed) the archive in dir and loads the
+// latest snapshot of every archived list.
+func NewTaskArchive(dir string, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, log btclog.Logger) (*TaskArchive,
+	error) {
+
+	err := os.MkdirAll(filepath.Join(dir, historyDirName), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive dir: %w", err)
+	}
+
+	return openTaskArchive(dir, taskStore, projectIndexer, log)
+}
+
+// openTaskArchive opens the existing archive in dir and loads the latest
+// snapshot of every archived list, without creating anything. It is used
+// directly by readers that must not leave an empty archive behind.
+func openTaskArchive(dir string, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, log btclog.Logger) (*TaskArchive,
+	error) {
+
+	a := &TaskArchive{
+		dir:            dir,
+		taskStore:      taskStore,
+		projectIndexer: projectIndexer,
+		maxSnapshots:   archiveMaxSnapshots,
+		latest:         make(map[string]*ArchivedList),
+		digests:        make(map[string][sha256.Size]byte),
+		counts:         make(map[string]int),
+		subs:           make(map[string]context.CancelFunc),
+		quit:           make(chan struct{}),
+		log:            log,
+	}
+
+	if err := a.load(); err != nil {
+		return nil, fmt.Errorf("failed to load archive: %w", err)
+	}
+
+	return a, nil
+}
+
+// openExistingArchive opens the daemon's archive for reading, for commands
+// that run without the daemon. It returns nil, creating nothing, if the
+// archive is disabled or has never been written.
+func openExistingArchive(cfg *Config, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer) (*TaskArchive, error) {
+
+	if cfg.NoArchive {
+		return nil, nil
+	}
+
+	dir, err := cfg.ResolveArchiveDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve archive dir: %w", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, nil
+	}
+
+	return openTaskArchive(dir, taskStore, projectIndexer, btclog.Disabled)
+}
+
+// load reads the last snapshot of every archive file.
+func (a *TaskArchive) load() error {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		listID, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok || e.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(a.dir, e.Name())
+		snapshot, count, err := readLastSnapshot(path)
+		if err != nil {
+			a.log.Warnf("Skipping unreadable archive %s: %v",
+				e.Name(), err)
+			continue
+		}
+		if snapshot == nil {
+			continue
+		}
+
+		a.latest[listID] = snapshot
+		a.digests[listID] = digestTasks(snapshot.Tasks)
+		a.counts[listID] = count
+	}
+
+	return nil
+}
+
+// readLastSnapshot returns the final well-formed snapshot in an archive file,
+// along with the number of lines the file holds. A torn trailing line from an
+// interrupted write is ignored.
+func readLastSnapshot(path string) (*ArchivedList, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var (
+		last  *ArchivedList
+		count int
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		count++
+
+		var snapshot ArchivedList
+		if err := json.Unmarshal(line, &snapshot); err != nil {
+			continue
+		}
+		last = &snapshot
+	}
+
+	return last, count, scanner.Err()
+}
+
+// digestTasks returns a content hash used to skip unchanged snapshots.
+func digestTasks(tasks []claudeagent.TaskListItem) [sha256.Size]byte {
+	data, _ := json.Marshal(tasks)
+	return sha256.Sum256(data)
+}
+
+// validListID reports whether a list ID is safe to use as a file name.
+func validListID(listID string) bool {
+	return listID != "" && listID != "." && listID != ".." &&
+		filepath.Base(listID) == listID
+}
+
+// Start begins following every active task list. This method is idempotent.
+func (a *TaskArchive) Start() error {
+	if !atomic.CompareAndSwapUint32(&a.started, 0, 1) {
+		return nil
+	}
+
+	a.wg.Add(1)
+	go a.sweepLoop()
+
+	return nil
+}
+
+// Stop cancels all subscriptions. This method is idempotent.
+func (a *TaskArchive) Stop() error {
+	if !atomic.CompareAndSwapUint32(&a.stopped, 0, 1) {
+		return nil
+	}
+
+	close(a.quit)
+
+	a.mu.Lock()
+	for _, cancel := range a.subs {
+		cancel()
+	}
+	a.mu.Unlock()
+
+	a.wg.Wait()
+
+	return nil
+}
+
+// sweepLoop periodically subscribes to newly active lists and drops
+// subscriptions for lists that have gone away.
+func (a *TaskArchive) sweepLoop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(archiveSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		a.sweep()
+
+		select {
+		case <-ticker.C:
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// sweep reconciles the live subscriptions with the active task lists.
+func (a *TaskArchive) sweep() {
+	activeLists, err := a.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		return
+	}
+
+	active := make(map[string]ActiveTaskList, len(activeLists))
+	for _, al := range activeLists {
+		active[al.SessionID] = al
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Stop cancels subscriptions under mu, so checking quit here ensures
+	// no subscription is started after shutdown began.
+	select {
+	case <-a.quit:
+		return
+	default:
+	}
+
+	for listID, cancel := range a.subs {
+		if _, ok := active[listID]; !ok {
+			cancel()
+			delete(a.subs, listID)
+		}
+	}
+
+	for listID, al := range active {
+		if _, ok := a.subs[listID]; ok || !validListID(listID) {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		a.subs[listID] = cancel
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+
+			a.follow(ctx, al)
+
+			// If the subscription ended on its own, forget it so
+			// the next sweep can resubscribe.
+			if ctx.Err() == nil {
+				a.mu.Lock()
+				delete(a.subs, al.SessionID)
+				a.mu.Unlock()
+			}
+			cancel()
+		}()
+	}
+}
+
+// follow snapshots a list immediately and again after every change event
+// until the context is cancelled or the subscription ends. A snapshot that
+// only lost tasks is retaken once the list has settled for
+// archiveShrinkDelay.
+func (a *TaskArchive) follow(ctx context.Context, al ActiveTaskList) {
+	events, err := a.taskStore.Subscribe(ctx, al.SessionID)
+	if err != nil {
+		a.log.Debugf("Archive: unable to subscribe to %s: %v",
+			al.SessionID, err)
+	}
+
+	var settle <-chan time.Time
+	snapshot := func(allowShrink bool) {
+		deferred, err := a.snapshot(ctx, al, allowShrink)
+		if err != nil {
+			a.log.Warnf("Archive: snapshot of %s failed: %v",
+				al.SessionID, err)
+		}
+
+		settle = nil
+		if deferred {
+			settle = time.After(archiveShrinkDelay)
+		}
+	}
+
+	snapshot(false)
+
+	if events == nil {
+		return
+	}
+
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+
+			snapshot(false)
+
+		case <-settle:
+			snapshot(true)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Snapshot reads the current tasks of a list from the task store and appends
+// them to the archive if they changed since the last snapshot.
+//
+// Claude removes a session's task files one by one when it ends, so a
+// snapshot is never taken once the task directory has disappeared, empty
+// lists are never recorded, and a change that only drops tasks from the last
+// snapshot is left out. This keeps the final complete board intact; follow
+// records a list that really shrank once it has settled.
+func (a *TaskArchive) Snapshot(ctx context.Context, al ActiveTaskList) error {
+	_, err := a.snapshot(ctx, al, false)
+	return err
+}
+
+// snapshot implements Snapshot. Unless allowShrink is set, a change that only
+// drops tasks is not recorded, and deferred is returned true instead.
+func (a *TaskArchive) snapshot(ctx context.Context, al ActiveTaskList,
+	allowShrink bool) (bool, error) {
+
+	if !validListID(al.SessionID) {
+		return false, fmt.Errorf("invalid list ID %q", al.SessionID)
+	}
+
+	tasks, err := a.taskStore.List(ctx, al.SessionID)
+	if err != nil {
+		return false, err
+	}
+	if len(tasks) == 0 {
+		return false, nil
+	}
+	if al.TaskDir != "" {
+		if _, err := os.Stat(al.TaskDir); err != nil {
+			return false, nil
+		}
+	}
+
+	digest := digestTasks(tasks)
+
+	a.mu.RLock()
+	prev, ok := a.digests[al.SessionID]
+	last := a.latest[al.SessionID]
+	a.mu.RUnlock()
+	if ok && prev == digest {
+		return false, nil
+	}
+
+	var prevTasks []claudeagent.TaskListItem
+	if last != nil {
+		prevTasks = last.Tasks
+	}
+	if !allowShrink && onlyDropsTasks(prevTasks, tasks) {
+		return true, nil
+	}
+
+	snapshot := &ArchivedList{
+		ListID:      al.SessionID,
+		ProjectName: al.ProjectName,
+		ProjectPath: al.ProjectPath,
+		Summary:     al.Summary,
+		FirstPrompt: al.FirstPrompt,
+		SnapshotAt:  time.Now(),
+		Tasks:       tasks,
+	}
+	if err := a.appendSnapshot(snapshot); err != nil {
+		return false, err
+	}
+
+	// Record what changed since the previous snapshot. The snapshot is
+	// already durable, so a failure here only loses history.
+	changes := diffTasks(
+		al.SessionID, snapshot.SnapshotAt, prevTasks, tasks,
+	)
+	if len(changes) > 0 {
+		err := a.appendHistory(al.SessionID, changes)
+		if err != nil {
+			a.log.Warnf("Archive: unable to record history of "+
+				"%s: %v", al.SessionID, err)
+		}
+	}
+
+	a.mu.Lock()
+	a.latest[al.SessionID] = snapshot
+	a.digests[al.SessionID] = digest
+	a.mu.Unlock()
+
+	return false, nil
+}
+
+// onlyDropsTasks reports whether tasks is prev with some tasks removed and
+// none added.
+func onlyDropsTasks(prev, tasks []claudeagent.TaskListItem) bool {
+	if len(tasks) >= len(prev) {
+		return false
+	}
+
+	ids := make(map[string]struct{}, len(prev))
+	for _, task := range prev {
+		ids[task.ID] = struct{}{}
+	}
+	for _, task := range tasks {
+		if _, ok := ids[task.ID]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// appendSnapshot writes a snapshot as a single line to the list's file. A
+// file that has reached maxSnapshots is instead replaced by one holding only
+// the new snapshot.
+func (a *TaskArchive) appendSnapshot(snapshot *ArchivedList) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	a.mu.RLock()
+	count := a.counts[snapshot.ListID]
+	a.mu.RUnlock()
+
+	path := filepath.Join(a.dir, snapshot.ListID+".jsonl")
+	if count >= a.maxSnapshots {
+		err = compactSnapshots(path, data)
+		count = 0
+	} else {
+		err = appendLine(path, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.counts[snapshot.ListID] = count + 1
+	a.mu.Unlock()
+
+	return nil
+}
+
+// appendLine appends data to the file at path, creating it if needed.
+func appendLine(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// compactSnapshots atomically replaces the file at path with one holding only
+// data, so a crash leaves either the old file or the new one.
+func compactSnapshots(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write compacted archive: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace archive: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the latest archived snapshot of a list. It is safe to call on a
+// nil archive, which holds nothing.
+func (a *TaskArchive) Get(listID string) (*ArchivedList, bool) {
+	if a == nil {
+		return nil, false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	snapshot, ok := a.latest[listID]
+
+	return snapshot, ok
+}
+
+// Has reports whether the archive holds a snapshot of a list.
+func (a *TaskArchive) Has(listID string) bool {
+	_, ok := a.Get(listID)
+	return ok
+}
+
+// List returns the latest snapshot of every archived list, most recent
+// first. It is safe to call on a nil archive.
+func (a *TaskArchive) List() []ArchivedList {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.RLock()
+	lists := make([]ArchivedList, 0, len(a.latest))
+	for _, snapshot := range a.latest {
+		lists = append(lists, *snapshot)
+	}
+	a.mu.RUnlock()
+
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].SnapshotAt.After(lists[j].SnapshotAt)
+	})
+
+	return lists
+}
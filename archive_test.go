@@ -0,0 +1,494 @@
+package taskviewer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// fakeTaskStore is an in-memory task store. Methods it does not implement
+// panic through the nil embedded interface.
+type fakeTaskStore struct {
+	claudeagent.TaskStore
+
+	mu    sync.Mutex
+	lists map[string][]claudeagent.TaskListItem
+
+	// getErr, if set, is returned by every Get.
+	getErr error
+
+	// subs holds the channels of the open subscriptions, keyed by list
+	// ID.
+	subs map[string][]chan claudeagent.TaskEvent
+
+	// beforeWrite, if set, is called before each Create, Update and
+	// Delete, outside the lock, so tests can race a concurrent edit.
+	beforeWrite func()
+}
+
+// newFakeTaskStore returns an empty fake task store.
+func newFakeTaskStore() *fakeTaskStore {
+	return &fakeTaskStore{
+		lists: make(map[string][]claudeagent.TaskListItem),
+		subs:  make(map[string][]chan claudeagent.TaskEvent),
+	}
+}
+
+// set replaces the tasks of a list.
+func (s *fakeTaskStore) set(listID string, tasks ...claudeagent.TaskListItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lists[listID] = tasks
+}
+
+// List returns the tasks of a list.
+func (s *fakeTaskStore) List(_ context.Context,
+	listID string) ([]claudeagent.TaskListItem, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]claudeagent.TaskListItem(nil), s.lists[listID]...), nil
+}
+
+// Get returns a single task of a list.
+func (s *fakeTaskStore) Get(_ context.Context, listID,
+	taskID string) (*claudeagent.TaskListItem, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+
+	for _, task := range s.lists[listID] {
+		if task.ID == taskID {
+			return &task, nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// Create adds a task to a list with the next free numeric ID.
+func (s *fakeTaskStore) Create(_ context.Context, listID string,
+	task claudeagent.TaskListItem) (*claudeagent.TaskListItem, error) {
+
+	s.hookWrite()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := 1
+	for _, t := range s.lists[listID] {
+		if n, err := strconv.Atoi(t.ID); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	task.ID = strconv.Itoa(next)
+	s.lists[listID] = append(s.lists[listID], task)
+
+	return &task, nil
+}
+
+// Update applies an update to a task, keeping both ends of every dependency
+// edge in step as the real store does.
+func (s *fakeTaskStore) Update(_ context.Context, listID, taskID string,
+	update claudeagent.TaskUpdate) (*claudeagent.TaskListItem, error) {
+
+	s.hookWrite()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := s.lists[listID]
+	find := func(id string) *claudeagent.TaskListItem {
+		for i := range tasks {
+			if tasks[i].ID == id {
+				return &tasks[i]
+			}
+		}
+		return nil
+	}
+	add := func(ids []string, id string) []string {
+		if slices.Contains(ids, id) {
+			return ids
+		}
+		return append(slices.Clone(ids), id)
+	}
+	remove := func(ids []string, id string) []string {
+		isID := func(x string) bool {
+			return x == id
+		}
+		return slices.DeleteFunc(slices.Clone(ids), isID)
+	}
+
+	task := find(taskID)
+	if task == nil {
+		return nil, os.ErrNotExist
+	}
+	if update.Subject != nil {
+		task.Subject = *update.Subject
+	}
+	if update.Status != nil {
+		task.Status = *update.Status
+	}
+	for _, id := range update.AddBlockedBy {
+		task.BlockedBy = add(task.BlockedBy, id)
+		if other := find(id); other != nil {
+			other.Blocks = add(other.Blocks, taskID)
+		}
+	}
+	for _, id := range update.RemoveBlockedBy {
+		task.BlockedBy = remove(task.BlockedBy, id)
+		if other := find(id); other != nil {
+			other.Blocks = remove(other.Blocks, taskID)
+		}
+	}
+	for _, id := range update.RemoveBlocks {
+		task.Blocks = remove(task.Blocks, id)
+		if other := find(id); other != nil {
+			other.BlockedBy = remove(other.BlockedBy, taskID)
+		}
+	}
+
+	updated := *task
+
+	return &updated, nil
+}
+
+// Delete removes a task from a list.
+func (s *fakeTaskStore) Delete(_ context.Context, listID,
+	taskID string) error {
+
+	s.hookWrite()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := s.lists[listID]
+	i := slices.IndexFunc(tasks, func(t claudeagent.TaskListItem) bool {
+		return t.ID == taskID
+	})
+	if i < 0 {
+		return os.ErrNotExist
+	}
+	s.lists[listID] = slices.Delete(slices.Clone(tasks), i, i+1)
+
+	return nil
+}
+
+// hookWrite runs the beforeWrite hook once, if set.
+func (s *fakeTaskStore) hookWrite() {
+	s.mu.Lock()
+	hook := s.beforeWrite
+	s.beforeWrite = nil
+	s.mu.Unlock()
+
+	if hook != nil {
+		hook()
+	}
+}
+
+// Subscribe returns a channel receiving the events sent to a list, closed
+// once the context is cancelled.
+func (s *fakeTaskStore) Subscribe(ctx context.Context,
+	listID string) (<-chan claudeagent.TaskEvent, error) {
+
+	ch := make(chan claudeagent.TaskEvent, 16)
+
+	s.mu.Lock()
+	s.subs[listID] = append(s.subs[listID], ch)
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		isCh := func(c chan claudeagent.TaskEvent) bool {
+			return c == ch
+		}
+		s.subs[listID] = slices.DeleteFunc(s.subs[listID], isCh)
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// send delivers a task event to every subscriber of a list.
+func (s *fakeTaskStore) send(listID string, event claudeagent.TaskEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.subs[listID] {
+		ch <- event
+	}
+}
+
+// subscribers returns the number of open subscriptions to a list.
+func (s *fakeTaskStore) subscribers(listID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subs[listID])
+}
+
+// testTask returns a pending task with the given ID.
+func testTask(id string) claudeagent.TaskListItem {
+	return claudeagent.TaskListItem{
+		ID:      id,
+		Subject: "Task " + id,
+		Status:  claudeagent.TaskListStatusPending,
+	}
+}
+
+// newTestArchive returns an archive over a fake store, and an active list
+// whose task directory exists.
+func newTestArchive(t *testing.T) (*TaskArchive, *fakeTaskStore,
+	ActiveTaskList) {
+
+	t.Helper()
+
+	root := t.TempDir()
+	store := newFakeTaskStore()
+
+	archive, err := NewTaskArchive(
+		filepath.Join(root, "archive"), store, nil, btclog.Disabled,
+	)
+	if err != nil {
+		t.Fatalf("unable to create archive: %v", err)
+	}
+
+	al := ActiveTaskList{
+		SessionID: "s1",
+		TaskDir:   filepath.Join(root, "tasks", "s1"),
+	}
+	if err := os.MkdirAll(al.TaskDir, 0o700); err != nil {
+		t.Fatalf("unable to create task dir: %v", err)
+	}
+
+	return archive, store, al
+}
+
+// changeKinds counts the history entries of a list by kind.
+func changeKinds(t *testing.T, archive *TaskArchive,
+	listID string) map[string]int {
+
+	t.Helper()
+
+	changes, err := archive.History(listID)
+	if err != nil {
+		t.Fatalf("unable to read history: %v", err)
+	}
+
+	kinds := make(map[string]int)
+	for _, change := range changes {
+		kinds[change.Kind]++
+	}
+
+	return kinds
+}
+
+// TestSnapshotKeepsBoardWhileListEnds checks that deleting a list's task
+// files one by one, as Claude does when a session ends, leaves the final
+// complete board archived and records no deletions.
+func TestSnapshotKeepsBoardWhileListEnds(t *testing.T) {
+	ctx := context.Background()
+	archive, store, al := newTestArchive(t)
+
+	tasks := []claudeagent.TaskListItem{
+		testTask("1"), testTask("2"), testTask("3"),
+	}
+	store.set(al.SessionID, tasks...)
+	if err := archive.Snapshot(ctx, al); err != nil {
+		t.Fatalf("unable to snapshot: %v", err)
+	}
+
+	for n := len(tasks) - 1; n >= 0; n-- {
+		store.set(al.SessionID, tasks[:n]...)
+
+		// The directory goes once the last file is removed.
+		if n == 0 {
+			if err := os.Remove(al.TaskDir); err != nil {
+				t.Fatalf("unable to remove task dir: %v", err)
+			}
+		}
+
+		deferred, err := archive.snapshot(ctx, al, false)
+		if err != nil {
+			t.Fatalf("unable to snapshot: %v", err)
+		}
+		if n > 0 && !deferred {
+			t.Fatalf("%d tasks left: shrink not deferred", n)
+		}
+
+		snapshot, ok := archive.Get(al.SessionID)
+		if !ok {
+			t.Fatalf("%d tasks left: list not archived", n)
+		}
+		if len(snapshot.Tasks) != len(tasks) {
+			t.Fatalf("%d tasks left: archived %d tasks, want %d",
+				n, len(snapshot.Tasks), len(tasks))
+		}
+	}
+
+	// Settling once the directory is gone records nothing either.
+	if _, err := archive.snapshot(ctx, al, true); err != nil {
+		t.Fatalf("unable to snapshot: %v", err)
+	}
+	snapshot, _ := archive.Get(al.SessionID)
+	if len(snapshot.Tasks) != len(tasks) {
+		t.Fatalf("archived %d tasks after settling, want %d",
+			len(snapshot.Tasks), len(tasks))
+	}
+
+	kinds := changeKinds(t, archive, al.SessionID)
+	if kinds[ChangeCreated] != len(tasks) || kinds[ChangeDeleted] != 0 {
+		t.Fatalf("unexpected history: %v", kinds)
+	}
+}
+
+// TestSnapshotRecordsSettledShrink checks that a list which really lost a
+// task is recorded once it settles, and that a change adding tasks is
+// recorded straight away.
+func TestSnapshotRecordsSettledShrink(t *testing.T) {
+	ctx := context.Background()
+	archive, store, al := newTestArchive(t)
+
+	store.set(al.SessionID, testTask("1"), testTask("2"), testTask("3"))
+	if err := archive.Snapshot(ctx, al); err != nil {
+		t.Fatalf("unable to snapshot: %v", err)
+	}
+
+	store.set(al.SessionID, testTask("1"), testTask("2"))
+	deferred, err := archive.snapshot(ctx, al, false)
+	if err != nil {
+		t.Fatalf("unable to snapshot: %v", err)
+	}
+	if !deferred {
+		t.Fatalf("shrink not deferred")
+	}
+
+	deferred, err = archive.snapshot(ctx, al, true)
+	if err != nil {
+		t.Fatalf("unable to snapshot: %v", err)
+	}
+	if deferred {
+		t.Fatalf("settled shrink deferred")
+	}
+	if snapshot, _ := archive.Get(al.SessionID); len(snapshot.Tasks) != 2 {
+		t.Fatalf("archived %d tasks, want 2", len(snapshot.Tasks))
+	}
+
+	// Dropping one task while adding another is not a shrink.
+	store.set(al.SessionID, testTask("1"), testTask("4"))
+	deferred, err = archive.snapshot(ctx, al, false)
+	if err != nil {
+		t.Fatalf("unable to snapshot: %v", err)
+	}
+	if deferred {
+		t.Fatalf("replacement deferred")
+	}
+
+	kinds := changeKinds(t, archive, al.SessionID)
+	want := map[string]int{ChangeCreated: 4, ChangeDeleted: 2}
+	for kind, n := range want {
+		if kinds[kind] != n {
+			t.Fatalf("got %d %s changes, want %d: %v", kinds[kind],
+				kind, n, kinds)
+		}
+	}
+}
+
+// TestSnapshotCompaction checks that a list's archive file is rewritten with
+// only the latest snapshot once it holds maxSnapshots of them, and that the
+// latest snapshot survives reopening the archive.
+func TestSnapshotCompaction(t *testing.T) {
+	ctx := context.Background()
+	archive, store, al := newTestArchive(t)
+	archive.maxSnapshots = 3
+
+	path := filepath.Join(archive.dir, al.SessionID+".jsonl")
+	wantLines := []int{1, 2, 3, 1, 2}
+	for i, want := range wantLines {
+		tasks := make([]claudeagent.TaskListItem, i+1)
+		for j := range tasks {
+			tasks[j] = testTask(strconv.Itoa(j + 1))
+		}
+		store.set(al.SessionID, tasks...)
+		if err := archive.Snapshot(ctx, al); err != nil {
+			t.Fatalf("unable to snapshot: %v", err)
+		}
+
+		snapshot, lines, err := readLastSnapshot(path)
+		if err != nil {
+			t.Fatalf("unable to read archive: %v", err)
+		}
+		if lines != want {
+			t.Fatalf("snapshot %d: file has %d lines, want %d",
+				i+1, lines, want)
+		}
+		if len(snapshot.Tasks) != i+1 {
+			t.Fatalf("snapshot %d: last has %d tasks, want %d",
+				i+1, len(snapshot.Tasks), i+1)
+		}
+	}
+
+	reopened, err := openTaskArchive(
+		archive.dir, store, nil, btclog.Disabled,
+	)
+	if err != nil {
+		t.Fatalf("unable to reopen archive: %v", err)
+	}
+	snapshot, ok := reopened.Get(al.SessionID)
+	if !ok || len(snapshot.Tasks) != len(wantLines) {
+		t.Fatalf("reopened archive lost the latest snapshot")
+	}
+	if reopened.counts[al.SessionID] != 2 {
+		t.Fatalf("reopened count %d, want 2",
+			reopened.counts[al.SessionID])
+	}
+}
+
+// TestOpenTaskArchiveCreatesNothing checks that opening a missing archive for
+// reading fails without creating its directory, and that an archive opened
+// for reading without a history directory reads as having no history.
+func TestOpenTaskArchiveCreatesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+
+	_, err := openTaskArchive(dir, newFakeTaskStore(), nil, btclog.Disabled)
+	if err == nil {
+		t.Fatalf("expected an error opening a missing archive")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("archive dir was created: %v", err)
+	}
+
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("unable to create archive dir: %v", err)
+	}
+	archive, err := openTaskArchive(
+		dir, newFakeTaskStore(), nil, btclog.Disabled,
+	)
+	if err != nil {
+		t.Fatalf("unable to open archive: %v", err)
+	}
+	history, err := archive.History("s1")
+	if err != nil || len(history) != 0 {
+		t.Fatalf("got history %v, err %v", history, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, historyDirName)); err == nil {
+		t.Fatalf("history dir was created")
+	}
+}
@@ -0,0 +1,416 @@
+package taskviewer
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// authCookie is the name of the cookie holding a signed in browser's
+	// session.
+	authCookie = "taskviewer_session"
+
+	// authSessionLifetime is how long a session lasts after signing in.
+	authSessionLifetime = 7 * 24 * time.Hour
+
+	// authFailureDelay is how long a request with a wrong password or
+	// token waits before it is answered, to slow down guessing.
+	authFailureDelay = time.Second
+)
+
+// accessLevel is what the credentials of a request allow.
+type accessLevel uint8
+
+const (
+	// accessNone is a request without valid credentials.
+	accessNone accessLevel = iota
+
+	// accessRead is a request with a read-only API token.
+	accessRead
+
+	// accessFull is a request from a signed in browser, or with the
+	// password as its bearer token.
+	accessFull
+)
+
+// Authenticator checks the credentials of requests to the viewer. Signing
+// in with the password starts a session, held in a cookie, with full access;
+// the password also works as a bearer token. Read-only API tokens allow GET
+// and HEAD requests and the read-only MCP endpoint only. A nil Authenticator
+// lets every request through.
+type Authenticator struct {
+	password   []byte
+	readTokens [][]byte
+
+	// failureDelay is how long wrong credentials wait. It is a field so
+	// that tests can shorten it.
+	failureDelay time.Duration
+
+	// sessions maps the ID of each signed in session to when it
+	// expires.
+	mu       sync.Mutex
+	sessions map[string]time.Time
+}
+
+// NewAuthenticator creates an authenticator for a password and a set of
+// read-only API tokens. It returns nil if neither is set, which disables
+// authentication.
+func NewAuthenticator(password string, readTokens []string) *Authenticator {
+	if password == "" && len(readTokens) == 0 {
+		return nil
+	}
+
+	a := &Authenticator{
+		failureDelay: authFailureDelay,
+		sessions:     make(map[string]time.Time),
+	}
+	if password != "" {
+		a.password = []byte(password)
+	}
+	for _, token := range readTokens {
+		if token != "" {
+			a.readTokens = append(a.readTokens, []byte(token))
+		}
+	}
+
+	return a
+}
+
+// HasPassword reports whether browsers can sign in.
+func (a *Authenticator) HasPassword() bool {
+	return a != nil && len(a.password) > 0
+}
+
+// checkPassword reports whether a password is the configured one.
+func (a *Authenticator) checkPassword(password string) bool {
+	return a.HasPassword() &&
+		subtle.ConstantTimeCompare([]byte(password), a.password) == 1
+}
+
+// checkToken returns the access a bearer token grants.
+func (a *Authenticator) checkToken(token string) accessLevel {
+	if a.checkPassword(token) {
+		return accessFull
+	}
+
+	// Compare against every token so that the time taken does not tell
+	// which one matched.
+	level := accessNone
+	for _, readToken := range a.readTokens {
+		if subtle.ConstantTimeCompare([]byte(token), readToken) == 1 {
+			level = accessRead
+		}
+	}
+
+	return level
+}
+
+// newSession starts a session and returns its ID.
+func (a *Authenticator) newSession() (string, error) {
+	var id [32]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for sessionID, expiry := range a.sessions {
+		if now.After(expiry) {
+			delete(a.sessions, sessionID)
+		}
+	}
+
+	sessionID := hex.EncodeToString(id[:])
+	a.sessions[sessionID] = now.Add(authSessionLifetime)
+
+	return sessionID, nil
+}
+
+// validSession reports whether a session ID belongs to a live session.
+func (a *Authenticator) validSession(sessionID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expiry, ok := a.sessions[sessionID]
+	return ok && time.Now().Before(expiry)
+}
+
+// endSession ends a session.
+func (a *Authenticator) endSession(sessionID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.sessions, sessionID)
+}
+
+// access returns the access a request's credentials grant. It reports
+// whether the request carried credentials at all, so that wrong ones can
+// be slowed down.
+func (a *Authenticator) access(r *http.Request) (accessLevel, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return accessNone, true
+		}
+
+		return a.checkToken(strings.TrimSpace(token)), true
+	}
+
+	cookie, err := r.Cookie(authCookie)
+	if err != nil {
+		return accessNone, false
+	}
+	if a.validSession(cookie.Value) {
+		return accessFull, true
+	}
+
+	// An expired session is not a guess, so it is not slowed down.
+	return accessNone, false
+}
+
+// SignedIn reports whether a request comes from a signed in browser.
+func (a *Authenticator) SignedIn(r *http.Request) bool {
+	if a == nil {
+		return false
+	}
+
+	cookie, err := r.Cookie(authCookie)
+	return err == nil && a.validSession(cookie.Value)
+}
+
+// isLoopbackAddr reports whether a listen address only accepts connections
+// from the local machine.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	return isLoopbackHost(host)
+}
+
+// isLoopbackHost reports whether a host name or IP address refers to the
+// local machine.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// authExempt reports whether a path is served without credentials: the
+// sign in page and the static files it needs.
+func authExempt(path string) bool {
+	return path == "/login" || strings.HasPrefix(path, "/static/")
+}
+
+// wantsJSON reports whether a request is from an API client rather than a
+// browser navigating the dashboard, and so should get a JSON error rather
+// than the sign in page.
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") ||
+		r.URL.Path == "/metrics" || r.URL.Path == "/mcp" ||
+		r.Header.Get("Authorization") != ""
+}
+
+// authenticate wraps a handler with the viewer's access checks.
+//
+// Without authentication, a viewer listening on loopback only answers
+// requests addressed to a loopback host name, so that a web page cannot
+// reach it by rebinding its own domain to 127.0.0.1. With authentication,
+// every request but the sign in page needs a session or a bearer token, and
+// read-only tokens cannot change anything.
+func (h *HTTPServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.auth == nil {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if h.loopbackOnly && !isLoopbackHost(host) {
+				http.Error(w, "Invalid Host header",
+					http.StatusMisdirectedRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if authExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		level, hadCredentials := h.auth.access(r)
+		switch level {
+		case accessFull:
+			next.ServeHTTP(w, r)
+			return
+
+		// Every MCP tool is read-only, even though it is POSTed.
+		case accessRead:
+			if r.Method == http.MethodGet ||
+				r.Method == http.MethodHead ||
+				r.URL.Path == "/mcp" {
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writeAPIError(
+				w, http.StatusForbidden, ErrCodeForbidden,
+				"read-only tokens cannot change tasks",
+			)
+			return
+		}
+
+		if hadCredentials {
+			time.Sleep(h.auth.failureDelay)
+		}
+
+		switch {
+		case wantsJSON(r):
+			w.Header().Set(
+				"WWW-Authenticate", `Bearer realm="taskviewer"`,
+			)
+			writeAPIError(
+				w, http.StatusUnauthorized, ErrCodeUnauthorized,
+				"a bearer token or signed in session is "+
+					"required",
+			)
+
+		// htmx swaps rather than follows redirects, so send the
+		// whole page to the sign in form instead.
+		case r.Header.Get("HX-Request") == "true":
+			w.Header().Set("HX-Redirect", "/login")
+			w.WriteHeader(http.StatusUnauthorized)
+
+		default:
+			http.Redirect(
+				w, r, "/login?next="+url.QueryEscape(
+					r.URL.RequestURI(),
+				), http.StatusSeeOther,
+			)
+		}
+	})
+}
+
+// LoginData holds data for the sign in page.
+type LoginData struct {
+	PageData
+
+	// Next is where to go once signed in.
+	Next string
+
+	// Enabled is false if the viewer only accepts API tokens.
+	Enabled bool
+}
+
+// safeNext returns a redirect target that stays on the viewer, falling
+// back to the dashboard.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") ||
+		strings.HasPrefix(next, "/\\") {
+
+		return "/"
+	}
+
+	return next
+}
+
+// handleLoginPage renders the sign in form.
+func (h *HTTPServer) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if h.auth == nil || h.auth.SignedIn(r) {
+		http.Redirect(
+			w, r, safeNext(r.URL.Query().Get("next")),
+			http.StatusSeeOther,
+		)
+		return
+	}
+
+	h.render(w, "login.html", LoginData{
+		PageData: PageData{Title: "Sign in"},
+		Next:     safeNext(r.URL.Query().Get("next")),
+		Enabled:  h.auth.HasPassword(),
+	})
+}
+
+// handleLogin checks the submitted password and starts a session.
+func (h *HTTPServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+	if h.auth == nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	data := LoginData{
+		PageData: PageData{Title: "Sign in"},
+		Next:     next,
+		Enabled:  h.auth.HasPassword(),
+	}
+
+	if !sameOrigin(r) {
+		w.WriteHeader(http.StatusForbidden)
+		data.Error = "Sign in from another site was refused."
+		h.render(w, "login.html", data)
+		return
+	}
+
+	if !h.auth.checkPassword(r.FormValue("password")) {
+		time.Sleep(h.auth.failureDelay)
+		h.log.Warnf("Failed sign in from %s", r.RemoteAddr)
+
+		w.WriteHeader(http.StatusUnauthorized)
+		data.Error = "Wrong password."
+		h.render(w, "login.html", data)
+		return
+	}
+
+	sessionID, err := h.auth.newSession()
+	if err != nil {
+		h.renderError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     authCookie,
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(authSessionLifetime / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// handleLogout ends the browser's session.
+func (h *HTTPServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(authCookie); err == nil && h.auth != nil {
+		h.auth.endSession(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     authCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
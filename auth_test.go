@@ -0,0 +1,304 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	// testPassword is the password of the test authenticator.
+	testPassword = "hunter2"
+
+	// testReadToken is a read-only token of the test authenticator.
+	testReadToken = "read-only"
+)
+
+// newTestAuthenticator returns an authenticator with the test password and
+// read-only token that does not slow down wrong credentials.
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+
+	a := NewAuthenticator(testPassword, []string{"", testReadToken})
+	if a == nil {
+		t.Fatalf("authenticator disabled")
+	}
+	a.failureDelay = 0
+
+	return a
+}
+
+// TestAuthenticatorAccess checks the access granted by bearer tokens and
+// session cookies, and which requests count as carrying credentials.
+func TestAuthenticatorAccess(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	session, err := a.newSession()
+	if err != nil {
+		t.Fatalf("unable to start session: %v", err)
+	}
+	expired, err := a.newSession()
+	if err != nil {
+		t.Fatalf("unable to start session: %v", err)
+	}
+	a.sessions[expired] = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		header    string
+		cookie    string
+		want      accessLevel
+		wantCreds bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:      "password",
+			header:    "Bearer " + testPassword,
+			want:      accessFull,
+			wantCreds: true,
+		},
+		{
+			name:      "read token",
+			header:    "Bearer  " + testReadToken + " ",
+			want:      accessRead,
+			wantCreds: true,
+		},
+		{
+			name:      "wrong token",
+			header:    "Bearer guess",
+			wantCreds: true,
+		},
+		{
+			name:      "empty token",
+			header:    "Bearer ",
+			wantCreds: true,
+		},
+		{
+			name:      "other scheme",
+			header:    "Basic " + testPassword,
+			wantCreds: true,
+		},
+		{
+			name:      "session",
+			cookie:    session,
+			want:      accessFull,
+			wantCreds: true,
+		},
+		{
+			name:   "unknown session",
+			cookie: "deadbeef",
+		},
+		{
+			name:   "expired session",
+			cookie: expired,
+		},
+		{
+			// A wrong token is not rescued by a session.
+			name:      "header first",
+			header:    "Bearer guess",
+			cookie:    session,
+			wantCreds: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/tasks", nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{
+					Name: authCookie, Value: test.cookie,
+				})
+			}
+
+			level, creds := a.access(r)
+			if level != test.want || creds != test.wantCreds {
+				t.Fatalf("got access %d with credentials %v, "+
+					"want %d with %v", level, creds,
+					test.want, test.wantCreds)
+			}
+		})
+	}
+
+	if a.validSession(expired) {
+		t.Fatalf("expired session still valid")
+	}
+	a.endSession(session)
+	if a.validSession(session) {
+		t.Fatalf("ended session still valid")
+	}
+}
+
+// TestAuthenticate checks that read-only tokens are limited to reading and
+// the MCP endpoint, and how requests without access are turned away.
+func TestAuthenticate(t *testing.T) {
+	h, _ := newTestHTTPServer(t, newFakeTaskStore())
+	h.auth = newTestAuthenticator(t)
+
+	handler := h.authenticate(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		htmx     bool
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "read GET",
+			method:   http.MethodGet,
+			path:     "/api/tasks/s1/1",
+			token:    testReadToken,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "read HEAD",
+			method:   http.MethodHead,
+			path:     "/metrics",
+			token:    testReadToken,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "read MCP",
+			method:   http.MethodPost,
+			path:     "/mcp",
+			token:    testReadToken,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "read POST",
+			method:   http.MethodPost,
+			path:     "/api/tasks/s1",
+			token:    testReadToken,
+			wantCode: http.StatusForbidden,
+			wantErr:  ErrCodeForbidden,
+		},
+		{
+			name:     "read PATCH",
+			method:   http.MethodPatch,
+			path:     "/api/tasks/s1/1",
+			token:    testReadToken,
+			wantCode: http.StatusForbidden,
+			wantErr:  ErrCodeForbidden,
+		},
+		{
+			name:     "read DELETE",
+			method:   http.MethodDelete,
+			path:     "/api/tasks/s1/1",
+			token:    testReadToken,
+			wantCode: http.StatusForbidden,
+			wantErr:  ErrCodeForbidden,
+		},
+		{
+			name:     "full DELETE",
+			method:   http.MethodDelete,
+			path:     "/api/tasks/s1/1",
+			token:    testPassword,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "wrong token",
+			method:   http.MethodGet,
+			path:     "/",
+			token:    "guess",
+			wantCode: http.StatusUnauthorized,
+			wantErr:  ErrCodeUnauthorized,
+		},
+		{
+			name:     "anonymous API",
+			method:   http.MethodGet,
+			path:     "/api/tasks",
+			wantCode: http.StatusUnauthorized,
+			wantErr:  ErrCodeUnauthorized,
+		},
+		{
+			name:     "anonymous htmx",
+			method:   http.MethodGet,
+			path:     "/partials/board",
+			htmx:     true,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "anonymous page",
+			method:   http.MethodGet,
+			path:     "/tasks/s1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "sign in page",
+			method:   http.MethodGet,
+			path:     "/login",
+			wantCode: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if test.token != "" {
+				r.Header.Set(
+					"Authorization", "Bearer "+test.token,
+				)
+			}
+			if test.htmx {
+				r.Header.Set("HX-Request", "true")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Fatalf("got status %d, want %d", w.Code,
+					test.wantCode)
+			}
+
+			switch w.Code {
+			case http.StatusSeeOther:
+				want := "/login?next=%2Ftasks%2Fs1"
+				got := w.Header().Get("Location")
+				if got != want {
+					t.Fatalf("redirected to %q, want %q",
+						got, want)
+				}
+				return
+
+			case http.StatusUnauthorized:
+				if test.htmx {
+					got := w.Header().Get("HX-Redirect")
+					if got != "/login" {
+						t.Fatalf("htmx redirected "+
+							"to %q", got)
+					}
+					return
+				}
+				if w.Header().Get("WWW-Authenticate") == "" {
+					t.Fatalf("no WWW-Authenticate header")
+				}
+			}
+			if test.wantErr == "" {
+				return
+			}
+
+			var apiErr APIError
+			err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+			if err != nil {
+				t.Fatalf("unable to decode error: %v", err)
+			}
+			if apiErr.Error.Code != test.wantErr {
+				t.Fatalf("got error %q, want %q",
+					apiErr.Error.Code, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,926 @@
+package taskviewer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// cliRequestTimeout bounds a single request of a client command.
+	// Streams opened by watch are not bounded.
+	cliRequestTimeout = 30 * time.Second
+
+	// cliLocalURL is the base URL of the in-process API used when no
+	// server is given. Its host is never resolved.
+	cliLocalURL = "http://taskviewer.local"
+
+	// cliTimeFormat is how times are printed in tables.
+	cliTimeFormat = "2006-01-02 15:04"
+)
+
+// CLIOptions are the options shared by every client command.
+type CLIOptions struct {
+	// Server is the base URL of a running daemon to query. If empty,
+	// the claude directory is read directly.
+	Server string `long:"server" description:"Query the daemon at this URL instead of reading the claude directory"`
+
+	// Token is the bearer token sent to the server.
+	Token string `long:"token" env:"TASKVIEWER_TOKEN" description:"Bearer token for --server: an --api-token or the --auth-password"`
+
+	// ServerCA is a PEM certificate trusted for the server, such as its
+	// self-signed tls.cert.
+	ServerCA string `long:"server-ca" description:"PEM certificate to trust for --server, such as its tls.cert"`
+
+	// JSON prints the API's JSON instead of a table.
+	JSON bool `long:"json" description:"Print JSON instead of a table"`
+}
+
+// CLICommand is a client command of taskviewerd, which prints what the
+// daemon's API returns.
+type CLICommand interface {
+	// options returns the command's shared options.
+	options() *CLIOptions
+
+	// run executes the command with the client, printing to out.
+	run(ctx context.Context, client *cliClient, out io.Writer) error
+}
+
+// CLICommandSpec describes a client command to the flag parser.
+type CLICommandSpec struct {
+	Name  string
+	Short string
+	Long  string
+
+	// Command receives the command's options and arguments.
+	Command CLICommand
+}
+
+// CLICommands returns every client command, with fresh options.
+func CLICommands() []CLICommandSpec {
+	return []CLICommandSpec{
+		{
+			Name:  "projects",
+			Short: "List projects",
+			Long: "List every project with sessions, most recent " +
+				"first.",
+			Command: &ProjectsCommand{},
+		},
+		{
+			Name:  "sessions",
+			Short: "List the sessions of a project",
+			Long: "List the sessions of a project, given by its " +
+				"directory name, path or name.",
+			Command: &SessionsCommand{},
+		},
+		{
+			Name:  "tasks",
+			Short: "List the tasks of a session",
+			Long: "List the tasks of a session, optionally by " +
+				"status.",
+			Command: &TasksCommand{},
+		},
+		{
+			Name:  "task",
+			Short: "Show a task",
+			Long: "Show a task with its description, the tasks " +
+				"it is blocked by and the tasks it blocks.",
+			Command: &TaskCommand{},
+		},
+		{
+			Name:  "instances",
+			Short: "List running Claude instances",
+			Long: "List the Claude processes running on this " +
+				"machine.",
+			Command: &InstancesCommand{},
+		},
+		{
+			Name:  "watch",
+			Short: "Print the changes to a session's tasks",
+			Long: "Print the tasks of a session, then every " +
+				"change to them until interrupted. With " +
+				"--json, print the task list and then one " +
+				"event per line.",
+			Command: &WatchCommand{},
+		},
+	}
+}
+
+// ProjectsCommand lists projects.
+type ProjectsCommand struct {
+	CLIOptions
+}
+
+// SessionsCommand lists the sessions of a project.
+type SessionsCommand struct {
+	CLIOptions
+
+	Args struct {
+		Project string `positional-arg-name:"project" description:"Project directory name, path or name"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// TasksCommand lists the tasks of a session.
+type TasksCommand struct {
+	CLIOptions
+
+	// Status restricts the tasks to a status, or to the active ones.
+	Status string `long:"status" choice:"all" choice:"active" choice:"pending" choice:"in_progress" choice:"completed" description:"Only list tasks with this status"`
+
+	Args struct {
+		Session string `positional-arg-name:"session" description:"Session ID"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// TaskCommand shows a task.
+type TaskCommand struct {
+	CLIOptions
+
+	Args struct {
+		Session string `positional-arg-name:"session" description:"Session ID"`
+		TaskID  string `positional-arg-name:"id" description:"Task ID"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// InstancesCommand lists the running instances.
+type InstancesCommand struct {
+	CLIOptions
+}
+
+// WatchCommand prints the changes to a session's tasks.
+type WatchCommand struct {
+	CLIOptions
+
+	Args struct {
+		Session string `positional-arg-name:"session" description:"Session ID"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// RunCLI runs a client command, printing to out. Without --server it reads
+// the claude directory given by cfg, through the same API handlers the
+// daemon serves, so both print the same thing.
+func RunCLI(ctx context.Context, cfg *Config, cmd CLICommand,
+	out io.Writer) error {
+
+	opts := cmd.options()
+
+	var (
+		client *cliClient
+		err    error
+	)
+	if opts.Server != "" {
+		client, err = newRemoteCLIClient(opts)
+	} else {
+		var cleanup func()
+		client, cleanup, err = newLocalCLIClient(cfg)
+		if cleanup != nil {
+			defer cleanup()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(ctx, client, out)
+}
+
+// cliClient makes requests to the versioned API of a daemon, or of the
+// in-process handlers standing in for one.
+type cliClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// newRemoteCLIClient creates a client for the daemon given by --server.
+func newRemoteCLIClient(opts *CLIOptions) (*cliClient, error) {
+	baseURL, err := parseBaseURL(opts.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ServerCA != "" {
+		path, err := cleanPath(opts.ServerCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate: %w",
+				err)
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s",
+				path)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    roots,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &cliClient{
+		baseURL: baseURL,
+		token:   opts.Token,
+		client:  &http.Client{Transport: transport},
+	}, nil
+}
+
+// newLocalCLIClient creates a client that serves the API in-process from
+// the claude directory. The returned function stops the components it
+// started, and is set even if an error is returned.
+func newLocalCLIClient(cfg *Config) (*cliClient, func(), error) {
+	local, stop, err := startLocal(
+		cfg, localOptions{archive: true}, btclog.Disabled,
+	)
+	if err != nil {
+		return nil, stop, err
+	}
+
+	httpServer, err := NewHTTPServer(
+		&HTTPConfig{}, local.taskStore, local.projectIndexer,
+		local.instanceTracker, local.taskArchive, local.eventBus, nil,
+		nil, nil, nil, btclog.Disabled,
+	)
+	if err != nil {
+		return nil, stop, fmt.Errorf("failed to create API: %w", err)
+	}
+	mux := http.NewServeMux()
+	httpServer.registerRoutes(mux)
+
+	return &cliClient{
+		baseURL: cliLocalURL,
+		client: &http.Client{
+			Transport: handlerTransport{handler: mux},
+		},
+	}, stop, nil
+}
+
+// open sends a GET request for an API path and returns the response if it
+// succeeded.
+func (c *cliClient) open(ctx context.Context, path string) (*http.Response,
+	error) {
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, c.baseURL+path, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		msg := apiErrorMessage(resp.Body)
+		if msg == "" {
+			msg = resp.Status
+		}
+
+		return nil, fmt.Errorf("%s", msg)
+	}
+
+	return resp, nil
+}
+
+// get decodes the JSON returned for an API path into v.
+func (c *cliClient) get(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, cliRequestTimeout)
+	defer cancel()
+
+	resp, err := c.open(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// handlerTransport is an http.RoundTripper that serves requests with an
+// in-process handler instead of the network. Response bodies are streamed
+// as the handler writes them, so event streams work too.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip serves a request and returns its response once the handler has
+// written the status.
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response,
+	error) {
+
+	body, pipe := io.Pipe()
+	w := &pipeResponseWriter{
+		header: make(http.Header),
+		body:   pipe,
+		ready:  make(chan struct{}),
+	}
+
+	go func() {
+		defer pipe.Close()
+
+		t.handler.ServeHTTP(w, req)
+		w.WriteHeader(http.StatusOK)
+	}()
+
+	select {
+	case <-w.ready:
+
+	case <-req.Context().Done():
+		body.Close()
+		return nil, req.Context().Err()
+	}
+
+	return &http.Response{
+		Status: fmt.Sprintf("%d %s", w.code,
+			http.StatusText(w.code)),
+		StatusCode: w.code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.sent,
+		Body:       body,
+		Request:    req,
+	}, nil
+}
+
+// pipeResponseWriter is the http.ResponseWriter of handlerTransport. It
+// writes the body to a pipe read by the client.
+type pipeResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+
+	// once guards code and sent, the status and headers given to the
+	// client, and closes ready when they are set.
+	once  sync.Once
+	code  int
+	sent  http.Header
+	ready chan struct{}
+}
+
+// Header returns the headers to send.
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader sends the status and headers. Only the first call counts.
+func (w *pipeResponseWriter) WriteHeader(code int) {
+	w.once.Do(func() {
+		w.code = code
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+// Write sends part of the body, blocking until the client reads it.
+func (w *pipeResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+
+	return w.body.Write(b)
+}
+
+// Flush does nothing, since every write reaches the client directly.
+func (w *pipeResponseWriter) Flush() {}
+
+// options returns the command's shared options.
+func (c *ProjectsCommand) options() *CLIOptions {
+	return &c.CLIOptions
+}
+
+// run lists projects.
+func (c *ProjectsCommand) run(ctx context.Context, client *cliClient,
+	out io.Writer) error {
+
+	var projects []ProjectSummary
+	if err := client.get(ctx, "/api/v1/projects", &projects); err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(out, projects)
+	}
+
+	tw := newTable(out, "PROJECT", "SESSIONS", "BRANCH", "LAST ACTIVE",
+		"DIR")
+	for _, p := range projects {
+		tw.row(p.Name, strconv.Itoa(p.SessionCount), dash(p.LastBranch),
+			formatCLITime(p.LastModified), p.DirName)
+	}
+
+	return tw.flush()
+}
+
+// options returns the command's shared options.
+func (c *SessionsCommand) options() *CLIOptions {
+	return &c.CLIOptions
+}
+
+// run lists the sessions of a project.
+func (c *SessionsCommand) run(ctx context.Context, client *cliClient,
+	out io.Writer) error {
+
+	dirName, err := resolveProject(ctx, client, c.Args.Project)
+	if err != nil {
+		return err
+	}
+
+	// Follow the pages so that every session is listed.
+	var project APIProject
+	offset := 0
+	for {
+		var page APIProject
+		path := fmt.Sprintf("/api/v1/projects/%s?offset=%d&limit=%d",
+			url.PathEscape(dirName), offset, apiMaxLimit)
+		if err := client.get(ctx, path, &page); err != nil {
+			return err
+		}
+
+		sessions := append(project.Sessions, page.Sessions...)
+		project = page
+		project.Sessions = sessions
+
+		if page.Page.NextOffset == nil {
+			break
+		}
+		offset = *page.Page.NextOffset
+	}
+	project.Page = APIPage{
+		Limit: len(project.Sessions),
+		Total: len(project.Sessions),
+	}
+
+	if c.JSON {
+		return printJSON(out, project)
+	}
+
+	tw := newTable(out, "SESSION", "TASKS", "MESSAGES", "BRANCH",
+		"MODIFIED", "SUMMARY")
+	for _, s := range project.Sessions {
+		tasks := "-"
+		switch {
+		case s.HasTasks:
+			tasks = strconv.Itoa(s.TaskCount)
+
+		case s.Archived:
+			tasks = strconv.Itoa(s.TaskCount) + " archived"
+		}
+
+		summary := sessionTitle(s.SessionEntry)
+		if s.IsSidechain {
+			summary = "[subagent] " + summary
+		}
+
+		tw.row(s.SessionID, tasks, strconv.Itoa(s.MessageCount),
+			dash(s.GitBranch), formatCLITime(s.Modified), summary)
+	}
+
+	return tw.flush()
+}
+
+// resolveProject returns the directory name of the project named by arg,
+// which may be its directory name, path, name or short name.
+func resolveProject(ctx context.Context, client *cliClient,
+	arg string) (string, error) {
+
+	var projects []ProjectSummary
+	if err := client.get(ctx, "/api/v1/projects", &projects); err != nil {
+		return "", err
+	}
+
+	var matches []ProjectSummary
+	for _, p := range projects {
+		if p.DirName == arg || p.Path == arg {
+			return p.DirName, nil
+		}
+		if p.Name == arg || p.Shortname == arg {
+			matches = append(matches, p)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("project not found: %s", arg)
+
+	case 1:
+		return matches[0].DirName, nil
+
+	default:
+		paths := make([]string, 0, len(matches))
+		for _, p := range matches {
+			paths = append(paths, p.Path)
+		}
+
+		return "", fmt.Errorf("%s matches several projects, give "+
+			"one of their paths: %s", arg,
+			strings.Join(paths, ", "))
+	}
+}
+
+// options returns the command's shared options.
+func (c *TasksCommand) options() *CLIOptions {
+	return &c.CLIOptions
+}
+
+// run lists the tasks of a session.
+func (c *TasksCommand) run(ctx context.Context, client *cliClient,
+	out io.Writer) error {
+
+	list, err := getTaskList(ctx, client, c.Args.Session, c.Status)
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(out, list)
+	}
+
+	return printTasks(out, list.Tasks)
+}
+
+// getTaskList fetches the tasks of a session with a status filter.
+func getTaskList(ctx context.Context, client *cliClient, listID,
+	filter string) (APITaskList, error) {
+
+	path := "/api/v1/lists/" + url.PathEscape(listID) + "/tasks"
+	if filter != "" {
+		path += "?filter=" + url.QueryEscape(filter)
+	}
+
+	var list APITaskList
+	if err := client.get(ctx, path, &list); err != nil {
+		return APITaskList{}, err
+	}
+
+	return list, nil
+}
+
+// printTasks prints a table of tasks.
+func printTasks(out io.Writer, tasks []claudeagent.TaskListItem) error {
+	tw := newTable(out, "ID", "STATUS", "OWNER", "BLOCKED BY", "SUBJECT")
+	for _, task := range tasks {
+		tw.row(task.ID, string(task.Status), dash(task.Owner),
+			dash(strings.Join(task.BlockedBy, ",")), task.Subject)
+	}
+
+	return tw.flush()
+}
+
+// options returns the command's shared options.
+func (c *TaskCommand) options() *CLIOptions {
+	return &c.CLIOptions
+}
+
+// run shows a task.
+func (c *TaskCommand) run(ctx context.Context, client *cliClient,
+	out io.Writer) error {
+
+	path := "/api/v1/lists/" + url.PathEscape(c.Args.Session) +
+		"/tasks/" + url.PathEscape(c.Args.TaskID)
+
+	var resp APITask
+	if err := client.get(ctx, path, &resp); err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(out, resp)
+	}
+
+	task := resp.Task
+	refs := func(tasks []claudeagent.TaskListItem) string {
+		parts := make([]string, 0, len(tasks))
+		for _, t := range tasks {
+			parts = append(parts, fmt.Sprintf("#%s %s (%s)", t.ID,
+				t.Subject, t.Status))
+		}
+
+		return dash(strings.Join(parts, ", "))
+	}
+
+	fmt.Fprintf(out, "#%s %s\n\n", task.ID, task.Subject)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Status:\t%s\n", task.Status)
+	fmt.Fprintf(tw, "Owner:\t%s\n", dash(task.Owner))
+	if task.ActiveForm != "" {
+		fmt.Fprintf(tw, "Active form:\t%s\n", task.ActiveForm)
+	}
+	fmt.Fprintf(tw, "Blocked by:\t%s\n", refs(resp.Blockers))
+	fmt.Fprintf(tw, "Blocks:\t%s\n", refs(resp.Blocking))
+	if resp.Archived {
+		fmt.Fprintf(tw, "Archived:\tyes\n")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	description := strings.TrimSpace(task.Description)
+	if description != "" {
+		fmt.Fprintf(out, "\n%s\n", description)
+	}
+
+	return nil
+}
+
+// options returns the command's shared options.
+func (c *InstancesCommand) options() *CLIOptions {
+	return &c.CLIOptions
+}
+
+// run lists the running instances.
+func (c *InstancesCommand) run(ctx context.Context, client *cliClient,
+	out io.Writer) error {
+
+	var instances []ClaudeInstance
+	if err := client.get(ctx, "/api/v1/instances", &instances); err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(out, instances)
+	}
+
+	tw := newTable(out, "PID", "PROJECT", "UPTIME", "TASKS", "SESSION",
+		"DIR")
+	for _, instance := range instances {
+		tasks := "-"
+		if instance.HasTasks {
+			tasks = strconv.Itoa(instance.TaskCount)
+		}
+		project := instance.ProjectName
+		if instance.Stall != nil {
+			project += " (stalled)"
+		}
+
+		tw.row(strconv.Itoa(instance.PID), project,
+			dash(instance.Uptime), tasks, dash(instance.SessionID),
+			instance.WorkingDir)
+	}
+
+	return tw.flush()
+}
+
+// options returns the command's shared options.
+func (c *WatchCommand) options() *CLIOptions {
+	return &c.CLIOptions
+}
+
+// run prints a session's tasks and then every change to them until the
+// context is canceled.
+func (c *WatchCommand) run(ctx context.Context, client *cliClient,
+	out io.Writer) error {
+
+	listID := c.Args.Session
+
+	// Open the stream before reading the tasks so that no change falls
+	// in between.
+	resp, err := client.open(
+		ctx, "/api/lists/"+url.PathEscape(listID)+"/events",
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	list, err := getTaskList(ctx, client, listID, "")
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		if err := printJSONLine(out, list); err != nil {
+			return err
+		}
+	} else {
+		if err := printTasks(out, list.Tasks); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\nWatching %s, press Ctrl-C to stop.\n",
+			listID)
+	}
+
+	tasks := list.Tasks
+	err = readEvents(resp.Body, func(eventType string, data []byte) error {
+		switch eventType {
+		case "ping", "dropped":
+			return nil
+		}
+
+		if c.JSON {
+			_, err := fmt.Fprintf(out, "%s\n", data)
+			return err
+		}
+
+		if eventType == "session-ended" {
+			fmt.Fprintf(out, "%s  session ended\n",
+				time.Now().Format("15:04:05"))
+		}
+
+		// Every other event may have changed the tasks, so they are
+		// read again and compared.
+		list, err := getTaskList(ctx, client, listID, "")
+		if err != nil {
+			return err
+		}
+		printTaskChanges(out, tasks, list.Tasks, time.Now())
+		tasks = list.Tasks
+
+		return nil
+	})
+
+	// Being interrupted is how watch is meant to end.
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("event stream closed")
+}
+
+// readEvents calls fn with the type and data of every event of a
+// Server-Sent Events stream until it ends or fn fails.
+func readEvents(r io.Reader,
+	fn func(eventType string, data []byte) error) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+
+	eventType, data := "", []byte(nil)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data == nil {
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			if err := fn(eventType, data); err != nil {
+				return err
+			}
+			eventType, data = "", nil
+
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(line[len("event:"):])
+
+		case strings.HasPrefix(line, "data:"):
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data,
+				strings.TrimPrefix(line[len("data:"):], " ")...)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// printTaskChanges prints a line for every task created, deleted or changed
+// between two versions of a task list.
+func printTaskChanges(out io.Writer, before,
+	after []claudeagent.TaskListItem, now time.Time) {
+
+	stamp := now.Format("15:04:05")
+
+	old := make(map[string]claudeagent.TaskListItem, len(before))
+	for _, task := range before {
+		old[task.ID] = task
+	}
+
+	for _, task := range after {
+		prev, ok := old[task.ID]
+		delete(old, task.ID)
+
+		var changes []string
+		switch {
+		case !ok:
+			fmt.Fprintf(out, "%s  #%s created: %s (%s)\n", stamp,
+				task.ID, task.Subject, task.Status)
+			continue
+
+		case prev.Status != task.Status:
+			changes = append(changes, fmt.Sprintf("%s -> %s",
+				prev.Status, task.Status))
+		}
+		if prev.Subject != task.Subject {
+			changes = append(changes, "renamed from "+
+				strconv.Quote(prev.Subject))
+		}
+		if prev.Owner != task.Owner {
+			changes = append(changes, "owner "+dash(task.Owner))
+		}
+		if strings.Join(prev.BlockedBy, ",") !=
+			strings.Join(task.BlockedBy, ",") {
+
+			changes = append(changes, "blocked by "+
+				dash(strings.Join(task.BlockedBy, ",")))
+		}
+		if prev.Description != task.Description {
+			changes = append(changes, "description changed")
+		}
+
+		if len(changes) > 0 {
+			fmt.Fprintf(out, "%s  #%s %s: %s\n", stamp, task.ID,
+				task.Subject, strings.Join(changes, ", "))
+		}
+	}
+
+	// What is left was deleted, and is printed in the list's order.
+	for _, task := range before {
+		if _, ok := old[task.ID]; ok {
+			fmt.Fprintf(out, "%s  #%s deleted: %s\n", stamp,
+				task.ID, task.Subject)
+		}
+	}
+}
+
+// cliTable prints rows aligned in columns.
+type cliTable struct {
+	tw *tabwriter.Writer
+}
+
+// newTable starts a table with a header row.
+func newTable(out io.Writer, header ...string) *cliTable {
+	t := &cliTable{tw: tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)}
+	t.row(header...)
+
+	return t
+}
+
+// row adds a row. Tabs and line breaks in cells are replaced by spaces so
+// that they cannot break the table.
+func (t *cliTable) row(cells ...string) {
+	for i, cell := range cells {
+		cells[i] = strings.Join(strings.Fields(cell), " ")
+	}
+	fmt.Fprintln(t.tw, strings.Join(cells, "\t"))
+}
+
+// flush prints the table.
+func (t *cliTable) flush() error {
+	return t.tw.Flush()
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// printJSONLine prints v as JSON on a single line.
+func printJSONLine(out io.Writer, v any) error {
+	return json.NewEncoder(out).Encode(v)
+}
+
+// formatCLITime formats a time for a table, or "-" if it is unset.
+func formatCLITime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(cliTimeFormat)
+}
+
+// dash returns s, or "-" if it is empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,411 @@
+package taskviewer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// newTestCLIClient returns a client of an in-process API over a fake store,
+// as the client commands use without --server.
+func newTestCLIClient(t *testing.T, store *fakeTaskStore) *cliClient {
+	t.Helper()
+
+	_, mux := newTestHTTPServer(t, store)
+
+	return &cliClient{
+		baseURL: cliLocalURL,
+		client: &http.Client{
+			Transport: handlerTransport{handler: mux},
+		},
+	}
+}
+
+// syncBuffer is a bytes.Buffer that a command can print to while the test
+// reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends to the buffer.
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// String returns what was written so far.
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// TestCLICommands runs the task commands against the in-process API.
+func TestCLICommands(t *testing.T) {
+	blocked := testTask("2")
+	blocked.Subject = "Ship it"
+	blocked.BlockedBy = []string{"1"}
+	blocked.Description = "  Tag and push.\n"
+
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"), blocked)
+	client := newTestCLIClient(t, store)
+
+	run := func(cmd CLICommand) (string, error) {
+		var out bytes.Buffer
+		err := cmd.run(context.Background(), client, &out)
+
+		return out.String(), err
+	}
+
+	tasks := &TasksCommand{}
+	tasks.Args.Session = "s1"
+	out, err := run(tasks)
+	if err != nil {
+		t.Fatalf("unable to list tasks: %v", err)
+	}
+	want := "" +
+		"ID  STATUS   OWNER  BLOCKED BY  SUBJECT\n" +
+		"1   pending  -      -           Task 1\n" +
+		"2   pending  -      1           Ship it\n"
+	if out != want {
+		t.Fatalf("got tasks\n%s\nwant\n%s", out, want)
+	}
+
+	tasks.JSON = true
+	out, err = run(tasks)
+	if err != nil {
+		t.Fatalf("unable to list tasks as JSON: %v", err)
+	}
+	var list APITaskList
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatalf("unable to decode %q: %v", out, err)
+	}
+	if list.ListID != "s1" || len(list.Tasks) != 2 {
+		t.Fatalf("got list %+v", list)
+	}
+
+	task := &TaskCommand{}
+	task.Args.Session = "s1"
+	task.Args.TaskID = "2"
+	out, err = run(task)
+	if err != nil {
+		t.Fatalf("unable to show task: %v", err)
+	}
+	want = "" +
+		"#2 Ship it\n\n" +
+		"Status:      pending\n" +
+		"Owner:       -\n" +
+		"Blocked by:  #1 Task 1 (pending)\n" +
+		"Blocks:      -\n" +
+		"\nTag and push.\n"
+	if out != want {
+		t.Fatalf("got task\n%s\nwant\n%s", out, want)
+	}
+
+	// An API error is reported with the API's message.
+	task.Args.TaskID = "9"
+	if _, err := run(task); err == nil ||
+		!strings.Contains(err.Error(), "not found") {
+
+		t.Fatalf("got error %v for a missing task", err)
+	}
+}
+
+// TestWatchCommand checks that watch prints the tasks, then a line for each
+// change, and ends quietly when interrupted.
+func TestWatchCommand(t *testing.T) {
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"))
+	client := newTestCLIClient(t, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch := &WatchCommand{}
+	watch.Args.Session = "s1"
+	out := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- watch.run(ctx, client, out)
+	}()
+
+	eventually(t, "watch to start", func() bool {
+		return store.subscribers("s1") == 1 &&
+			strings.Contains(out.String(), "Watching s1")
+	})
+
+	renamed := testTask("1")
+	renamed.Subject = "Renamed"
+	store.set("s1", renamed, testTask("2"))
+	store.send("s1", claudeagent.TaskEvent{
+		Type: "updated", ListID: "s1", TaskID: "1",
+	})
+
+	eventually(t, "the changes", func() bool {
+		return strings.Contains(out.String(), "#2 created") &&
+			strings.Contains(out.String(), "#1 Renamed")
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("watch ended with %v", err)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watch did not end when interrupted")
+	}
+}
+
+// TestHandlerTransport checks that responses of the in-process handler are
+// passed on as written, and streamed as they are written.
+func TestHandlerTransport(t *testing.T) {
+	chunks := make(chan string)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /missing", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("gone"))
+
+		// Changes after the status was sent are not seen.
+		w.Header().Set("X-Late", "yes")
+	})
+	mux.HandleFunc("GET /empty", func(http.ResponseWriter,
+		*http.Request) {
+	})
+	mux.HandleFunc("GET /stream", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		for chunk := range chunks {
+			w.Write([]byte(chunk))
+		}
+	})
+	mux.HandleFunc("GET /stuck", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		<-r.Context().Done()
+	})
+
+	client := &http.Client{Transport: handlerTransport{handler: mux}}
+	get := func(ctx context.Context, path string) (*http.Response,
+		error) {
+
+		req, err := http.NewRequestWithContext(
+			ctx, http.MethodGet, cliLocalURL+path, nil,
+		)
+		if err != nil {
+			t.Fatalf("unable to create request: %v", err)
+		}
+
+		return client.Do(req)
+	}
+
+	resp, err := get(context.Background(), "/missing")
+	if err != nil {
+		t.Fatalf("unable to get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || string(body) != "gone" ||
+		resp.Header.Get("X-Test") != "yes" ||
+		resp.Header.Get("X-Late") != "" {
+
+		t.Fatalf("got %s %v %q", resp.Status, resp.Header, body)
+	}
+
+	// A handler that writes nothing answers 200 with an empty body.
+	resp, err = get(context.Background(), "/empty")
+	if err != nil {
+		t.Fatalf("unable to get: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(body) != 0 {
+		t.Fatalf("got %s %q", resp.Status, body)
+	}
+
+	// Each chunk can be read while the handler is still running. The
+	// first write sends the status, so the request returns only once a
+	// chunk is on its way.
+	go func() {
+		chunks <- "one"
+	}()
+	resp, err = get(context.Background(), "/stream")
+	if err != nil {
+		t.Fatalf("unable to get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	for i, chunk := range []string{"one", "two"} {
+		if i > 0 {
+			go func() {
+				chunks <- chunk
+			}()
+		}
+		buf := make([]byte, len(chunk))
+		if _, err := io.ReadFull(resp.Body, buf); err != nil {
+			t.Fatalf("unable to read %s: %v", chunk, err)
+		}
+		if string(buf) != chunk {
+			t.Fatalf("got chunk %q, want %q", buf, chunk)
+		}
+	}
+	close(chunks)
+	if rest, _ := io.ReadAll(resp.Body); len(rest) != 0 {
+		t.Fatalf("got %q after the last chunk", rest)
+	}
+
+	// A request canceled before the status is sent fails.
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+	if _, err := get(ctx, "/stuck"); !errors.Is(
+		err, context.DeadlineExceeded,
+	) {
+
+		t.Fatalf("got error %v, want deadline exceeded", err)
+	}
+}
+
+// sseEvent is an event read from a Server-Sent Events stream.
+type sseEvent struct {
+	eventType string
+	data      string
+}
+
+// TestReadEvents checks how a Server-Sent Events stream is split into
+// events.
+func TestReadEvents(t *testing.T) {
+	stream := "" +
+		": comment\n" +
+		"event: ping\n" +
+		"data: connected\n" +
+		"\n" +
+		"\n" +
+		"id: 7\n" +
+		"event: task-updated\n" +
+		"data: {\"a\":1}\n" +
+		"\n" +
+		"data:first\n" +
+		"data: second\n" +
+		"\n" +
+		"event: ignored\n" +
+		"\n" +
+		"event: unfinished\n" +
+		"data: never delivered\n"
+
+	var got []sseEvent
+	err := readEvents(strings.NewReader(stream),
+		func(eventType string, data []byte) error {
+			got = append(got, sseEvent{eventType, string(data)})
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("unable to read events: %v", err)
+	}
+
+	// An event without data is dropped, and its type with it.
+	want := []sseEvent{
+		{"ping", "connected"},
+		{"task-updated", `{"a":1}`},
+		{"message", "first\nsecond"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// An error from the callback stops the stream.
+	errStop := errors.New("stop")
+	calls := 0
+	err = readEvents(strings.NewReader(stream),
+		func(string, []byte) error {
+			calls++
+			return errStop
+		},
+	)
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Fatalf("got %v after %d calls", err, calls)
+	}
+}
+
+// TestPrintTaskChanges checks the line printed for each kind of change
+// between two versions of a task list.
+func TestPrintTaskChanges(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 30, 5, 0, time.Local)
+
+	task := func(id string, edit func(*claudeagent.TaskListItem)) (
+		t claudeagent.TaskListItem) {
+
+		t = testTask(id)
+		if edit != nil {
+			edit(&t)
+		}
+
+		return t
+	}
+
+	before := []claudeagent.TaskListItem{
+		task("1", nil),
+		task("2", nil),
+		task("3", nil),
+		task("4", nil),
+		task("5", func(t *claudeagent.TaskListItem) {
+			t.Owner = "alice"
+			t.BlockedBy = []string{"1"}
+		}),
+	}
+	after := []claudeagent.TaskListItem{
+		task("5", func(t *claudeagent.TaskListItem) {
+			t.Subject = "Renamed"
+			t.Status = claudeagent.TaskListStatusInProgress
+			t.Description = "More detail"
+		}),
+		task("6", nil),
+		task("1", nil),
+		task("3", func(t *claudeagent.TaskListItem) {
+			t.Owner = "bob"
+			t.BlockedBy = []string{"1", "6"}
+		}),
+	}
+
+	var out bytes.Buffer
+	printTaskChanges(&out, before, after, now)
+
+	want := "" +
+		"09:30:05  #5 Renamed: pending -> in_progress, renamed " +
+		"from \"Task 5\", owner -, blocked by -, description " +
+		"changed\n" +
+		"09:30:05  #6 created: Task 6 (pending)\n" +
+		"09:30:05  #3 Task 3: owner bob, blocked by 1,6\n" +
+		"09:30:05  #2 deleted: Task 2\n" +
+		"09:30:05  #4 deleted: Task 4\n"
+	if out.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printTaskChanges(&out, before, before, now)
+	if out.Len() != 0 {
+		t.Fatalf("got %q for an unchanged list", out.String())
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -15,6 +16,48 @@ func main() {
 	// Parse configuration.
 	cfg := taskviewer.DefaultConfig()
 	parser := flags.NewParser(cfg, flags.Default)
+
+	// Without a command the daemon serves the web dashboard.
+	parser.SubcommandsOptional = true
+	_, err := parser.AddCommand(
+		"tui", "Show a live dashboard in the terminal",
+		"Show the running instances, active sessions and their tasks "+
+			"in the terminal, updated live. Reads the claude "+
+			"directory directly, without a running daemon.",
+		&struct{}{},
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error adding commands: %v\n", err)
+		os.Exit(1)
+	}
+	_, err = parser.AddCommand(
+		"mcp", "Serve MCP to an agent on stdio",
+		"Serve the Model Context Protocol on stdin and stdout, so "+
+			"that an agent can see what the other Claude "+
+			"sessions on this machine are doing. Reads the "+
+			"claude directory directly, without a running daemon.",
+		&struct{}{},
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error adding commands: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The client commands print what the API returns, read from the
+	// claude directory or from a daemon given by --server.
+	clientCommands := make(map[string]taskviewer.CLICommand)
+	for _, spec := range taskviewer.CLICommands() {
+		_, err := parser.AddCommand(
+			spec.Name, spec.Short, spec.Long, spec.Command,
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding commands: %v\n",
+				err)
+			os.Exit(1)
+		}
+		clientCommands[spec.Name] = spec.Command
+	}
+
 	if _, err := parser.Parse(); err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok {
 			if flagsErr.Type == flags.ErrHelp {
@@ -39,6 +82,50 @@ func main() {
 
 	log := logger
 
+	if parser.Active != nil && parser.Active.Name == "tui" {
+		// Log lines would tear through the dashboard.
+		err := taskviewer.RunTUI(cfg, btclog.Disabled)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parser.Active != nil && parser.Active.Name == "mcp" {
+		// Stdout carries the protocol, so logs go to stderr.
+		backend := btclog.NewDefaultHandler(os.Stderr)
+		mcpLog := btclog.NewSLogger(backend.SubSystem("TVWR"))
+		mcpLog.SetLevel(level)
+
+		ctx, cancel := signal.NotifyContext(
+			context.Background(), syscall.SIGINT, syscall.SIGTERM,
+		)
+		err := taskviewer.RunMCP(ctx, cfg, mcpLog)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parser.Active != nil {
+		if cmd, ok := clientCommands[parser.Active.Name]; ok {
+			ctx, cancel := signal.NotifyContext(
+				context.Background(), syscall.SIGINT,
+				syscall.SIGTERM,
+			)
+			err := taskviewer.RunCLI(ctx, cfg, cmd, os.Stdout)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Create and start server.
 	server, err := taskviewer.NewServer(cfg, log)
 	if err != nil {
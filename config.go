@@ -1,19 +1,130 @@
 package taskviewer
 
 import (
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // Config holds the main configuration for the task viewer daemon.
 type Config struct {
-	// ListenAddr is the address the HTTP server will listen on.
-	ListenAddr string `long:"listen" description:"Address to listen on" default:":8080"`
+	// ListenAddr is the address the HTTP server will listen on. It
+	// defaults to loopback so that the viewer is not reachable from the
+	// network unless asked.
+	ListenAddr string `long:"listen" description:"Address to listen on; use 0.0.0.0:8080 to listen on all interfaces" default:"127.0.0.1:8080"`
+
+	// AuthPassword is the password to sign in to the dashboard. It is
+	// also accepted as a bearer token with full access.
+	AuthPassword string `long:"auth-password" env:"TASKVIEWER_PASSWORD" description:"Require this password to sign in to the dashboard; also accepted as a bearer token"`
+
+	// APITokens are bearer tokens with read-only access.
+	APITokens []string `long:"api-token" env:"TASKVIEWER_API_TOKENS" env-delim:"," description:"Accept this bearer token for read-only access (may be repeated)"`
+
+	// TLS serves HTTPS, with a self-signed certificate generated on
+	// first run unless TLSCertPath and TLSKeyPath name an existing pair.
+	TLS bool `long:"tls" description:"Serve HTTPS, generating a self-signed certificate on first run"`
+
+	// TLSCertPath is the TLS certificate. Defaults to
+	// ~/.taskviewer/tls.cert if empty.
+	TLSCertPath string `long:"tls-cert" description:"TLS certificate (default: ~/.taskviewer/tls.cert)"`
+
+	// TLSKeyPath is the TLS key. Defaults to ~/.taskviewer/tls.key if
+	// empty.
+	TLSKeyPath string `long:"tls-key" description:"TLS key (default: ~/.taskviewer/tls.key)"`
+
+	// TLSHosts are extra host names and IP addresses to put in a
+	// generated certificate.
+	TLSHosts []string `long:"tls-host" description:"Extra host name or IP address for the generated certificate (may be repeated)"`
+
+	// ClaudeDir is the base Claude state directory. Defaults to ~/.claude
+	// if empty.
+	ClaudeDir string `long:"claude-dir" description:"Claude state directory (default: ~/.claude)"`
+
+	// ProjectsDir is the directory containing per-project session
+	// indexes. Defaults to {claude-dir}/projects if empty.
+	ProjectsDir string `long:"projects-dir" description:"Project session index directory (default: {claude-dir}/projects)"`
 
 	// TasksDir is the directory containing task lists. Defaults to
-	// ~/.claude/tasks if empty.
-	TasksDir string `long:"tasks-dir" description:"Task storage directory"`
+	// {claude-dir}/tasks if empty.
+	TasksDir string `long:"tasks-dir" description:"Task storage directory (default: {claude-dir}/tasks)"`
+
+	// ArchiveDir is where snapshots of task lists are persisted so they
+	// outlive their sessions. Defaults to ~/.taskviewer/archive if empty.
+	ArchiveDir string `long:"archive-dir" description:"Task list archive directory (default: ~/.taskviewer/archive)"`
+
+	// NoArchive disables the task list archive.
+	NoArchive bool `long:"no-archive" description:"Do not archive task lists"`
+
+	// StallTaskAge is how long a task must be in progress before its
+	// session can be flagged as stalled.
+	StallTaskAge time.Duration `long:"stall-task-age" description:"Flag a running session as stalled once a task has been in progress this long" default:"30m"`
+
+	// StallIdle is how long a session's transcript must go unwritten
+	// before the session can be flagged as stalled.
+	StallIdle time.Duration `long:"stall-idle" description:"Flag a running session as stalled once its transcript has been idle this long" default:"10m"`
+
+	// NoWatchdog disables stalled session detection.
+	NoWatchdog bool `long:"no-watchdog" description:"Do not watch for stalled sessions"`
+
+	// Webhooks are URLs that receive every notification with the default
+	// payload.
+	Webhooks []string `long:"webhook" description:"Send task and session notifications to this webhook URL (may be repeated)"`
+
+	// WebhookConfig is a JSON file of webhooks with event filters,
+	// payload templates and headers.
+	WebhookConfig string `long:"webhook-config" description:"JSON file configuring webhooks with event filters and payload templates"`
+
+	// PriceTable is a JSON file of model prices used to estimate the cost
+	// of token usage. Its entries override the built-in prices.
+	PriceTable string `long:"price-table" description:"JSON file of model prices per million tokens, overriding the built-in prices"`
+
+	// AllowWrites enables creating, editing and deleting tasks from the
+	// dashboard and the API. The viewer is read-only without it.
+	AllowWrites bool `long:"allow-writes" description:"Allow tasks to be created, edited and deleted from the dashboard and API"`
+
+	// SearchTranscripts adds the text of every session transcript to the
+	// search index, on top of summaries, prompts and tasks.
+	SearchTranscripts bool `long:"search-transcripts" description:"Also index the conversation text of session transcripts for search"`
+
+	// HostName names this machine to hubs and in the fleet view.
+	// Defaults to the system host name if empty.
+	HostName string `long:"host-name" description:"Name of this machine in the fleet view (default: the system host name)"`
+
+	// Agent serves this machine's state to hubs over the API. It needs
+	// --auth-password or --api-token, since hubs connect from other
+	// machines.
+	Agent bool `long:"agent" description:"Serve this machine's projects, instances and task lists to hubs"`
+
+	// Hub merges the state of other machines into the fleet view, from
+	// the Peers it polls and the agents that push to it.
+	Hub bool `long:"hub" description:"Merge the state of other machines into a fleet view, accepting pushed snapshots"`
+
+	// Peers are agents polled by a hub, each given as name=url. Setting
+	// any implies Hub.
+	Peers []string `long:"peer" description:"Poll the agent at this URL, given as name=url (may be repeated; implies --hub)"`
+
+	// PeerToken is the bearer token sent when polling peers.
+	PeerToken string `long:"peer-token" env:"TASKVIEWER_PEER_TOKEN" description:"Bearer token for polling peers"`
+
+	// PushTo are hubs this machine pushes its state to, for hubs that
+	// cannot reach it.
+	PushTo []string `long:"push-to" description:"Push this machine's state to the hub at this URL (may be repeated)"`
+
+	// PushToken is the bearer token sent when pushing to hubs. Hubs
+	// only accept pushes with full access, so this is their password.
+	PushToken string `long:"push-token" env:"TASKVIEWER_PUSH_TOKEN" description:"Bearer token for pushing to hubs: the hub's --auth-password"`
+
+	// FederationInterval is how often peers are polled and snapshots
+	// pushed.
+	FederationInterval time.Duration `long:"federation-interval" description:"How often to poll peers and push to hubs" default:"15s"`
+
+	// FederationCAs are PEM certificates trusted when connecting to peers
+	// and hubs, such as their self-signed tls.cert.
+	FederationCAs []string `long:"federation-ca" description:"PEM certificate to trust for peers and hubs, such as their tls.cert (may be repeated)"`
 
 	// LogLevel sets the logging verbosity.
 	LogLevel string `long:"loglevel" description:"Log level (trace, debug, info, warn, error, critical)" default:"info"`
@@ -25,8 +136,11 @@ type Config struct {
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() *Config {
 	return &Config{
-		ListenAddr: ":8080",
-		LogLevel:   "info",
+		ListenAddr:         "127.0.0.1:8080",
+		StallTaskAge:       30 * time.Minute,
+		StallIdle:          10 * time.Minute,
+		FederationInterval: 15 * time.Second,
+		LogLevel:           "info",
 	}
 }
 
@@ -36,13 +150,94 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("listen address cannot be empty")
 	}
 
+	if c.StallTaskAge < 0 || c.StallIdle < 0 {
+		return fmt.Errorf("stall thresholds cannot be negative")
+	}
+
+	// Anyone who can reach a writable viewer can change tasks, so only
+	// allow that beyond this machine behind a password.
+	if c.AllowWrites && !isLoopbackAddr(c.ListenAddr) &&
+		c.AuthPassword == "" {
+
+		return fmt.Errorf("--allow-writes on a non-loopback address " +
+			"requires --auth-password")
+	}
+
+	// An agent's state includes every prompt on the machine, so it is
+	// only served to hubs that authenticate.
+	if c.Agent && c.AuthPassword == "" && len(c.APITokens) == 0 {
+		return fmt.Errorf("--agent requires --api-token or " +
+			"--auth-password")
+	}
+
+	// A hub shows the state of every machine in its fleet, and anyone
+	// who can reach it could push hosts of their own, so beyond this
+	// machine it also needs credentials.
+	if (c.Hub || len(c.Peers) > 0) && !isLoopbackAddr(c.ListenAddr) &&
+		c.AuthPassword == "" && len(c.APITokens) == 0 {
+
+		return fmt.Errorf("--hub on a non-loopback address requires " +
+			"--api-token or --auth-password")
+	}
+
+	if (c.Hub || len(c.Peers) > 0 || len(c.PushTo) > 0) &&
+		c.FederationInterval <= 0 {
+
+		return fmt.Errorf("federation interval must be positive")
+	}
+
 	return nil
 }
 
-// ResolveTasksDir returns the tasks directory, defaulting to ~/.claude/tasks.
+// ResolveTasksDir returns the tasks directory, defaulting to
+// {claude-dir}/tasks.
 func (c *Config) ResolveTasksDir() (string, error) {
 	if c.TasksDir != "" {
-		return c.TasksDir, nil
+		return cleanPath(c.TasksDir)
+	}
+
+	claudeDir, err := c.ResolveClaudeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(claudeDir, "tasks"), nil
+}
+
+// ResolveProjectsDir returns the projects directory, defaulting to
+// {claude-dir}/projects.
+func (c *Config) ResolveProjectsDir() (string, error) {
+	if c.ProjectsDir != "" {
+		return cleanPath(c.ProjectsDir)
+	}
+
+	claudeDir, err := c.ResolveClaudeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(claudeDir, "projects"), nil
+}
+
+// ResolveClaudeDir returns the base claude directory, defaulting to ~/.claude.
+func (c *Config) ResolveClaudeDir() (string, error) {
+	if c.ClaudeDir != "" {
+		return cleanPath(c.ClaudeDir)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".claude"), nil
+}
+
+// ResolveArchiveDir returns the task list archive directory, defaulting to
+// ~/.taskviewer/archive.
+func (c *Config) ResolveArchiveDir() (string, error) {
+	if c.ArchiveDir != "" {
+		return cleanPath(c.ArchiveDir)
 	}
 
 	home, err := os.UserHomeDir()
@@ -50,7 +245,272 @@ func (c *Config) ResolveTasksDir() (string, error) {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	return filepath.Join(home, ".claude", "tasks"), nil
+	return filepath.Join(home, ".taskviewer", "archive"), nil
+}
+
+// ResolveTLSPaths returns the TLS certificate and key paths, defaulting to
+// ~/.taskviewer/tls.cert and ~/.taskviewer/tls.key.
+func (c *Config) ResolveTLSPaths() (string, string, error) {
+	certPath, keyPath := c.TLSCertPath, c.TLSKeyPath
+	if certPath == "" || keyPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get home "+
+				"directory: %w", err)
+		}
+		dir := filepath.Join(home, ".taskviewer")
+		if certPath == "" {
+			certPath = filepath.Join(dir, "tls.cert")
+		}
+		if keyPath == "" {
+			keyPath = filepath.Join(dir, "tls.key")
+		}
+	}
+
+	certPath, err := cleanPath(certPath)
+	if err != nil {
+		return "", "", err
+	}
+	keyPath, err = cleanPath(keyPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, nil
+}
+
+// ResolvePrices returns the price table, defaulting to the built-in prices.
+func (c *Config) ResolvePrices() (PriceTable, error) {
+	if c.PriceTable == "" {
+		return DefaultPriceTable(), nil
+	}
+
+	path, err := cleanPath(c.PriceTable)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadPriceTable(path)
+}
+
+// ResolveWebhooks returns the webhooks given on the command line followed by
+// those in the webhook config file, if any.
+func (c *Config) ResolveWebhooks() ([]WebhookConfig, error) {
+	var webhooks []WebhookConfig
+	for _, webhookURL := range c.Webhooks {
+		webhooks = append(webhooks, WebhookConfig{URL: webhookURL})
+	}
+
+	if c.WebhookConfig != "" {
+		path, err := cleanPath(c.WebhookConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		configs, err := LoadWebhookConfigs(path)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, configs...)
+	}
+
+	return webhooks, nil
+}
+
+// ResolveFederation returns the federation settings, parsing peers and
+// loading the trusted certificates.
+func (c *Config) ResolveFederation() (FederationConfig, error) {
+	cfg := FederationConfig{
+		HostName:  c.HostName,
+		Agent:     c.Agent,
+		Hub:       c.Hub || len(c.Peers) > 0,
+		PeerToken: c.PeerToken,
+		PushToken: c.PushToken,
+		Interval:  c.FederationInterval,
+	}
+	if cfg.HostName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return FederationConfig{}, fmt.Errorf("failed to get "+
+				"host name, set --host-name: %w", err)
+		}
+		cfg.HostName = hostname
+	}
+
+	names := map[string]bool{cfg.HostName: true}
+	for _, peer := range c.Peers {
+		name, rawURL, ok := strings.Cut(peer, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return FederationConfig{}, fmt.Errorf("invalid peer "+
+				"%q: want name=url", peer)
+		}
+		if names[name] {
+			return FederationConfig{}, fmt.Errorf("duplicate host "+
+				"name %q", name)
+		}
+		names[name] = true
+
+		baseURL, err := parseBaseURL(rawURL)
+		if err != nil {
+			return FederationConfig{}, fmt.Errorf("invalid peer "+
+				"%s: %w", name, err)
+		}
+		cfg.Peers = append(cfg.Peers, PeerConfig{
+			Name: name, URL: baseURL,
+		})
+	}
+
+	for _, rawURL := range c.PushTo {
+		baseURL, err := parseBaseURL(rawURL)
+		if err != nil {
+			return FederationConfig{}, fmt.Errorf("invalid hub "+
+				"%s: %w", rawURL, err)
+		}
+		cfg.PushTo = append(cfg.PushTo, baseURL)
+	}
+
+	if len(c.FederationCAs) > 0 {
+		cfg.RootCAs = x509.NewCertPool()
+	}
+	for _, caPath := range c.FederationCAs {
+		path, err := cleanPath(caPath)
+		if err != nil {
+			return FederationConfig{}, err
+		}
+
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return FederationConfig{}, fmt.Errorf("failed to read "+
+				"certificate: %w", err)
+		}
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return FederationConfig{}, fmt.Errorf("no certificate "+
+				"found in %s", path)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseBaseURL checks that a URL is an http or https address and strips
+// any trailing slash, so that paths can be appended to it.
+func parseBaseURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("want an http or https URL, got %q",
+			rawURL)
+	}
+
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// StatePaths holds the resolved locations of Claude's on-disk state. Every
+// component that reads that state is built from the same StatePaths so that
+// the task store, project indexer and instance tracker always agree.
+type StatePaths struct {
+	// ClaudeDir is the base Claude state directory.
+	ClaudeDir string
+
+	// ProjectsDir contains one directory per project, each holding a
+	// sessions-index.json and the session transcripts.
+	ProjectsDir string
+
+	// TasksDir contains one directory per session with its task files.
+	TasksDir string
+
+	// explicitProjects and explicitTasks record whether the directories
+	// were set on the command line rather than derived from ClaudeDir.
+	explicitProjects bool
+	explicitTasks    bool
+}
+
+// ResolvePaths resolves all state directories from the configuration.
+func (c *Config) ResolvePaths() (*StatePaths, error) {
+	claudeDir, err := c.ResolveClaudeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve claude dir: %w", err)
+	}
+
+	projectsDir, err := c.ResolveProjectsDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve projects dir: %w", err)
+	}
+
+	tasksDir, err := c.ResolveTasksDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tasks dir: %w", err)
+	}
+
+	return &StatePaths{
+		ClaudeDir:        claudeDir,
+		ProjectsDir:      projectsDir,
+		TasksDir:         tasksDir,
+		explicitProjects: c.ProjectsDir != "",
+		explicitTasks:    c.TasksDir != "",
+	}, nil
+}
+
+// Validate checks that the state directories exist. Claude creates projects/
+// and tasks/ lazily, so derived subdirectories may be missing on a fresh
+// install; directories given explicitly on the command line must exist. The
+// claude directory itself need not exist if both are given explicitly.
+func (p *StatePaths) Validate() error {
+	if !p.explicitProjects || !p.explicitTasks {
+		err := checkDir("claude dir (--claude-dir)", p.ClaudeDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := checkDir("projects dir (--projects-dir)", p.ProjectsDir)
+	if err != nil && (p.explicitProjects || !os.IsNotExist(err)) {
+		return err
+	}
+
+	err = checkDir("tasks dir (--tasks-dir)", p.TasksDir)
+	if err != nil && (p.explicitTasks || !os.IsNotExist(err)) {
+		return err
+	}
+
+	return nil
+}
+
+// checkDir returns a descriptive error if path is missing or is not a
+// directory. Missing paths wrap os.ErrNotExist.
+func checkDir(what, path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return fmt.Errorf("%s %s does not exist: %w", what, path,
+			os.ErrNotExist)
+
+	case err != nil:
+		return fmt.Errorf("unable to access %s %s: %w", what, path, err)
+
+	case !info.IsDir():
+		return fmt.Errorf("%s %s is not a directory", what, path)
+	}
+
+	return nil
+}
+
+// cleanPath expands a leading ~ to the user's home directory and cleans the
+// resulting path.
+func cleanPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w",
+				err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	return filepath.Abs(path)
 }
 
 // HTTPConfig holds configuration for the HTTP server component.
@@ -61,19 +521,31 @@ type HTTPConfig struct {
 	// ClaudeDir is the base ~/.claude directory.
 	ClaudeDir string
 
+	// ProjectsDir is the resolved path to the projects directory.
+	ProjectsDir string
+
 	// TasksDir is the resolved path to the tasks directory.
 	TasksDir string
 
 	// DebugHTTP enables request/response logging.
 	DebugHTTP bool
-}
 
-// ResolveClaudeDir returns the base claude directory, defaulting to ~/.claude.
-func (c *Config) ResolveClaudeDir() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
+	// Prices is used to estimate the cost of token usage. Defaults to
+	// DefaultPriceTable if nil.
+	Prices PriceTable
 
-	return filepath.Join(home, ".claude"), nil
+	// AllowWrites enables the endpoints that change tasks.
+	AllowWrites bool
+
+	// Auth checks the credentials of every request. Nil disables
+	// authentication.
+	Auth *Authenticator
+
+	// TLSCertPath and TLSKeyPath are the TLS key pair, generated if
+	// missing. HTTPS is served if they are set.
+	TLSCertPath string
+	TLSKeyPath  string
+
+	// TLSHosts are extra hosts to put in a generated certificate.
+	TLSHosts []string
 }
@@ -0,0 +1,77 @@
+package taskviewer
+
+import (
+	"testing"
+	"time"
+)
+
+// TestConfigValidate checks that agents, and hubs reachable from other
+// machines, are refused without credentials.
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "loopback hub",
+			cfg:  Config{ListenAddr: "127.0.0.1:8080", Hub: true},
+		},
+		{
+			name:    "open hub",
+			cfg:     Config{ListenAddr: "0.0.0.0:8080", Hub: true},
+			wantErr: true,
+		},
+		{
+			name: "open peers",
+			cfg: Config{
+				ListenAddr: ":8080",
+				Peers:      []string{"box1=http://box1:8080"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hub with password",
+			cfg: Config{
+				ListenAddr:   "0.0.0.0:8080",
+				Hub:          true,
+				AuthPassword: "hunter2",
+			},
+		},
+		{
+			name: "hub with token",
+			cfg: Config{
+				ListenAddr: "0.0.0.0:8080",
+				Hub:        true,
+				APITokens:  []string{"fleet"},
+			},
+		},
+		{
+			name: "agent",
+			cfg: Config{
+				ListenAddr: "127.0.0.1:8080",
+				Agent:      true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "pushing agent",
+			cfg: Config{
+				ListenAddr: "0.0.0.0:8080",
+				PushTo:     []string{"http://hub:8080"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.cfg.FederationInterval = 15 * time.Second
+
+			err := test.cfg.Validate()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err,
+					test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,559 @@
+package taskviewer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+var (
+	// errWritesDisabled is returned for every edit unless the daemon was
+	// started with --allow-writes.
+	errWritesDisabled = errors.New("task editing is disabled; start the " +
+		"daemon with --allow-writes to enable it")
+
+	// errCrossOrigin is returned for edits submitted from another site.
+	errCrossOrigin = errors.New("cross-origin edits are not allowed")
+
+	// errListEnded is returned for edits to a list whose task files are
+	// gone, since only the archived snapshot remains.
+	errListEnded = errors.New("the session has ended, so its tasks can " +
+		"no longer be edited")
+
+	// errInvalidEdit wraps every edit rejected as invalid.
+	errInvalidEdit = errors.New("invalid edit")
+)
+
+// TaskEdit is a change to an existing task. Fields left nil are unchanged.
+type TaskEdit struct {
+	Subject     *string                     `json:"subject,omitempty"`
+	Description *string                     `json:"description,omitempty"`
+	ActiveForm  *string                     `json:"activeForm,omitempty"`
+	Owner       *string                     `json:"owner,omitempty"`
+	Status      *claudeagent.TaskListStatus `json:"status,omitempty"`
+
+	// AddBlockedBy and RemoveBlockedBy are the IDs of tasks to add to and
+	// remove from the task's blockers.
+	AddBlockedBy    []string `json:"addBlockedBy,omitempty"`
+	RemoveBlockedBy []string `json:"removeBlockedBy,omitempty"`
+}
+
+// TaskDraft is a task to be created. Its ID is assigned by the task store.
+type TaskDraft struct {
+	Subject     string                     `json:"subject"`
+	Description string                     `json:"description,omitempty"`
+	ActiveForm  string                     `json:"activeForm,omitempty"`
+	Owner       string                     `json:"owner,omitempty"`
+	Status      claudeagent.TaskListStatus `json:"status,omitempty"`
+	BlockedBy   []string                   `json:"blockedBy,omitempty"`
+}
+
+// validStatus reports whether s is a task status.
+func validStatus(s claudeagent.TaskListStatus) bool {
+	return slices.Contains(taskStatuses, s)
+}
+
+// invalidEdit returns an error wrapping errInvalidEdit.
+func invalidEdit(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	return fmt.Errorf("%w: %s", errInvalidEdit, msg)
+}
+
+// wouldCycle reports whether making a task blocked by blocker would create a
+// dependency cycle, which is the case if blocker already waits on the task,
+// directly or through other tasks.
+func wouldCycle(tasks map[string]claudeagent.TaskListItem, taskID,
+	blocker string) bool {
+
+	seen := make(map[string]bool)
+	stack := []string{blocker}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if id == taskID {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		stack = append(stack, tasks[id].BlockedBy...)
+	}
+
+	return false
+}
+
+// checkBlockers validates the blockers to add to a task.
+func checkBlockers(tasks map[string]claudeagent.TaskListItem, taskID string,
+	blockers []string) error {
+
+	for _, blocker := range blockers {
+		if _, ok := tasks[blocker]; !ok {
+			return invalidEdit("blocker #%s does not exist",
+				blocker)
+		}
+		if blocker == taskID {
+			return invalidEdit("a task cannot block itself")
+		}
+		if wouldCycle(tasks, taskID, blocker) {
+			return invalidEdit("#%s already waits on #%s, so it "+
+				"cannot block it", blocker, taskID)
+		}
+	}
+
+	return nil
+}
+
+// blockerCandidates returns the tasks of a list that could be added as
+// blockers of a task without creating a cycle.
+func blockerCandidates(tasks []claudeagent.TaskListItem,
+	task *claudeagent.TaskListItem) []claudeagent.TaskListItem {
+
+	byID := make(map[string]claudeagent.TaskListItem, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	var candidates []claudeagent.TaskListItem
+	for _, t := range tasks {
+		if t.ID == task.ID || slices.Contains(task.BlockedBy, t.ID) ||
+			wouldCycle(byID, task.ID, t.ID) {
+
+			continue
+		}
+		candidates = append(candidates, t)
+	}
+
+	return candidates
+}
+
+// checkWritable returns an error unless edits are enabled, the request is
+// from the viewer itself and the list's task files still exist.
+func (h *HTTPServer) checkWritable(r *http.Request, listID string) error {
+	switch {
+	case !h.cfg.AllowWrites:
+		return errWritesDisabled
+
+	case !sameOrigin(r):
+		return errCrossOrigin
+
+	case !h.projectIndexer.HasTasks(listID):
+		return errListEnded
+	}
+
+	return nil
+}
+
+// editStatus returns the HTTP status and API error code for an edit error.
+func editStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errWritesDisabled), errors.Is(err, errCrossOrigin):
+		return http.StatusForbidden, ErrCodeForbidden
+
+	case errors.Is(err, errListEnded):
+		return http.StatusConflict, ErrCodeConflict
+
+	case errors.Is(err, errTaskNotFound):
+		return http.StatusNotFound, ErrCodeNotFound
+
+	case errors.Is(err, errInvalidEdit):
+		return http.StatusBadRequest, ErrCodeBadRequest
+
+	default:
+		return http.StatusInternalServerError, ErrCodeInternal
+	}
+}
+
+// loadList returns the live tasks of a list indexed by ID.
+func (h *HTTPServer) loadList(ctx context.Context,
+	listID string) (map[string]claudeagent.TaskListItem, error) {
+
+	tasks, err := h.taskStore.List(ctx, listID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+
+	byID := make(map[string]claudeagent.TaskListItem, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	return byID, nil
+}
+
+// createTask validates and creates a task through the task store, which
+// locks the list so that running agents see a consistent view.
+//
+// The store only locks the list for each call, not from the validating List
+// to the writes, so a concurrent edit can slip in between. The blockers are
+// therefore checked again once written, and any deleted meanwhile are
+// detached.
+func (h *HTTPServer) createTask(ctx context.Context, listID string,
+	draft TaskDraft) (*claudeagent.TaskListItem, error) {
+
+	draft.Subject = strings.TrimSpace(draft.Subject)
+	if draft.Subject == "" {
+		return nil, invalidEdit("subject is required")
+	}
+	if draft.Status == "" {
+		draft.Status = claudeagent.TaskListStatusPending
+	}
+	if !validStatus(draft.Status) {
+		return nil, invalidEdit("unknown status %q", draft.Status)
+	}
+
+	tasks, err := h.loadList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new task cannot be part of a cycle, so only check that its
+	// blockers exist.
+	if err := checkBlockers(tasks, "", draft.BlockedBy); err != nil {
+		return nil, err
+	}
+
+	task, err := h.taskStore.Create(ctx, listID, claudeagent.TaskListItem{
+		Subject:     draft.Subject,
+		Description: draft.Description,
+		ActiveForm:  draft.ActiveForm,
+		Owner:       draft.Owner,
+		Status:      draft.Status,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	// Blockers are added as a separate update so that the store records
+	// both ends of every edge.
+	if len(draft.BlockedBy) > 0 {
+		task, err = h.taskStore.Update(
+			ctx, listID, task.ID, claudeagent.TaskUpdate{
+				AddBlockedBy: draft.BlockedBy,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add blockers: %w",
+				err)
+		}
+
+		task, err = h.recheckBlockers(
+			ctx, listID, task.ID, draft.BlockedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h.log.Infof("Created task %s/%s: %s", listID, task.ID, task.Subject)
+
+	return task, nil
+}
+
+// editTask validates and applies an edit to a task through the task store.
+// As with createTask, added blockers are checked again once written, since a
+// concurrent edit may have made one of them wait on the task meanwhile.
+func (h *HTTPServer) editTask(ctx context.Context, listID, taskID string,
+	edit TaskEdit) (*claudeagent.TaskListItem, error) {
+
+	if edit.Subject != nil {
+		subject := strings.TrimSpace(*edit.Subject)
+		if subject == "" {
+			return nil, invalidEdit("subject cannot be empty")
+		}
+		edit.Subject = &subject
+	}
+	if edit.Status != nil && !validStatus(*edit.Status) {
+		return nil, invalidEdit("unknown status %q", *edit.Status)
+	}
+
+	tasks, err := h.loadList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	task, ok := tasks[taskID]
+	if !ok {
+		return nil, errTaskNotFound
+	}
+	for _, blocker := range edit.RemoveBlockedBy {
+		if !slices.Contains(task.BlockedBy, blocker) {
+			return nil, invalidEdit("#%s does not block #%s",
+				blocker, taskID)
+		}
+	}
+	err = checkBlockers(tasks, taskID, edit.AddBlockedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.taskStore.Update(
+		ctx, listID, taskID, claudeagent.TaskUpdate{
+			Subject:         edit.Subject,
+			Description:     edit.Description,
+			ActiveForm:      edit.ActiveForm,
+			Owner:           edit.Owner,
+			Status:          edit.Status,
+			AddBlockedBy:    edit.AddBlockedBy,
+			RemoveBlockedBy: edit.RemoveBlockedBy,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	if len(edit.AddBlockedBy) > 0 {
+		updated, err = h.recheckBlockers(
+			ctx, listID, taskID, edit.AddBlockedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h.log.Infof("Updated task %s/%s", listID, taskID)
+
+	return updated, nil
+}
+
+// recheckBlockers re-reads a list after blockers were added to a task, and
+// removes those that were deleted or that now close a cycle because of an
+// edit made concurrently. An invalid edit error is returned if a cycle had
+// to be undone.
+func (h *HTTPServer) recheckBlockers(ctx context.Context, listID,
+	taskID string, added []string) (*claudeagent.TaskListItem, error) {
+
+	tasks, err := h.loadList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	task, ok := tasks[taskID]
+	if !ok {
+		return nil, errTaskNotFound
+	}
+
+	var (
+		undo  []string
+		cycle string
+	)
+	for _, blocker := range added {
+		if !slices.Contains(task.BlockedBy, blocker) {
+			continue
+		}
+
+		if _, ok := tasks[blocker]; !ok {
+			undo = append(undo, blocker)
+			continue
+		}
+
+		if wouldCycle(tasks, taskID, blocker) {
+			undo = append(undo, blocker)
+			cycle = blocker
+		}
+	}
+	if len(undo) == 0 {
+		return &task, nil
+	}
+
+	updated, err := h.taskStore.Update(
+		ctx, listID, taskID, claudeagent.TaskUpdate{
+			RemoveBlockedBy: undo,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to undo blockers: %w", err)
+	}
+
+	if cycle != "" {
+		return nil, invalidEdit("#%s was changed to wait on #%s "+
+			"meanwhile, so it cannot block it", cycle, taskID)
+	}
+
+	return updated, nil
+}
+
+// deleteTask removes a task and every dependency edge that refers to it.
+// Edges added by a concurrent edit after the task was read are found by
+// reading the list again once it is deleted, and removed too.
+func (h *HTTPServer) deleteTask(ctx context.Context, listID,
+	taskID string) error {
+
+	tasks, err := h.loadList(ctx, listID)
+	if err != nil {
+		return err
+	}
+	task, ok := tasks[taskID]
+	if !ok {
+		return errTaskNotFound
+	}
+
+	// Detach the task first so that no other task is left blocked by, or
+	// blocking, a task that no longer exists.
+	if len(task.BlockedBy) > 0 || len(task.Blocks) > 0 {
+		_, err := h.taskStore.Update(
+			ctx, listID, taskID, claudeagent.TaskUpdate{
+				RemoveBlockedBy: task.BlockedBy,
+				RemoveBlocks:    task.Blocks,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove dependencies: %w",
+				err)
+		}
+	}
+
+	if err := h.taskStore.Delete(ctx, listID, taskID); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	tasks, err = h.loadList(ctx, listID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		var update claudeagent.TaskUpdate
+		if slices.Contains(t.BlockedBy, taskID) {
+			update.RemoveBlockedBy = []string{taskID}
+		}
+		if slices.Contains(t.Blocks, taskID) {
+			update.RemoveBlocks = []string{taskID}
+		}
+		if update.RemoveBlockedBy == nil && update.RemoveBlocks == nil {
+			continue
+		}
+
+		_, err := h.taskStore.Update(ctx, listID, t.ID, update)
+		if err != nil {
+			return fmt.Errorf("failed to remove dependencies of "+
+				"#%s: %w", t.ID, err)
+		}
+	}
+
+	h.log.Infof("Deleted task %s/%s: %s", listID, taskID, task.Subject)
+
+	return nil
+}
+
+// formString returns a trimmed form value, or nil if the field was not
+// submitted.
+func formString(r *http.Request, name string) *string {
+	if _, ok := r.PostForm[name]; !ok {
+		return nil
+	}
+
+	value := strings.TrimSpace(r.PostForm.Get(name))
+
+	return &value
+}
+
+// renderEditError renders the error page for a failed edit.
+func (h *HTTPServer) renderEditError(w http.ResponseWriter, err error) {
+	code, _ := editStatus(err)
+	if code == http.StatusInternalServerError {
+		h.log.Errorf("Task edit failed: %v", err)
+	}
+
+	h.renderError(w, err.Error(), code)
+}
+
+// handleCreateTask creates a task from the new task form, then shows it.
+func (h *HTTPServer) handleCreateTask(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+	if err := h.checkWritable(r, listID); err != nil {
+		h.renderEditError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.renderEditError(w, invalidEdit("%v", err))
+		return
+	}
+
+	draft := TaskDraft{
+		Subject:     r.PostForm.Get("subject"),
+		Description: strings.TrimSpace(r.PostForm.Get("description")),
+		Owner:       strings.TrimSpace(r.PostForm.Get("owner")),
+	}
+	task, err := h.createTask(r.Context(), listID, draft)
+	if err != nil {
+		h.renderEditError(w, err)
+		return
+	}
+
+	http.Redirect(
+		w, r, "/lists/"+listID+"/tasks/"+task.ID, http.StatusSeeOther,
+	)
+}
+
+// handleEditTask applies a change submitted from the task page, then shows
+// the task again. The form's action selects the change: a new status, new
+// fields, or a blocker to add or remove.
+func (h *HTTPServer) handleEditTask(w http.ResponseWriter, r *http.Request) {
+	listID := r.PathValue("listID")
+	taskID := r.PathValue("taskID")
+	if err := h.checkWritable(r, listID); err != nil {
+		h.renderEditError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.renderEditError(w, invalidEdit("%v", err))
+		return
+	}
+
+	var edit TaskEdit
+	switch action := r.PostForm.Get("action"); action {
+	case "status":
+		status := claudeagent.TaskListStatus(r.PostForm.Get("status"))
+		edit.Status = &status
+
+	case "fields":
+		edit.Subject = formString(r, "subject")
+		edit.Description = formString(r, "description")
+		edit.Owner = formString(r, "owner")
+
+	case "block":
+		edit.AddBlockedBy = []string{r.PostForm.Get("blocker")}
+
+	case "unblock":
+		edit.RemoveBlockedBy = []string{r.PostForm.Get("blocker")}
+
+	default:
+		h.renderEditError(w, invalidEdit("unknown action %q", action))
+		return
+	}
+
+	if _, err := h.editTask(r.Context(), listID, taskID, edit); err != nil {
+		h.renderEditError(w, err)
+		return
+	}
+
+	http.Redirect(
+		w, r, "/lists/"+listID+"/tasks/"+taskID, http.StatusSeeOther,
+	)
+}
+
+// handleDeleteTask deletes a task from the task page, then shows its list.
+func (h *HTTPServer) handleDeleteTask(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+	if err := h.checkWritable(r, listID); err != nil {
+		h.renderEditError(w, err)
+		return
+	}
+
+	err := h.deleteTask(r.Context(), listID, r.PathValue("taskID"))
+	if err != nil {
+		h.renderEditError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/lists/"+listID, http.StatusSeeOther)
+}
@@ -0,0 +1,345 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// newTestEditServer returns a test server with edits enabled, whose live
+// list s1 has a task file on disk.
+func newTestEditServer(t *testing.T, store *fakeTaskStore) (*HTTPServer,
+	*http.ServeMux) {
+
+	t.Helper()
+
+	h, mux := newTestHTTPServer(t, store)
+	h.cfg.AllowWrites = true
+
+	writeTaskFiles(t, filepath.Join(h.projectIndexer.tasksDir, "s1"), 1)
+	if err := h.projectIndexer.Rescan(); err != nil {
+		t.Fatalf("unable to rescan: %v", err)
+	}
+
+	return h, mux
+}
+
+// blockedTask returns a pending task blocked by the given tasks.
+func blockedTask(id string, blockedBy ...string) claudeagent.TaskListItem {
+	task := testTask(id)
+	task.BlockedBy = blockedBy
+
+	return task
+}
+
+// serveEdit sends an edit request and returns the response.
+func serveEdit(mux *http.ServeMux, method, path, body,
+	origin string) *httptest.ResponseRecorder {
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// TestAPIEditErrors checks the checks every edit endpoint makes before
+// touching the task store.
+func TestAPIEditErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		origin   string
+		disabled bool
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "writes disabled",
+			method:   http.MethodPost,
+			path:     "/api/v1/lists/s1/tasks",
+			body:     `{"subject":"x"}`,
+			disabled: true,
+			wantCode: http.StatusForbidden,
+			wantErr:  ErrCodeForbidden,
+		},
+		{
+			name:     "cross origin",
+			method:   http.MethodPatch,
+			path:     "/api/v1/lists/s1/tasks/1",
+			body:     `{"subject":"x"}`,
+			origin:   "https://evil.example",
+			wantCode: http.StatusForbidden,
+			wantErr:  ErrCodeForbidden,
+		},
+		{
+			name:     "same origin",
+			method:   http.MethodPatch,
+			path:     "/api/v1/lists/s1/tasks/1",
+			body:     `{"subject":"x"}`,
+			origin:   "http://example.com",
+			wantCode: http.StatusOK,
+		},
+		{
+			// s2 has no task files, so only its archive could
+			// remain.
+			name:     "ended list",
+			method:   http.MethodDelete,
+			path:     "/api/v1/lists/s2/tasks/1",
+			wantCode: http.StatusConflict,
+			wantErr:  ErrCodeConflict,
+		},
+		{
+			name:     "missing task",
+			method:   http.MethodDelete,
+			path:     "/api/v1/lists/s1/tasks/9",
+			wantCode: http.StatusNotFound,
+			wantErr:  ErrCodeNotFound,
+		},
+		{
+			name:     "unknown field",
+			method:   http.MethodPatch,
+			path:     "/api/v1/lists/s1/tasks/1",
+			body:     `{"subjet":"x"}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  ErrCodeBadRequest,
+		},
+		{
+			name:     "cycle",
+			method:   http.MethodPatch,
+			path:     "/api/v1/lists/s1/tasks/1",
+			body:     `{"addBlockedBy":["3"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  ErrCodeBadRequest,
+		},
+		{
+			name:     "self block",
+			method:   http.MethodPatch,
+			path:     "/api/v1/lists/s1/tasks/1",
+			body:     `{"addBlockedBy":["1"]}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  ErrCodeBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeTaskStore()
+			store.set(
+				"s1", testTask("1"), blockedTask("2", "1"),
+				blockedTask("3", "2"),
+			)
+
+			h, mux := newTestEditServer(t, store)
+			h.cfg.AllowWrites = !test.disabled
+
+			rec := serveEdit(
+				mux, test.method, test.path, test.body,
+				test.origin,
+			)
+			if rec.Code != test.wantCode {
+				t.Fatalf("got status %d, want %d: %s",
+					rec.Code, test.wantCode, rec.Body)
+			}
+			if test.wantErr == "" {
+				return
+			}
+
+			var apiErr APIError
+			err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			if err != nil {
+				t.Fatalf("unable to decode error: %v", err)
+			}
+			if apiErr.Error.Code != test.wantErr {
+				t.Fatalf("got code %q, want %q",
+					apiErr.Error.Code, test.wantErr)
+			}
+		})
+	}
+}
+
+// TestHandleEditTaskForm checks that the dashboard's edit form refuses
+// cross-origin submissions and applies same-origin ones.
+func TestHandleEditTaskForm(t *testing.T) {
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"))
+	_, mux := newTestEditServer(t, store)
+
+	body := "action=status&status=completed"
+	post := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(
+			http.MethodPost, "/lists/s1/tasks/1",
+			strings.NewReader(body),
+		)
+		req.Header.Set(
+			"Content-Type", "application/x-www-form-urlencoded",
+		)
+		req.Header.Set("Origin", origin)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := post("https://evil.example")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("cross-origin form: got status %d", rec.Code)
+	}
+	rec = post("http://example.com")
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("same-origin form: got status %d: %s", rec.Code,
+			rec.Body)
+	}
+
+	tasks, _ := store.List(t.Context(), "s1")
+	if tasks[0].Status != claudeagent.TaskListStatusCompleted {
+		t.Fatalf("task not completed: %+v", tasks[0])
+	}
+}
+
+// TestDeleteTaskDetachesEdges checks that deleting a task in the middle of a
+// chain leaves no task referring to it.
+func TestDeleteTaskDetachesEdges(t *testing.T) {
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"), testTask("2"), testTask("3"))
+	h, mux := newTestEditServer(t, store)
+
+	ctx := t.Context()
+	for _, edge := range [][2]string{{"2", "1"}, {"3", "2"}} {
+		_, err := h.editTask(ctx, "s1", edge[0], TaskEdit{
+			AddBlockedBy: []string{edge[1]},
+		})
+		if err != nil {
+			t.Fatalf("unable to add edge: %v", err)
+		}
+	}
+
+	rec := serveEdit(mux, http.MethodDelete, "/api/v1/lists/s1/tasks/2",
+		"", "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	assertNoEdgesTo(t, store, "2")
+}
+
+// assertNoEdgesTo fails if any task in s1 refers to taskID.
+func assertNoEdgesTo(t *testing.T, store *fakeTaskStore, taskID string) {
+	t.Helper()
+
+	tasks, _ := store.List(t.Context(), "s1")
+	for _, task := range tasks {
+		if task.ID == taskID {
+			t.Fatalf("task %s not deleted", taskID)
+		}
+		if len(task.BlockedBy) > 0 || len(task.Blocks) > 0 {
+			t.Fatalf("task %s still has edges: blockedBy %v, "+
+				"blocks %v", task.ID, task.BlockedBy,
+				task.Blocks)
+		}
+	}
+}
+
+// TestEditRace checks that edits made concurrently between an edit's
+// validation and its write cannot leave a cycle or a dangling edge behind.
+func TestEditRace(t *testing.T) {
+	t.Run("cycle", func(t *testing.T) {
+		store := newFakeTaskStore()
+		store.set("s1", testTask("1"), testTask("2"))
+		h, _ := newTestEditServer(t, store)
+		ctx := t.Context()
+
+		// While 1 is being made to wait on 2, 2 is made to wait
+		// on 1.
+		store.beforeWrite = func() {
+			_, err := store.Update(ctx, "s1", "2",
+				claudeagent.TaskUpdate{
+					AddBlockedBy: []string{"1"},
+				})
+			if err != nil {
+				t.Errorf("unable to race edit: %v", err)
+			}
+		}
+
+		_, err := h.editTask(ctx, "s1", "1", TaskEdit{
+			AddBlockedBy: []string{"2"},
+		})
+		if code, _ := editStatus(err); code != http.StatusBadRequest {
+			t.Fatalf("got %v, want an invalid edit", err)
+		}
+
+		// The concurrent edge wins, and the edit's is undone.
+		tasks, _ := store.List(ctx, "s1")
+		got := map[string][]string{}
+		for _, task := range tasks {
+			got[task.ID] = task.BlockedBy
+		}
+		if len(got["1"]) != 0 || !reflect.DeepEqual(got["2"],
+			[]string{"1"}) {
+
+			t.Fatalf("got blockers %v", got)
+		}
+	})
+
+	t.Run("deleted blocker", func(t *testing.T) {
+		store := newFakeTaskStore()
+		store.set("s1", testTask("1"), testTask("2"))
+		h, _ := newTestEditServer(t, store)
+		ctx := t.Context()
+
+		// The blocker is deleted while the new task is created.
+		store.beforeWrite = func() {
+			if err := store.Delete(ctx, "s1", "1"); err != nil {
+				t.Errorf("unable to race delete: %v", err)
+			}
+		}
+
+		task, err := h.createTask(ctx, "s1", TaskDraft{
+			Subject:   "new",
+			BlockedBy: []string{"1"},
+		})
+		if err != nil {
+			t.Fatalf("unable to create task: %v", err)
+		}
+		if len(task.BlockedBy) != 0 {
+			t.Fatalf("new task blocked by %v", task.BlockedBy)
+		}
+		assertNoEdgesTo(t, store, "1")
+	})
+
+	t.Run("edge to deleted task", func(t *testing.T) {
+		store := newFakeTaskStore()
+		store.set("s1", testTask("1"), testTask("2"))
+		h, _ := newTestEditServer(t, store)
+		ctx := t.Context()
+
+		// 2 is made to wait on 1 after 1 was read for deletion.
+		store.beforeWrite = func() {
+			_, err := store.Update(ctx, "s1", "2",
+				claudeagent.TaskUpdate{
+					AddBlockedBy: []string{"1"},
+				})
+			if err != nil {
+				t.Errorf("unable to race edit: %v", err)
+			}
+		}
+
+		if err := h.deleteTask(ctx, "s1", "1"); err != nil {
+			t.Fatalf("unable to delete task: %v", err)
+		}
+		assertNoEdgesTo(t, store, "1")
+	})
+}
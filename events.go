@@ -0,0 +1,584 @@
+package taskviewer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// Event types published on the event bus. Task store events are forwarded
+// as "task-" followed by their type, e.g. "task-updated".
+const (
+	EventSessionAppeared = "session-appeared"
+	EventSessionEnded    = "session-ended"
+	EventIndexChanged    = "project-index-changed"
+	EventInstanceStarted = "instance-started"
+	EventInstanceExited  = "instance-exited"
+)
+
+const (
+	// instancePollInterval is how often running instances are listed
+	// while anyone is subscribed to the event bus.
+	instancePollInterval = 2 * time.Second
+
+	// eventClientBuffer is the number of events buffered per subscriber
+	// before events are dropped for it.
+	eventClientBuffer = 256
+
+	// eventReplaySize is the number of recent events kept for replay to
+	// reconnecting clients.
+	eventReplaySize = 1024
+
+	// sseHeartbeatInterval is how often an idle event stream receives a
+	// comment line to keep intermediaries from closing it.
+	sseHeartbeatInterval = 15 * time.Second
+)
+
+// Event is a notification published on the event bus.
+type Event struct {
+	// ID increases by one with every event published. IDs restart from
+	// one when the daemon restarts.
+	ID uint64 `json:"id"`
+
+	Type   string    `json:"type"`
+	ListID string    `json:"listId,omitempty"`
+	Time   time.Time `json:"time"`
+
+	// Task is the task store event behind a task-* event.
+	Task *claudeagent.TaskEvent `json:"task,omitempty"`
+
+	// Session describes the task list of a session-appeared event.
+	Session *ActiveTaskList `json:"session,omitempty"`
+
+	// Projects holds the changed project directories of a
+	// project-index-changed event.
+	Projects []string `json:"projects,omitempty"`
+
+	// Instance is the process behind an instance-* event.
+	Instance *ClaudeInstance `json:"instance,omitempty"`
+
+	// Stall describes the session of a session-stalled or
+	// session-recovered event.
+	Stall *StallInfo `json:"stall,omitempty"`
+}
+
+// EventBusStats reports the activity of the event bus.
+type EventBusStats struct {
+	// Subscribers is the number of open subscriptions.
+	Subscribers int `json:"subscribers"`
+
+	// Upstreams is the number of task store subscriptions held.
+	Upstreams int `json:"upstreams"`
+
+	// Published is the number of events published since startup.
+	Published uint64 `json:"published"`
+
+	// Dropped is the number of events not delivered to a subscriber
+	// because its buffer was full.
+	Dropped uint64 `json:"dropped"`
+}
+
+// listFollow is the single upstream task store subscription of a list,
+// shared by every subscriber interested in it.
+type listFollow struct {
+	cancel context.CancelFunc
+
+	// active is true while the list has task files on disk.
+	active bool
+
+	// viewers counts the subscriptions filtered to this list.
+	viewers int
+}
+
+// EventBus multiplexes everything that changes in the viewer into a single
+// stream: task events from every task list, task lists appearing and ending,
+// project index changes, and Claude instances starting and exiting.
+//
+// The bus holds exactly one task store subscription per list no matter how
+// many clients watch it, and fans events out to subscribers through
+// per-subscriber buffers. Recent events are kept in a ring so that clients
+// can resume where they left off after reconnecting.
+type EventBus struct {
+	taskStore       claudeagent.TaskStore
+	projectIndexer  *ProjectIndexer
+	instanceTracker *InstanceTracker
+
+	// mu guards everything below.
+	mu   sync.Mutex
+	subs map[*EventSubscription]struct{}
+
+	// lists holds the upstream subscriptions, keyed by list ID.
+	lists map[string]*listFollow
+
+	// seq is the ID of the last published event, and ring holds the
+	// most recent events in ID order.
+	seq  uint64
+	ring []Event
+
+	dropped uint64
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	log btclog.Logger
+}
+
+// NewEventBus creates an event bus over the given sources.
+func NewEventBus(taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, instanceTracker *InstanceTracker,
+	log btclog.Logger) *EventBus {
+
+	return &EventBus{
+		taskStore:       taskStore,
+		projectIndexer:  projectIndexer,
+		instanceTracker: instanceTracker,
+		subs:            make(map[*EventSubscription]struct{}),
+		lists:           make(map[string]*listFollow),
+		ring:            make([]Event, 0, eventReplaySize),
+		quit:            make(chan struct{}),
+		log:             log,
+	}
+}
+
+// Start begins following the task lists, the project index and the running
+// instances. This method is idempotent.
+func (b *EventBus) Start() error {
+	if !atomic.CompareAndSwapUint32(&b.started, 0, 1) {
+		return nil
+	}
+
+	// Subscribe to index changes before listing the active lists so that
+	// no list can appear in between unnoticed.
+	changes, cancel := b.projectIndexer.SubscribeChanges()
+
+	activeLists, err := b.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		b.log.Warnf("Event bus: unable to list task lists: %v", err)
+	}
+
+	b.mu.Lock()
+	for _, al := range activeLists {
+		b.followLocked(al.SessionID).active = true
+	}
+	b.mu.Unlock()
+
+	b.wg.Add(2)
+	go b.indexLoop(changes, cancel)
+	go b.instanceLoop()
+
+	return nil
+}
+
+// Stop cancels every upstream subscription and closes all subscriber
+// channels. This method is idempotent.
+func (b *EventBus) Stop() error {
+	if !atomic.CompareAndSwapUint32(&b.stopped, 0, 1) {
+		return nil
+	}
+
+	close(b.quit)
+
+	b.mu.Lock()
+	for _, follow := range b.lists {
+		follow.cancel()
+	}
+	for sub := range b.subs {
+		close(sub.ch)
+	}
+	b.subs = make(map[*EventSubscription]struct{})
+	b.mu.Unlock()
+
+	b.wg.Wait()
+
+	return nil
+}
+
+// EventSubscription is a subscriber's view of the event bus.
+type EventSubscription struct {
+	bus *EventBus
+
+	// listID restricts the subscription to the events of one list. It
+	// is empty for subscriptions to every event.
+	listID string
+
+	ch      chan Event
+	dropped uint64
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription is closed or the bus stops.
+func (s *EventSubscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events that were not delivered because the
+// subscriber fell behind.
+func (s *EventSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close cancels the subscription. It is safe to call more than once.
+func (s *EventSubscription) Close() {
+	b := s.bus
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+
+	if s.listID == "" {
+		return
+	}
+
+	// Drop the upstream subscription once nobody needs it.
+	follow, ok := b.lists[s.listID]
+	if !ok {
+		return
+	}
+	follow.viewers--
+	if follow.viewers <= 0 && !follow.active {
+		follow.cancel()
+		delete(b.lists, s.listID)
+	}
+}
+
+// matches reports whether an event is delivered to the subscription.
+func (s *EventSubscription) matches(ev *Event) bool {
+	return s.listID == "" || s.listID == ev.ListID
+}
+
+// Subscribe opens a subscription to every event published from now on, or
+// only to those of one list if listID is not empty.
+//
+// If lastEventID is non-zero, the buffered events published after it are
+// returned for replay. The returned flag is false if events after
+// lastEventID have already left the replay buffer, in which case the client
+// should reload its state.
+func (b *EventBus) Subscribe(listID string,
+	lastEventID uint64) (*EventSubscription, []Event, bool) {
+
+	sub := &EventSubscription{
+		bus:    b,
+		listID: listID,
+		ch:     make(chan Event, eventClientBuffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-b.quit:
+		close(sub.ch)
+		return sub, nil, true
+
+	default:
+	}
+
+	// Registering under the same lock as the replay snapshot guarantees
+	// that every event is either replayed or delivered, exactly once.
+	b.subs[sub] = struct{}{}
+
+	if listID != "" {
+		b.followLocked(listID).viewers++
+	}
+
+	if lastEventID == 0 {
+		return sub, nil, true
+	}
+
+	// An ID from the future means the daemon restarted since the client
+	// last connected.
+	complete := lastEventID <= b.seq
+	if len(b.ring) > 0 && lastEventID+1 < b.ring[0].ID {
+		complete = false
+	}
+
+	var replay []Event
+	for i := range b.ring {
+		ev := &b.ring[i]
+		if ev.ID > lastEventID && sub.matches(ev) {
+			replay = append(replay, *ev)
+		}
+	}
+
+	return sub, replay, complete
+}
+
+// Stats returns the activity of the bus.
+func (b *EventBus) Stats() EventBusStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return EventBusStats{
+		Subscribers: len(b.subs),
+		Upstreams:   len(b.lists),
+		Published:   b.seq,
+		Dropped:     b.dropped,
+	}
+}
+
+// hasSubscribers reports whether anyone is subscribed.
+func (b *EventBus) hasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subs) > 0
+}
+
+// publish assigns an event its ID, records it for replay and delivers it to
+// every matching subscriber.
+func (b *EventBus) publish(ev Event) {
+	ev.Time = time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev.ID = b.seq
+
+	if len(b.ring) == eventReplaySize {
+		copy(b.ring, b.ring[1:])
+		b.ring = b.ring[:eventReplaySize-1]
+	}
+	b.ring = append(b.ring, ev)
+
+	for sub := range b.subs {
+		if !sub.matches(&ev) {
+			continue
+		}
+
+		select {
+		case sub.ch <- ev:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+			b.dropped++
+		}
+	}
+}
+
+// followLocked returns the upstream subscription of a list, starting it if
+// needed. The caller must hold mu.
+func (b *EventBus) followLocked(listID string) *listFollow {
+	if follow, ok := b.lists[listID]; ok {
+		return follow
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	follow := &listFollow{cancel: cancel}
+	b.lists[listID] = follow
+
+	// Stop cancels subscriptions under mu, so checking quit here ensures
+	// no subscription is started after shutdown began.
+	select {
+	case <-b.quit:
+		cancel()
+		return follow
+
+	default:
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		b.forwardTaskEvents(ctx, listID)
+
+		// If the subscription ended on its own, forget it so that the
+		// list can be followed again.
+		if ctx.Err() == nil {
+			b.mu.Lock()
+			if b.lists[listID] == follow {
+				delete(b.lists, listID)
+			}
+			b.mu.Unlock()
+		}
+		cancel()
+	}()
+
+	return follow
+}
+
+// setActive records whether a list has task files on disk, following it
+// while it does or while anyone is watching it.
+func (b *EventBus) setActive(listID string, active bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if active {
+		b.followLocked(listID).active = true
+		return
+	}
+
+	follow, ok := b.lists[listID]
+	if !ok {
+		return
+	}
+	follow.active = false
+	if follow.viewers <= 0 {
+		follow.cancel()
+		delete(b.lists, listID)
+	}
+}
+
+// forwardTaskEvents publishes the task events of a list until the context is
+// cancelled or the subscription ends.
+func (b *EventBus) forwardTaskEvents(ctx context.Context, listID string) {
+	events, err := b.taskStore.Subscribe(ctx, listID)
+	if err != nil {
+		b.log.Debugf("Event bus: unable to subscribe to %s: %v",
+			listID, err)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			b.publish(Event{
+				Type:   "task-" + string(event.Type),
+				ListID: listID,
+				Task:   &event,
+			})
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// indexLoop turns project index changes into events and keeps the task list
+// subscriptions in sync with the active lists.
+func (b *EventBus) indexLoop(changes <-chan IndexChange, cancel func()) {
+	defer b.wg.Done()
+	defer cancel()
+
+	for {
+		select {
+		case change := <-changes:
+			b.applyIndexChange(change)
+
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// applyIndexChange publishes the events for a single index change.
+func (b *EventBus) applyIndexChange(change IndexChange) {
+	if len(change.ListsAppeared) > 0 {
+		activeLists, _ := b.projectIndexer.ListActiveTaskLists()
+		byID := make(map[string]ActiveTaskList, len(activeLists))
+		for _, al := range activeLists {
+			byID[al.SessionID] = al
+		}
+
+		for _, listID := range change.ListsAppeared {
+			b.setActive(listID, true)
+
+			session, ok := byID[listID]
+			if !ok {
+				session = ActiveTaskList{SessionID: listID}
+			}
+
+			b.publish(Event{
+				Type:    EventSessionAppeared,
+				ListID:  listID,
+				Session: &session,
+			})
+		}
+	}
+
+	for _, listID := range change.ListsEnded {
+		b.setActive(listID, false)
+
+		b.publish(Event{
+			Type:   EventSessionEnded,
+			ListID: listID,
+		})
+	}
+
+	if len(change.Projects) > 0 {
+		b.publish(Event{
+			Type:     EventIndexChanged,
+			Projects: change.Projects,
+		})
+	}
+}
+
+// instanceLoop polls the running instances while anyone is subscribed and
+// publishes the instances that started or exited between polls.
+func (b *EventBus) instanceLoop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(instancePollInterval)
+	defer ticker.Stop()
+
+	// known is nil until a baseline is taken, so instances that were
+	// already running when the first client subscribed are not reported
+	// as started.
+	var known map[int]ClaudeInstance
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.quit:
+			return
+		}
+
+		if !b.hasSubscribers() {
+			known = nil
+			continue
+		}
+
+		instances, err := b.instanceTracker.ListRunningInstances()
+		if err != nil {
+			continue
+		}
+
+		current := make(map[int]ClaudeInstance, len(instances))
+		for _, instance := range instances {
+			current[instance.PID] = instance
+		}
+
+		if known != nil {
+			for pid, instance := range current {
+				if _, ok := known[pid]; ok {
+					continue
+				}
+
+				b.publish(Event{
+					Type:     EventInstanceStarted,
+					ListID:   instance.SessionID,
+					Instance: &instance,
+				})
+			}
+
+			for pid, instance := range known {
+				if _, ok := current[pid]; ok {
+					continue
+				}
+
+				b.publish(Event{
+					Type:     EventInstanceExited,
+					ListID:   instance.SessionID,
+					Instance: &instance,
+				})
+			}
+		}
+
+		known = current
+	}
+}
@@ -0,0 +1,353 @@
+package taskviewer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// newTestEventBus returns an event bus over a fake task store and an empty
+// claude directory. The bus is stopped when the test ends.
+func newTestEventBus(t *testing.T) (*EventBus, *fakeTaskStore) {
+	t.Helper()
+
+	root := t.TempDir()
+	projectIndexer := NewProjectIndexer(
+		filepath.Join(root, "projects"), filepath.Join(root, "tasks"),
+	)
+
+	store := newFakeTaskStore()
+	bus := NewEventBus(
+		store, projectIndexer, NewInstanceTracker(projectIndexer, nil),
+		btclog.Disabled,
+	)
+	t.Cleanup(func() {
+		bus.Stop()
+	})
+
+	return bus, store
+}
+
+// nextEvent waits for the next event on a subscription.
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatalf("subscription closed")
+		}
+		return ev
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+// TestEventBusIndexChanges checks that index changes are published to every
+// subscriber, and that a list's task events are forwarded from when it
+// appears until it ends.
+func TestEventBusIndexChanges(t *testing.T) {
+	bus, store := newTestEventBus(t)
+
+	first, _, _ := bus.Subscribe("", 0)
+	defer first.Close()
+	second, _, _ := bus.Subscribe("", 0)
+	defer second.Close()
+
+	bus.applyIndexChange(IndexChange{
+		Projects:      []string{"-a-app"},
+		ListsAppeared: []string{"s1"},
+	})
+	for _, sub := range []*EventSubscription{first, second} {
+		ev := nextEvent(t, sub.Events())
+		if ev.Type != EventSessionAppeared || ev.ListID != "s1" {
+			t.Fatalf("got %s for %q, want %s", ev.Type, ev.ListID,
+				EventSessionAppeared)
+		}
+
+		ev = nextEvent(t, sub.Events())
+		if ev.Type != EventIndexChanged || len(ev.Projects) != 1 {
+			t.Fatalf("got %s %v, want %s", ev.Type, ev.Projects,
+				EventIndexChanged)
+		}
+	}
+
+	eventually(t, "list subscription", func() bool {
+		return store.subscribers("s1") == 1
+	})
+	store.send("s1", claudeagent.TaskEvent{
+		Type: "updated", ListID: "s1", TaskID: "1",
+	})
+	ev := nextEvent(t, first.Events())
+	if ev.Type != "task-updated" || ev.Task.TaskID != "1" {
+		t.Fatalf("got %s for task %v, want task-updated", ev.Type,
+			ev.Task)
+	}
+
+	bus.applyIndexChange(IndexChange{ListsEnded: []string{"s1"}})
+	ev = nextEvent(t, first.Events())
+	if ev.Type != EventSessionEnded || ev.ListID != "s1" {
+		t.Fatalf("got %s for %q, want %s", ev.Type, ev.ListID,
+			EventSessionEnded)
+	}
+	eventually(t, "list unsubscribe", func() bool {
+		return store.subscribers("s1") == 0
+	})
+}
+
+// TestEventBusReplay checks that a subscriber resuming from a Last-Event-ID
+// is replayed exactly the events it missed, and is told to reload once they
+// have left the replay ring.
+func TestEventBusReplay(t *testing.T) {
+	bus, _ := newTestEventBus(t)
+
+	for i := 0; i < 10; i++ {
+		listID := "s1"
+		if i%2 == 1 {
+			listID = "s2"
+		}
+		bus.publish(Event{Type: EventSessionEnded, ListID: listID})
+	}
+
+	tests := []struct {
+		name         string
+		listID       string
+		lastEventID  uint64
+		wantIDs      []uint64
+		wantComplete bool
+	}{
+		{
+			name:         "fresh",
+			wantComplete: true,
+		},
+		{
+			name:         "resume",
+			lastEventID:  7,
+			wantIDs:      []uint64{8, 9, 10},
+			wantComplete: true,
+		},
+		{
+			name:         "resume one list",
+			listID:       "s1",
+			lastEventID:  4,
+			wantIDs:      []uint64{5, 7, 9},
+			wantComplete: true,
+		},
+		{
+			name:         "up to date",
+			lastEventID:  10,
+			wantComplete: true,
+		},
+		{
+			// The daemon restarted since the client connected.
+			name:        "future",
+			lastEventID: 11,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sub, replay, complete := bus.Subscribe(
+				test.listID, test.lastEventID,
+			)
+			defer sub.Close()
+
+			if complete != test.wantComplete {
+				t.Fatalf("complete = %v, want %v", complete,
+					test.wantComplete)
+			}
+
+			var ids []uint64
+			for _, ev := range replay {
+				ids = append(ids, ev.ID)
+			}
+			if !reflect.DeepEqual(ids, test.wantIDs) {
+				t.Fatalf("replayed %v, want %v", ids,
+					test.wantIDs)
+			}
+		})
+	}
+
+	// Push the first events out of the ring.
+	for i := 0; i < eventReplaySize; i++ {
+		bus.publish(Event{Type: EventIndexChanged})
+	}
+
+	sub, replay, complete := bus.Subscribe("", 5)
+	defer sub.Close()
+	if complete {
+		t.Fatalf("resume past the ring reported complete")
+	}
+	if len(replay) != eventReplaySize {
+		t.Fatalf("replayed %d events, want the %d buffered",
+			len(replay), eventReplaySize)
+	}
+	if replay[0].ID != 11 {
+		t.Fatalf("replay starts at %d, want 11", replay[0].ID)
+	}
+
+	// The oldest buffered event is still a complete resume point.
+	sub, _, complete = bus.Subscribe("", 10)
+	defer sub.Close()
+	if !complete {
+		t.Fatalf("resume from the ring's start reported incomplete")
+	}
+}
+
+// TestEventBusSlowSubscriber checks that a subscriber that stops reading
+// loses, and is told the number of, the events beyond its buffer without
+// holding up anyone else.
+func TestEventBusSlowSubscriber(t *testing.T) {
+	bus, _ := newTestEventBus(t)
+
+	slow, _, _ := bus.Subscribe("", 0)
+	defer slow.Close()
+	fast, _, _ := bus.Subscribe("", 0)
+	defer fast.Close()
+
+	// The fast subscriber keeps up by draining its buffer whenever it
+	// fills, while the slow one never reads.
+	const total = 2*eventClientBuffer + 10
+	received := 0
+	for i := 0; i < total; i++ {
+		bus.publish(Event{Type: EventIndexChanged})
+
+		if len(fast.Events()) == eventClientBuffer || i == total-1 {
+			for len(fast.Events()) > 0 {
+				<-fast.Events()
+				received++
+			}
+		}
+	}
+	if received != total || fast.Dropped() != 0 {
+		t.Fatalf("fast subscriber got %d events and dropped %d, "+
+			"want %d and 0", received, fast.Dropped(), total)
+	}
+
+	if n := len(slow.Events()); n != eventClientBuffer {
+		t.Fatalf("slow subscriber buffered %d events, want %d", n,
+			eventClientBuffer)
+	}
+	wantDropped := uint64(total - eventClientBuffer)
+	if slow.Dropped() != wantDropped {
+		t.Fatalf("slow subscriber dropped %d, want %d",
+			slow.Dropped(), wantDropped)
+	}
+	if stats := bus.Stats(); stats.Dropped != wantDropped ||
+		stats.Published != total {
+
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// TestEventBusUpstreamRelease checks that a list is followed once however
+// many clients watch it, and that the upstream subscription is released
+// when the last one leaves unless the list is still active.
+func TestEventBusUpstreamRelease(t *testing.T) {
+	bus, store := newTestEventBus(t)
+
+	first, _, _ := bus.Subscribe("s1", 0)
+	second, _, _ := bus.Subscribe("s1", 0)
+	eventually(t, "list subscription", func() bool {
+		return store.subscribers("s1") == 1
+	})
+	if n := bus.Stats().Upstreams; n != 1 {
+		t.Fatalf("got %d upstreams, want 1", n)
+	}
+
+	// Only subscribers to the list, or to everything, see its events.
+	other, _, _ := bus.Subscribe("s2", 0)
+	defer other.Close()
+	bus.publish(Event{Type: EventSessionEnded, ListID: "s1"})
+	if len(other.Events()) != 0 {
+		t.Fatalf("event delivered to another list's subscriber")
+	}
+
+	first.Close()
+	first.Close()
+	if n := store.subscribers("s1"); n != 1 {
+		t.Fatalf("upstream released with a viewer left")
+	}
+
+	second.Close()
+	eventually(t, "upstream release", func() bool {
+		return store.subscribers("s1") == 0
+	})
+
+	// An active list stays followed after its last viewer leaves.
+	bus.setActive("s3", true)
+	viewer, _, _ := bus.Subscribe("s3", 0)
+	viewer.Close()
+	if _, ok := bus.lists["s3"]; !ok {
+		t.Fatalf("active list unfollowed when its viewer left")
+	}
+	bus.setActive("s3", false)
+	eventually(t, "inactive list release", func() bool {
+		return store.subscribers("s3") == 0
+	})
+}
+
+// TestEventBusStop checks that stopping the bus closes every subscription,
+// including ones made afterwards.
+func TestEventBusStop(t *testing.T) {
+	bus, _ := newTestEventBus(t)
+
+	sub, _, _ := bus.Subscribe("", 0)
+	if err := bus.Stop(); err != nil {
+		t.Fatalf("unable to stop: %v", err)
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Fatalf("subscription still open after stop")
+	}
+
+	// Closing after the bus closed the channel must not panic.
+	sub.Close()
+
+	late, _, _ := bus.Subscribe("", 0)
+	if _, ok := <-late.Events(); ok {
+		t.Fatalf("subscription made after stop is open")
+	}
+}
+
+// TestListEventsUnknownList checks that the list event stream refuses
+// invalid and unknown list IDs without following them.
+func TestListEventsUnknownList(t *testing.T) {
+	store := newFakeTaskStore()
+	h, mux := newTestHTTPServer(t, store)
+
+	for _, listID := range []string{"nope", "%2e%2e"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(
+			http.MethodGet, "/api/lists/"+listID+"/events", nil,
+		))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: got status %d, want %d", listID,
+				rec.Code, http.StatusNotFound)
+		}
+	}
+
+	if n := h.eventBus.Stats().Upstreams; n != 0 {
+		t.Fatalf("unknown lists left %d upstreams", n)
+	}
+
+	// A live list streams until the client goes away.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(
+		http.MethodGet, "/api/lists/s1/events", nil,
+	).WithContext(ctx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("live list: got status %d, want %d", rec.Code,
+			http.StatusOK)
+	}
+}
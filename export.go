@@ -0,0 +1,645 @@
+package taskviewer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// ExportMarkdown exports a Markdown checklist.
+	ExportMarkdown = "markdown"
+
+	// ExportCSV exports one CSV row per task.
+	ExportCSV = "csv"
+
+	// ExportJSON exports the tasks as JSON.
+	ExportJSON = "json"
+
+	// ExportDOT exports the dependency graph in Graphviz DOT.
+	ExportDOT = "dot"
+
+	// ExportMermaid exports the dependency graph as a Mermaid
+	// flowchart.
+	ExportMermaid = "mermaid"
+)
+
+// exportFormat describes how to write one export format.
+type exportFormat struct {
+	ext         string
+	contentType string
+	write       func(w io.Writer, doc *exportDoc) error
+}
+
+// exportFormats maps each format name to its writer. The text formats are
+// served as plain text so that browsers show them inline, ready to copy.
+var exportFormats = map[string]exportFormat{
+	ExportMarkdown: {
+		ext:         "md",
+		contentType: "text/plain; charset=utf-8",
+		write:       writeMarkdownExport,
+	},
+	ExportCSV: {
+		ext:         "csv",
+		contentType: "text/csv; charset=utf-8",
+		write:       writeCSVExport,
+	},
+	ExportJSON: {
+		ext:         "json",
+		contentType: "application/json",
+		write:       writeJSONExport,
+	},
+	ExportDOT: {
+		ext:         "dot",
+		contentType: "text/plain; charset=utf-8",
+		write:       writeDOTExport,
+	},
+	ExportMermaid: {
+		ext:         "mmd",
+		contentType: "text/plain; charset=utf-8",
+		write:       writeMermaidExport,
+	},
+}
+
+// ExportList is a task list as it appears in an export.
+type ExportList struct {
+	SessionID   string                     `json:"sessionId"`
+	ProjectName string                     `json:"projectName,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Branch      string                     `json:"branch,omitempty"`
+	Tasks       []claudeagent.TaskListItem `json:"tasks"`
+
+	// Graph is the dependency analysis of the whole list, which may
+	// hold more tasks than the filter let through.
+	Graph *GraphAnalysis `json:"-"`
+
+	// completed holds the completed tasks of the whole list.
+	completed map[string]bool
+}
+
+// exportDoc is a set of task lists to export.
+type exportDoc struct {
+	// Title heads the Markdown export.
+	Title string
+
+	// Name is the base of the download file name.
+	Name string
+
+	// Single is true if the export is of a single list, which drops
+	// the per-list headings and the session from node IDs.
+	Single bool
+
+	Lists []ExportList
+}
+
+// newExportList builds the export of a list, keeping the tasks that pass
+// the filter.
+func newExportList(sessionID string, tasks []claudeagent.TaskListItem,
+	filter string) ExportList {
+
+	list := ExportList{
+		SessionID: sessionID,
+		Tasks:     filterTasks(tasks, filter),
+		Graph:     AnalyzeGraph(tasks),
+		completed: make(map[string]bool),
+	}
+	for _, task := range tasks {
+		if task.Status == claudeagent.TaskListStatusCompleted {
+			list.completed[task.ID] = true
+		}
+	}
+
+	return list
+}
+
+// title returns the heading of a list in a multi-list export.
+func (l *ExportList) title() string {
+	title := l.Summary
+	if title == "" {
+		title = "Session " + l.SessionID
+	}
+	if l.ProjectName != "" {
+		title = l.ProjectName + ": " + title
+	}
+
+	return title
+}
+
+// counts returns the number of completed tasks and of all tasks in the
+// export of a list.
+func (l *ExportList) counts() (int, int) {
+	done := 0
+	for _, task := range l.Tasks {
+		if task.Status == claudeagent.TaskListStatusCompleted {
+			done++
+		}
+	}
+
+	return done, len(l.Tasks)
+}
+
+// exported returns the set of task IDs in the export of a list, so that
+// edges to filtered out tasks can be left out of the graph formats.
+func (l *ExportList) exported() map[string]bool {
+	ids := make(map[string]bool, len(l.Tasks))
+	for _, task := range l.Tasks {
+		ids[task.ID] = true
+	}
+
+	return ids
+}
+
+// markdownEscaper escapes the characters that would turn a task subject into
+// Markdown markup.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "\n", " ",
+)
+
+// markdownID formats a task ID as a code span, so that GitHub does not
+// link "#3" to an issue.
+func markdownID(id string) string {
+	return "`#" + id + "`"
+}
+
+// markdownIDs formats a list of task IDs, joined by sep.
+func markdownIDs(ids []string, sep string) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = markdownID(id)
+	}
+
+	return strings.Join(parts, sep)
+}
+
+// writeMarkdownExport writes a Markdown checklist of each list. Every task
+// notes the open tasks it is blocked by and the completed tasks it came
+// after, and each list ends with its critical path, cycles and missing
+// blockers.
+func writeMarkdownExport(w io.Writer, doc *exportDoc) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", markdownEscaper.Replace(doc.Title))
+	if len(doc.Lists) == 0 {
+		b.WriteString("\n_No tasks._\n")
+	}
+
+	for _, list := range doc.Lists {
+		b.WriteString("\n")
+		if !doc.Single {
+			fmt.Fprintf(&b, "## %s\n\n",
+				markdownEscaper.Replace(list.title()))
+		}
+
+		done, total := list.counts()
+		if total == 0 {
+			b.WriteString("_No tasks._\n")
+			continue
+		}
+		fmt.Fprintf(&b, "_%d of %d tasks completed._\n\n", done, total)
+
+		for _, task := range list.Tasks {
+			check := " "
+			if task.Status == claudeagent.TaskListStatusCompleted {
+				check = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s %s", check,
+				markdownID(task.ID),
+				markdownEscaper.Replace(task.Subject))
+
+			var notes []string
+			if task.Status == claudeagent.TaskListStatusInProgress {
+				notes = append(notes, "in progress")
+			}
+			if task.Owner != "" {
+				notes = append(notes, "@"+task.Owner)
+			}
+			if len(notes) > 0 {
+				fmt.Fprintf(&b, " _(%s)_",
+					markdownEscaper.Replace(
+						strings.Join(notes, ", "),
+					))
+			}
+
+			var open, satisfied []string
+			for _, id := range list.Graph.Blockers(task.ID) {
+				if list.completed[id] {
+					satisfied = append(satisfied, id)
+					continue
+				}
+				open = append(open, id)
+			}
+			if len(open) > 0 {
+				fmt.Fprintf(&b, " — blocked by %s",
+					markdownIDs(open, ", "))
+			}
+			if len(satisfied) > 0 {
+				sep := " — "
+				if len(open) > 0 {
+					sep = "; "
+				}
+				fmt.Fprintf(&b, "%safter %s", sep,
+					markdownIDs(satisfied, ", "))
+			}
+			b.WriteString("\n")
+		}
+
+		if path := list.Graph.CriticalPath; len(path) > 0 {
+			fmt.Fprintf(&b, "\nCritical path: %s\n",
+				markdownIDs(path, " → "))
+		}
+		for _, cycle := range list.Graph.Cycles {
+			fmt.Fprintf(&b, "\n> **Dependency cycle** between "+
+				"%s.\n", markdownIDs(cycle, ", "))
+		}
+		for _, ref := range list.Graph.Dangling {
+			fmt.Fprintf(&b, "\n> **Missing task:** %s refers to "+
+				"%s, which does not exist.\n",
+				markdownID(ref.TaskID), markdownID(ref.Missing))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCSVExport writes one row per task, with the list's session and
+// project on every row so that multi-list exports can be sorted and
+// filtered in a spreadsheet.
+func writeCSVExport(w io.Writer, doc *exportDoc) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"session_id", "project", "task_id", "subject", "status",
+		"owner", "active_form", "blocked_by", "blocks", "blocked",
+		"ready", "description",
+	})
+
+	for _, list := range doc.Lists {
+		for _, task := range list.Tasks {
+			cw.Write([]string{
+				list.SessionID, list.ProjectName, task.ID,
+				task.Subject, string(task.Status), task.Owner,
+				task.ActiveForm,
+				strings.Join(task.BlockedBy, " "),
+				strings.Join(task.Blocks, " "),
+				fmt.Sprint(list.Graph.IsBlocked(task.ID)),
+				fmt.Sprint(list.Graph.IsReady(task.ID)),
+				task.Description,
+			})
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSONExport writes the tasks of a single list as the raw array the
+// task store holds, and multiple lists as an array of ExportList.
+func writeJSONExport(w io.Writer, doc *exportDoc) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if doc.Single && len(doc.Lists) == 1 {
+		return enc.Encode(doc.Lists[0].Tasks)
+	}
+
+	lists := doc.Lists
+	if lists == nil {
+		lists = []ExportList{}
+	}
+
+	return enc.Encode(lists)
+}
+
+// dotEscaper escapes a string for a double quoted DOT ID.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotFill is the fill color of a task in the DOT export, matching the
+// status colors of the board.
+var dotFill = map[claudeagent.TaskListStatus]string{
+	claudeagent.TaskListStatusPending:    "#f6efd9",
+	claudeagent.TaskListStatusInProgress: "#e8f2ec",
+	claudeagent.TaskListStatusCompleted:  "#ecebe6",
+}
+
+// dotNodeID returns the escaped ID of a task's node in the DOT export.
+// Multi-list exports prefix the session, since task IDs are only unique
+// within a list.
+func dotNodeID(doc *exportDoc, list *ExportList, taskID string) string {
+	if doc.Single {
+		return dotEscaper.Replace(taskID)
+	}
+
+	return dotEscaper.Replace(list.SessionID + "/" + taskID)
+}
+
+// writeDOTExport writes the dependency graph in Graphviz DOT, with an edge
+// from each blocker to the task it blocks. Multi-list exports draw each
+// list in its own cluster.
+func writeDOTExport(w io.Writer, doc *exportDoc) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph \"%s\" {\n", dotEscaper.Replace(doc.Title))
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=\"rounded,filled\", " +
+		"fontname=\"Helvetica\"];\n")
+
+	for i := range doc.Lists {
+		list := &doc.Lists[i]
+		indent := "\t"
+		if !doc.Single {
+			fmt.Fprintf(&b, "\n\tsubgraph \"cluster_%d\" {\n", i)
+			fmt.Fprintf(&b, "\t\tlabel=\"%s\";\n",
+				dotEscaper.Replace(list.title()))
+			indent = "\t\t"
+		}
+
+		for _, task := range list.Tasks {
+			attrs := fmt.Sprintf(
+				"label=\"#%s %s\", fillcolor=\"%s\"",
+				dotEscaper.Replace(task.ID),
+				dotEscaper.Replace(task.Subject),
+				dotFill[task.Status])
+			switch {
+			case list.Graph.InCycle(task.ID):
+				attrs += ", color=\"#b04040\", penwidth=2"
+
+			case list.Graph.OnCriticalPath(task.ID):
+				attrs += ", penwidth=2"
+
+			case list.Graph.IsBlocked(task.ID):
+				attrs += ", color=\"#7a5252\""
+			}
+			fmt.Fprintf(&b, "%s\"%s\" [%s];\n", indent,
+				dotNodeID(doc, list, task.ID), attrs)
+		}
+
+		if !doc.Single {
+			b.WriteString("\t}\n")
+		}
+	}
+
+	for i := range doc.Lists {
+		list := &doc.Lists[i]
+		exported := list.exported()
+		for _, task := range list.Tasks {
+			for _, id := range list.Graph.Blockers(task.ID) {
+				if !exported[id] {
+					continue
+				}
+
+				attrs := ""
+				switch {
+				case list.Graph.CriticalEdge(id, task.ID):
+					attrs = " [penwidth=2]"
+
+				case list.completed[id]:
+					attrs = " [style=dashed]"
+				}
+				fmt.Fprintf(&b, "\t\"%s\" -> \"%s\"%s;\n",
+					dotNodeID(doc, list, id),
+					dotNodeID(doc, list, task.ID), attrs)
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscaper escapes text for a quoted Mermaid label, using Mermaid's
+// entity codes for the characters it would otherwise interpret. A "#"
+// followed by a space, as in the task ID prefix, is not an entity code and
+// needs no escaping.
+var mermaidEscaper = strings.NewReplacer(
+	`"`, "#quot;", "#", "#35;", "<", "#lt;", ">", "#gt;", "\n", " ",
+)
+
+// writeMermaidExport writes the dependency graph as a Mermaid flowchart.
+// Mermaid node IDs must be plain identifiers, so tasks are numbered in
+// order of appearance and labelled with their task ID.
+func writeMermaidExport(w io.Writer, doc *exportDoc) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	nodes := make([]map[string]string, len(doc.Lists))
+	next := 0
+	for i := range doc.Lists {
+		list := &doc.Lists[i]
+		nodes[i] = make(map[string]string, len(list.Tasks))
+
+		indent := "    "
+		if !doc.Single {
+			fmt.Fprintf(&b, "    subgraph l%d[\"%s\"]\n", i,
+				mermaidEscaper.Replace(list.title()))
+			indent = "        "
+		}
+
+		for _, task := range list.Tasks {
+			node := fmt.Sprintf("t%d", next)
+			next++
+			nodes[i][task.ID] = node
+
+			class := strings.ReplaceAll(
+				string(task.Status), "_", "",
+			)
+			switch {
+			case list.Graph.InCycle(task.ID):
+				class = "cyclic"
+
+			case list.Graph.IsBlocked(task.ID):
+				class = "blocked"
+			}
+			fmt.Fprintf(&b, "%s%s[\"#%s %s\"]:::%s\n", indent, node,
+				mermaidEscaper.Replace(task.ID),
+				mermaidEscaper.Replace(task.Subject), class)
+		}
+
+		if !doc.Single {
+			b.WriteString("    end\n")
+		}
+	}
+
+	for i := range doc.Lists {
+		list := &doc.Lists[i]
+		for _, task := range list.Tasks {
+			for _, id := range list.Graph.Blockers(task.ID) {
+				from, ok := nodes[i][id]
+				if !ok {
+					continue
+				}
+
+				arrow := "-->"
+				switch {
+				case list.Graph.CriticalEdge(id, task.ID):
+					arrow = "==>"
+
+				case list.completed[id]:
+					arrow = "-.->"
+				}
+				fmt.Fprintf(&b, "    %s %s %s\n", from, arrow,
+					nodes[i][task.ID])
+			}
+		}
+	}
+
+	b.WriteString("    classDef pending fill:#f6efd9,stroke:#b89b5e\n")
+	b.WriteString("    classDef inprogress fill:#e8f2ec,stroke:#4a7a5c\n")
+	b.WriteString("    classDef completed fill:#ecebe6,stroke:#8a857a\n")
+	b.WriteString("    classDef blocked fill:#f3e8e8,stroke:#7a5252\n")
+	b.WriteString("    classDef cyclic fill:#f3e8e8,stroke:#b04040," +
+		"stroke-dasharray:4 3\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeExport writes a document in the format named by the request's format
+// parameter. The document is shown inline unless the download parameter is
+// set.
+func (h *HTTPServer) writeExport(w http.ResponseWriter, r *http.Request,
+	doc *exportDoc) {
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = ExportMarkdown
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown export format %q: use "+
+			"markdown, csv, json, dot or mermaid", name),
+			http.StatusBadRequest)
+		return
+	}
+
+	disposition := "inline"
+	if r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(
+		disposition, map[string]string{
+			"filename": doc.Name + "." + format.ext,
+		},
+	))
+
+	if err := format.write(w, doc); err != nil {
+		h.log.Debugf("Unable to write %s export: %v", name, err)
+	}
+}
+
+// exportFilter returns the task filter of an export request, or the given
+// default if it names no valid filter.
+func exportFilter(r *http.Request, def string) string {
+	filter := r.URL.Query().Get("filter")
+	if filter == "" || !validTaskFilter(filter) {
+		return def
+	}
+
+	return filter
+}
+
+// handleExportList exports a single task list.
+func (h *HTTPServer) handleExportList(w http.ResponseWriter, r *http.Request) {
+	listID := r.PathValue("listID")
+
+	tasks, _, err := h.listTasks(r.Context(), listID)
+	if err != nil {
+		http.Error(w, "Failed to load tasks: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	list := newExportList(listID, tasks, exportFilter(r, TaskFilterAll))
+	if session, _, ok := h.projectIndexer.GetSession(listID); ok {
+		list.Summary = session.Summary
+		list.Branch = session.GitBranch
+		if session.ProjectPath != "" {
+			list.ProjectName = filepath.Base(session.ProjectPath)
+		}
+	}
+
+	doc := &exportDoc{
+		Title:  list.title(),
+		Name:   "tasks-" + listID,
+		Single: true,
+		Lists:  []ExportList{list},
+	}
+	h.writeExport(w, r, doc)
+}
+
+// handleExportAllTasks exports the tasks of every active session, as the
+// unified tasks view shows them.
+func (h *HTTPServer) handleExportAllTasks(w http.ResponseWriter,
+	r *http.Request) {
+
+	activeLists, err := h.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		http.Error(w, "Failed to list active sessions: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	filter := exportFilter(r, TaskFilterActive)
+	doc := &exportDoc{Title: "All tasks", Name: "tasks"}
+	for _, active := range activeLists {
+		tasks, err := h.taskStore.List(r.Context(), active.SessionID)
+		if err != nil || len(tasks) == 0 {
+			continue
+		}
+
+		list := newExportList(active.SessionID, tasks, filter)
+		if len(list.Tasks) == 0 {
+			continue
+		}
+		list.ProjectName = active.ProjectName
+		list.Summary = active.Summary
+		doc.Lists = append(doc.Lists, list)
+	}
+
+	h.writeExport(w, r, doc)
+}
+
+// handleExportProject exports the task lists of every session of a project,
+// including the archived lists of ended sessions.
+func (h *HTTPServer) handleExportProject(w http.ResponseWriter,
+	r *http.Request) {
+
+	dirName := r.PathValue("projectID")
+	project, err := h.projectIndexer.GetProject(dirName)
+	if err != nil {
+		http.Error(w, "Project not found: "+err.Error(),
+			http.StatusNotFound)
+		return
+	}
+
+	filter := exportFilter(r, TaskFilterAll)
+	doc := &exportDoc{
+		Title: project.Name,
+		Name:  "tasks-" + project.Name,
+	}
+	for _, session := range project.Sessions {
+		tasks, _, err := h.listTasks(r.Context(), session.SessionID)
+		if err != nil || len(tasks) == 0 {
+			continue
+		}
+
+		list := newExportList(session.SessionID, tasks, filter)
+		if len(list.Tasks) == 0 {
+			continue
+		}
+		list.ProjectName = project.Name
+		list.Summary = session.Summary
+		list.Branch = session.GitBranch
+		doc.Lists = append(doc.Lists, list)
+	}
+
+	h.writeExport(w, r, doc)
+}
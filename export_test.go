@@ -0,0 +1,62 @@
+package taskviewer
+
+import (
+	"mime"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWriteExportDisposition checks that export file names survive quotes,
+// backslashes and multi-byte text in the Content-Disposition header.
+func TestWriteExportDisposition(t *testing.T) {
+	h, _ := newTestHTTPServer(t, newFakeTaskStore())
+
+	tests := []struct {
+		name     string
+		query    string
+		wantType string
+		wantFile string
+	}{
+		{
+			name:     "tasks-app",
+			query:    "?format=csv",
+			wantType: "inline",
+			wantFile: "tasks-app.csv",
+		},
+		{
+			name:     `tasks-my "app"\v2`,
+			query:    "?format=json&download=1",
+			wantType: "attachment",
+			wantFile: `tasks-my "app"\v2.json`,
+		},
+		{
+			name:     "tasks-übersicht",
+			query:    "",
+			wantType: "inline",
+			wantFile: "tasks-übersicht.md",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(
+				"GET", "/export"+test.query, nil,
+			)
+			w := httptest.NewRecorder()
+			h.writeExport(w, r, &exportDoc{Name: test.name})
+
+			header := w.Header().Get("Content-Disposition")
+			kind, params, err := mime.ParseMediaType(header)
+			if err != nil {
+				t.Fatalf("unable to parse %q: %v", header, err)
+			}
+			if kind != test.wantType ||
+				params["filename"] != test.wantFile {
+
+				t.Fatalf("got %s with %q, want %s with %q",
+					kind, params["filename"], test.wantType,
+					test.wantFile)
+			}
+		})
+	}
+}
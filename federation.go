@@ -0,0 +1,846 @@
+package taskviewer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// federationTimeout bounds a single poll of, or push to, another
+	// host.
+	federationTimeout = 10 * time.Second
+
+	// federationMaxSnapshot bounds the size of a snapshot read from
+	// another host.
+	federationMaxSnapshot = 32 << 20
+
+	// hostStaleIntervals is how many intervals may pass without a
+	// snapshot from a host before it is considered down.
+	hostStaleIntervals = 3
+
+	// maxHostNameLen bounds the length of a pushed host name.
+	maxHostNameLen = 128
+
+	// pushedHostRetention is how long a pushed host stays in the fleet
+	// view once it is down, before it is forgotten.
+	pushedHostRetention = time.Hour
+
+	// maxPushedHosts bounds the number of pushed hosts a hub keeps.
+	maxPushedHosts = 256
+
+	// snapshotPath and pushPath are where agents serve their snapshot
+	// and hubs accept pushed ones.
+	snapshotPath = "/api/v1/federation/snapshot"
+	pushPath     = "/api/v1/federation/snapshots"
+)
+
+// Host health states.
+const (
+	// HostHealthy is a host whose latest snapshot is recent and whose
+	// last poll or push succeeded.
+	HostHealthy = "healthy"
+
+	// HostDegraded is a host whose latest snapshot is recent but whose
+	// last poll failed.
+	HostDegraded = "degraded"
+
+	// HostDown is a host without a recent snapshot. Its state is left
+	// out of the merged view.
+	HostDown = "down"
+)
+
+// Host sources.
+const (
+	// SourceLocal is the hub itself.
+	SourceLocal = "local"
+
+	// SourcePull is an agent the hub polls.
+	SourcePull = "pull"
+
+	// SourcePush is an agent that pushes its snapshots to the hub.
+	SourcePush = "push"
+)
+
+var (
+	// errPeerName is returned for a pushed host name that is already
+	// used by a polled agent.
+	errPeerName = errors.New("host name is used by a polled agent")
+
+	// errTooManyHosts is returned for a snapshot pushed by a new host
+	// once the hub keeps maxPushedHosts.
+	errTooManyHosts = errors.New("hub has too many pushed hosts")
+)
+
+// PeerConfig is an agent polled by a hub.
+type PeerConfig struct {
+	// Name is shown in the host column of the hub's dashboard.
+	Name string
+
+	// URL is the base URL of the agent's dashboard.
+	URL string
+}
+
+// FederationConfig configures how the daemon shares its state with other
+// daemons and merges theirs.
+type FederationConfig struct {
+	// HostName names this machine in its snapshots.
+	HostName string
+
+	// Agent serves this machine's snapshot to hubs.
+	Agent bool
+
+	// Hub merges the state of other machines into a fleet view, polling
+	// Peers and accepting pushed snapshots.
+	Hub bool
+
+	// Peers are the agents a hub polls, with PeerToken as the bearer
+	// token.
+	Peers     []PeerConfig
+	PeerToken string
+
+	// PushTo are the base URLs of hubs this machine pushes its snapshot
+	// to, with PushToken as the bearer token.
+	PushTo    []string
+	PushToken string
+
+	// Interval is how often peers are polled and snapshots pushed.
+	Interval time.Duration
+
+	// RootCAs are the certificates trusted for peers and hubs, or nil
+	// for the system's.
+	RootCAs *x509.CertPool
+}
+
+// FederationSnapshot is the state of one machine as exchanged between agents
+// and hubs.
+type FederationSnapshot struct {
+	Host        string           `json:"host"`
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Projects    []ProjectSummary `json:"projects"`
+	Instances   []ClaudeInstance `json:"instances"`
+	Lists       []SnapshotList   `json:"lists"`
+}
+
+// SnapshotList is an active task list with its task counts.
+type SnapshotList struct {
+	ActiveTaskList
+	Counts TaskCounts `json:"counts"`
+}
+
+// HostStatus is the health of one host of a fleet.
+type HostStatus struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Health string `json:"health"`
+
+	// URL is the base URL of the host's dashboard, if known.
+	URL string `json:"url,omitempty"`
+
+	// LastSeen is when the latest snapshot was received, and LastError
+	// is why the last poll or push failed, if it did.
+	LastSeen  time.Time `json:"lastSeen"`
+	LastError string    `json:"lastError,omitempty"`
+
+	// LatencyMS is how long the latest successful poll took.
+	LatencyMS int64 `json:"latencyMs,omitempty"`
+
+	Projects  int `json:"projects"`
+	Instances int `json:"instances"`
+	Lists     int `json:"lists"`
+}
+
+// hostState is what a hub knows about a remote host.
+type hostState struct {
+	status   HostStatus
+	snapshot *FederationSnapshot
+}
+
+// HostRef names the host a fleet entry comes from.
+type HostRef struct {
+	Host string `json:"host"`
+
+	// HostURL is the base URL of the host's dashboard, if known.
+	HostURL string `json:"hostUrl,omitempty"`
+
+	// Local is true for entries of the hub itself.
+	Local bool `json:"local,omitempty"`
+}
+
+// Link returns the link to a path on the host's dashboard, or "" if the
+// dashboard's address is unknown.
+func (r HostRef) Link(path string) string {
+	switch {
+	case r.Local:
+		return path
+	case r.HostURL != "":
+		return r.HostURL + path
+	default:
+		return ""
+	}
+}
+
+// FleetInstance is a running instance on some host.
+type FleetInstance struct {
+	HostRef
+	ClaudeInstance
+}
+
+// FleetList is an active task list on some host.
+type FleetList struct {
+	HostRef
+	SnapshotList
+}
+
+// FleetProject is a project on some host.
+type FleetProject struct {
+	HostRef
+	ProjectSummary
+}
+
+// FleetView merges the state of every reachable host.
+type FleetView struct {
+	// Hosts lists the hub first and then every other host by name,
+	// including the hosts that are down.
+	Hosts []HostStatus `json:"hosts"`
+
+	Instances []FleetInstance `json:"instances"`
+	Lists     []FleetList     `json:"lists"`
+	Projects  []FleetProject  `json:"projects"`
+}
+
+// Federation shares this machine's state with hubs and, on a hub, merges
+// the state of other machines. A nil Federation is valid and shares
+// nothing.
+type Federation struct {
+	cfg FederationConfig
+
+	taskStore       claudeagent.TaskStore
+	projectIndexer  *ProjectIndexer
+	instanceTracker *InstanceTracker
+	watchdog        *Watchdog
+
+	client *http.Client
+
+	// mu guards hosts, which is keyed by host name.
+	mu    sync.Mutex
+	hosts map[string]*hostState
+
+	// retention is how long a pushed host is kept once it is down. It
+	// is a field so that tests can shorten it.
+	retention time.Duration
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	log btclog.Logger
+}
+
+// NewFederation creates the federation of this daemon with others. It
+// returns nil if the daemon is neither an agent nor a hub and pushes to no
+// hub.
+func NewFederation(cfg FederationConfig, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, instanceTracker *InstanceTracker,
+	watchdog *Watchdog, log btclog.Logger) *Federation {
+
+	if !cfg.Agent && !cfg.Hub && len(cfg.PushTo) == 0 {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.RootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    cfg.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	f := &Federation{
+		cfg:             cfg,
+		taskStore:       taskStore,
+		projectIndexer:  projectIndexer,
+		instanceTracker: instanceTracker,
+		watchdog:        watchdog,
+		client: &http.Client{
+			Timeout:   federationTimeout,
+			Transport: transport,
+		},
+		hosts:     make(map[string]*hostState),
+		retention: pushedHostRetention,
+		quit:      make(chan struct{}),
+		log:       log,
+	}
+
+	for _, peer := range cfg.Peers {
+		f.hosts[peer.Name] = &hostState{
+			status: HostStatus{
+				Name:   peer.Name,
+				Source: SourcePull,
+				URL:    peer.URL,
+			},
+		}
+	}
+
+	return f
+}
+
+// Start begins polling peers and pushing snapshots. This method is
+// idempotent.
+func (f *Federation) Start() error {
+	if !atomic.CompareAndSwapUint32(&f.started, 0, 1) {
+		return nil
+	}
+
+	for _, peer := range f.cfg.Peers {
+		f.wg.Add(1)
+		go f.pollLoop(peer)
+	}
+	for _, hubURL := range f.cfg.PushTo {
+		f.wg.Add(1)
+		go f.pushLoop(hubURL)
+	}
+
+	return nil
+}
+
+// Stop ends polling and pushing. This method is idempotent.
+func (f *Federation) Stop() error {
+	if !atomic.CompareAndSwapUint32(&f.stopped, 0, 1) {
+		return nil
+	}
+
+	close(f.quit)
+	f.wg.Wait()
+
+	return nil
+}
+
+// IsAgent reports whether the daemon serves its snapshot to hubs.
+func (f *Federation) IsAgent() bool {
+	return f != nil && f.cfg.Agent
+}
+
+// IsHub reports whether the daemon merges the state of other machines.
+func (f *Federation) IsHub() bool {
+	return f != nil && f.cfg.Hub
+}
+
+// Snapshot returns the current state of this machine.
+func (f *Federation) Snapshot(ctx context.Context) (FederationSnapshot,
+	error) {
+
+	snapshot := FederationSnapshot{
+		Host:        f.cfg.HostName,
+		GeneratedAt: time.Now().UTC(),
+		Projects:    []ProjectSummary{},
+		Instances:   []ClaudeInstance{},
+		Lists:       []SnapshotList{},
+	}
+
+	projects, err := f.projectIndexer.ListProjectSummaries()
+	if err != nil {
+		return FederationSnapshot{}, fmt.Errorf("failed to list "+
+			"projects: %w", err)
+	}
+	if projects != nil {
+		snapshot.Projects = projects
+	}
+
+	instances, err := f.instanceTracker.ListRunningInstances()
+	if err != nil {
+		return FederationSnapshot{}, fmt.Errorf("failed to list "+
+			"instances: %w", err)
+	}
+	f.watchdog.annotateInstances(instances)
+	if instances != nil {
+		snapshot.Instances = instances
+	}
+
+	lists, err := f.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		return FederationSnapshot{}, fmt.Errorf("failed to list "+
+			"task lists: %w", err)
+	}
+	f.watchdog.annotateLists(lists)
+	for _, list := range lists {
+		tasks, err := f.taskStore.List(ctx, list.SessionID)
+		if err != nil {
+			continue
+		}
+
+		// The task directory is a path on this machine only.
+		list.TaskDir = ""
+		snapshot.Lists = append(snapshot.Lists, SnapshotList{
+			ActiveTaskList: list,
+			Counts:         countTasks(tasks),
+		})
+	}
+
+	return snapshot, nil
+}
+
+// pollLoop polls a peer immediately and then on every interval.
+func (f *Federation) pollLoop(peer PeerConfig) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		f.poll(peer)
+
+		select {
+		case <-ticker.C:
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// poll fetches a peer's snapshot and records the outcome.
+func (f *Federation) poll(peer PeerConfig) {
+	start := time.Now()
+	snapshot, err := f.fetchSnapshot(peer)
+	latency := time.Since(start)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	host := f.hosts[peer.Name]
+	if err != nil {
+		if host.status.LastError == "" {
+			f.log.Warnf("Unable to poll %s: %v", peer.Name, err)
+		}
+		host.status.LastError = err.Error()
+		return
+	}
+
+	if host.status.LastError != "" {
+		f.log.Infof("Polling %s again", peer.Name)
+	}
+	host.status.LastError = ""
+	host.status.LastSeen = time.Now()
+	host.status.LatencyMS = latency.Milliseconds()
+	host.snapshot = snapshot
+}
+
+// fetchSnapshot requests a peer's snapshot.
+func (f *Federation) fetchSnapshot(
+	peer PeerConfig) (*FederationSnapshot, error) {
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), federationTimeout,
+	)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, peer.URL+snapshotPath, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if f.cfg.PeerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.PeerToken)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach agent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("agent returned %s: %s", resp.Status,
+			apiErrorMessage(resp.Body))
+	}
+
+	var snapshot FederationSnapshot
+	err = json.NewDecoder(
+		io.LimitReader(resp.Body, federationMaxSnapshot),
+	).Decode(&snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// pushLoop pushes this machine's snapshot to a hub immediately and then on
+// every interval.
+func (f *Federation) pushLoop(hubURL string) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	var failing bool
+	for {
+		err := f.push(hubURL)
+		switch {
+		case err != nil && !failing:
+			f.log.Warnf("Unable to push to %s: %v", hubURL, err)
+		case err == nil && failing:
+			f.log.Infof("Pushing to %s again", hubURL)
+		}
+		failing = err != nil
+
+		select {
+		case <-ticker.C:
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// push sends this machine's snapshot to a hub.
+func (f *Federation) push(hubURL string) error {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), federationTimeout,
+	)
+	defer cancel()
+
+	snapshot, err := f.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, hubURL+pushPath, bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.cfg.PushToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.PushToken)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach hub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent &&
+		resp.StatusCode != http.StatusOK {
+
+		return fmt.Errorf("hub returned %s: %s", resp.Status,
+			apiErrorMessage(resp.Body))
+	}
+
+	return nil
+}
+
+// apiErrorMessage returns the message of an API error body, or a short
+// prefix of the body if it is not one.
+func apiErrorMessage(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+
+	var apiErr APIError
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+		return apiErr.Error.Message
+	}
+
+	msg := strings.TrimSpace(string(data))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+
+	return msg
+}
+
+// receive records a snapshot pushed by an agent.
+func (f *Federation) receive(snapshot *FederationSnapshot) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	host, ok := f.hosts[snapshot.Host]
+	switch {
+	case !ok:
+		if f.prunePushed(time.Now()) >= maxPushedHosts {
+			return errTooManyHosts
+		}
+
+		host = &hostState{
+			status: HostStatus{
+				Name:   snapshot.Host,
+				Source: SourcePush,
+			},
+		}
+		f.hosts[snapshot.Host] = host
+		f.log.Infof("Receiving snapshots from %s", snapshot.Host)
+
+	case host.status.Source != SourcePush:
+		return errPeerName
+	}
+
+	host.status.LastSeen = time.Now()
+	host.snapshot = snapshot
+
+	return nil
+}
+
+// prunePushed forgets the pushed hosts that have been down for longer than
+// the retention, and returns the number of pushed hosts left. The caller
+// must hold the write lock.
+func (f *Federation) prunePushed(now time.Time) int {
+	expiry := hostStaleIntervals*f.cfg.Interval + f.retention
+
+	var pushed int
+	for name, host := range f.hosts {
+		if host.status.Source != SourcePush {
+			continue
+		}
+		if now.Sub(host.status.LastSeen) > expiry {
+			f.log.Infof("Forgetting %s, down since %v", name,
+				host.status.LastSeen.Format(time.RFC3339))
+			delete(f.hosts, name)
+			continue
+		}
+		pushed++
+	}
+
+	return pushed
+}
+
+// health returns the health of a remote host.
+func (f *Federation) health(host *hostState, now time.Time) string {
+	window := hostStaleIntervals * f.cfg.Interval
+	switch {
+	case host.snapshot == nil || now.Sub(host.status.LastSeen) > window:
+		return HostDown
+
+	case host.status.LastError != "":
+		return HostDegraded
+
+	default:
+		return HostHealthy
+	}
+}
+
+// Fleet merges the state of this machine and every host that is not down.
+func (f *Federation) Fleet(ctx context.Context) (FleetView, error) {
+	local, err := f.Snapshot(ctx)
+	if err != nil {
+		return FleetView{}, err
+	}
+
+	view := FleetView{
+		Instances: []FleetInstance{},
+		Lists:     []FleetList{},
+		Projects:  []FleetProject{},
+	}
+	add := func(ref HostRef, snapshot *FederationSnapshot) {
+		for _, instance := range snapshot.Instances {
+			view.Instances = append(view.Instances, FleetInstance{
+				HostRef: ref, ClaudeInstance: instance,
+			})
+		}
+		for _, list := range snapshot.Lists {
+			view.Lists = append(view.Lists, FleetList{
+				HostRef: ref, SnapshotList: list,
+			})
+		}
+		for _, project := range snapshot.Projects {
+			view.Projects = append(view.Projects, FleetProject{
+				HostRef: ref, ProjectSummary: project,
+			})
+		}
+	}
+
+	view.Hosts = append(view.Hosts, HostStatus{
+		Name:      f.cfg.HostName,
+		Source:    SourceLocal,
+		Health:    HostHealthy,
+		LastSeen:  local.GeneratedAt,
+		Projects:  len(local.Projects),
+		Instances: len(local.Instances),
+		Lists:     len(local.Lists),
+	})
+	add(HostRef{Host: f.cfg.HostName, Local: true}, &local)
+
+	now := time.Now()
+
+	f.mu.Lock()
+	f.prunePushed(now)
+	names := make([]string, 0, len(f.hosts))
+	for name := range f.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		host := f.hosts[name]
+
+		status := host.status
+		status.Health = f.health(host, now)
+		if host.snapshot != nil {
+			status.Projects = len(host.snapshot.Projects)
+			status.Instances = len(host.snapshot.Instances)
+			status.Lists = len(host.snapshot.Lists)
+		}
+		view.Hosts = append(view.Hosts, status)
+
+		if status.Health == HostDown {
+			continue
+		}
+		add(HostRef{Host: name, HostURL: status.URL}, host.snapshot)
+	}
+	f.mu.Unlock()
+
+	// Projects and instances are shown most recent first across hosts;
+	// task lists stay grouped by host.
+	sort.SliceStable(view.Projects, func(i, j int) bool {
+		return view.Projects[i].LastModified.After(
+			view.Projects[j].LastModified,
+		)
+	})
+	sort.SliceStable(view.Instances, func(i, j int) bool {
+		return view.Instances[i].StartTime.After(
+			view.Instances[j].StartTime,
+		)
+	})
+
+	return view, nil
+}
+
+// FleetData holds data for the fleet page.
+type FleetData struct {
+	PageData
+	FleetView
+}
+
+// handleFleet renders the merged state of every host.
+func (h *HTTPServer) handleFleet(w http.ResponseWriter, r *http.Request) {
+	if !h.federation.IsHub() {
+		h.renderError(
+			w, "This viewer is not a hub; start it with --hub or "+
+				"--peer", http.StatusNotFound,
+		)
+		return
+	}
+
+	view, err := h.federation.Fleet(r.Context())
+	if err != nil {
+		h.renderError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, "fleet.html", FleetData{
+		PageData:  PageData{Title: "Fleet"},
+		FleetView: view,
+	})
+}
+
+// handleAPIFleet returns the merged state of every host.
+func (h *HTTPServer) handleAPIFleet(w http.ResponseWriter, r *http.Request) {
+	if !h.federation.IsHub() {
+		writeAPIError(
+			w, http.StatusForbidden, ErrCodeForbidden,
+			"this viewer is not a hub",
+		)
+		return
+	}
+
+	view, err := h.federation.Fleet(r.Context())
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleAPIFederationSnapshot serves this machine's snapshot to hubs.
+func (h *HTTPServer) handleAPIFederationSnapshot(w http.ResponseWriter,
+	r *http.Request) {
+
+	if !h.federation.IsAgent() {
+		writeAPIError(
+			w, http.StatusForbidden, ErrCodeForbidden,
+			"this viewer is not an agent; start it with --agent",
+		)
+		return
+	}
+
+	snapshot, err := h.federation.Snapshot(r.Context())
+	if err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			err.Error(),
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleAPIFederationPush accepts a snapshot pushed by an agent.
+func (h *HTTPServer) handleAPIFederationPush(w http.ResponseWriter,
+	r *http.Request) {
+
+	if !h.federation.IsHub() {
+		writeAPIError(
+			w, http.StatusForbidden, ErrCodeForbidden,
+			"this viewer is not a hub",
+		)
+		return
+	}
+
+	var snapshot FederationSnapshot
+	err := json.NewDecoder(
+		http.MaxBytesReader(w, r.Body, federationMaxSnapshot),
+	).Decode(&snapshot)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusBadRequest, ErrCodeBadRequest,
+			"invalid snapshot: "+err.Error(),
+		)
+		return
+	}
+
+	snapshot.Host = strings.TrimSpace(snapshot.Host)
+	if snapshot.Host == "" || len(snapshot.Host) > maxHostNameLen ||
+		snapshot.Host == h.federation.cfg.HostName {
+
+		writeAPIError(
+			w, http.StatusBadRequest, ErrCodeBadRequest,
+			fmt.Sprintf("host name must be 1 to %d characters "+
+				"and differ from the hub's", maxHostNameLen),
+		)
+		return
+	}
+
+	if err := h.federation.receive(&snapshot); err != nil {
+		writeAPIError(
+			w, http.StatusConflict, ErrCodeConflict,
+			fmt.Sprintf("%s: %v", snapshot.Host, err),
+		)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
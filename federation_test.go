@@ -0,0 +1,325 @@
+package taskviewer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+)
+
+const (
+	// testInterval is the federation interval of the test fleet.
+	testInterval = 100 * time.Millisecond
+
+	// testWait bounds how long the test fleet may take to settle.
+	testWait = 5 * time.Second
+)
+
+// testNode is a daemon of the test fleet, serving on an ephemeral port.
+type testNode struct {
+	federation *Federation
+	server     *HTTPServer
+	url        string
+}
+
+// startTestNode starts a daemon with a single live task list. The list ID
+// is given so that the lists of different hosts can be told apart.
+func startTestNode(t *testing.T, cfg FederationConfig, auth *Authenticator,
+	listID string) *testNode {
+
+	t.Helper()
+
+	root := t.TempDir()
+	projectsDir := filepath.Join(root, "projects")
+	tasksDir := filepath.Join(root, "tasks")
+	for _, dir := range []string{projectsDir, tasksDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("unable to create %s: %v", dir, err)
+		}
+	}
+
+	store := newFakeTaskStore()
+	if listID != "" {
+		listDir := filepath.Join(tasksDir, listID)
+		if err := os.MkdirAll(listDir, 0o700); err != nil {
+			t.Fatalf("unable to create %s: %v", listDir, err)
+		}
+		taskFile := filepath.Join(listDir, "1.json")
+		if err := os.WriteFile(taskFile, nil, 0o600); err != nil {
+			t.Fatalf("unable to write %s: %v", taskFile, err)
+		}
+		store.set(listID, testTask("1"))
+	}
+
+	cfg.Interval = testInterval
+	projectIndexer := NewProjectIndexer(projectsDir, tasksDir)
+	instanceTracker := NewInstanceTracker(
+		projectIndexer, &fakeProcessSource{},
+	)
+	federation := NewFederation(
+		cfg, store, projectIndexer, instanceTracker, nil,
+		btclog.Disabled,
+	)
+
+	server, err := NewHTTPServer(
+		&HTTPConfig{ListenAddr: "127.0.0.1:0", Auth: auth}, store,
+		projectIndexer, instanceTracker, nil, nil, nil, nil, nil,
+		federation, btclog.Disabled,
+	)
+	if err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("unable to start server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = server.Stop(context.Background())
+	})
+
+	return &testNode{
+		federation: federation,
+		server:     server,
+		url:        "http://" + server.listener.Addr().String(),
+	}
+}
+
+// start begins polling and pushing.
+func (n *testNode) start(t *testing.T) {
+	t.Helper()
+
+	if err := n.federation.Start(); err != nil {
+		t.Fatalf("unable to start federation: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = n.federation.Stop()
+	})
+}
+
+// hosts returns the hosts of the node's fleet view by name, and the hosts
+// of the task lists merged into it by list ID.
+func (n *testNode) hosts(t *testing.T) (map[string]HostStatus,
+	map[string]string) {
+
+	t.Helper()
+
+	view, err := n.federation.Fleet(context.Background())
+	if err != nil {
+		t.Fatalf("unable to get fleet: %v", err)
+	}
+
+	hosts := make(map[string]HostStatus, len(view.Hosts))
+	for _, host := range view.Hosts {
+		hosts[host.Name] = host
+	}
+	lists := make(map[string]string, len(view.Lists))
+	for _, list := range view.Lists {
+		lists[list.SessionID] = list.Host
+	}
+
+	return hosts, lists
+}
+
+// waitForHealth waits until a host of the node's fleet has the given
+// health, with "" standing for a host that is not in the fleet.
+func (n *testNode) waitForHealth(t *testing.T, name, health string) {
+	t.Helper()
+
+	deadline := time.Now().Add(testWait)
+	for {
+		hosts, _ := n.hosts(t)
+		if hosts[name].Health == health {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s is %q, want %q", name,
+				hosts[name].Health, health)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pushSnapshot pushes a snapshot to a hub by hand and returns the response
+// status and API error code.
+func pushSnapshot(t *testing.T, hubURL, token string,
+	snapshot FederationSnapshot) (int, string) {
+
+	t.Helper()
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("unable to encode snapshot: %v", err)
+	}
+	req, err := http.NewRequest(
+		http.MethodPost, hubURL+pushPath, bytes.NewReader(body),
+	)
+	if err != nil {
+		t.Fatalf("unable to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unable to push: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var apiErr APIError
+	_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+
+	return resp.StatusCode, apiErr.Error.Code
+}
+
+// TestFederation starts a polled agent, a pushing agent and a hub, and
+// checks the merged view as the agents come and go.
+func TestFederation(t *testing.T) {
+	const (
+		peerToken = "fleet"
+		hubToken  = "hub-secret"
+	)
+
+	polled := startTestNode(t, FederationConfig{
+		HostName: "box1",
+		Agent:    true,
+	}, NewAuthenticator("", []string{peerToken}), "list-a")
+	polled.start(t)
+
+	hubAuth := NewAuthenticator(hubToken, nil)
+	hubAuth.failureDelay = 0
+	hub := startTestNode(t, FederationConfig{
+		HostName:  "hub",
+		Hub:       true,
+		Peers:     []PeerConfig{{Name: "box1", URL: polled.url}},
+		PeerToken: peerToken,
+	}, hubAuth, "")
+	hub.federation.retention = testInterval
+	hub.start(t)
+
+	pusher := startTestNode(t, FederationConfig{
+		HostName:  "box2",
+		PushTo:    []string{hub.url},
+		PushToken: hubToken,
+	}, nil, "list-b")
+	pusher.start(t)
+
+	// Both agents show up with their lists, each by its own route.
+	hub.waitForHealth(t, "box1", HostHealthy)
+	hub.waitForHealth(t, "box2", HostHealthy)
+
+	hosts, lists := hub.hosts(t)
+	wantSources := map[string]string{
+		"hub": SourceLocal, "box1": SourcePull, "box2": SourcePush,
+	}
+	for name, source := range wantSources {
+		if hosts[name].Source != source {
+			t.Fatalf("%s has source %q, want %q", name,
+				hosts[name].Source, source)
+		}
+	}
+	if hosts["box1"].URL != polled.url || hosts["box1"].Lists != 1 {
+		t.Fatalf("unexpected polled host: %+v", hosts["box1"])
+	}
+	if lists["list-a"] != "box1" || lists["list-b"] != "box2" {
+		t.Fatalf("unexpected lists: %v", lists)
+	}
+
+	// A pushed snapshot cannot take over a polled host or the hub's
+	// own name, and pushing needs the hub's credentials.
+	status, code := pushSnapshot(
+		t, hub.url, hubToken, FederationSnapshot{Host: "box1"},
+	)
+	if status != http.StatusConflict || code != ErrCodeConflict {
+		t.Fatalf("pushing as a peer: got %d %s", status, code)
+	}
+	if err := hub.federation.receive(
+		&FederationSnapshot{Host: "box1"},
+	); !errors.Is(err, errPeerName) {
+
+		t.Fatalf("receiving as a peer: got %v", err)
+	}
+	status, _ = pushSnapshot(
+		t, hub.url, hubToken, FederationSnapshot{Host: "hub"},
+	)
+	if status != http.StatusBadRequest {
+		t.Fatalf("pushing as the hub: got %d", status)
+	}
+	status, _ = pushSnapshot(
+		t, hub.url, peerToken, FederationSnapshot{Host: "box3"},
+	)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("pushing with a wrong token: got %d", status)
+	}
+
+	// Once the polled agent is gone, the hub still shows its last
+	// snapshot while it is recent, and then leaves it out.
+	if err := polled.server.Stop(context.Background()); err != nil {
+		t.Fatalf("unable to stop agent: %v", err)
+	}
+	hub.waitForHealth(t, "box1", HostDegraded)
+	hosts, lists = hub.hosts(t)
+	if hosts["box1"].LastError == "" || lists["list-a"] != "box1" {
+		t.Fatalf("degraded host: %+v, lists %v", hosts["box1"], lists)
+	}
+
+	hub.waitForHealth(t, "box1", HostDown)
+	if _, lists = hub.hosts(t); lists["list-a"] != "" {
+		t.Fatalf("down host still merged: %v", lists)
+	}
+
+	// A pushing agent that stops goes down, and is then forgotten,
+	// unlike a polled one.
+	if err := pusher.federation.Stop(); err != nil {
+		t.Fatalf("unable to stop pushing: %v", err)
+	}
+	hub.waitForHealth(t, "box2", HostDown)
+	hub.waitForHealth(t, "box2", "")
+	if hosts, _ = hub.hosts(t); hosts["box1"].Health != HostDown {
+		t.Fatalf("polled host forgotten: %+v", hosts)
+	}
+}
+
+// TestFederationPushedHostLimit checks that a hub keeps a bounded number of
+// pushed hosts, making room as hosts are forgotten.
+func TestFederationPushedHostLimit(t *testing.T) {
+	f := NewFederation(
+		FederationConfig{Hub: true, Interval: time.Minute}, nil, nil,
+		nil, nil, btclog.Disabled,
+	)
+
+	for i := 0; i < maxPushedHosts; i++ {
+		host := fmt.Sprintf("box%d", i)
+		err := f.receive(&FederationSnapshot{Host: host})
+		if err != nil {
+			t.Fatalf("unable to receive from %s: %v", host, err)
+		}
+	}
+
+	err := f.receive(&FederationSnapshot{Host: "late"})
+	if !errors.Is(err, errTooManyHosts) {
+		t.Fatalf("got %v, want %v", err, errTooManyHosts)
+	}
+	if err := f.receive(&FederationSnapshot{Host: "box0"}); err != nil {
+		t.Fatalf("known host refused: %v", err)
+	}
+
+	// A host down for longer than the retention makes room.
+	f.mu.Lock()
+	f.hosts["box1"].status.LastSeen = time.Now().Add(
+		-hostStaleIntervals*time.Minute - f.retention - time.Second,
+	)
+	f.mu.Unlock()
+
+	if err := f.receive(&FederationSnapshot{Host: "late"}); err != nil {
+		t.Fatalf("unable to receive after pruning: %v", err)
+	}
+	if _, ok := f.hosts["box1"]; ok {
+		t.Fatalf("down host kept")
+	}
+}
@@ -8,11 +8,11 @@ require (
 	github.com/btcsuite/btclog/v2 v2.0.0
 	github.com/jessevdk/go-flags v1.6.1
 	github.com/roasbeef/claude-agent-sdk-go v0.0.0-00010101000000-000000000000
+	github.com/yuin/goldmark v1.7.16
 )
 
 require (
 	github.com/btcsuite/btclog v0.0.0-20241003133417-09c4e92e319c // indirect
-	github.com/yuin/goldmark v1.7.16 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
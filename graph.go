@@ -0,0 +1,356 @@
+package taskviewer
+
+import (
+	"sort"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// DanglingRef is a dependency on a task ID that does not exist in the list,
+// usually left behind when a blocker is deleted by hand.
+type DanglingRef struct {
+	// TaskID is the task holding the reference.
+	TaskID string `json:"taskId"`
+
+	// Missing is the ID of the task that does not exist.
+	Missing string `json:"missing"`
+
+	// Field is the field holding the reference, either "blockedBy" or
+	// "blocks".
+	Field string `json:"field"`
+}
+
+// GraphAnalysis is the dependency analysis of a single task list. A task
+// depends on the tasks in its BlockedBy field and on every task that lists
+// it in Blocks, so a list whose two fields disagree is still analyzed as a
+// whole. Task IDs in every list are given in the order of the task list.
+type GraphAnalysis struct {
+	// Blocked holds the incomplete tasks that depend on at least one
+	// incomplete task. Completed blockers and missing tasks never block.
+	Blocked []string `json:"blocked"`
+
+	// Ready holds the pending tasks that are not blocked, which are the
+	// tasks that can be picked up now.
+	Ready []string `json:"ready"`
+
+	// Waves groups the incomplete tasks into topological levels. The
+	// first wave can be worked on now and every later wave once the
+	// waves before it are done. Tasks in or behind a cycle can never
+	// start, so they are in no wave.
+	Waves [][]string `json:"waves"`
+
+	// CriticalPath is the longest chain of incomplete tasks that depend
+	// on each other, first to last. Its length is the least number of
+	// steps left to finish the list. It is empty unless at least two
+	// incomplete tasks depend on each other.
+	CriticalPath []string `json:"criticalPath"`
+
+	// Cycles holds each group of tasks that depend on each other in a
+	// loop. A task that blocks itself is a cycle of its own.
+	Cycles [][]string `json:"cycles"`
+
+	// Dangling holds the dependencies on tasks that do not exist.
+	Dangling []DanglingRef `json:"dangling"`
+
+	deps     map[string][]string
+	blocked  map[string]bool
+	ready    map[string]bool
+	critical map[string]bool
+	cyclic   map[string]bool
+	wave     map[string]int
+}
+
+// Blockers returns the tasks a task depends on, whether or not they are
+// completed. References to missing tasks are left out.
+func (g *GraphAnalysis) Blockers(id string) []string {
+	if g == nil {
+		return nil
+	}
+
+	return g.deps[id]
+}
+
+// IsBlocked reports whether a task waits on an incomplete task.
+func (g *GraphAnalysis) IsBlocked(id string) bool {
+	return g != nil && g.blocked[id]
+}
+
+// IsReady reports whether a task is pending and not blocked.
+func (g *GraphAnalysis) IsReady(id string) bool {
+	return g != nil && g.ready[id]
+}
+
+// OnCriticalPath reports whether a task is on the critical path.
+func (g *GraphAnalysis) OnCriticalPath(id string) bool {
+	return g != nil && g.critical[id]
+}
+
+// CriticalEdge reports whether the dependency of one task on a blocker
+// links two steps of the critical path.
+func (g *GraphAnalysis) CriticalEdge(blocker, id string) bool {
+	return g.OnCriticalPath(blocker) && g.OnCriticalPath(id) &&
+		g.wave[blocker] == g.wave[id]-1
+}
+
+// InCycle reports whether a task is part of a dependency cycle.
+func (g *GraphAnalysis) InCycle(id string) bool {
+	return g != nil && g.cyclic[id]
+}
+
+// Wave returns the index of a task's wave, or -1 if the task is completed
+// or can never start.
+func (g *GraphAnalysis) Wave(id string) int {
+	if g == nil {
+		return -1
+	}
+	if w, ok := g.wave[id]; ok {
+		return w
+	}
+
+	return -1
+}
+
+// AnalyzeGraph analyzes the dependencies of a task list.
+func AnalyzeGraph(tasks []claudeagent.TaskListItem) *GraphAnalysis {
+	g := &GraphAnalysis{
+		Blocked:      []string{},
+		Ready:        []string{},
+		Waves:        [][]string{},
+		CriticalPath: []string{},
+		Cycles:       [][]string{},
+		Dangling:     []DanglingRef{},
+		blocked:      make(map[string]bool),
+		ready:        make(map[string]bool),
+		critical:     make(map[string]bool),
+		cyclic:       make(map[string]bool),
+		wave:         make(map[string]int),
+	}
+
+	pos := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		pos[task.ID] = i
+	}
+
+	// Collect the dependencies of every task from both sides of each
+	// edge, setting aside references to tasks that do not exist.
+	deps := make(map[string][]string, len(tasks))
+	seen := make(map[[2]string]bool)
+	addDep := func(task, blocker string) {
+		if seen[[2]string{task, blocker}] {
+			return
+		}
+		seen[[2]string{task, blocker}] = true
+		deps[task] = append(deps[task], blocker)
+	}
+	for _, task := range tasks {
+		for _, id := range task.BlockedBy {
+			if _, ok := pos[id]; !ok {
+				g.Dangling = append(g.Dangling, DanglingRef{
+					TaskID: task.ID, Missing: id,
+					Field: "blockedBy",
+				})
+				continue
+			}
+			addDep(task.ID, id)
+		}
+		for _, id := range task.Blocks {
+			if _, ok := pos[id]; !ok {
+				g.Dangling = append(g.Dangling, DanglingRef{
+					TaskID: task.ID, Missing: id,
+					Field: "blocks",
+				})
+				continue
+			}
+			addDep(id, task.ID)
+		}
+	}
+	for _, d := range deps {
+		sortByPos(d, pos)
+	}
+
+	g.deps = deps
+	g.findCycles(tasks, deps, pos)
+
+	incomplete := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		if task.Status != claudeagent.TaskListStatusCompleted {
+			incomplete[task.ID] = true
+		}
+	}
+
+	// Only incomplete dependencies count from here on: a completed
+	// blocker no longer holds anything up.
+	open := make(map[string][]string, len(tasks))
+	dependents := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		if !incomplete[task.ID] {
+			continue
+		}
+		for _, id := range deps[task.ID] {
+			if !incomplete[id] {
+				continue
+			}
+			open[task.ID] = append(open[task.ID], id)
+			dependents[id] = append(dependents[id], task.ID)
+		}
+
+		if len(open[task.ID]) > 0 {
+			g.blocked[task.ID] = true
+			g.Blocked = append(g.Blocked, task.ID)
+		} else if task.Status == claudeagent.TaskListStatusPending {
+			g.ready[task.ID] = true
+			g.Ready = append(g.Ready, task.ID)
+		}
+	}
+
+	g.findWaves(tasks, open, dependents, pos)
+	g.findCriticalPath(open)
+
+	return g
+}
+
+// sortByPos sorts task IDs into the order of the task list.
+func sortByPos(ids []string, pos map[string]int) {
+	sort.Slice(ids, func(i, j int) bool {
+		return pos[ids[i]] < pos[ids[j]]
+	})
+}
+
+// findCycles finds the strongly connected components of the dependency
+// graph with Tarjan's algorithm and records every one that forms a loop.
+func (g *GraphAnalysis) findCycles(tasks []claudeagent.TaskListItem,
+	deps map[string][]string, pos map[string]int) {
+
+	var (
+		index   = make(map[string]int, len(tasks))
+		lowlink = make(map[string]int, len(tasks))
+		onStack = make(map[string]bool, len(tasks))
+		stack   []string
+		next    int
+	)
+
+	var connect func(id string)
+	connect = func(id string) {
+		index[id] = next
+		lowlink[id] = next
+		next++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, dep := range deps[id] {
+			if _, ok := index[dep]; !ok {
+				connect(dep)
+				lowlink[id] = min(lowlink[id], lowlink[dep])
+			} else if onStack[dep] {
+				lowlink[id] = min(lowlink[id], index[dep])
+			}
+		}
+
+		if lowlink[id] != index[id] {
+			return
+		}
+
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+
+		loop := len(component) > 1
+		if !loop {
+			for _, dep := range deps[id] {
+				if dep == id {
+					loop = true
+				}
+			}
+		}
+		if !loop {
+			return
+		}
+
+		sortByPos(component, pos)
+		for _, member := range component {
+			g.cyclic[member] = true
+		}
+		g.Cycles = append(g.Cycles, component)
+	}
+
+	for _, task := range tasks {
+		if _, ok := index[task.ID]; !ok {
+			connect(task.ID)
+		}
+	}
+
+	sort.Slice(g.Cycles, func(i, j int) bool {
+		return pos[g.Cycles[i][0]] < pos[g.Cycles[j][0]]
+	})
+}
+
+// findWaves levels the incomplete tasks with Kahn's algorithm. A task is
+// placed in the wave after the last of its incomplete dependencies.
+func (g *GraphAnalysis) findWaves(tasks []claudeagent.TaskListItem,
+	open, dependents map[string][]string, pos map[string]int) {
+
+	remaining := make(map[string]int, len(open))
+	var current []string
+	for _, task := range tasks {
+		if task.Status == claudeagent.TaskListStatusCompleted {
+			continue
+		}
+		remaining[task.ID] = len(open[task.ID])
+		if remaining[task.ID] == 0 {
+			current = append(current, task.ID)
+		}
+	}
+
+	for len(current) > 0 {
+		sortByPos(current, pos)
+		for _, id := range current {
+			g.wave[id] = len(g.Waves)
+		}
+		g.Waves = append(g.Waves, current)
+
+		var next []string
+		for _, id := range current {
+			for _, dependent := range dependents[id] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		current = next
+	}
+}
+
+// findCriticalPath walks back from the first task of the last wave, each
+// step to a dependency in the wave before. Since a task's wave is one past
+// its latest dependency, such a dependency always exists and the chain
+// spans every wave.
+func (g *GraphAnalysis) findCriticalPath(open map[string][]string) {
+	if len(g.Waves) < 2 {
+		return
+	}
+
+	last := len(g.Waves) - 1
+	path := make([]string, len(g.Waves))
+	path[last] = g.Waves[last][0]
+	for w := last; w > 0; w-- {
+		for _, dep := range open[path[w]] {
+			if g.wave[dep] == w-1 {
+				path[w-1] = dep
+				break
+			}
+		}
+	}
+
+	g.CriticalPath = path
+	for _, id := range path {
+		g.critical[id] = true
+	}
+}
@@ -0,0 +1,222 @@
+package taskviewer
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// graphTask returns a test task in the given status with dependencies.
+func graphTask(id string, status claudeagent.TaskListStatus,
+	blockedBy, blocks []string) claudeagent.TaskListItem {
+
+	task := withStatus(id, status)
+	task.BlockedBy = blockedBy
+	task.Blocks = blocks
+
+	return task
+}
+
+// TestAnalyzeGraph checks blocked and ready tasks, dangling references,
+// cycles, waves and the critical path of a task list.
+func TestAnalyzeGraph(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []claudeagent.TaskListItem
+
+		wantBlocked  []string
+		wantReady    []string
+		wantWaves    [][]string
+		wantCritical []string
+		wantCycles   [][]string
+		wantDangling []DanglingRef
+	}{
+		{
+			name: "completed blockers",
+			tasks: []claudeagent.TaskListItem{
+				graphTask("1", completed, nil, []string{"2"}),
+				graphTask("2", pending, []string{"1"}, nil),
+				graphTask("3", pending, []string{"1", "2"},
+					nil),
+			},
+			wantBlocked:  []string{"3"},
+			wantReady:    []string{"2"},
+			wantWaves:    [][]string{{"2"}, {"3"}},
+			wantCritical: []string{"2", "3"},
+		},
+		{
+			name: "dangling",
+			tasks: []claudeagent.TaskListItem{
+				graphTask("1", pending, []string{"9"}, nil),
+				graphTask("2", inProgress, nil,
+					[]string{"1", "8"}),
+			},
+			wantBlocked:  []string{"1"},
+			wantReady:    []string{},
+			wantWaves:    [][]string{{"2"}, {"1"}},
+			wantCritical: []string{"2", "1"},
+			wantDangling: []DanglingRef{
+				{TaskID: "1", Missing: "9", Field: "blockedBy"},
+				{TaskID: "2", Missing: "8", Field: "blocks"},
+			},
+		},
+		{
+			name: "self loop",
+			tasks: []claudeagent.TaskListItem{
+				graphTask("1", pending, []string{"1"}, nil),
+				graphTask("2", pending, nil, nil),
+			},
+			wantBlocked: []string{"1"},
+			wantReady:   []string{"2"},
+			wantWaves:   [][]string{{"2"}},
+			wantCycles:  [][]string{{"1"}},
+		},
+		{
+			// Task 4 waits behind the cycle, so it never starts
+			// either.
+			name: "cycle",
+			tasks: []claudeagent.TaskListItem{
+				graphTask("1", pending, []string{"3"}, nil),
+				graphTask("2", pending, []string{"1"},
+					[]string{"3"}),
+				graphTask("3", pending, nil, []string{"1"}),
+				graphTask("4", pending, []string{"3"}, nil),
+				graphTask("5", pending, nil, []string{"3"}),
+				graphTask("6", pending, nil, nil),
+			},
+			wantBlocked: []string{"1", "2", "3", "4"},
+			wantReady:   []string{"5", "6"},
+			wantWaves:   [][]string{{"5", "6"}},
+			wantCycles:  [][]string{{"1", "2", "3"}},
+		},
+		{
+			name: "waves",
+			tasks: []claudeagent.TaskListItem{
+				graphTask("1", pending, nil, nil),
+				graphTask("2", inProgress, nil, []string{"4"}),
+				graphTask("3", pending, []string{"1"}, nil),
+				graphTask("4", pending, []string{"1", "3"},
+					nil),
+				graphTask("5", pending, []string{"2"}, nil),
+				graphTask("6", completed, nil, []string{"5"}),
+			},
+			wantBlocked:  []string{"3", "4", "5"},
+			wantReady:    []string{"1"},
+			wantWaves:    [][]string{{"1", "2"}, {"3", "5"}, {"4"}},
+			wantCritical: []string{"1", "3", "4"},
+		},
+		{
+			name:      "empty",
+			wantReady: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := AnalyzeGraph(test.tasks)
+
+			if test.wantBlocked == nil {
+				test.wantBlocked = []string{}
+			}
+			if test.wantWaves == nil {
+				test.wantWaves = [][]string{}
+			}
+			if test.wantCritical == nil {
+				test.wantCritical = []string{}
+			}
+			if test.wantCycles == nil {
+				test.wantCycles = [][]string{}
+			}
+			if test.wantDangling == nil {
+				test.wantDangling = []DanglingRef{}
+			}
+
+			if !reflect.DeepEqual(g.Blocked, test.wantBlocked) {
+				t.Fatalf("blocked %v, want %v", g.Blocked,
+					test.wantBlocked)
+			}
+			if !reflect.DeepEqual(g.Ready, test.wantReady) {
+				t.Fatalf("ready %v, want %v", g.Ready,
+					test.wantReady)
+			}
+			if !reflect.DeepEqual(g.Waves, test.wantWaves) {
+				t.Fatalf("waves %v, want %v", g.Waves,
+					test.wantWaves)
+			}
+			if !reflect.DeepEqual(
+				g.CriticalPath, test.wantCritical,
+			) {
+
+				t.Fatalf("critical path %v, want %v",
+					g.CriticalPath, test.wantCritical)
+			}
+			if !reflect.DeepEqual(g.Cycles, test.wantCycles) {
+				t.Fatalf("cycles %v, want %v", g.Cycles,
+					test.wantCycles)
+			}
+			if !reflect.DeepEqual(g.Dangling, test.wantDangling) {
+				t.Fatalf("dangling %+v, want %+v", g.Dangling,
+					test.wantDangling)
+			}
+
+			// The critical path takes one task from every wave,
+			// in order.
+			for i, id := range g.CriticalPath {
+				if g.Wave(id) != i ||
+					!slices.Contains(g.Waves[i], id) {
+
+					t.Fatalf("critical task %s not in "+
+						"wave %d", id, i)
+				}
+				if !g.OnCriticalPath(id) {
+					t.Fatalf("critical task %s not on the "+
+						"critical path", id)
+				}
+			}
+			for _, task := range test.tasks {
+				if g.IsBlocked(task.ID) !=
+					slices.Contains(g.Blocked, task.ID) {
+
+					t.Fatalf("IsBlocked(%s) disagrees",
+						task.ID)
+				}
+				if g.InCycle(task.ID) != slices.ContainsFunc(
+					g.Cycles, func(c []string) bool {
+						return slices.Contains(
+							c, task.ID,
+						)
+					},
+				) {
+
+					t.Fatalf("InCycle(%s) disagrees",
+						task.ID)
+				}
+			}
+		})
+	}
+}
+
+// TestGraphBlockers checks that blockers are gathered from both fields in
+// list order, completed or not, without missing tasks.
+func TestGraphBlockers(t *testing.T) {
+	g := AnalyzeGraph([]claudeagent.TaskListItem{
+		graphTask("1", completed, nil, []string{"3"}),
+		graphTask("2", pending, nil, nil),
+		graphTask("3", pending, []string{"2", "1", "7"}, nil),
+	})
+
+	want := []string{"1", "2"}
+	if got := g.Blockers("3"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got blockers %v, want %v", got, want)
+	}
+	if g.Wave("1") != -1 {
+		t.Fatalf("completed task has wave %d", g.Wave("1"))
+	}
+
+	var nilGraph *GraphAnalysis
+	if nilGraph.Blockers("3") != nil || nilGraph.Wave("3") != -1 {
+		t.Fatalf("nil analysis reports dependencies")
+	}
+}
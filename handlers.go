@@ -1,9 +1,16 @@
 package taskviewer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
 )
@@ -23,6 +30,13 @@ type IndexData struct {
 	Groups         []ProjectGroup
 	ActiveLists    []ActiveTaskList
 	TotalTaskCount int
+
+	// SignedIn is true if the browser signed in with the password, and
+	// so can sign out.
+	SignedIn bool
+
+	// Fleet is true if the viewer is a hub with a fleet page.
+	Fleet bool
 }
 
 // ListSummary summarizes a task list.
@@ -40,13 +54,26 @@ type ProjectViewData struct {
 	Project            Project
 	Sessions           []SessionViewEntry
 	SessionsWithTasks  int
+
+	// Usage is the project's token usage, if its transcripts could be
+	// read.
+	Usage *ProjectUsage
+
+	// Tree holds the project's main sessions with the sidechain sessions
+	// they started below them, and Sidechains counts the sidechains.
+	Tree       []*SessionNode
+	Sidechains int
 }
 
 // SessionViewEntry extends SessionEntry with task info.
 type SessionViewEntry struct {
 	SessionEntry
-	TaskCount int
-	HasTasks  bool
+	TaskCount int  `json:"taskCount"`
+	HasTasks  bool `json:"hasTasks"`
+
+	// Archived is true if the session's live tasks are gone but a
+	// snapshot of them is in the archive.
+	Archived bool `json:"archived"`
 }
 
 // ListViewData holds data for the task list view.
@@ -58,6 +85,25 @@ type ListViewData struct {
 	PendingCount    int
 	InProgressCount int
 	CompletedCount  int
+
+	// Archived is true if the tasks were loaded from the archive because
+	// the session's live task files no longer exist.
+	Archived bool
+
+	// Timing holds how long each task has spent in its status, and
+	// TaskTimes indexes it by task ID.
+	Timing    ListTiming
+	TaskTimes map[string]TaskTiming
+
+	// Burndown is the list's burndown chart, or nil if there is no
+	// history to draw.
+	Burndown *BurndownChart
+
+	// Writable is true if tasks can be added to the list.
+	Writable bool
+
+	// Graph is the dependency analysis of the whole list.
+	Graph *GraphAnalysis
 }
 
 // TaskDetailData holds data for the task detail view.
@@ -66,6 +112,64 @@ type TaskDetailData struct {
 	Task     *claudeagent.TaskListItem
 	Blockers []claudeagent.TaskListItem
 	Blocking []claudeagent.TaskListItem
+	Archived bool
+
+	// History is the task's recorded changes, oldest first.
+	History []TaskChange
+
+	// Writable is true if the task can be edited, and Candidates holds
+	// the tasks that can be added as its blockers.
+	Writable   bool
+	Candidates []claudeagent.TaskListItem
+}
+
+// ActivityData holds data for a list's activity feed.
+type ActivityData struct {
+	PageData
+
+	// Changes holds the most recent changes, newest first.
+	Changes []TaskChange
+
+	// Total is the number of changes recorded for the list.
+	Total int
+
+	// Recording is false when the archive, which records history, is
+	// disabled.
+	Recording bool
+}
+
+// NotificationsData holds data for the webhook delivery log.
+type NotificationsData struct {
+	PageData
+
+	// Webhooks describes the configured webhooks.
+	Webhooks []WebhookSummary
+
+	// Deliveries holds the recent deliveries, newest first.
+	Deliveries []Delivery
+}
+
+// SearchData holds data for the search page.
+type SearchData struct {
+	PageData
+
+	// Query is the search as entered, and Kind the single kind of
+	// document it is restricted to, if any.
+	Query SearchQuery
+	Kind  string
+
+	// Since and Until are the date filters as entered.
+	Since string
+	Until string
+
+	// Results holds the matches, or nothing if no search was entered.
+	Results SearchResults
+
+	// Error explains why the search could not be run.
+	Error string
+
+	// Stats describes the search index.
+	Stats SearchStats
 }
 
 // AllTasksData holds data for the unified tasks view.
@@ -86,12 +190,28 @@ type SessionTasks struct {
 	ProjectName string
 	Summary     string
 	Tasks       []claudeagent.TaskListItem
+
+	// Graph is the dependency analysis of the session's whole list.
+	Graph *GraphAnalysis
+}
+
+// SessionPageData holds data for the session transcript view.
+type SessionPageData struct {
+	PageData
+	Session       SessionEntry
+	ProjectDir    string
+	Transcript    *Transcript
+	TaskCount     int
+	Archived      bool
+	ToolCallCount int
 }
 
 // GraphData holds data for the dependency graph API.
 type GraphData struct {
-	Nodes []GraphNode `json:"nodes"`
-	Edges []GraphEdge `json:"edges"`
+	Nodes    []GraphNode    `json:"nodes"`
+	Edges    []GraphEdge    `json:"edges"`
+	Archived bool           `json:"archived,omitempty"`
+	Analysis *GraphAnalysis `json:"analysis"`
 }
 
 // GraphNode represents a task in the graph.
@@ -101,12 +221,33 @@ type GraphNode struct {
 	Status      string `json:"status"`
 	IsBlocked   bool   `json:"isBlocked"`
 	Description string `json:"description,omitempty"`
+
+	// IsReady is true if the task is pending and not blocked.
+	IsReady bool `json:"isReady"`
+
+	// Wave is the index of the task's wave, or -1 if it is completed
+	// or can never start.
+	Wave int `json:"wave"`
+
+	// Critical is true if the task is on the critical path.
+	Critical bool `json:"critical"`
+
+	// InCycle is true if the task is part of a dependency cycle.
+	InCycle bool `json:"inCycle"`
 }
 
 // GraphEdge represents a dependency relationship.
 type GraphEdge struct {
 	Source string `json:"source"`
 	Target string `json:"target"`
+
+	// Satisfied is true if the source task is completed, so the edge no
+	// longer blocks the target.
+	Satisfied bool `json:"satisfied"`
+
+	// Critical is true if the edge links two steps of the critical
+	// path.
+	Critical bool `json:"critical"`
 }
 
 // handleIndex renders the dashboard with all projects.
@@ -135,6 +276,8 @@ func (h *HTTPServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 		Groups:         groups,
 		ActiveLists:    activeLists,
 		TotalTaskCount: totalTaskCount,
+		SignedIn:       h.auth.SignedIn(r),
+		Fleet:          h.federation.IsHub(),
 	}
 
 	h.render(w, "index.html", data)
@@ -146,8 +289,8 @@ func (h *HTTPServer) handleAllTasks(w http.ResponseWriter, r *http.Request) {
 
 	// Get filter from query param, default to "active".
 	filter := r.URL.Query().Get("filter")
-	if filter == "" {
-		filter = "active"
+	if filter == "" || !validTaskFilter(filter) {
+		filter = TaskFilterActive
 	}
 
 	// Get all active task lists.
@@ -193,32 +336,7 @@ func (h *HTTPServer) handleAllTasks(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Filter tasks based on filter param.
-		var filtered []claudeagent.TaskListItem
-		for _, t := range tasks {
-			include := false
-			switch filter {
-			case "all":
-				include = true
-
-			case "active":
-				include = t.Status == "pending" || t.Status == "in_progress"
-
-			case "pending":
-				include = t.Status == "pending"
-
-			case "in_progress":
-				include = t.Status == "in_progress"
-
-			case "completed":
-				include = t.Status == "completed"
-
-			default:
-				include = t.Status == "pending" || t.Status == "in_progress"
-			}
-			if include {
-				filtered = append(filtered, t)
-			}
-		}
+		filtered := filterTasks(tasks, filter)
 
 		// Only add session if it has tasks after filtering.
 		if len(filtered) > 0 {
@@ -227,6 +345,7 @@ func (h *HTTPServer) handleAllTasks(w http.ResponseWriter, r *http.Request) {
 				ProjectName: active.ProjectName,
 				Summary:     active.Summary,
 				Tasks:       filtered,
+				Graph:       AnalyzeGraph(tasks),
 			}
 			tasksBySession = append(tasksBySession, st)
 		}
@@ -267,17 +386,15 @@ func (h *HTTPServer) handleProjectView(w http.ResponseWriter, r *http.Request) {
 	// Build session view entries with task counts.
 	sessions := make([]SessionViewEntry, len(project.Sessions))
 	sessionsWithTasks := 0
+	sidechains := 0
 	for i, s := range project.Sessions {
-		taskCount := h.projectIndexer.GetTaskCount(s.SessionID)
-		hasTasks := taskCount > 0
-		sessions[i] = SessionViewEntry{
-			SessionEntry: s,
-			TaskCount:    taskCount,
-			HasTasks:     hasTasks,
-		}
-		if hasTasks {
+		sessions[i] = h.sessionViewEntry(s)
+		if sessions[i].HasTasks {
 			sessionsWithTasks++
 		}
+		if s.IsSidechain {
+			sidechains++
+		}
 	}
 
 	data := ProjectViewData{
@@ -288,8 +405,17 @@ func (h *HTTPServer) handleProjectView(w http.ResponseWriter, r *http.Request) {
 		Project:           project,
 		Sessions:          sessions,
 		SessionsWithTasks: sessionsWithTasks,
+		Sidechains:        sidechains,
 	}
 
+	usage, err := h.usageTracker.ProjectUsage(dirName)
+	if err != nil {
+		h.log.Warnf("Unable to read usage of %s: %v", dirName, err)
+	} else {
+		data.Usage = &usage
+	}
+	data.Tree = buildSessionTree(sessions, data.Usage)
+
 	h.render(w, "project.html", data)
 }
 
@@ -299,46 +425,37 @@ func (h *HTTPServer) handleListView(w http.ResponseWriter, r *http.Request) {
 	listID := r.PathValue("listID")
 	filter := r.URL.Query().Get("filter")
 
-	tasks, err := h.taskStore.List(ctx, listID)
+	tasks, archived, err := h.listTasks(ctx, listID)
 	if err != nil {
 		h.renderError(w, "Failed to load tasks: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Count tasks by status.
-	var pendingCount, inProgressCount, completedCount int
-	for _, t := range tasks {
-		switch t.Status {
-		case "pending":
-			pendingCount++
-		case "in_progress":
-			inProgressCount++
-		case "completed":
-			completedCount++
-		}
-	}
-
-	// Apply filter.
-	filtered := tasks
-	if filter != "" {
-		filtered = nil
-		for _, t := range tasks {
-			if string(t.Status) == filter {
-				filtered = append(filtered, t)
-			}
-		}
-	}
+	counts := countTasks(tasks)
 
 	data := ListViewData{
 		PageData:        PageData{Title: "Tasks - " + listID, ListID: listID},
-		Tasks:           filtered,
+		Tasks:           filterTasks(tasks, filter),
 		Filter:          filter,
-		TotalCount:      len(tasks),
-		PendingCount:    pendingCount,
-		InProgressCount: inProgressCount,
-		CompletedCount:  completedCount,
+		TotalCount:      counts.Total,
+		PendingCount:    counts.Pending,
+		InProgressCount: counts.InProgress,
+		CompletedCount:  counts.Completed,
+		Archived:        archived,
+		Writable:        h.cfg.AllowWrites && !archived,
+		Graph:           AnalyzeGraph(tasks),
 	}
 
+	data.Timing = h.listTiming(listID, tasks, archived)
+	data.TaskTimes = make(map[string]TaskTiming, len(data.Timing.Tasks))
+	for _, tt := range data.Timing.Tasks {
+		data.TaskTimes[tt.TaskID] = tt
+	}
+	end := data.Timing.FirstSeen.Add(
+		time.Duration(data.Timing.ElapsedSeconds * float64(time.Second)),
+	)
+	data.Burndown = newBurndownChart(data.Timing.Burndown, end)
+
 	h.render(w, "tasks.html", data)
 }
 
@@ -348,7 +465,7 @@ func (h *HTTPServer) handleTaskDetail(w http.ResponseWriter, r *http.Request) {
 	listID := r.PathValue("listID")
 	taskID := r.PathValue("taskID")
 
-	task, err := h.taskStore.Get(ctx, listID, taskID)
+	task, archived, err := h.getTask(ctx, listID, taskID)
 	if err != nil {
 		h.renderError(w, "Task not found: "+err.Error(), http.StatusNotFound)
 		return
@@ -356,7 +473,7 @@ func (h *HTTPServer) handleTaskDetail(w http.ResponseWriter, r *http.Request) {
 
 	// Load blocker and blocking tasks.
 	var blockers, blocking []claudeagent.TaskListItem
-	allTasks, _ := h.taskStore.List(ctx, listID)
+	allTasks, _, _ := h.listTasks(ctx, listID)
 
 	taskMap := make(map[string]claudeagent.TaskListItem)
 	for _, t := range allTasks {
@@ -383,11 +500,203 @@ func (h *HTTPServer) handleTaskDetail(w http.ResponseWriter, r *http.Request) {
 		Task:     task,
 		Blockers: blockers,
 		Blocking: blocking,
+		Archived: archived,
+		Writable: h.cfg.AllowWrites && !archived,
+	}
+	if data.Writable {
+		data.Candidates = blockerCandidates(allTasks, task)
 	}
 
+	// History is a nice-to-have, so the task still renders without it.
+	history, err := h.taskArchive.TaskHistory(listID, taskID)
+	if err != nil {
+		h.log.Warnf("Unable to load task history: %v", err)
+	}
+	data.History = history
+
 	h.render(w, "task_detail.html", data)
 }
 
+// handleListActivity renders the recorded changes of a list, newest first.
+func (h *HTTPServer) handleListActivity(w http.ResponseWriter,
+	r *http.Request) {
+
+	listID := r.PathValue("listID")
+
+	history, err := h.taskArchive.History(listID)
+	if err != nil {
+		h.renderError(
+			w, "Failed to load activity: "+err.Error(),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+
+	data := ActivityData{
+		PageData: PageData{
+			Title:  "Activity - " + listID,
+			ListID: listID,
+		},
+		Total:     len(history),
+		Recording: h.taskArchive != nil,
+	}
+
+	// Show the most recent changes first.
+	limit := min(len(history), activityFeedLimit)
+	for i := len(history) - 1; i >= len(history)-limit; i-- {
+		data.Changes = append(data.Changes, history[i])
+	}
+
+	h.render(w, "activity.html", data)
+}
+
+// handleNotifications renders the configured webhooks and the log of recent
+// deliveries.
+func (h *HTTPServer) handleNotifications(w http.ResponseWriter,
+	r *http.Request) {
+
+	data := NotificationsData{
+		PageData:   PageData{Title: "Notifications"},
+		Webhooks:   h.notifier.Webhooks(),
+		Deliveries: h.notifier.Deliveries(),
+	}
+
+	h.render(w, "notifications.html", data)
+}
+
+// handleSearch renders the search form and, if a query was entered, its
+// results.
+func (h *HTTPServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	data := SearchData{
+		PageData: PageData{Title: "Search"},
+		Since:    r.URL.Query().Get("since"),
+		Until:    r.URL.Query().Get("until"),
+		Stats:    h.searchIndex.Stats(),
+	}
+
+	q, err := parseSearchQuery(r)
+	data.Query = q
+	if len(q.Kinds) == 1 {
+		data.Kind = q.Kinds[0]
+	}
+
+	switch {
+	case err != nil:
+		data.Error = err.Error()
+
+	case q.Text != "":
+		data.Title = q.Text + " - Search"
+		data.Results, err = h.searchIndex.Search(q)
+		if err != nil {
+			data.Error = err.Error()
+		}
+	}
+
+	h.render(w, "search.html", data)
+}
+
+// sameOrigin reports whether a request was made from the viewer's own pages,
+// or by a client that is not a browser. Browsers send Origin with every form
+// submission and fetch that changes state, so a request from another site
+// can always be told apart.
+func sameOrigin(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+		return true
+
+	default:
+		return false
+	}
+}
+
+// handleNotificationTest sends a test notification to every webhook.
+func (h *HTTPServer) handleNotificationTest(w http.ResponseWriter,
+	r *http.Request) {
+
+	if !sameOrigin(r) {
+		writeAPIError(
+			w, http.StatusForbidden, ErrCodeForbidden,
+			"cross-origin requests are not allowed",
+		)
+		return
+	}
+
+	if h.notifier == nil {
+		writeAPIError(
+			w, http.StatusConflict, ErrCodeConflict,
+			"no webhooks configured",
+		)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]int{
+		"webhooks": h.notifier.SendTest(),
+	})
+}
+
+// handleSessionView renders a session's conversation from its transcript.
+func (h *HTTPServer) handleSessionView(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+
+	path, ok := h.projectIndexer.TranscriptPath(sessionID)
+	if !ok {
+		h.renderError(
+			w, "Transcript not found for session "+sessionID,
+			http.StatusNotFound,
+		)
+		return
+	}
+
+	transcript, err := LoadTranscript(path)
+	if err != nil {
+		h.renderError(
+			w, "Failed to read transcript: "+err.Error(),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+
+	session, projectDir, _ := h.projectIndexer.GetSession(sessionID)
+	entry := h.sessionViewEntry(session)
+
+	toolCalls := 0
+	for _, m := range transcript.Messages {
+		for _, b := range m.Blocks {
+			if b.Kind == BlockToolUse {
+				toolCalls++
+			}
+		}
+	}
+
+	title := session.Summary
+	if title == "" {
+		title = transcript.Summary
+	}
+	if title == "" {
+		title = "Session " + sessionID
+	}
+
+	data := SessionPageData{
+		PageData: PageData{
+			Title:  title,
+			ListID: sessionID,
+		},
+		Session:       session,
+		ProjectDir:    projectDir,
+		Transcript:    transcript,
+		TaskCount:     entry.TaskCount,
+		Archived:      entry.Archived,
+		ToolCallCount: toolCalls,
+	}
+
+	h.render(w, "session.html", data)
+}
+
 // handleGraphView renders the dependency graph page.
 func (h *HTTPServer) handleGraphView(w http.ResponseWriter, r *http.Request) {
 	listID := r.PathValue("listID")
@@ -405,15 +714,26 @@ func (h *HTTPServer) handleGraphData(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	listID := r.PathValue("listID")
 
-	tasks, err := h.taskStore.List(ctx, listID)
+	tasks, archived, err := h.listTasks(ctx, listID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to load tasks: "+err.Error(),
+		)
 		return
 	}
 
+	analysis := AnalyzeGraph(tasks)
 	graph := GraphData{
-		Nodes: make([]GraphNode, 0, len(tasks)),
-		Edges: make([]GraphEdge, 0),
+		Nodes:    make([]GraphNode, 0, len(tasks)),
+		Edges:    make([]GraphEdge, 0),
+		Archived: archived,
+		Analysis: analysis,
+	}
+
+	status := make(map[string]claudeagent.TaskListStatus, len(tasks))
+	for _, t := range tasks {
+		status[t.ID] = t.Status
 	}
 
 	for _, t := range tasks {
@@ -421,16 +741,24 @@ func (h *HTTPServer) handleGraphData(w http.ResponseWriter, r *http.Request) {
 			ID:          t.ID,
 			Label:       t.Subject,
 			Status:      string(t.Status),
-			IsBlocked:   len(t.BlockedBy) > 0,
+			IsBlocked:   analysis.IsBlocked(t.ID),
 			Description: t.Description,
+			IsReady:     analysis.IsReady(t.ID),
+			Wave:        analysis.Wave(t.ID),
+			Critical:    analysis.OnCriticalPath(t.ID),
+			InCycle:     analysis.InCycle(t.ID),
 		}
 		graph.Nodes = append(graph.Nodes, node)
 
-		// Add edges for blockedBy relationships.
-		for _, blockerID := range t.BlockedBy {
+		// Add an edge from each blocker. Blockers that do not exist
+		// are reported in the analysis rather than drawn.
+		for _, blockerID := range analysis.Blockers(t.ID) {
 			graph.Edges = append(graph.Edges, GraphEdge{
 				Source: blockerID,
 				Target: t.ID,
+				Satisfied: status[blockerID] ==
+					claudeagent.TaskListStatusCompleted,
+				Critical: analysis.CriticalEdge(blockerID, t.ID),
 			})
 		}
 	}
@@ -439,10 +767,52 @@ func (h *HTTPServer) handleGraphData(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(graph)
 }
 
-// handleSSE streams task events via Server-Sent Events.
+// handleSSE streams the events of a single task list via Server-Sent Events.
+// Unknown lists are refused before the bus starts following them.
 func (h *HTTPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
 	listID := r.PathValue("listID")
+	if !h.knownList(listID) {
+		http.Error(w, "Task list not found", http.StatusNotFound)
+		return
+	}
+
+	h.streamEvents(w, r, listID)
+}
+
+// knownList reports whether a list ID names a live task list, an archived
+// one, or an indexed session that may still create one.
+func (h *HTTPServer) knownList(listID string) bool {
+	if !validListID(listID) {
+		return false
+	}
+
+	if h.projectIndexer.HasTaskDir(listID) || h.taskArchive.Has(listID) {
+		return true
+	}
+
+	_, _, ok := h.projectIndexer.GetSession(listID)
+
+	return ok
+}
+
+// handleEvents streams every event on the event bus via Server-Sent Events:
+// task events from all task lists, sessions appearing and ending, project
+// index changes, and instances starting and exiting.
+func (h *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	h.streamEvents(w, r, "")
+}
+
+// streamEvents streams events from the event bus via Server-Sent Events,
+// restricted to one list if listID is not empty.
+//
+// Every event carries its bus ID, so a reconnecting client's Last-Event-ID
+// header resumes the stream where it left off. If the events it missed are
+// no longer buffered, a reset event tells it to reload instead. A dropped
+// event reports the number of events lost because the client fell behind.
+func (h *HTTPServer) streamEvents(w http.ResponseWriter, r *http.Request,
+	listID string) {
+
+	ctx := r.Context()
 
 	// Set SSE headers.
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -455,18 +825,41 @@ func (h *HTTPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Subscribe to task events.
-	events, err := h.taskStore.Subscribe(ctx, listID)
-	if err != nil {
-		http.Error(w, "Failed to subscribe: "+err.Error(), http.StatusInternalServerError)
-		return
+	// The stream outlives the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// A malformed Last-Event-ID is treated as a fresh connection.
+	lastEventID, _ := strconv.ParseUint(
+		r.Header.Get("Last-Event-ID"), 10, 64,
+	)
+
+	sub, replay, complete := h.eventBus.Subscribe(listID, lastEventID)
+	defer sub.Close()
+
+	atomic.AddInt64(&h.sseClients, 1)
+	defer atomic.AddInt64(&h.sseClients, -1)
+
+	writeEvent := func(event Event) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID,
+			event.Type, data)
 	}
 
-	// Send initial ping.
+	// Send initial ping, then whatever the client missed.
 	fmt.Fprintf(w, "event: ping\ndata: connected\n\n")
+	if !complete {
+		fmt.Fprintf(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeEvent(event)
+	}
 	flusher.Flush()
 
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
 	// Stream events.
+	var reportedDrops uint64
 	for {
 		select {
 		case <-ctx.Done():
@@ -475,13 +868,23 @@ func (h *HTTPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 		case <-h.quit:
 			return
 
-		case event, ok := <-events:
+		case <-heartbeat.C:
+			// Comments keep proxies from closing an idle stream.
+			fmt.Fprintf(w, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case event, ok := <-sub.Events():
 			if !ok {
 				return
 			}
 
-			data, _ := json.Marshal(event)
-			fmt.Fprintf(w, "event: task-%s\ndata: %s\n\n", event.Type, data)
+			if dropped := sub.Dropped(); dropped > reportedDrops {
+				fmt.Fprintf(w, "event: dropped\n"+
+					"data: {\"dropped\":%d}\n\n", dropped)
+				reportedDrops = dropped
+			}
+
+			writeEvent(event)
 			flusher.Flush()
 		}
 	}
@@ -493,7 +896,7 @@ func (h *HTTPServer) handleTaskPartial(w http.ResponseWriter, r *http.Request) {
 	listID := r.PathValue("listID")
 	taskID := r.PathValue("taskID")
 
-	task, err := h.taskStore.Get(ctx, listID, taskID)
+	task, _, err := h.getTask(ctx, listID, taskID)
 	if err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
@@ -508,38 +911,222 @@ func (h *HTTPServer) handleTasksPartial(w http.ResponseWriter, r *http.Request)
 	listID := r.PathValue("listID")
 	filter := r.URL.Query().Get("filter")
 
-	tasks, err := h.taskStore.List(ctx, listID)
+	tasks, _, err := h.listTasks(ctx, listID)
 	if err != nil {
 		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
 		return
 	}
 
-	// Apply filter.
-	filtered := tasks
-	if filter != "" {
-		filtered = nil
-		for _, t := range tasks {
-			if string(t.Status) == filter {
-				filtered = append(filtered, t)
-			}
-		}
-	}
-
 	data := struct {
 		Tasks  []claudeagent.TaskListItem
 		ListID string
+		Graph  *GraphAnalysis
 	}{
-		Tasks:  filtered,
+		Tasks:  filterTasks(tasks, filter),
 		ListID: listID,
+		Graph:  AnalyzeGraph(tasks),
 	}
 
 	h.render(w, "tasks_list.html", data)
 }
 
+// listTasks loads a task list from the task store, falling back to the
+// archive when the session's live task files are gone. The returned flag
+// reports whether the tasks came from the archive.
+func (h *HTTPServer) listTasks(ctx context.Context,
+	listID string) ([]claudeagent.TaskListItem, bool, error) {
+
+	return loadTasks(
+		ctx, h.taskStore, h.projectIndexer, h.taskArchive, listID,
+	)
+}
+
+// loadTasks loads a task list from a task store, falling back to the
+// archive, which may be nil.
+func loadTasks(ctx context.Context, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, taskArchive *TaskArchive,
+	listID string) ([]claudeagent.TaskListItem, bool, error) {
+
+	tasks, err := taskStore.List(ctx, listID)
+	if err == nil && len(tasks) > 0 {
+		return tasks, false, nil
+	}
+
+	// Only consult the archive once the live list is gone, so a running
+	// session whose tasks were all deleted is not shown its old board.
+	if projectIndexer.HasTaskDir(listID) {
+		return tasks, false, err
+	}
+
+	if snapshot, ok := taskArchive.Get(listID); ok {
+		return snapshot.Tasks, true, nil
+	}
+
+	return tasks, false, err
+}
+
+// getTask loads a single task, falling back to the archive when the
+// session's live task files are gone. An error wrapping errTaskNotFound is
+// returned if the task does not exist.
+func (h *HTTPServer) getTask(ctx context.Context, listID,
+	taskID string) (*claudeagent.TaskListItem, bool, error) {
+
+	task, err := h.taskStore.Get(ctx, listID, taskID)
+	if err == nil {
+		return task, false, nil
+	}
+
+	// Only consult the archive once the live list is gone, so a task
+	// deleted from a running session is not resurrected.
+	if h.projectIndexer.HasTaskDir(listID) {
+		return nil, false, h.taskLoadError(ctx, listID, taskID, err)
+	}
+
+	if snapshot, ok := h.taskArchive.Get(listID); ok {
+		for _, t := range snapshot.Tasks {
+			if t.ID == taskID {
+				return &t, true, nil
+			}
+		}
+	}
+
+	return nil, false, h.taskLoadError(ctx, listID, taskID, err)
+}
+
+// errTaskNotFound is returned for a task that does not exist.
+var errTaskNotFound = errors.New("task not found")
+
+// taskLoadError tells a task that does not exist apart from a task store
+// failure, by whether the task is still listed.
+func (h *HTTPServer) taskLoadError(ctx context.Context, listID,
+	taskID string, err error) error {
+
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", errTaskNotFound, taskID)
+	}
+
+	tasks, listErr := h.taskStore.List(ctx, listID)
+	if listErr != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if t.ID == taskID {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errTaskNotFound, taskID)
+}
+
+// Task filters accepted by the task views and the API, in addition to the
+// task statuses themselves.
+const (
+	// TaskFilterAll matches every task.
+	TaskFilterAll = "all"
+
+	// TaskFilterActive matches pending and in-progress tasks.
+	TaskFilterActive = "active"
+)
+
+// validTaskFilter reports whether filter is a known task filter.
+func validTaskFilter(filter string) bool {
+	switch claudeagent.TaskListStatus(filter) {
+	case "", TaskFilterAll, TaskFilterActive,
+		claudeagent.TaskListStatusPending,
+		claudeagent.TaskListStatusInProgress,
+		claudeagent.TaskListStatusCompleted:
+
+		return true
+
+	default:
+		return false
+	}
+}
+
+// filterTasks returns the tasks matching a filter. An empty filter matches
+// every task.
+func filterTasks(tasks []claudeagent.TaskListItem,
+	filter string) []claudeagent.TaskListItem {
+
+	if filter == "" || filter == TaskFilterAll {
+		return tasks
+	}
+
+	var filtered []claudeagent.TaskListItem
+	for _, t := range tasks {
+		include := string(t.Status) == filter
+		if filter == TaskFilterActive {
+			include = t.Status == claudeagent.TaskListStatusPending ||
+				t.Status == claudeagent.TaskListStatusInProgress
+		}
+
+		if include {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return filtered
+}
+
+// TaskCounts tallies the tasks of a list by status.
+type TaskCounts struct {
+	Total      int `json:"total"`
+	Pending    int `json:"pending"`
+	InProgress int `json:"inProgress"`
+	Completed  int `json:"completed"`
+}
+
+// countTasks tallies tasks by status.
+func countTasks(tasks []claudeagent.TaskListItem) TaskCounts {
+	counts := TaskCounts{Total: len(tasks)}
+	for _, t := range tasks {
+		switch t.Status {
+		case claudeagent.TaskListStatusPending:
+			counts.Pending++
+
+		case claudeagent.TaskListStatusInProgress:
+			counts.InProgress++
+
+		case claudeagent.TaskListStatusCompleted:
+			counts.Completed++
+		}
+	}
+
+	return counts
+}
+
+// sessionViewEntry builds the view of a session with its task info.
+func (h *HTTPServer) sessionViewEntry(s SessionEntry) SessionViewEntry {
+	return newSessionViewEntry(h.projectIndexer, h.taskArchive, s)
+}
+
+// newSessionViewEntry builds the view of a session with its task info,
+// counting archived tasks if its live ones are gone. The archive may be nil.
+func newSessionViewEntry(projectIndexer *ProjectIndexer,
+	taskArchive *TaskArchive, s SessionEntry) SessionViewEntry {
+
+	taskCount := projectIndexer.GetTaskCount(s.SessionID)
+	entry := SessionViewEntry{
+		SessionEntry: s,
+		TaskCount:    taskCount,
+		HasTasks:     taskCount > 0,
+	}
+
+	if !entry.HasTasks {
+		if snapshot, ok := taskArchive.Get(s.SessionID); ok {
+			entry.Archived = true
+			entry.TaskCount = len(snapshot.Tasks)
+		}
+	}
+
+	return entry
+}
+
 // render executes a template and writes the result.
 func (h *HTTPServer) render(w http.ResponseWriter, name string, data any) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
+		atomic.AddUint64(&h.renderErrors, 1)
 		h.log.Errorf("Template error (%s): %v", name, err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
 	}
@@ -568,6 +1155,7 @@ func (h *HTTPServer) handleInstancesPartial(w http.ResponseWriter, r *http.Reque
 		h.log.Warnf("Failed to list instances: %v", err)
 		instances = nil
 	}
+	h.watchdog.annotateInstances(instances)
 
 	data := InstancesData{
 		Instances: instances,
@@ -581,13 +1169,17 @@ func (h *HTTPServer) handleInstancesPartial(w http.ResponseWriter, r *http.Reque
 func (h *HTTPServer) handleInstancesAPI(w http.ResponseWriter, r *http.Request) {
 	instances, err := h.instanceTracker.ListRunningInstances()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to list instances: "+err.Error(),
+		)
 		return
 	}
 
 	if instances == nil {
 		instances = []ClaudeInstance{}
 	}
+	h.watchdog.annotateInstances(instances)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(instances)
@@ -598,6 +1190,7 @@ func (h *HTTPServer) handleActiveSessionsPartial(
 	w http.ResponseWriter, r *http.Request,
 ) {
 	activeLists, _ := h.projectIndexer.ListActiveTaskLists()
+	h.watchdog.annotateLists(activeLists)
 
 	totalTaskCount := 0
 	for _, al := range activeLists {
@@ -652,13 +1245,9 @@ func (h *HTTPServer) handleSessionsPartial(
 
 	sessions := make([]SessionViewEntry, 0, end-start)
 	for i := start; i < end; i++ {
-		s := project.Sessions[i]
-		taskCount := h.projectIndexer.GetTaskCount(s.SessionID)
-		sessions = append(sessions, SessionViewEntry{
-			SessionEntry: s,
-			TaskCount:    taskCount,
-			HasTasks:     taskCount > 0,
-		})
+		sessions = append(
+			sessions, h.sessionViewEntry(project.Sessions[i]),
+		)
 	}
 
 	data := struct {
@@ -723,31 +1312,52 @@ func (h *HTTPServer) handleTaskCountsPartial(
 	ctx := r.Context()
 	listID := r.PathValue("listID")
 
-	tasks, err := h.taskStore.List(ctx, listID)
+	tasks, _, err := h.listTasks(ctx, listID)
 	if err != nil {
 		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
 		return
 	}
 
-	var pendingCount, inProgressCount, completedCount int
-	for _, t := range tasks {
-		switch t.Status {
-		case "pending":
-			pendingCount++
-		case "in_progress":
-			inProgressCount++
-		case "completed":
-			completedCount++
-		}
-	}
+	counts := countTasks(tasks)
 
 	data := TaskCountsData{
 		ListID:          listID,
-		PendingCount:    pendingCount,
-		InProgressCount: inProgressCount,
-		CompletedCount:  completedCount,
-		TotalCount:      len(tasks),
+		PendingCount:    counts.Pending,
+		InProgressCount: counts.InProgress,
+		CompletedCount:  counts.Completed,
+		TotalCount:      counts.Total,
 	}
 
 	h.render(w, "task_counts_oob.html", data)
 }
+
+// RescanResult is the response body of the rescan API.
+type RescanResult struct {
+	Projects    int    `json:"projects"`
+	ActiveLists int    `json:"activeLists"`
+	Duration    string `json:"duration"`
+}
+
+// handleRescanAPI forces the project indexer to rebuild its index from disk.
+func (h *HTTPServer) handleRescanAPI(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if err := h.projectIndexer.Rescan(); err != nil {
+		writeAPIError(
+			w, http.StatusInternalServerError, ErrCodeInternal,
+			"failed to rescan: "+err.Error(),
+		)
+		return
+	}
+
+	projects, _ := h.projectIndexer.ListProjects()
+	activeLists, _ := h.projectIndexer.ListActiveTaskLists()
+
+	result := RescanResult{
+		Projects:    len(projects),
+		ActiveLists: len(activeLists),
+		Duration:    time.Since(start).String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,229 @@
+package taskviewer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// sidechainScanLines bounds how many lines of a sidechain transcript are
+// read while looking for the session that started it.
+const sidechainScanLines = 50
+
+// linkSidechains sets the parent of every sidechain session of a project.
+// The parent is read from the sidechain's transcript where possible, and
+// otherwise guessed from the index.
+func (pi *ProjectIndexer) linkSidechains(dirName string,
+	entries []SessionEntry) {
+
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.IsSidechain {
+			continue
+		}
+
+		pi.sidechainMu.Lock()
+		parent, ok := pi.sidechainParents[entry.SessionID]
+		pi.sidechainMu.Unlock()
+
+		if !ok {
+			path := entry.FullPath
+			if path == "" {
+				path = filepath.Join(
+					pi.projectsDir, dirName,
+					entry.SessionID+".jsonl",
+				)
+			}
+
+			// A transcript may not name its parent until its
+			// first message is written, so only found parents
+			// are cached.
+			parent = transcriptParent(path, entry.SessionID)
+			if parent != "" {
+				pi.sidechainMu.Lock()
+				pi.sidechainParents[entry.SessionID] = parent
+				pi.sidechainMu.Unlock()
+			}
+		}
+
+		if parent == "" {
+			parent = indexParent(*entry, entries)
+		}
+		entry.ParentSessionID = parent
+	}
+}
+
+// transcriptParent returns the session a sidechain transcript belongs to,
+// or "" if it does not say. Subagent transcripts are written under the
+// session ID of the session that started them, so the first line naming
+// another session names the parent.
+func transcriptParent(path, sessionID string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for i := 0; i < sidechainScanLines; i++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var raw transcriptLine
+			jsonErr := json.Unmarshal(line, &raw)
+			if jsonErr == nil && raw.SessionID != "" &&
+				raw.SessionID != sessionID {
+
+				return raw.SessionID
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return ""
+}
+
+// indexParent guesses the parent of a sidechain from the index alone: the
+// most recently started main session that was running when the sidechain
+// started. It returns "" if no main session was.
+func indexParent(sidechain SessionEntry, entries []SessionEntry) string {
+	if sidechain.Created.IsZero() {
+		return ""
+	}
+
+	var parent *SessionEntry
+	for i := range entries {
+		entry := &entries[i]
+		if entry.IsSidechain ||
+			entry.Created.After(sidechain.Created) ||
+			entry.Modified.Before(sidechain.Created) {
+
+			continue
+		}
+		if parent == nil || entry.Created.After(parent.Created) {
+			parent = entry
+		}
+	}
+
+	if parent == nil {
+		return ""
+	}
+
+	return parent.SessionID
+}
+
+// SessionNode is a session in a project's session tree, together with the
+// sidechain sessions it started.
+type SessionNode struct {
+	SessionViewEntry
+
+	// Children holds the sidechains started by this session, in the
+	// order of the project's sessions.
+	Children []*SessionNode `json:"children,omitempty"`
+
+	// Orphan is true for a sidechain whose parent is unknown or not in
+	// the project, which is shown as a root instead.
+	Orphan bool `json:"orphan,omitempty"`
+
+	// Descendants is the number of sessions below this one.
+	Descendants int `json:"descendants"`
+
+	// TotalTaskCount is the number of tasks of this session and of every
+	// session below it.
+	TotalTaskCount int `json:"totalTaskCount"`
+
+	// Usage is the session's own token usage, and TotalUsage includes
+	// every session below it. Both are nil if there is no usage.
+	Usage      *UsageSummary `json:"usage,omitempty"`
+	TotalUsage *UsageSummary `json:"totalUsage,omitempty"`
+}
+
+// buildSessionTree arranges a project's sessions into trees of sidechains
+// under the sessions that started them, and rolls task counts and usage up
+// each tree. Sessions whose parent is not in the project, or that would
+// form a loop, are roots. Usage may be nil.
+func buildSessionTree(sessions []SessionViewEntry,
+	usage *ProjectUsage) []*SessionNode {
+
+	nodes := make(map[string]*SessionNode, len(sessions))
+	for _, session := range sessions {
+		node := &SessionNode{SessionViewEntry: session}
+		if usage != nil {
+			if u, ok := usage.Sessions[session.SessionID]; ok {
+				node.Usage = &u
+			}
+		}
+		nodes[session.SessionID] = node
+	}
+
+	// parentOf follows a link only if it stays within the project.
+	parentOf := func(node *SessionNode) *SessionNode {
+		parent, ok := nodes[node.ParentSessionID]
+		if !ok || parent == node {
+			return nil
+		}
+
+		return parent
+	}
+
+	var roots []*SessionNode
+	for _, session := range sessions {
+		node := nodes[session.SessionID]
+		parent := parentOf(node)
+
+		// Walk up from the parent so that a loop of sidechains is
+		// broken rather than dropped from the tree. A walk longer
+		// than the number of sessions is stuck in a loop above.
+		ancestor := parent
+		for steps := 0; ancestor != nil; steps++ {
+			if ancestor == node || steps > len(sessions) {
+				parent = nil
+				break
+			}
+			ancestor = parentOf(ancestor)
+		}
+
+		if parent == nil {
+			node.Orphan = node.IsSidechain
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	for _, root := range roots {
+		root.rollUp()
+	}
+
+	return roots
+}
+
+// rollUp totals the task counts and usage of a node and every node below
+// it.
+func (n *SessionNode) rollUp() {
+	n.Descendants = 0
+	n.TotalTaskCount = n.TaskCount
+	if n.Usage != nil {
+		total := *n.Usage
+		total.UnpricedModels = slices.Clone(total.UnpricedModels)
+		n.TotalUsage = &total
+	}
+
+	for _, child := range n.Children {
+		child.rollUp()
+
+		n.Descendants += 1 + child.Descendants
+		n.TotalTaskCount += child.TotalTaskCount
+		if child.TotalUsage == nil {
+			continue
+		}
+		if n.TotalUsage == nil {
+			n.TotalUsage = &UsageSummary{}
+		}
+		n.TotalUsage.combine(*child.TotalUsage)
+	}
+}
@@ -0,0 +1,333 @@
+package taskviewer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionView returns a session of a project's session list.
+func sessionView(id, parent string, sidechain bool,
+	tasks int) SessionViewEntry {
+
+	return SessionViewEntry{
+		SessionEntry: SessionEntry{
+			SessionID:       id,
+			IsSidechain:     sidechain,
+			ParentSessionID: parent,
+		},
+		TaskCount: tasks,
+	}
+}
+
+// formatTree renders session trees as "id(child child)", marking orphans
+// with a "!" and giving each node's descendants and total task count.
+func formatTree(nodes []*SessionNode) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		part := node.SessionID
+		if node.Orphan {
+			part += "!"
+		}
+		part += fmt.Sprintf("[%d/%d]", node.Descendants,
+			node.TotalTaskCount)
+		if len(node.Children) > 0 {
+			part += "(" + formatTree(node.Children) + ")"
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// TestBuildSessionTree checks how sidechains are nested under their parents,
+// and that broken links leave sessions as roots instead of dropping them.
+func TestBuildSessionTree(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []SessionViewEntry
+		want     string
+	}{
+		{
+			name: "nested",
+			sessions: []SessionViewEntry{
+				sessionView("m1", "", false, 1),
+				sessionView("a", "m1", true, 2),
+				sessionView("b", "a", true, 4),
+				sessionView("c", "m1", true, 0),
+				sessionView("m2", "", false, 8),
+			},
+			want: "m1[3/7](a[1/6](b[0/4]) c[0/0]) m2[0/8]",
+		},
+		{
+			// A child listed before its parent is still nested.
+			name: "child first",
+			sessions: []SessionViewEntry{
+				sessionView("a", "m1", true, 2),
+				sessionView("m1", "", false, 1),
+			},
+			want: "m1[1/3](a[0/2])",
+		},
+		{
+			name: "missing parent",
+			sessions: []SessionViewEntry{
+				sessionView("m1", "", false, 1),
+				sessionView("a", "gone", true, 2),
+				sessionView("b", "a", true, 4),
+			},
+			want: "m1[0/1] a![1/6](b[0/4])",
+		},
+		{
+			name: "unknown parent",
+			sessions: []SessionViewEntry{
+				sessionView("a", "", true, 2),
+			},
+			want: "a![0/2]",
+		},
+		{
+			name: "own parent",
+			sessions: []SessionViewEntry{
+				sessionView("a", "a", true, 2),
+			},
+			want: "a![0/2]",
+		},
+		{
+			// Neither side of a loop can be placed under the
+			// other, so both are roots.
+			name: "loop",
+			sessions: []SessionViewEntry{
+				sessionView("a", "b", true, 1),
+				sessionView("b", "a", true, 2),
+			},
+			want: "a![0/1] b![0/2]",
+		},
+		{
+			// A session hanging off a loop is kept as a root
+			// rather than lost with it.
+			name: "below a loop",
+			sessions: []SessionViewEntry{
+				sessionView("a", "b", true, 1),
+				sessionView("b", "a", true, 2),
+				sessionView("c", "a", true, 4),
+			},
+			want: "a![0/1] b![0/2] c![0/4]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			roots := buildSessionTree(test.sessions, nil)
+			if got := formatTree(roots); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestSessionTreeUsage checks that usage is totalled up each tree without
+// changing the sessions' own usage.
+func TestSessionTreeUsage(t *testing.T) {
+	sessions := []SessionViewEntry{
+		sessionView("m1", "", false, 0),
+		sessionView("a", "m1", true, 0),
+		sessionView("b", "a", true, 0),
+		sessionView("c", "m1", true, 0),
+	}
+	usage := &ProjectUsage{
+		Sessions: map[string]UsageSummary{
+			"a": {
+				TokenUsage:     TokenUsage{InputTokens: 10},
+				CostUSD:        1,
+				UnpricedModels: []string{"x"},
+			},
+			"b": {
+				TokenUsage:     TokenUsage{InputTokens: 20},
+				CostUSD:        2,
+				UnpricedModels: []string{"w"},
+			},
+		},
+	}
+
+	roots := buildSessionTree(sessions, usage)
+	if len(roots) != 1 {
+		t.Fatalf("got %d roots, want 1", len(roots))
+	}
+	root := roots[0]
+	a, c := root.Children[0], root.Children[1]
+
+	// The parent has no usage of its own but totals its children's, and
+	// a session without any usage has none in total either.
+	if root.Usage != nil {
+		t.Fatalf("got own usage %+v for m1", root.Usage)
+	}
+	if c.TotalUsage != nil {
+		t.Fatalf("got total usage %+v for c", c.TotalUsage)
+	}
+
+	want := UsageSummary{
+		TokenUsage:     TokenUsage{InputTokens: 30},
+		CostUSD:        3,
+		UnpricedModels: []string{"w", "x"},
+	}
+	for _, node := range []*SessionNode{root, a} {
+		if !reflect.DeepEqual(*node.TotalUsage, want) {
+			t.Fatalf("got total %+v for %s, want %+v",
+				*node.TotalUsage, node.SessionID, want)
+		}
+	}
+
+	// Totalling must not touch the usage it was given.
+	if got := usage.Sessions["a"].UnpricedModels; !reflect.DeepEqual(
+		got, []string{"x"},
+	) {
+		t.Fatalf("project usage changed to %v", got)
+	}
+	if got := a.Usage.UnpricedModels; !reflect.DeepEqual(
+		got, []string{"x"},
+	) {
+		t.Fatalf("own usage changed to %v", got)
+	}
+}
+
+// TestTranscriptParent checks which session a sidechain transcript is read
+// as belonging to.
+func TestTranscriptParent(t *testing.T) {
+	line := func(sessionID string) string {
+		return fmt.Sprintf(`{"type":"user","sessionId":%q}`+"\n",
+			sessionID)
+	}
+
+	tests := []struct {
+		name       string
+		transcript string
+		want       string
+	}{
+		{
+			name:       "first line",
+			transcript: line("parent") + line("side"),
+			want:       "parent",
+		},
+		{
+			name: "after own lines",
+			transcript: line("side") + "\n" + "not json\n" +
+				`{"type":"summary"}` + "\n" + line("parent"),
+			want: "parent",
+		},
+		{
+			name:       "last line unterminated",
+			transcript: line("side") + `{"sessionId":"parent"}`,
+			want:       "parent",
+		},
+		{
+			name:       "only own lines",
+			transcript: line("side") + line("side"),
+		},
+		{
+			name: "past the scan limit",
+			transcript: strings.Repeat(
+				line("side"), sidechainScanLines,
+			) + line("parent"),
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "side.jsonl")
+			err := os.WriteFile(
+				path, []byte(test.transcript), 0o600,
+			)
+			if err != nil {
+				t.Fatalf("unable to write transcript: %v", err)
+			}
+
+			got := transcriptParent(path, "side")
+			if got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.jsonl")
+	if got := transcriptParent(missing, "side"); got != "" {
+		t.Fatalf("got %q for a missing transcript", got)
+	}
+}
+
+// TestIndexParent checks how the parent of a sidechain is guessed from the
+// index when its transcript does not name one.
+func TestIndexParent(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	session := func(id string, sidechain bool, created,
+		modified int) SessionEntry {
+
+		return SessionEntry{
+			SessionID:   id,
+			IsSidechain: sidechain,
+			Created:     at(created),
+			Modified:    at(modified),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		sidechain SessionEntry
+		entries   []SessionEntry
+		want      string
+	}{
+		{
+			name:      "latest running",
+			sidechain: session("side", true, 30, 40),
+			entries: []SessionEntry{
+				session("m1", false, 0, 60),
+				session("m2", false, 10, 50),
+				session("m3", false, 20, 25),
+				session("m4", false, 35, 60),
+			},
+			want: "m2",
+		},
+		{
+			name:      "sidechains skipped",
+			sidechain: session("side", true, 30, 40),
+			entries: []SessionEntry{
+				session("m1", false, 0, 60),
+				session("other", true, 20, 60),
+			},
+			want: "m1",
+		},
+		{
+			name:      "none running",
+			sidechain: session("side", true, 30, 40),
+			entries: []SessionEntry{
+				session("m1", false, 0, 10),
+				session("m2", false, 35, 60),
+			},
+		},
+		{
+			name:      "unknown start",
+			sidechain: SessionEntry{SessionID: "side"},
+			entries: []SessionEntry{
+				session("m1", false, 0, 60),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := append(test.entries, test.sidechain)
+			got := indexParent(test.sidechain, entries)
+			if got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package taskviewer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// historyDirName is the archive subdirectory holding the change
+	// journals.
+	historyDirName = "history"
+
+	// activityFeedLimit caps the number of changes shown in a list's
+	// activity feed.
+	activityFeedLimit = 500
+)
+
+// Kinds of task change.
+const (
+	// ChangeCreated records a task first seen by the daemon.
+	ChangeCreated = "created"
+
+	// ChangeUpdated records a change to one or more fields of a task.
+	ChangeUpdated = "updated"
+
+	// ChangeDeleted records a task that disappeared from its list.
+	ChangeDeleted = "deleted"
+)
+
+// Task fields whose changes are recorded.
+const (
+	FieldStatus      = "status"
+	FieldSubject     = "subject"
+	FieldOwner       = "owner"
+	FieldDescription = "description"
+	FieldBlockedBy   = "blockedBy"
+	FieldBlocks      = "blocks"
+)
+
+// FieldChange describes how a single task field changed.
+type FieldChange struct {
+	Field string `json:"field"`
+
+	// From and To hold the old and new value of a scalar field.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	// Added and Removed hold the task IDs added to and removed from a
+	// dependency field.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// TaskChange is a single observed change to a task.
+type TaskChange struct {
+	Time   time.Time `json:"time"`
+	ListID string    `json:"listId"`
+	TaskID string    `json:"taskId"`
+	Kind   string    `json:"kind"`
+
+	// Subject is the task's subject after the change, or its last known
+	// subject if it was deleted.
+	Subject string `json:"subject"`
+
+	// Status is the task's status after the change.
+	Status claudeagent.TaskListStatus `json:"status"`
+
+	// Fields lists the fields that changed in an update.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// diffTasks returns the changes between two snapshots of a list, in the
+// order tasks appear in next followed by any deleted tasks. A nil prev
+// records every task in next as created.
+func diffTasks(listID string, at time.Time, prev,
+	next []claudeagent.TaskListItem) []TaskChange {
+
+	prevByID := make(map[string]*claudeagent.TaskListItem, len(prev))
+	for i := range prev {
+		prevByID[prev[i].ID] = &prev[i]
+	}
+
+	var changes []TaskChange
+	seen := make(map[string]bool, len(next))
+	for i := range next {
+		task := &next[i]
+		seen[task.ID] = true
+
+		change := TaskChange{
+			Time:    at,
+			ListID:  listID,
+			TaskID:  task.ID,
+			Subject: task.Subject,
+			Status:  task.Status,
+		}
+
+		old, ok := prevByID[task.ID]
+		if !ok {
+			change.Kind = ChangeCreated
+			changes = append(changes, change)
+			continue
+		}
+
+		change.Fields = diffTask(old, task)
+		if len(change.Fields) == 0 {
+			continue
+		}
+		change.Kind = ChangeUpdated
+		changes = append(changes, change)
+	}
+
+	for i := range prev {
+		task := &prev[i]
+		if seen[task.ID] {
+			continue
+		}
+
+		changes = append(changes, TaskChange{
+			Time:    at,
+			ListID:  listID,
+			TaskID:  task.ID,
+			Kind:    ChangeDeleted,
+			Subject: task.Subject,
+			Status:  task.Status,
+		})
+	}
+
+	return changes
+}
+
+// diffTask returns the recorded fields that differ between two versions of a
+// task.
+func diffTask(old, task *claudeagent.TaskListItem) []FieldChange {
+	var fields []FieldChange
+
+	scalar := func(field, from, to string) {
+		if from != to {
+			fields = append(fields, FieldChange{
+				Field: field, From: from, To: to,
+			})
+		}
+	}
+	scalar(FieldStatus, string(old.Status), string(task.Status))
+	scalar(FieldSubject, old.Subject, task.Subject)
+	scalar(FieldOwner, old.Owner, task.Owner)
+	scalar(FieldDescription, old.Description, task.Description)
+
+	edges := func(field string, from, to []string) {
+		added, removed := diffIDs(from, to)
+		if len(added) > 0 || len(removed) > 0 {
+			fields = append(fields, FieldChange{
+				Field: field, Added: added, Removed: removed,
+			})
+		}
+	}
+	edges(FieldBlockedBy, old.BlockedBy, task.BlockedBy)
+	edges(FieldBlocks, old.Blocks, task.Blocks)
+
+	return fields
+}
+
+// diffIDs returns the IDs only in to and the IDs only in from.
+func diffIDs(from, to []string) ([]string, []string) {
+	var added, removed []string
+	for _, id := range to {
+		if !slices.Contains(from, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range from {
+		if !slices.Contains(to, id) {
+			removed = append(removed, id)
+		}
+	}
+
+	return added, removed
+}
+
+// historyPath returns the change journal of a list.
+func (a *TaskArchive) historyPath(listID string) string {
+	return filepath.Join(a.dir, historyDirName, listID+".jsonl")
+}
+
+// appendHistory writes changes to the list's journal, one per line.
+func (a *TaskArchive) appendHistory(listID string,
+	changes []TaskChange) error {
+
+	var buf bytes.Buffer
+	for _, change := range changes {
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	f, err := os.OpenFile(
+		a.historyPath(listID), os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0o600,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// History returns every recorded change to a list, oldest first. It is safe
+// to call on a nil archive, which records nothing.
+func (a *TaskArchive) History(listID string) ([]TaskChange, error) {
+	if a == nil || !validListID(listID) {
+		return nil, nil
+	}
+
+	changes, err := readHistory(a.historyPath(listID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w",
+			listID, err)
+	}
+
+	return changes, nil
+}
+
+// TaskHistory returns the recorded changes to a single task, oldest first.
+func (a *TaskArchive) TaskHistory(listID, taskID string) ([]TaskChange,
+	error) {
+
+	changes, err := a.History(listID)
+	if err != nil {
+		return nil, err
+	}
+
+	var taskChanges []TaskChange
+	for _, change := range changes {
+		if change.TaskID == taskID {
+			taskChanges = append(taskChanges, change)
+		}
+	}
+
+	return taskChanges, nil
+}
+
+// readHistory reads a change journal. A missing journal holds no changes, and
+// a torn trailing line from an interrupted write is ignored.
+func readHistory(path string) ([]TaskChange, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var changes []TaskChange
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var change TaskChange
+		if err := json.Unmarshal(line, &change); err != nil {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, scanner.Err()
+}
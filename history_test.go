@@ -0,0 +1,164 @@
+package taskviewer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// TestDiffTasks checks the changes recorded between two snapshots of a list.
+func TestDiffTasks(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	task1 := testTask("1")
+	task2 := testTask("2")
+
+	// change builds the expected change of a task, taking the subject
+	// and status from the task.
+	change := func(task claudeagent.TaskListItem, kind string,
+		fields ...FieldChange) TaskChange {
+
+		return TaskChange{
+			Time:    at,
+			ListID:  "s1",
+			TaskID:  task.ID,
+			Kind:    kind,
+			Subject: task.Subject,
+			Status:  task.Status,
+			Fields:  fields,
+		}
+	}
+
+	completed := task1
+	completed.Status = claudeagent.TaskListStatusCompleted
+
+	edited := task1
+	edited.Subject = "Renamed"
+	edited.Owner = "agent-1"
+	edited.Description = "More detail"
+	edited.Status = claudeagent.TaskListStatusInProgress
+
+	blocked := task1
+	blocked.BlockedBy = []string{"2", "3"}
+	reblocked := task1
+	reblocked.BlockedBy = []string{"3", "4"}
+	reblocked.Blocks = []string{"5"}
+	reordered := task1
+	reordered.BlockedBy = []string{"3", "2"}
+
+	tests := []struct {
+		name string
+		prev []claudeagent.TaskListItem
+		next []claudeagent.TaskListItem
+		want []TaskChange
+	}{
+		{
+			name: "first snapshot",
+			next: []claudeagent.TaskListItem{task1, task2},
+			want: []TaskChange{
+				change(task1, ChangeCreated),
+				change(task2, ChangeCreated),
+			},
+		},
+		{
+			name: "unchanged",
+			prev: []claudeagent.TaskListItem{task1, task2},
+			next: []claudeagent.TaskListItem{task1, task2},
+		},
+		{
+			name: "status",
+			prev: []claudeagent.TaskListItem{task1},
+			next: []claudeagent.TaskListItem{completed},
+			want: []TaskChange{
+				change(
+					completed, ChangeUpdated,
+					FieldChange{
+						Field: FieldStatus,
+						From:  string(task1.Status),
+						To:    string(completed.Status),
+					},
+				),
+			},
+		},
+		{
+			name: "scalar fields",
+			prev: []claudeagent.TaskListItem{task1},
+			next: []claudeagent.TaskListItem{edited},
+			want: []TaskChange{
+				change(
+					edited, ChangeUpdated,
+					FieldChange{
+						Field: FieldStatus,
+						From:  string(task1.Status),
+						To:    string(edited.Status),
+					},
+					FieldChange{
+						Field: FieldSubject,
+						From:  "Task 1",
+						To:    "Renamed",
+					},
+					FieldChange{
+						Field: FieldOwner,
+						To:    "agent-1",
+					},
+					FieldChange{
+						Field: FieldDescription,
+						To:    "More detail",
+					},
+				),
+			},
+		},
+		{
+			name: "dependency edges",
+			prev: []claudeagent.TaskListItem{blocked},
+			next: []claudeagent.TaskListItem{reblocked},
+			want: []TaskChange{
+				change(
+					reblocked, ChangeUpdated,
+					FieldChange{
+						Field:   FieldBlockedBy,
+						Added:   []string{"4"},
+						Removed: []string{"2"},
+					},
+					FieldChange{
+						Field: FieldBlocks,
+						Added: []string{"5"},
+					},
+				),
+			},
+		},
+		{
+			name: "reordered edges",
+			prev: []claudeagent.TaskListItem{blocked},
+			next: []claudeagent.TaskListItem{reordered},
+		},
+		{
+			name: "created and deleted",
+			prev: []claudeagent.TaskListItem{task1},
+			next: []claudeagent.TaskListItem{task2},
+			want: []TaskChange{
+				change(task2, ChangeCreated),
+				change(task1, ChangeDeleted),
+			},
+		},
+		{
+			name: "all deleted",
+			prev: []claudeagent.TaskListItem{task1, task2},
+			want: []TaskChange{
+				change(task1, ChangeDeleted),
+				change(task2, ChangeDeleted),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := diffTasks("s1", at, test.prev, test.next)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package taskviewer
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"embed"
 	"fmt"
 	"html/template"
@@ -27,7 +28,9 @@ var _ = time.Now
 //go:embed templates/*
 var templatesFS embed.FS
 
-// mdRenderer is the shared markdown renderer.
+// mdRenderer is the shared markdown renderer. Raw HTML in task descriptions
+// is left out rather than rendered, since anything an agent wrote would
+// otherwise run as script in the dashboard.
 var mdRenderer = goldmark.New(
 	goldmark.WithExtensions(
 		extension.GFM,
@@ -36,7 +39,6 @@ var mdRenderer = goldmark.New(
 	),
 	goldmark.WithRendererOptions(
 		html.WithHardWraps(),
-		html.WithUnsafe(),
 	),
 )
 
@@ -52,11 +54,26 @@ type HTTPServer struct {
 	taskStore       claudeagent.TaskStore
 	projectIndexer  *ProjectIndexer
 	instanceTracker *InstanceTracker
+	taskArchive     *TaskArchive
+	eventBus        *EventBus
+	watchdog        *Watchdog
+	notifier        *Notifier
+	searchIndex     *SearchIndex
+	federation      *Federation
+	usageTracker    *UsageTracker
+	mcp             *MCPServer
+	auth            *Authenticator
 	templates       *template.Template
 
-	// sseClients tracks active SSE connections per list ID.
-	sseClients   map[string][]chan []byte
-	sseClientsMu sync.RWMutex
+	// loopbackOnly is true if the listener only accepts connections
+	// from this machine.
+	loopbackOnly bool
+
+	// sseClients is the number of open event streams, and renderErrors
+	// the number of templates that failed to render. Both are accessed
+	// atomically.
+	sseClients   int64
+	renderErrors uint64
 
 	started uint32
 	stopped uint32
@@ -68,6 +85,9 @@ type HTTPServer struct {
 
 // NewHTTPServer creates a new HTTP server component.
 func NewHTTPServer(cfg *HTTPConfig, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, instanceTracker *InstanceTracker,
+	taskArchive *TaskArchive, eventBus *EventBus, watchdog *Watchdog,
+	notifier *Notifier, searchIndex *SearchIndex, federation *Federation,
 	log btclog.Logger) (*HTTPServer, error) {
 
 	// Parse embedded templates.
@@ -78,23 +98,30 @@ func NewHTTPServer(cfg *HTTPConfig, taskStore claudeagent.TaskStore,
 		return nil, fmt.Errorf("failed to parse templates: %w", err)
 	}
 
-	// Create project indexer using same base dir as task store.
-	projectIndexer := NewProjectIndexer(cfg.ClaudeDir)
-
-	// Create instance tracker for detecting running Claude processes.
-	instanceTracker := NewInstanceTracker(projectIndexer)
-
 	h := &HTTPServer{
 		cfg:             cfg,
 		taskStore:       taskStore,
 		projectIndexer:  projectIndexer,
 		instanceTracker: instanceTracker,
+		taskArchive:     taskArchive,
+		eventBus:        eventBus,
+		watchdog:        watchdog,
+		notifier:        notifier,
+		searchIndex:     searchIndex,
+		federation:      federation,
+		usageTracker:    NewUsageTracker(projectIndexer, cfg.Prices),
+		auth:            cfg.Auth,
 		templates:       tmpl,
-		sseClients:      make(map[string][]chan []byte),
 		quit:            make(chan struct{}),
 		log:             log,
 	}
 
+	// Agents query the same components over MCP.
+	h.mcp = NewMCPServer(
+		taskStore, projectIndexer, instanceTracker, taskArchive,
+		watchdog, searchIndex, log,
+	)
+
 	return h, nil
 }
 
@@ -104,7 +131,10 @@ func templateFuncs() template.FuncMap {
 		"renderMarkdown": func(s string) template.HTML {
 			var buf bytes.Buffer
 			if err := mdRenderer.Convert([]byte(s), &buf); err != nil {
-				return template.HTML("<pre>" + s + "</pre>")
+				return template.HTML(
+					"<pre>" + template.HTMLEscapeString(s) +
+						"</pre>",
+				)
 			}
 			return template.HTML(buf.String())
 		},
@@ -138,15 +168,17 @@ func templateFuncs() template.FuncMap {
 				return "?"
 			}
 		},
-		"isBlocked": func(task claudeagent.TaskListItem) bool {
-			return len(task.BlockedBy) > 0
-		},
 		"formatTime": func(t time.Time) string {
 			if t.IsZero() {
 				return ""
 			}
 			return t.Local().Format("Jan 02, 2006 3:04 PM")
 		},
+		"formatSeconds": formatSeconds,
+		"formatTokens":  formatTokens,
+		"formatCost":    formatCost,
+		"recentDays":    recentDays,
+		"highlight":     highlightSnippet,
 		"truncate": func(s string, n int) string {
 			if len(s) <= n {
 				return s
@@ -173,6 +205,15 @@ func templateFuncs() template.FuncMap {
 			}
 			return true
 		},
+		"isLong": isLongText,
+		"historyEntry": func(change TaskChange, showTask bool) any {
+			// Bundle a change with whether to name its task,
+			// for the history_entry template.
+			return struct {
+				Change   TaskChange
+				ShowTask bool
+			}{change, showTask}
+		},
 		"shortPath": func(path string, n int) string {
 			// Return the last n path components with ellipsis prefix.
 			parts := strings.Split(path, "/")
@@ -195,6 +236,28 @@ func (h *HTTPServer) Start() error {
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", h.cfg.ListenAddr, err)
 	}
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		h.loopbackOnly = addr.IP.IsLoopback()
+	}
+
+	if h.cfg.TLSCertPath != "" {
+		cert, created, err := loadOrCreateTLSCert(
+			h.cfg.TLSCertPath, h.cfg.TLSKeyPath, h.cfg.TLSHosts,
+		)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		if created {
+			h.log.Infof("Generated self-signed TLS certificate %s",
+				h.cfg.TLSCertPath)
+		}
+
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+	}
 	h.listener = listener
 
 	// Set up routes.
@@ -202,7 +265,7 @@ func (h *HTTPServer) Start() error {
 	h.registerRoutes(mux)
 
 	h.server = &http.Server{
-		Handler:      mux,
+		Handler:      h.authenticate(mux),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
@@ -226,18 +289,10 @@ func (h *HTTPServer) Stop(ctx context.Context) error {
 		return nil
 	}
 
+	// Closing quit ends every event stream, which would otherwise hold
+	// up the shutdown below.
 	close(h.quit)
 
-	// Close all SSE clients.
-	h.sseClientsMu.Lock()
-	for _, clients := range h.sseClients {
-		for _, ch := range clients {
-			close(ch)
-		}
-	}
-	h.sseClients = make(map[string][]chan []byte)
-	h.sseClientsMu.Unlock()
-
 	// Shutdown HTTP server.
 	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -265,11 +320,33 @@ func (h *HTTPServer) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /projects/{projectID}", h.handleProjectView)
 	mux.HandleFunc("GET /lists/{listID}", h.handleListView)
 	mux.HandleFunc("GET /lists/{listID}/tasks/{taskID}", h.handleTaskDetail)
+	mux.HandleFunc("POST /lists/{listID}/tasks", h.handleCreateTask)
+	mux.HandleFunc(
+		"POST /lists/{listID}/tasks/{taskID}", h.handleEditTask,
+	)
+	mux.HandleFunc(
+		"POST /lists/{listID}/tasks/{taskID}/delete",
+		h.handleDeleteTask,
+	)
 	mux.HandleFunc("GET /lists/{listID}/graph", h.handleGraphView)
+	mux.HandleFunc("GET /lists/{listID}/export", h.handleExportList)
+	mux.HandleFunc("GET /tasks/export", h.handleExportAllTasks)
+	mux.HandleFunc(
+		"GET /projects/{projectID}/export", h.handleExportProject,
+	)
+	mux.HandleFunc("GET /lists/{listID}/activity", h.handleListActivity)
+	mux.HandleFunc("GET /sessions/{sessionID}", h.handleSessionView)
+	mux.HandleFunc("GET /notifications", h.handleNotifications)
+	mux.HandleFunc("GET /search", h.handleSearch)
+	mux.HandleFunc("GET /fleet", h.handleFleet)
+	mux.HandleFunc("GET /login", h.handleLoginPage)
+	mux.HandleFunc("POST /login", h.handleLogin)
+	mux.HandleFunc("POST /logout", h.handleLogout)
 
 	// API endpoints.
 	mux.HandleFunc("GET /api/lists/{listID}/graph", h.handleGraphData)
 	mux.HandleFunc("GET /api/lists/{listID}/events", h.handleSSE)
+	mux.HandleFunc("GET /api/events", h.handleEvents)
 
 	// HTMX partials.
 	mux.HandleFunc(
@@ -292,41 +369,23 @@ func (h *HTTPServer) registerRoutes(mux *http.ServeMux) {
 
 	// Instance API.
 	mux.HandleFunc("GET /api/instances", h.handleInstancesAPI)
-}
-
-// addSSEClient registers a new SSE client for a task list.
-func (h *HTTPServer) addSSEClient(listID string, ch chan []byte) {
-	h.sseClientsMu.Lock()
-	defer h.sseClientsMu.Unlock()
-	h.sseClients[listID] = append(h.sseClients[listID], ch)
-}
 
-// removeSSEClient unregisters an SSE client.
-func (h *HTTPServer) removeSSEClient(listID string, ch chan []byte) {
-	h.sseClientsMu.Lock()
-	defer h.sseClientsMu.Unlock()
+	// Index maintenance.
+	mux.HandleFunc("POST /api/index/rescan", h.handleRescanAPI)
 
-	clients := h.sseClients[listID]
-	for i, c := range clients {
-		if c == ch {
-			h.sseClients[listID] = append(clients[:i], clients[i+1:]...)
-			break
-		}
-	}
-}
+	// Prometheus metrics.
+	mux.HandleFunc("GET /metrics", h.handleMetrics)
 
-// broadcastSSE sends an event to all clients watching a task list.
-func (h *HTTPServer) broadcastSSE(listID string, eventType, data string) {
-	msg := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, data))
+	// Webhook notifications.
+	mux.HandleFunc(
+		"POST /api/notifications/test", h.handleNotificationTest,
+	)
 
-	h.sseClientsMu.RLock()
-	defer h.sseClientsMu.RUnlock()
+	// MCP server for agents. GET is routed too, so that it is refused
+	// rather than served the dashboard.
+	mux.Handle("POST /mcp", h.mcp)
+	mux.Handle("GET /mcp", h.mcp)
 
-	for _, ch := range h.sseClients[listID] {
-		select {
-		case ch <- msg:
-		default:
-			// Skip if buffer full.
-		}
-	}
+	// Versioned JSON API.
+	h.registerAPIRoutes(mux)
 }
@@ -1,14 +1,36 @@
 package taskviewer
 
 import (
-	"bufio"
-	"bytes"
-	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 )
 
+// MatchConfidence describes how an instance was tied to its session.
+type MatchConfidence string
+
+const (
+	// MatchOpenFile means the process has the session's transcript open.
+	MatchOpenFile MatchConfidence = "open-file"
+
+	// MatchRecent means the session is the one in the instance's project
+	// created soonest after the process started or, failing that, the
+	// most recently written one since it started.
+	MatchRecent MatchConfidence = "recent"
+
+	// MatchProject means the project is known but not the session.
+	MatchProject MatchConfidence = "project"
+
+	// MatchNone means the instance could not be tied to a project.
+	MatchNone MatchConfidence = "none"
+)
+
+// sessionStartSlack is how long before a process's recorded start time a
+// session may have been created and still be considered its own. It absorbs
+// the coarse start times reported by ps.
+const sessionStartSlack = time.Second
+
 // ClaudeInstance represents a running Claude Code process.
 type ClaudeInstance struct {
 	PID         int       `json:"pid"`
@@ -19,271 +41,290 @@ type ClaudeInstance struct {
 	Uptime      string    `json:"uptime"`
 	HasTasks    bool      `json:"hasTasks"`
 	TaskCount   int       `json:"taskCount"`
+
+	// ProjectDir is the sanitized project directory the instance's
+	// sessions are stored under.
+	ProjectDir string `json:"projectDir,omitempty"`
+
+	// MatchConfidence is how SessionID was determined.
+	MatchConfidence MatchConfidence `json:"matchConfidence"`
+
+	// Entrypoint is how the instance was launched (e.g. "cli" or
+	// "sdk-ts"), when the process environment is readable.
+	Entrypoint string `json:"entrypoint,omitempty"`
+
+	// Stall is set by the watchdog if the instance's session appears to
+	// have stalled.
+	Stall *StallInfo `json:"stall,omitempty"`
 }
 
 // InstanceTracker detects running Claude Code instances.
 type InstanceTracker struct {
 	projectIndexer *ProjectIndexer
+	procs          ProcessSource
 }
 
-// NewInstanceTracker creates a new instance tracker.
-func NewInstanceTracker(projectIndexer *ProjectIndexer) *InstanceTracker {
+// NewInstanceTracker creates a new instance tracker. If procs is nil, the
+// platform's default process source is used.
+func NewInstanceTracker(projectIndexer *ProjectIndexer,
+	procs ProcessSource) *InstanceTracker {
+
+	if procs == nil {
+		procs = defaultProcessSource()
+	}
+
 	return &InstanceTracker{
 		projectIndexer: projectIndexer,
+		procs:          procs,
 	}
 }
 
 // ListRunningInstances finds all running Claude Code processes.
 func (it *InstanceTracker) ListRunningInstances() ([]ClaudeInstance, error) {
-	// Find claude processes using pgrep and ps.
-	pids, err := it.findClaudePIDs()
+	pids, err := it.procs.ClaudePIDs()
 	if err != nil || len(pids) == 0 {
 		return nil, nil
 	}
 
-	var instances []ClaudeInstance
+	matched := make([]*ClaudeInstance, 0, len(pids))
 	for _, pid := range pids {
-		instance, err := it.getInstanceInfo(pid)
+		proc, err := it.procs.Process(pid)
 		if err != nil {
 			continue
 		}
 
-		// Enrich with project indexer data.
-		it.enrichInstance(instance)
+		instance := newClaudeInstance(proc)
+		it.matchProject(instance)
+		matched = append(matched, instance)
+	}
+
+	it.matchSessions(matched)
+
+	instances := make([]ClaudeInstance, 0, len(matched))
+	for _, instance := range matched {
 		instances = append(instances, *instance)
 	}
 
 	return instances, nil
 }
 
-// findClaudePIDs uses pgrep to find Claude Code process IDs.
-func (it *InstanceTracker) findClaudePIDs() ([]int, error) {
-	// Look for processes named exactly "claude" (the CLI binary).
-	cmd := exec.Command("pgrep", "-x", "claude")
-	output, err := cmd.Output()
-	if err != nil {
-		// pgrep returns exit code 1 if no processes found.
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if exitErr.ExitCode() == 1 {
-				return nil, nil
-			}
-		}
-		// Fall back to searching for "claude" in process list.
-		return it.findClaudePIDsFallback()
+// newClaudeInstance builds an instance from the details of its process.
+func newClaudeInstance(proc *ProcessInfo) *ClaudeInstance {
+	instance := &ClaudeInstance{
+		PID:        proc.PID,
+		WorkingDir: proc.WorkingDir,
+		StartTime:  proc.StartTime,
+		Entrypoint: proc.Env["CLAUDE_CODE_ENTRYPOINT"],
+
+		MatchConfidence: MatchNone,
 	}
 
-	return it.parsePIDs(output), nil
+	if !proc.StartTime.IsZero() {
+		instance.Uptime = formatUptime(time.Since(proc.StartTime))
+	}
+
+	return instance
 }
 
-// findClaudePIDsFallback uses ps to find Claude processes.
-func (it *InstanceTracker) findClaudePIDsFallback() ([]int, error) {
-	cmd := exec.Command("ps", "aux")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// formatUptime converts a duration to human-readable format.
+func formatUptime(duration time.Duration) string {
+	if duration < time.Minute {
+		return "just now"
 	}
 
-	var pids []int
-	scanner := bufio.NewScanner(bytes.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "grep") {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 11 {
-			continue
+	if duration < time.Hour {
+		minutes := int(duration.Minutes())
+		if minutes == 1 {
+			return "1 min"
 		}
+		return strconv.Itoa(minutes) + " mins"
+	}
 
-		// Command is typically in field 10+ (after USER PID %CPU etc).
-		cmdPart := strings.Join(fields[10:], " ")
-
-		// Match: "claude", "claude --flags", or version-managed path.
-		isClaudeCmd := strings.HasPrefix(cmdPart, "claude ") ||
-			cmdPart == "claude"
-		isClaudePath := strings.Contains(cmdPart, ".local/share/claude") ||
-			strings.Contains(cmdPart, "claude/versions")
-
-		if isClaudeCmd || isClaudePath {
-			if pid, err := strconv.Atoi(fields[1]); err == nil {
-				pids = append(pids, pid)
-			}
+	if duration < 24*time.Hour {
+		hours := int(duration.Hours())
+		if hours == 1 {
+			return "1 hour"
 		}
+		return strconv.Itoa(hours) + " hours"
 	}
 
-	return pids, nil
-}
-
-// parsePIDs parses newline-separated PIDs from command output.
-func (it *InstanceTracker) parsePIDs(output []byte) []int {
-	var pids []int
-	scanner := bufio.NewScanner(bytes.NewReader(output))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if pid, err := strconv.Atoi(line); err == nil {
-			pids = append(pids, pid)
-		}
+	days := int(duration.Hours() / 24)
+	if days == 1 {
+		return "1 day"
 	}
-	return pids
+	return strconv.Itoa(days) + " days"
 }
 
-// getInstanceInfo retrieves detailed info about a Claude process.
-func (it *InstanceTracker) getInstanceInfo(pid int) (*ClaudeInstance, error) {
-	instance := &ClaudeInstance{
-		PID: pid,
+// matchProject ties an instance to the project directory of its working
+// directory.
+func (it *InstanceTracker) matchProject(instance *ClaudeInstance) {
+	if instance.WorkingDir == "" {
+		return
 	}
 
-	// Get working directory using lsof.
-	cwd, err := it.getProcessCWD(pid)
-	if err == nil && cwd != "" {
-		instance.WorkingDir = cwd
+	instance.ProjectName = filepath.Base(instance.WorkingDir)
+
+	if it.projectIndexer == nil {
+		return
 	}
 
-	// Get process start time using ps.
-	startTime, uptime, err := it.getProcessTimes(pid)
-	if err == nil {
-		instance.StartTime = startTime
-		instance.Uptime = uptime
+	dirName, ok := it.projectIndexer.ProjectDirForPath(instance.WorkingDir)
+	if !ok {
+		return
 	}
 
-	return instance, nil
+	instance.ProjectDir = dirName
+	instance.MatchConfidence = MatchProject
 }
 
-// getProcessCWD gets the current working directory of a process.
-func (it *InstanceTracker) getProcessCWD(pid int) (string, error) {
-	// Use lsof to find the cwd.
-	cmd := exec.Command("lsof", "-p", strconv.Itoa(pid), "-Fn")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
+// matchSessions ties instances to sessions. Sessions whose transcript a
+// process holds open are claimed first. The remaining instances are then
+// paired with the unclaimed sessions of their project by creation time: the
+// instance and session with the smallest gap between process start and the
+// transcript's first timestamp are paired first, so a newer process cannot
+// take an older one's session just because it was written more recently.
+// Instances left over, such as resumed sessions created before the process,
+// take the most recently written unclaimed session modified since they
+// started. Two instances in the same repository never share a session.
+func (it *InstanceTracker) matchSessions(instances []*ClaudeInstance) {
+	if it.projectIndexer == nil {
+		return
 	}
 
-	// Parse lsof output for cwd (field type 'cwd').
-	scanner := bufio.NewScanner(bytes.NewReader(output))
-	foundCwd := false
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "fcwd" {
-			foundCwd = true
+	claimed := make(map[string]bool)
+	var pending []*ClaudeInstance
+	for _, instance := range instances {
+		sessionID, dirName, ok := it.openSession(instance.PID)
+		if !ok || claimed[sessionID] {
+			if instance.ProjectDir != "" {
+				pending = append(pending, instance)
+			}
 			continue
 		}
-		if foundCwd && strings.HasPrefix(line, "n") {
-			return strings.TrimPrefix(line, "n"), nil
-		}
-	}
 
-	return "", nil
-}
-
-// getProcessTimes gets the start time and uptime of a process.
-func (it *InstanceTracker) getProcessTimes(pid int) (time.Time, string, error) {
-	// Use ps to get elapsed time.
-	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "etime=")
-	output, err := cmd.Output()
-	if err != nil {
-		return time.Time{}, "", err
+		claimed[sessionID] = true
+		instance.ProjectDir = dirName
+		it.setSession(instance, sessionID, MatchOpenFile)
 	}
 
-	etimeStr := strings.TrimSpace(string(output))
-	uptime := it.formatUptime(etimeStr)
-
-	// Calculate start time from elapsed time.
-	duration := it.parseEtime(etimeStr)
-	startTime := time.Now().Add(-duration)
-
-	return startTime, uptime, nil
-}
-
-// parseEtime parses ps etime format: [[DD-]HH:]MM:SS
-func (it *InstanceTracker) parseEtime(etime string) time.Duration {
-	etime = strings.TrimSpace(etime)
-
-	var days, hours, minutes, seconds int
-
-	// Check for days component.
-	if strings.Contains(etime, "-") {
-		parts := strings.SplitN(etime, "-", 2)
-		days, _ = strconv.Atoi(parts[0])
-		etime = parts[1]
+	// The most recently started instance has the fewest candidate
+	// sessions, so it picks first. PIDs break ties deterministically.
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := pending[i], pending[j]
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.After(b.StartTime)
+		}
+		return a.PID < b.PID
+	})
+
+	files := make(map[string][]SessionFile)
+	sessionFiles := func(dirName string) []SessionFile {
+		sessions, ok := files[dirName]
+		if !ok {
+			sessions, _ = it.projectIndexer.SessionFiles(dirName)
+			files[dirName] = sessions
+		}
+		return sessions
 	}
 
-	parts := strings.Split(etime, ":")
-	switch len(parts) {
-	case 3: // HH:MM:SS
-		hours, _ = strconv.Atoi(parts[0])
-		minutes, _ = strconv.Atoi(parts[1])
-		seconds, _ = strconv.Atoi(parts[2])
-	case 2: // MM:SS
-		minutes, _ = strconv.Atoi(parts[0])
-		seconds, _ = strconv.Atoi(parts[1])
-	case 1: // SS
-		seconds, _ = strconv.Atoi(parts[0])
+	// candidate pairs an instance with a session created after it
+	// started.
+	type candidate struct {
+		instance  *ClaudeInstance
+		sessionID string
+		gap       time.Duration
 	}
 
-	return time.Duration(days)*24*time.Hour +
-		time.Duration(hours)*time.Hour +
-		time.Duration(minutes)*time.Minute +
-		time.Duration(seconds)*time.Second
-}
+	var candidates []candidate
+	created := make(map[string]time.Time)
+	for _, instance := range pending {
+		earliest := instance.StartTime.Add(-sessionStartSlack)
 
-// formatUptime converts etime to human-readable format.
-func (it *InstanceTracker) formatUptime(etime string) string {
-	duration := it.parseEtime(etime)
+		// Sessions are sorted most recent first, and a session
+		// created after the process started was also written since.
+		for _, session := range sessionFiles(instance.ProjectDir) {
+			if session.ModTime.Before(earliest) {
+				break
+			}
+			if claimed[session.SessionID] {
+				continue
+			}
 
-	if duration < time.Minute {
-		return "just now"
-	}
+			createdAt, ok := created[session.Path]
+			if !ok {
+				createdAt, _ = transcriptStartTime(session.Path)
+				created[session.Path] = createdAt
+			}
+			if createdAt.IsZero() || createdAt.Before(earliest) {
+				continue
+			}
 
-	if duration < time.Hour {
-		minutes := int(duration.Minutes())
-		if minutes == 1 {
-			return "1 min"
+			candidates = append(candidates, candidate{
+				instance:  instance,
+				sessionID: session.SessionID,
+				gap:       createdAt.Sub(instance.StartTime),
+			})
 		}
-		return strconv.Itoa(minutes) + " mins"
 	}
 
-	if duration < 24*time.Hour {
-		hours := int(duration.Hours())
-		if hours == 1 {
-			return "1 hour"
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].gap < candidates[j].gap
+	})
+	for _, c := range candidates {
+		if c.instance.SessionID != "" || claimed[c.sessionID] {
+			continue
 		}
-		return strconv.Itoa(hours) + " hours"
-	}
 
-	days := int(duration.Hours() / 24)
-	if days == 1 {
-		return "1 day"
+		claimed[c.sessionID] = true
+		it.setSession(c.instance, c.sessionID, MatchRecent)
 	}
-	return strconv.Itoa(days) + " days"
-}
 
-// enrichInstance adds project and task data to an instance.
-func (it *InstanceTracker) enrichInstance(instance *ClaudeInstance) {
-	if instance.WorkingDir == "" || it.projectIndexer == nil {
-		return
-	}
+	for _, instance := range pending {
+		if instance.SessionID != "" {
+			continue
+		}
 
-	// Extract project name from working directory.
-	instance.ProjectName = extractProjectName(
-		strings.ReplaceAll(instance.WorkingDir, "/", "-"),
-	)
+		for _, session := range sessionFiles(instance.ProjectDir) {
+			if session.ModTime.Before(instance.StartTime) {
+				break
+			}
+			if claimed[session.SessionID] {
+				continue
+			}
 
-	// Try to find active session for this project.
-	activeLists, err := it.projectIndexer.ListActiveTaskLists()
-	if err != nil {
-		return
+			claimed[session.SessionID] = true
+			it.setSession(instance, session.SessionID, MatchRecent)
+			break
+		}
 	}
+}
 
-	// Match by project path.
-	for _, active := range activeLists {
-		if active.ProjectPath != "" &&
-			strings.Contains(instance.WorkingDir, active.ProjectName) {
+// openSession returns the session whose transcript a process has open.
+func (it *InstanceTracker) openSession(pid int) (string, string, bool) {
+	files, err := it.procs.OpenFiles(pid)
+	if err != nil {
+		return "", "", false
+	}
 
-			instance.SessionID = active.SessionID
-			instance.TaskCount = active.TaskCount
-			instance.HasTasks = active.TaskCount > 0
-			break
+	for _, path := range files {
+		sessionID, dirName, ok := it.projectIndexer.SessionForTranscript(
+			path,
+		)
+		if ok {
+			return sessionID, dirName, true
 		}
 	}
+
+	return "", "", false
+}
+
+// setSession records the session an instance was matched to along with its
+// task count.
+func (it *InstanceTracker) setSession(instance *ClaudeInstance,
+	sessionID string, confidence MatchConfidence) {
+
+	instance.SessionID = sessionID
+	instance.MatchConfidence = confidence
+	instance.TaskCount = it.projectIndexer.GetTaskCount(sessionID)
+	instance.HasTasks = instance.TaskCount > 0
 }
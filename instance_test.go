@@ -0,0 +1,183 @@
+package taskviewer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeProcessSource is a ProcessSource over a fixed process table.
+type fakeProcessSource struct {
+	procs map[int]*ProcessInfo
+	open  map[int][]string
+}
+
+// ClaudePIDs returns the PIDs of the fake process table in order.
+func (f *fakeProcessSource) ClaudePIDs() ([]int, error) {
+	pids := make([]int, 0, len(f.procs))
+	for pid := range f.procs {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	return pids, nil
+}
+
+// Process returns a process from the fake table.
+func (f *fakeProcessSource) Process(pid int) (*ProcessInfo, error) {
+	proc, ok := f.procs[pid]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return proc, nil
+}
+
+// OpenFiles returns the files a fake process has open.
+func (f *fakeProcessSource) OpenFiles(pid int) ([]string, error) {
+	return f.open[pid], nil
+}
+
+// writeTranscript writes a transcript whose first line is stamped created
+// and sets its modification time.
+func writeTranscript(t *testing.T, path string, created,
+	modified time.Time) {
+
+	t.Helper()
+
+	line := fmt.Sprintf(
+		`{"type":"user","timestamp":%q}`+"\n",
+		created.UTC().Format(time.RFC3339Nano),
+	)
+	if err := os.WriteFile(path, []byte(line), 0o600); err != nil {
+		t.Fatalf("unable to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatalf("unable to touch %s: %v", path, err)
+	}
+}
+
+// TestMatchSessions checks how two instances in the same project are tied
+// to their sessions.
+func TestMatchSessions(t *testing.T) {
+	const workDir = "/repo/app"
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	older := start
+	newer := start.Add(10 * time.Minute)
+
+	tests := []struct {
+		name string
+
+		// sessions maps a session ID to its creation and
+		// modification times, relative to start.
+		sessions map[string][2]time.Duration
+
+		// open maps a PID to the session whose transcript it holds.
+		open map[int]string
+
+		want map[int]string
+	}{
+		{
+			// The older instance wrote last, but each session
+			// was created just after its own process started.
+			name: "creation time beats recency",
+			sessions: map[string][2]time.Duration{
+				"s-old": {2 * time.Second, 30 * time.Minute},
+				"s-new": {
+					10*time.Minute + 3*time.Second,
+					20 * time.Minute,
+				},
+			},
+			want: map[int]string{1: "s-old", 2: "s-new"},
+		},
+		{
+			// An open transcript wins, leaving the other
+			// session to the other instance.
+			name: "open file first",
+			sessions: map[string][2]time.Duration{
+				"s-old": {2 * time.Second, 30 * time.Minute},
+				"s-new": {
+					10*time.Minute + 3*time.Second,
+					20 * time.Minute,
+				},
+			},
+			open: map[int]string{2: "s-old"},
+			want: map[int]string{1: "s-new", 2: "s-old"},
+		},
+		{
+			// A resumed session predates both processes, so it
+			// falls back to recency.
+			name: "resumed session",
+			sessions: map[string][2]time.Duration{
+				"s-resumed": {-time.Hour, 30 * time.Minute},
+				"s-new": {
+					10*time.Minute + 3*time.Second,
+					20 * time.Minute,
+				},
+			},
+			want: map[int]string{1: "s-resumed", 2: "s-new"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			projectsDir := t.TempDir()
+			projectDir := filepath.Join(
+				projectsDir, sanitizeProjectPath(workDir),
+			)
+			if err := os.MkdirAll(projectDir, 0o700); err != nil {
+				t.Fatalf("unable to create project: %v", err)
+			}
+
+			for id, times := range test.sessions {
+				path := filepath.Join(projectDir, id+".jsonl")
+				created := start.Add(times[0])
+				modified := start.Add(times[1])
+				writeTranscript(t, path, created, modified)
+			}
+
+			procs := &fakeProcessSource{
+				procs: map[int]*ProcessInfo{
+					1: {
+						PID:        1,
+						WorkingDir: workDir,
+						StartTime:  older,
+					},
+					2: {
+						PID:        2,
+						WorkingDir: workDir,
+						StartTime:  newer,
+					},
+				},
+				open: make(map[int][]string),
+			}
+			for pid, id := range test.open {
+				procs.open[pid] = []string{
+					filepath.Join(projectDir, id+".jsonl"),
+				}
+			}
+
+			pi := NewProjectIndexer(projectsDir, t.TempDir())
+			instances, err := NewInstanceTracker(pi, procs).
+				ListRunningInstances()
+			if err != nil {
+				t.Fatalf("unable to list instances: %v", err)
+			}
+
+			got := make(map[int]string)
+			for _, instance := range instances {
+				got[instance.PID] = instance.SessionID
+			}
+			for pid, want := range test.want {
+				if got[pid] != want {
+					t.Fatalf("pid %d matched %q, want %q",
+						pid, got[pid], want)
+				}
+			}
+		})
+	}
+}
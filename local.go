@@ -0,0 +1,131 @@
+package taskviewer
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// localOptions selects the optional components of startLocal.
+type localOptions struct {
+	// archive reads the daemon's archive, if it has one, so that ended
+	// sessions still show their tasks.
+	archive bool
+
+	// watchdog detects stalled sessions, unless --no-watchdog is set.
+	watchdog bool
+
+	// search indexes tasks and sessions for full-text search.
+	search bool
+}
+
+// localComponents are the components of a command that reads the claude
+// directory itself rather than asking the daemon. Optional components that
+// were not asked for are nil.
+type localComponents struct {
+	taskStore       claudeagent.TaskStore
+	projectIndexer  *ProjectIndexer
+	instanceTracker *InstanceTracker
+	taskArchive     *TaskArchive
+	eventBus        *EventBus
+	watchdog        *Watchdog
+	searchIndex     *SearchIndex
+}
+
+// startLocal creates and starts the components of a command that runs
+// without the daemon. The returned function stops the components that were
+// started, and is set even if an error is returned.
+func startLocal(cfg *Config, opts localOptions,
+	log btclog.Logger) (*localComponents, func(), error) {
+
+	var stops []func() error
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			_ = stops[i]()
+		}
+	}
+
+	paths, err := cfg.ResolvePaths()
+	if err != nil {
+		return nil, stop, err
+	}
+	if err := paths.Validate(); err != nil {
+		return nil, stop, fmt.Errorf("invalid state directory: %w", err)
+	}
+
+	taskStore, err := claudeagent.NewFileTaskStore(paths.TasksDir)
+	if err != nil {
+		return nil, stop, fmt.Errorf("failed to create task store: %w",
+			err)
+	}
+	projectIndexer := NewProjectIndexer(paths.ProjectsDir, paths.TasksDir)
+	instanceTracker := NewInstanceTracker(projectIndexer, nil)
+	eventBus := NewEventBus(taskStore, projectIndexer, instanceTracker, log)
+
+	c := &localComponents{
+		taskStore:       taskStore,
+		projectIndexer:  projectIndexer,
+		instanceTracker: instanceTracker,
+		eventBus:        eventBus,
+	}
+
+	if opts.archive {
+		c.taskArchive, err = openExistingArchive(
+			cfg, taskStore, projectIndexer,
+		)
+		if err != nil {
+			return nil, stop, err
+		}
+	}
+
+	// The archive belongs to the daemon, so the watchdog goes without
+	// it here.
+	if opts.watchdog && !cfg.NoWatchdog {
+		c.watchdog = NewWatchdog(
+			WatchdogConfig{
+				TaskAge: cfg.StallTaskAge,
+				Idle:    cfg.StallIdle,
+			},
+			taskStore, projectIndexer, instanceTracker, nil,
+			eventBus, paths.TasksDir, log,
+		)
+	}
+
+	if opts.search {
+		c.searchIndex = NewSearchIndex(
+			taskStore, projectIndexer, c.taskArchive, eventBus,
+			cfg.SearchTranscripts, log,
+		)
+	}
+
+	if err := projectIndexer.Start(); err != nil {
+		return nil, stop, fmt.Errorf("failed to start project "+
+			"indexer: %w", err)
+	}
+	stops = append(stops, projectIndexer.Stop)
+
+	if err := eventBus.Start(); err != nil {
+		return nil, stop, fmt.Errorf("failed to start event bus: %w",
+			err)
+	}
+	stops = append(stops, eventBus.Stop)
+
+	if c.searchIndex != nil {
+		if err := c.searchIndex.Start(); err != nil {
+			return nil, stop, fmt.Errorf("failed to start search "+
+				"index: %w", err)
+		}
+		stops = append(stops, c.searchIndex.Stop)
+	}
+
+	if c.watchdog != nil {
+		if err := c.watchdog.Start(); err != nil {
+			return nil, stop, fmt.Errorf("failed to start "+
+				"watchdog: %w", err)
+		}
+		stops = append(stops, c.watchdog.Stop)
+	}
+
+	return c, stop, nil
+}
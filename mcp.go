@@ -0,0 +1,862 @@
+package taskviewer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// mcpProtocolVersion is the newest MCP revision the server speaks.
+	mcpProtocolVersion = "2025-06-18"
+
+	// mcpMaxMessageSize caps the size of a message from a client.
+	mcpMaxMessageSize = 1 << 20
+)
+
+// mcpProtocolVersions holds every MCP revision the server can speak. A
+// client asking for another one is answered with mcpProtocolVersion.
+var mcpProtocolVersions = map[string]bool{
+	"2024-11-05":       true,
+	"2025-03-26":       true,
+	mcpProtocolVersion: true,
+}
+
+// JSON-RPC error codes used by the MCP server.
+const (
+	mcpErrParse          = -32700
+	mcpErrInvalidRequest = -32600
+	mcpErrMethodNotFound = -32601
+	mcpErrInvalidParams  = -32602
+)
+
+// mcpInstructions is sent to clients when they connect, telling the model
+// what the server is for.
+const mcpInstructions = "Reports what the Claude sessions on this " +
+	"machine are doing: the running instances, the task lists of " +
+	"active sessions and their tasks. Check it before starting work " +
+	"to avoid duplicating what another agent is already doing."
+
+// mcpRequest is a JSON-RPC request or notification from a client.
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// mcpResponse is a JSON-RPC response to a client.
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+// mcpError is a JSON-RPC error.
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool is a tool offered to clients.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema mcpSchema      `json:"inputSchema"`
+	Annotations mcpAnnotations `json:"annotations"`
+
+	// call runs the tool with its arguments.
+	call func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// mcpSchema is the JSON schema of a tool's arguments, which are always an
+// object.
+type mcpSchema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]mcpProperty `json:"properties"`
+	Required   []string               `json:"required,omitempty"`
+}
+
+// mcpProperty is the JSON schema of an argument.
+type mcpProperty struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Enum        []string `json:"enum,omitempty"`
+}
+
+// mcpSessionIDProperty is the argument naming a session.
+var mcpSessionIDProperty = mcpProperty{
+	Type:        "string",
+	Description: "Session ID, which is also the ID of its task list",
+}
+
+// mcpListsSchema is the schema of list_active_task_lists.
+var mcpListsSchema = mcpSchema{
+	Properties: map[string]mcpProperty{
+		"project": {
+			Type: "string",
+			Description: "Only lists of the project with this " +
+				"name, path or directory name",
+		},
+	},
+}
+
+// mcpTaskListSchema is the schema of get_task_list.
+var mcpTaskListSchema = mcpSchema{
+	Properties: map[string]mcpProperty{
+		"session_id": mcpSessionIDProperty,
+		"status": {
+			Type: "string",
+			Enum: []string{
+				TaskFilterAll,
+				TaskFilterActive,
+				string(claudeagent.TaskListStatusPending),
+				string(claudeagent.TaskListStatusInProgress),
+				string(claudeagent.TaskListStatusCompleted),
+			},
+			Description: "Only tasks with this status; active " +
+				"means pending or in progress",
+		},
+	},
+	Required: []string{"session_id"},
+}
+
+// mcpSearchSchema is the schema of search_sessions.
+var mcpSearchSchema = mcpSchema{
+	Properties: map[string]mcpProperty{
+		"query": {
+			Type:        "string",
+			Description: `Words or "quoted phrases" to search for`,
+		},
+		"project": {
+			Type: "string",
+			Description: "Only results in the project with this " +
+				"name or directory name",
+		},
+		"branch": {
+			Type:        "string",
+			Description: "Only results on this git branch",
+		},
+		"limit": {
+			Type: "integer",
+			Description: "Maximum number of results, at most 200 " +
+				"and 50 by default",
+		},
+	},
+	Required: []string{"query"},
+}
+
+// mcpSessionSchema is the schema of get_session_summary.
+var mcpSessionSchema = mcpSchema{
+	Properties: map[string]mcpProperty{
+		"session_id": mcpSessionIDProperty,
+	},
+	Required: []string{"session_id"},
+}
+
+// mcpAnnotations are hints to clients about how a tool behaves.
+type mcpAnnotations struct {
+	ReadOnlyHint bool `json:"readOnlyHint"`
+}
+
+// mcpToolResult is the result of a tool call.
+type mcpToolResult struct {
+	Content           []mcpContent `json:"content"`
+	StructuredContent any          `json:"structuredContent,omitempty"`
+	IsError           bool         `json:"isError,omitempty"`
+}
+
+// mcpContent is a block of a tool result.
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// MCPTaskList is an active task list with its task counts and the tasks
+// in progress, which say what the session is working on.
+type MCPTaskList struct {
+	SnapshotList
+	InProgress []claudeagent.TaskListItem `json:"inProgress"`
+}
+
+// MCPSessionSummary describes a session: its index entry, its tasks and the
+// instance running it, if any.
+type MCPSessionSummary struct {
+	APISession
+	ProjectName string                     `json:"projectName,omitempty"`
+	Counts      TaskCounts                 `json:"counts"`
+	InProgress  []claudeagent.TaskListItem `json:"inProgress"`
+
+	// Instance is the running Claude process of the session, if any.
+	Instance *ClaudeInstance `json:"instance,omitempty"`
+}
+
+// MCPServer serves the viewer's data to agents over the Model Context
+// Protocol, either on stdio or on the daemon's /mcp endpoint. Every tool is
+// read-only. The archive, watchdog and search index may be nil.
+type MCPServer struct {
+	taskStore       claudeagent.TaskStore
+	projectIndexer  *ProjectIndexer
+	instanceTracker *InstanceTracker
+	taskArchive     *TaskArchive
+	watchdog        *Watchdog
+	searchIndex     *SearchIndex
+
+	tools []mcpTool
+
+	log btclog.Logger
+}
+
+// NewMCPServer creates an MCP server backed by the given components.
+func NewMCPServer(taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, instanceTracker *InstanceTracker,
+	taskArchive *TaskArchive, watchdog *Watchdog,
+	searchIndex *SearchIndex, log btclog.Logger) *MCPServer {
+
+	m := &MCPServer{
+		taskStore:       taskStore,
+		projectIndexer:  projectIndexer,
+		instanceTracker: instanceTracker,
+		taskArchive:     taskArchive,
+		watchdog:        watchdog,
+		searchIndex:     searchIndex,
+		log:             log,
+	}
+
+	m.tools = []mcpTool{
+		{
+			Name: "list_instances",
+			Description: "List the Claude processes running on " +
+				"this machine, with their working " +
+				"directory, session, task count and whether " +
+				"they appear stalled.",
+			call: m.listInstances,
+		},
+		{
+			Name: "list_active_task_lists",
+			Description: "List the sessions that have tasks, " +
+				"with their project, task counts and the " +
+				"tasks in progress. Use it to see what " +
+				"other agents are working on.",
+			InputSchema: mcpListsSchema,
+			call:        m.listActiveTaskLists,
+		},
+		{
+			Name: "get_task_list",
+			Description: "Get the tasks of a session, with " +
+				"their status, owner and dependencies. " +
+				"Ended sessions are read from the archive.",
+			InputSchema: mcpTaskListSchema,
+			call:        m.getTaskList,
+		},
+		{
+			Name: "search_sessions",
+			Description: "Search session summaries, first " +
+				"prompts, branches and tasks, and " +
+				"transcripts if the server indexes them. " +
+				"Words must all match; quote a phrase to " +
+				"match it in order.",
+			InputSchema: mcpSearchSchema,
+			call:        m.searchSessions,
+		},
+		{
+			Name: "get_session_summary",
+			Description: "Summarize a session: its summary, " +
+				"first prompt, branch and project, its task " +
+				"counts and tasks in progress, and the " +
+				"instance running it, if any.",
+			InputSchema: mcpSessionSchema,
+			call:        m.getSessionSummary,
+		},
+	}
+	for i := range m.tools {
+		schema := &m.tools[i].InputSchema
+		schema.Type = "object"
+		if schema.Properties == nil {
+			schema.Properties = map[string]mcpProperty{}
+		}
+		m.tools[i].Annotations.ReadOnlyHint = true
+	}
+
+	return m
+}
+
+// RunMCP serves MCP on stdin and stdout, reading the claude directory given
+// by cfg, until the client closes stdin or the context is canceled.
+func RunMCP(ctx context.Context, cfg *Config, log btclog.Logger) error {
+	local, stop, err := startLocal(cfg, localOptions{
+		archive:  true,
+		watchdog: true,
+		search:   true,
+	}, log)
+	defer stop()
+	if err != nil {
+		return err
+	}
+
+	mcp := NewMCPServer(
+		local.taskStore, local.projectIndexer, local.instanceTracker,
+		local.taskArchive, local.watchdog, local.searchIndex, log,
+	)
+
+	return mcp.ServeStdio(ctx, os.Stdin, os.Stdout)
+}
+
+// ServeStdio serves MCP on a pair of streams carrying one JSON-RPC message
+// per line, until in ends or the context is canceled.
+func (m *MCPServer) ServeStdio(ctx context.Context, in io.Reader,
+	out io.Writer) error {
+
+	type line struct {
+		data []byte
+		err  error
+	}
+
+	// Reads block, so they are made in the background to let a canceled
+	// context end the loop.
+	lines := make(chan line)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			data, err := reader.ReadBytes('\n')
+			select {
+			case lines <- line{data, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var next line
+		select {
+		case next = <-lines:
+		case <-ctx.Done():
+			return nil
+		}
+
+		if data := strings.TrimSpace(string(next.data)); data != "" {
+			resp := m.handle(ctx, []byte(data))
+			if resp != nil {
+				msg, err := json.Marshal(resp)
+				if err != nil {
+					return fmt.Errorf("failed to encode "+
+						"response: %w", err)
+				}
+				msg = append(msg, '\n')
+				if _, err := out.Write(msg); err != nil {
+					return fmt.Errorf("failed to write "+
+						"response: %w", err)
+				}
+			}
+		}
+
+		switch {
+		case next.err == io.EOF:
+			return nil
+
+		case next.err != nil:
+			return fmt.Errorf("failed to read request: %w",
+				next.err)
+		}
+	}
+}
+
+// ServeHTTP serves MCP's Streamable HTTP transport: each POST carries one
+// JSON-RPC message and gets the response as JSON. The server never starts a
+// stream of its own, so GET is not allowed.
+func (m *MCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// A browser may send a simple POST to any address, so requests from
+	// web pages on another origin are refused.
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			writeAPIError(
+				w, http.StatusForbidden, ErrCodeForbidden,
+				"cross-origin requests are not allowed",
+			)
+			return
+		}
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(
+			w, http.StatusMethodNotAllowed, ErrCodeBadRequest,
+			"MCP requests must be POSTed",
+		)
+		return
+	}
+
+	data, err := io.ReadAll(
+		http.MaxBytesReader(w, r.Body, mcpMaxMessageSize),
+	)
+	if err != nil {
+		writeAPIError(
+			w, http.StatusRequestEntityTooLarge, ErrCodeBadRequest,
+			"failed to read request: "+err.Error(),
+		)
+		return
+	}
+
+	resp := m.handle(r.Context(), data)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handle answers a JSON-RPC message, returning nil for notifications.
+func (m *MCPServer) handle(ctx context.Context, data []byte) *mcpResponse {
+	var req mcpRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return mcpErrorResponse(
+			nil, mcpErrParse, "invalid JSON-RPC message",
+		)
+	}
+
+	// Notifications have no ID and get no response, even on error.
+	notification := len(req.ID) == 0
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if notification {
+			return nil
+		}
+		return mcpErrorResponse(
+			req.ID, mcpErrInvalidRequest,
+			"invalid JSON-RPC request",
+		)
+	}
+
+	var (
+		result any
+		rpcErr *mcpError
+	)
+	switch req.Method {
+	case "initialize":
+		result, rpcErr = m.initialize(req.Params)
+
+	case "ping":
+		result = struct{}{}
+
+	case "tools/list":
+		result = map[string]any{"tools": m.tools}
+
+	case "tools/call":
+		result, rpcErr = m.callTool(ctx, req.Params)
+
+	default:
+		// Notifications such as notifications/initialized need
+		// nothing from the server.
+		rpcErr = &mcpError{
+			Code:    mcpErrMethodNotFound,
+			Message: "method not found: " + req.Method,
+		}
+	}
+
+	if notification {
+		return nil
+	}
+	if rpcErr != nil {
+		return mcpErrorResponse(req.ID, rpcErr.Code, rpcErr.Message)
+	}
+
+	return &mcpResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// mcpErrorResponse builds an error response. A nil ID is sent as null.
+func mcpErrorResponse(id json.RawMessage, code int,
+	msg string) *mcpResponse {
+
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	return &mcpResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &mcpError{Code: code, Message: msg},
+	}
+}
+
+// initialize answers a client's initialize request, agreeing on the
+// protocol revision.
+func (m *MCPServer) initialize(params json.RawMessage) (any, *mcpError) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &mcpError{
+			Code:    mcpErrInvalidParams,
+			Message: "invalid initialize params",
+		}
+	}
+
+	version := mcpProtocolVersion
+	if mcpProtocolVersions[p.ProtocolVersion] {
+		version = p.ProtocolVersion
+	}
+
+	serverVersion := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok &&
+		info.Main.Version != "" && info.Main.Version != "(devel)" {
+
+		serverVersion = info.Main.Version
+	}
+
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools": map[string]any{},
+		},
+		"serverInfo": map[string]any{
+			"name":    "taskviewer",
+			"version": serverVersion,
+		},
+		"instructions": mcpInstructions,
+	}, nil
+}
+
+// callTool runs a tool. Failures of the tool itself are returned in the
+// result, where the model can see them, rather than as JSON-RPC errors.
+func (m *MCPServer) callTool(ctx context.Context,
+	params json.RawMessage) (any, *mcpError) {
+
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &mcpError{
+			Code:    mcpErrInvalidParams,
+			Message: "invalid tools/call params",
+		}
+	}
+
+	var tool *mcpTool
+	for i := range m.tools {
+		if m.tools[i].Name == p.Name {
+			tool = &m.tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return nil, &mcpError{
+			Code:    mcpErrInvalidParams,
+			Message: "unknown tool: " + p.Name,
+		}
+	}
+
+	if len(p.Arguments) == 0 || string(p.Arguments) == "null" {
+		p.Arguments = json.RawMessage("{}")
+	}
+
+	output, err := tool.call(ctx, p.Arguments)
+	if err != nil {
+		m.log.Debugf("MCP tool %s failed: %v", p.Name, err)
+
+		return mcpToolResult{
+			Content: []mcpContent{{
+				Type: "text",
+				Text: err.Error(),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	text, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return mcpToolResult{
+			Content: []mcpContent{{
+				Type: "text",
+				Text: "failed to encode result: " + err.Error(),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	return mcpToolResult{
+		Content: []mcpContent{{
+			Type: "text",
+			Text: string(text),
+		}},
+		StructuredContent: output,
+	}, nil
+}
+
+// decodeToolArgs decodes the arguments of a tool call into v.
+func decodeToolArgs(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	return nil
+}
+
+// listInstances lists the running instances.
+func (m *MCPServer) listInstances(_ context.Context,
+	_ json.RawMessage) (any, error) {
+
+	instances, err := m.instanceTracker.ListRunningInstances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	if instances == nil {
+		instances = []ClaudeInstance{}
+	}
+	m.watchdog.annotateInstances(instances)
+
+	return map[string]any{"instances": instances}, nil
+}
+
+// listActiveTaskLists lists the sessions with tasks, optionally of one
+// project.
+func (m *MCPServer) listActiveTaskLists(ctx context.Context,
+	args json.RawMessage) (any, error) {
+
+	var p struct {
+		Project string `json:"project"`
+	}
+	if err := decodeToolArgs(args, &p); err != nil {
+		return nil, err
+	}
+
+	dirName := ""
+	if p.Project != "" {
+		var err error
+		dirName, err = m.resolveProject(p.Project)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lists, err := m.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task lists: %w", err)
+	}
+	m.watchdog.annotateLists(lists)
+
+	result := []MCPTaskList{}
+	for _, list := range lists {
+		if dirName != "" && list.ProjectPath != dirName {
+			continue
+		}
+
+		tasks, err := m.taskStore.List(ctx, list.SessionID)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, MCPTaskList{
+			SnapshotList: SnapshotList{
+				ActiveTaskList: list,
+				Counts:         countTasks(tasks),
+			},
+			InProgress: inProgressTasks(tasks),
+		})
+	}
+
+	return map[string]any{"lists": result}, nil
+}
+
+// resolveProject returns the directory name of the project with the given
+// name, path or directory name.
+func (m *MCPServer) resolveProject(name string) (string, error) {
+	projects, err := m.projectIndexer.ListProjectSummaries()
+	if err != nil {
+		return "", fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	var matches []string
+	for _, p := range projects {
+		if p.DirName == name || p.Path == name {
+			return p.DirName, nil
+		}
+		if p.Name == name || p.Shortname == name {
+			matches = append(matches, p.DirName)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("project not found: %s", name)
+
+	case 1:
+		return matches[0], nil
+
+	default:
+		return "", fmt.Errorf("%s matches several projects, give "+
+			"its path instead", name)
+	}
+}
+
+// getTaskList returns the tasks of a session.
+func (m *MCPServer) getTaskList(ctx context.Context,
+	args json.RawMessage) (any, error) {
+
+	var p struct {
+		SessionID string `json:"session_id"`
+		Status    string `json:"status"`
+	}
+	if err := decodeToolArgs(args, &p); err != nil {
+		return nil, err
+	}
+	if p.SessionID == "" {
+		return nil, errors.New("session_id is required")
+	}
+	if !validTaskFilter(p.Status) {
+		return nil, fmt.Errorf("unknown status: %s", p.Status)
+	}
+
+	tasks, archived, err := loadTasks(
+		ctx, m.taskStore, m.projectIndexer, m.taskArchive,
+		p.SessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+
+	filtered := filterTasks(tasks, p.Status)
+	if filtered == nil {
+		filtered = []claudeagent.TaskListItem{}
+	}
+
+	return APITaskList{
+		ListID:   p.SessionID,
+		Filter:   p.Status,
+		Archived: archived,
+		Counts:   countTasks(tasks),
+		Tasks:    filtered,
+	}, nil
+}
+
+// searchSessions searches the search index.
+func (m *MCPServer) searchSessions(_ context.Context,
+	args json.RawMessage) (any, error) {
+
+	var p struct {
+		Query   string `json:"query"`
+		Project string `json:"project"`
+		Branch  string `json:"branch"`
+		Limit   int    `json:"limit"`
+	}
+	if err := decodeToolArgs(args, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(p.Query) == "" {
+		return nil, errors.New("query is required")
+	}
+	if p.Limit < 0 || p.Limit > searchMaxLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d",
+			searchMaxLimit)
+	}
+
+	results, err := m.searchIndex.Search(SearchQuery{
+		Text:    p.Query,
+		Project: p.Project,
+		Branch:  p.Branch,
+		Limit:   p.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if results.Results == nil {
+		results.Results = []SearchResult{}
+	}
+
+	// Results are incomplete until the index is first built, which
+	// matters for a server that has only just started.
+	return struct {
+		SearchResults
+		Indexing bool `json:"indexing,omitempty"`
+	}{
+		SearchResults: results,
+		Indexing:      m.searchIndex.Stats().Building,
+	}, nil
+}
+
+// getSessionSummary summarizes a session.
+func (m *MCPServer) getSessionSummary(ctx context.Context,
+	args json.RawMessage) (any, error) {
+
+	var p struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := decodeToolArgs(args, &p); err != nil {
+		return nil, err
+	}
+	if p.SessionID == "" {
+		return nil, errors.New("session_id is required")
+	}
+
+	session, projectDir, ok := m.projectIndexer.GetSession(p.SessionID)
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", p.SessionID)
+	}
+	_, hasTranscript := m.projectIndexer.TranscriptPath(p.SessionID)
+
+	summary := MCPSessionSummary{
+		APISession: APISession{
+			SessionViewEntry: newSessionViewEntry(
+				m.projectIndexer, m.taskArchive, session,
+			),
+			ProjectDir:    projectDir,
+			HasTranscript: hasTranscript,
+		},
+		InProgress: []claudeagent.TaskListItem{},
+	}
+	if project, err := m.projectIndexer.GetProject(projectDir); err == nil {
+		summary.ProjectName = project.Name
+	}
+
+	tasks, _, err := loadTasks(
+		ctx, m.taskStore, m.projectIndexer, m.taskArchive, p.SessionID,
+	)
+	if err == nil {
+		summary.Counts = countTasks(tasks)
+		summary.InProgress = inProgressTasks(tasks)
+	}
+
+	instances, err := m.instanceTracker.ListRunningInstances()
+	if err == nil {
+		m.watchdog.annotateInstances(instances)
+		for i := range instances {
+			if instances[i].SessionID == p.SessionID {
+				summary.Instance = &instances[i]
+				break
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// inProgressTasks returns the tasks in progress, never nil.
+func inProgressTasks(
+	tasks []claudeagent.TaskListItem) []claudeagent.TaskListItem {
+
+	inProgress := []claudeagent.TaskListItem{}
+	for _, task := range tasks {
+		if task.Status == claudeagent.TaskListStatusInProgress {
+			inProgress = append(inProgress, task)
+		}
+	}
+
+	return inProgress
+}
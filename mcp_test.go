@@ -0,0 +1,302 @@
+package taskviewer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// mcpTestResponse is a JSON-RPC response as a client decodes it.
+type mcpTestResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *mcpError       `json:"error"`
+}
+
+// mcpTestToolResult is a tool call result as a client decodes it.
+type mcpTestToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError"`
+
+	StructuredContent json.RawMessage `json:"structuredContent"`
+}
+
+// decodeToolResult decodes the result of a tool call, checking that the
+// text content repeats the structured content.
+func decodeToolResult(t *testing.T, resp mcpTestResponse) mcpTestToolResult {
+	t.Helper()
+
+	if resp.Error != nil {
+		t.Fatalf("got error %+v", resp.Error)
+	}
+
+	var result mcpTestToolResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unable to decode result %s: %v", resp.Result, err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Type != "text" {
+		t.Fatalf("got content %+v", result.Content)
+	}
+
+	if !result.IsError {
+		var text, structured any
+		err := json.Unmarshal([]byte(result.Content[0].Text), &text)
+		if err != nil {
+			t.Fatalf("unable to decode text: %v", err)
+		}
+		err = json.Unmarshal(result.StructuredContent, &structured)
+		if err != nil {
+			t.Fatalf("unable to decode structured content: %v",
+				err)
+		}
+		if !reflect.DeepEqual(text, structured) {
+			t.Fatalf("text %v differs from structured content %v",
+				text, structured)
+		}
+	}
+
+	return result
+}
+
+// TestMCPStdio runs a client session over stdio: initialize, list the tools
+// and call them, with the errors a client can run into.
+func TestMCPStdio(t *testing.T) {
+	running := testTask("2")
+	running.Status = claudeagent.TaskListStatusInProgress
+
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"), running)
+	h, _ := newTestHTTPServer(t, store)
+
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize",` +
+			`"params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":` +
+			`{"name":"get_task_list","arguments":` +
+			`{"session_id":"s1","status":"in_progress"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":` +
+			`{"name":"get_task_list","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":` +
+			`{"name":"list_instances"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":` +
+			`{"name":"delete_everything"}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"resources/list"}`,
+		`{"id":8,"method":"ping"}`,
+		`not json`,
+	}
+
+	in := strings.NewReader(strings.Join(requests, "\n"))
+	var out strings.Builder
+	err := h.mcp.ServeStdio(context.Background(), in, &out)
+	if err != nil {
+		t.Fatalf("unable to serve: %v", err)
+	}
+
+	// Every request but the notification and the blank line is
+	// answered, in order, on its own line.
+	responses := make(map[string]mcpTestResponse)
+	var ids []string
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var resp mcpTestResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("unable to decode %q: %v", scanner.Text(), err)
+		}
+		if resp.JSONRPC != "2.0" {
+			t.Fatalf("got jsonrpc %q", resp.JSONRPC)
+		}
+		ids = append(ids, string(resp.ID))
+		responses[string(resp.ID)] = resp
+	}
+	wantIDs := []string{"1", "2", "3", "4", "5", "6", "7", "8", "null"}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Fatalf("got responses to %v, want %v", ids, wantIDs)
+	}
+
+	var initialized struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	err = json.Unmarshal(responses["1"].Result, &initialized)
+	if err != nil {
+		t.Fatalf("unable to decode initialize result: %v", err)
+	}
+	if initialized.ProtocolVersion != "2025-03-26" ||
+		initialized.ServerInfo.Name != "taskviewer" {
+
+		t.Fatalf("got initialize result %+v", initialized)
+	}
+
+	var listed struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema mcpSchema      `json:"inputSchema"`
+			Annotations mcpAnnotations `json:"annotations"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(responses["2"].Result, &listed); err != nil {
+		t.Fatalf("unable to decode tools: %v", err)
+	}
+	var names []string
+	for _, tool := range listed.Tools {
+		names = append(names, tool.Name)
+		if tool.InputSchema.Type != "object" ||
+			tool.InputSchema.Properties == nil ||
+			!tool.Annotations.ReadOnlyHint {
+
+			t.Fatalf("got tool %+v", tool)
+		}
+	}
+	wantNames := []string{
+		"list_instances", "list_active_task_lists", "get_task_list",
+		"search_sessions", "get_session_summary",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("got tools %v, want %v", names, wantNames)
+	}
+
+	var list APITaskList
+	result := decodeToolResult(t, responses["3"])
+	if err := json.Unmarshal(result.StructuredContent, &list); err != nil {
+		t.Fatalf("unable to decode task list: %v", err)
+	}
+	if list.ListID != "s1" || list.Counts.Total != 2 ||
+		len(list.Tasks) != 1 || list.Tasks[0].ID != "2" {
+
+		t.Fatalf("got task list %+v", list)
+	}
+
+	// A failing tool reports the failure to the model.
+	result = decodeToolResult(t, responses["4"])
+	if !result.IsError ||
+		result.Content[0].Text != "session_id is required" {
+
+		t.Fatalf("got result %+v for a missing session", result)
+	}
+
+	var instances struct {
+		Instances []ClaudeInstance `json:"instances"`
+	}
+	result = decodeToolResult(t, responses["5"])
+	err = json.Unmarshal(result.StructuredContent, &instances)
+	if err != nil || instances.Instances == nil {
+		t.Fatalf("got instances %s: %v", result.StructuredContent, err)
+	}
+
+	wantErrs := map[string]int{
+		"6":    mcpErrInvalidParams,
+		"7":    mcpErrMethodNotFound,
+		"8":    mcpErrInvalidRequest,
+		"null": mcpErrParse,
+	}
+	for id, code := range wantErrs {
+		if err := responses[id].Error; err == nil || err.Code != code {
+			t.Fatalf("got error %+v for %s, want code %d", err,
+				id, code)
+		}
+	}
+}
+
+// TestMCPHTTP checks the /mcp endpoint: a tools/call round trip, and the
+// requests it refuses.
+func TestMCPHTTP(t *testing.T) {
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"))
+	_, mux := newTestHTTPServer(t, store)
+
+	serve := func(method, body, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(
+			method, "/mcp", strings.NewReader(body),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	call := `{"jsonrpc":"2.0","id":"a","method":"tools/call",` +
+		`"params":{"name":"get_task_list",` +
+		`"arguments":{"session_id":"s1"}}}`
+	rec := serve(http.MethodPost, call, "http://example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var resp mcpTestResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unable to decode %s: %v", rec.Body, err)
+	}
+	if string(resp.ID) != `"a"` {
+		t.Fatalf("got ID %s", resp.ID)
+	}
+	var list APITaskList
+	result := decodeToolResult(t, resp)
+	if err := json.Unmarshal(result.StructuredContent, &list); err != nil {
+		t.Fatalf("unable to decode task list: %v", err)
+	}
+	if len(list.Tasks) != 1 || list.Tasks[0].ID != "1" {
+		t.Fatalf("got task list %+v", list)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		origin   string
+		wantCode int
+	}{
+		{
+			name:     "notification",
+			method:   http.MethodPost,
+			body:     `{"jsonrpc":"2.0","method":"initialized"}`,
+			wantCode: http.StatusAccepted,
+		},
+		{
+			name:     "GET",
+			method:   http.MethodGet,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "cross origin",
+			method:   http.MethodPost,
+			body:     call,
+			origin:   "http://evil.example",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:   "too large",
+			method: http.MethodPost,
+			body: strings.Repeat(
+				" ", mcpMaxMessageSize+1,
+			),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := serve(test.method, test.body, test.origin)
+			if rec.Code != test.wantCode {
+				t.Fatalf("got status %d, want %d: %s",
+					rec.Code, test.wantCode, rec.Body)
+			}
+		})
+	}
+}
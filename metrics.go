@@ -0,0 +1,256 @@
+package taskviewer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// taskStatuses lists every task status, so that a project reports zero
+// rather than no series for a status it has no tasks in.
+var taskStatuses = []claudeagent.TaskListStatus{
+	claudeagent.TaskListStatusPending,
+	claudeagent.TaskListStatusInProgress,
+	claudeagent.TaskListStatusCompleted,
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family starts a metric family with its help text and type.
+func (m *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(&m.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name,
+		kind)
+}
+
+// sample writes a single sample. Labels are given as name/value pairs.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&m.buf, "%s=\"%s\"", labels[i],
+				escapeLabel(labels[i+1]))
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.buf.WriteByte('\n')
+}
+
+// gauge writes a metric family holding a single unlabelled sample.
+func (m *metricsWriter) gauge(name, help string, value float64) {
+	m.family(name, "gauge", help)
+	m.sample(name, value)
+}
+
+// counter writes a counter family holding a single unlabelled sample.
+func (m *metricsWriter) counter(name, help string, value float64) {
+	m.family(name, "counter", help)
+	m.sample(name, value)
+}
+
+// labelEscaper escapes label values as the text format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value.
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// projectMetrics aggregates the task lists of a single project.
+type projectMetrics struct {
+	// name is the project's short name and dir its sanitized directory
+	// name. Checkouts of different repos can share a name, so projects
+	// are told apart by dir.
+	name string
+	dir  string
+
+	tasks map[claudeagent.TaskListStatus]int
+
+	// oldest is when the longest running in-progress task went in
+	// progress, or zero if no task is in progress.
+	oldest time.Time
+}
+
+// collectProjectMetrics aggregates the tasks of every active list by
+// project directory.
+func (h *HTTPServer) collectProjectMetrics(
+	activeLists []ActiveTaskList) map[string]*projectMetrics {
+
+	projects := make(map[string]*projectMetrics)
+	for _, al := range activeLists {
+		tasks, err := h.taskStore.List(
+			context.Background(), al.SessionID,
+		)
+		if err != nil || len(tasks) == 0 {
+			continue
+		}
+
+		pm, ok := projects[al.ProjectPath]
+		if !ok {
+			pm = &projectMetrics{
+				name:  al.ProjectName,
+				dir:   al.ProjectPath,
+				tasks: make(map[claudeagent.TaskListStatus]int),
+			}
+			if pm.name == "" {
+				pm.name = "unknown"
+			}
+			projects[al.ProjectPath] = pm
+		}
+
+		inProgress := false
+		for _, task := range tasks {
+			pm.tasks[task.Status]++
+			if task.Status == claudeagent.TaskListStatusInProgress {
+				inProgress = true
+			}
+		}
+		if !inProgress {
+			continue
+		}
+
+		timing := h.listTiming(al.SessionID, tasks, false)
+		for _, tt := range timing.Tasks {
+			if tt.Status != claudeagent.TaskListStatusInProgress {
+				continue
+			}
+			if pm.oldest.IsZero() || tt.Since.Before(pm.oldest) {
+				pm.oldest = tt.Since
+			}
+		}
+	}
+
+	return projects
+}
+
+// handleMetrics exports the viewer's state and internals in the Prometheus
+// text format. Every value is computed on scrape from the same indexer,
+// tracker and task store data the pages are rendered from.
+func (h *HTTPServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	var m metricsWriter
+
+	instances, err := h.instanceTracker.ListRunningInstances()
+	if err != nil {
+		h.log.Debugf("Metrics: unable to list instances: %v", err)
+	}
+	m.gauge(
+		"taskviewer_instances_running",
+		"Number of running Claude processes.", float64(len(instances)),
+	)
+
+	activeLists, err := h.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		h.log.Debugf("Metrics: unable to list task lists: %v", err)
+	}
+	m.gauge(
+		"taskviewer_task_lists_active",
+		"Number of task lists with task files on disk.",
+		float64(len(activeLists)),
+	)
+
+	m.gauge(
+		"taskviewer_sessions_stalled",
+		"Number of running sessions flagged as stalled by the "+
+			"watchdog.",
+		float64(len(h.watchdog.Stalled())),
+	)
+
+	projects := make([]*projectMetrics, 0, len(activeLists))
+	for _, pm := range h.collectProjectMetrics(activeLists) {
+		projects = append(projects, pm)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].name != projects[j].name {
+			return projects[i].name < projects[j].name
+		}
+		return projects[i].dir < projects[j].dir
+	})
+
+	m.family(
+		"taskviewer_tasks", "gauge",
+		"Number of tasks in active task lists by project and status.",
+	)
+	for _, pm := range projects {
+		for _, status := range taskStatuses {
+			m.sample(
+				"taskviewer_tasks", float64(pm.tasks[status]),
+				"project", pm.name, "project_dir", pm.dir,
+				"status", string(status),
+			)
+		}
+	}
+
+	m.family(
+		"taskviewer_oldest_in_progress_task_age_seconds", "gauge",
+		"Time the longest running in-progress task of a project has "+
+			"been in progress.",
+	)
+	for _, pm := range projects {
+		if pm.oldest.IsZero() {
+			continue
+		}
+		m.sample(
+			"taskviewer_oldest_in_progress_task_age_seconds",
+			max(now.Sub(pm.oldest).Seconds(), 0),
+			"project", pm.name, "project_dir", pm.dir,
+		)
+	}
+
+	scanDuration, scans := h.projectIndexer.ScanStats()
+	m.gauge(
+		"taskviewer_index_scan_duration_seconds",
+		"Duration of the last full scan of the project index.",
+		scanDuration.Seconds(),
+	)
+	m.counter(
+		"taskviewer_index_scans_total",
+		"Number of full scans of the project index.", float64(scans),
+	)
+
+	m.gauge(
+		"taskviewer_sse_clients",
+		"Number of open Server-Sent Events streams.",
+		float64(atomic.LoadInt64(&h.sseClients)),
+	)
+
+	stats := h.eventBus.Stats()
+	m.counter(
+		"taskviewer_events_published_total",
+		"Number of events published on the event bus.",
+		float64(stats.Published),
+	)
+	m.counter(
+		"taskviewer_events_dropped_total",
+		"Number of events dropped for subscribers that fell behind.",
+		float64(stats.Dropped),
+	)
+
+	m.counter(
+		"taskviewer_template_render_errors_total",
+		"Number of templates that failed to render.",
+		float64(atomic.LoadUint64(&h.renderErrors)),
+	)
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(m.buf.Bytes())
+}
@@ -0,0 +1,48 @@
+package taskviewer
+
+import (
+	"testing"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// TestCollectProjectMetrics checks that projects sharing a name are kept
+// apart by their directory.
+func TestCollectProjectMetrics(t *testing.T) {
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"), testTask("2"))
+	store.set("s2", testTask("1"))
+	store.set("s3", testTask("1"))
+	h, _ := newTestHTTPServer(t, store)
+
+	projects := h.collectProjectMetrics([]ActiveTaskList{
+		{SessionID: "s1", ProjectName: "app", ProjectPath: "-a-app"},
+		{SessionID: "s2", ProjectName: "app", ProjectPath: "-b-app"},
+		{SessionID: "s3"},
+		{SessionID: "empty", ProjectName: "app", ProjectPath: "-c-app"},
+	})
+
+	want := map[string]struct {
+		name    string
+		pending int
+	}{
+		"-a-app": {name: "app", pending: 2},
+		"-b-app": {name: "app", pending: 1},
+		"":       {name: "unknown", pending: 1},
+	}
+	if len(projects) != len(want) {
+		t.Fatalf("got %d projects, want %d", len(projects), len(want))
+	}
+	for dir, w := range want {
+		pm, ok := projects[dir]
+		if !ok {
+			t.Fatalf("project %q missing", dir)
+		}
+		pending := pm.tasks[claudeagent.TaskListStatusPending]
+		if pm.name != w.name || pm.dir != dir || pending != w.pending {
+			t.Fatalf("project %q: got %s with %d pending, want %s "+
+				"with %d", dir, pm.name, pending, w.name,
+				w.pending)
+		}
+	}
+}
@@ -0,0 +1,766 @@
+package taskviewer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// Notification events that webhooks can filter on.
+const (
+	// NotifyTaskCompleted is sent when a task is completed.
+	NotifyTaskCompleted = "task-completed"
+
+	// NotifyTaskUnblocked is sent when the last incomplete blocker of a
+	// task is completed.
+	NotifyTaskUnblocked = "task-unblocked"
+
+	// NotifyListCompleted is sent when every task of a list is completed.
+	NotifyListCompleted = "list-completed"
+
+	// NotifySessionEnded is sent when a session's task list is removed.
+	NotifySessionEnded = EventSessionEnded
+
+	// NotifyInstanceExited is sent when a Claude process exits.
+	NotifyInstanceExited = EventInstanceExited
+
+	// NotifySessionStalled is sent when the watchdog flags a session.
+	NotifySessionStalled = EventSessionStalled
+
+	// NotifyTest is sent on request to every webhook, whatever its
+	// filter, to check that deliveries arrive.
+	NotifyTest = "test"
+)
+
+// notifyEvents lists the events a webhook filter may name.
+var notifyEvents = []string{
+	NotifyTaskCompleted, NotifyTaskUnblocked, NotifyListCompleted,
+	NotifySessionEnded, NotifyInstanceExited, NotifySessionStalled,
+}
+
+// Delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	// webhookQueueSize is the number of notifications queued per webhook
+	// before new ones are dropped.
+	webhookQueueSize = 256
+
+	// webhookTimeout bounds a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+
+	// webhookMaxAttempts is the number of times a delivery is tried
+	// before it is given up on.
+	webhookMaxAttempts = 5
+
+	// webhookInitialBackoff is the wait before the first retry. It
+	// doubles with every further retry.
+	webhookInitialBackoff = 2 * time.Second
+
+	// deliveryLogSize is the number of recent deliveries kept for the
+	// delivery log.
+	deliveryLogSize = 200
+)
+
+// WebhookConfig configures a single webhook.
+type WebhookConfig struct {
+	// URL is the endpoint notifications are POSTed to.
+	URL string `json:"url"`
+
+	// Events restricts the webhook to these notification events. An
+	// empty filter receives every event.
+	Events []string `json:"events,omitempty"`
+
+	// Template is a text/template rendering the JSON body from a
+	// Notification. The json function quotes a value as JSON, e.g.
+	// {"text": {{json .Message}}}. If empty, the Notification itself is
+	// sent.
+	Template string `json:"template,omitempty"`
+
+	// Headers are added to every request, e.g. for authorization.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// LoadWebhookConfigs reads a JSON array of webhook configurations.
+func LoadWebhookConfigs(path string) ([]WebhookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook config: %w", err)
+	}
+
+	var configs []WebhookConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook config %s: %w",
+			path, err)
+	}
+
+	return configs, nil
+}
+
+// Notification is the payload describing a notified event.
+type Notification struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	ListID      string    `json:"listId,omitempty"`
+	ProjectName string    `json:"projectName,omitempty"`
+	TaskID      string    `json:"taskId,omitempty"`
+	TaskSubject string    `json:"taskSubject,omitempty"`
+	PID         int       `json:"pid,omitempty"`
+
+	// Message is a one-line human-readable summary, suitable for chat.
+	Message string `json:"message"`
+}
+
+// Delivery records an attempt to deliver a notification to a webhook.
+type Delivery struct {
+	ID    uint64    `json:"id"`
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+
+	// Webhook is the webhook's URL with its path and query redacted,
+	// since they often embed a secret.
+	Webhook string `json:"webhook"`
+
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Payload    string `json:"payload"`
+}
+
+// webhook is a configured webhook and its delivery queue.
+type webhook struct {
+	cfg   WebhookConfig
+	tmpl  *template.Template
+	queue chan Notification
+}
+
+// matches reports whether the webhook receives an event.
+func (wh *webhook) matches(event string) bool {
+	return event == NotifyTest || len(wh.cfg.Events) == 0 ||
+		slices.Contains(wh.cfg.Events, event)
+}
+
+// render returns the request body for a notification.
+func (wh *webhook) render(n Notification) ([]byte, error) {
+	if wh.tmpl == nil {
+		return json.Marshal(n)
+	}
+
+	var buf bytes.Buffer
+	if err := wh.tmpl.Execute(&buf, n); err != nil {
+		return nil, fmt.Errorf("failed to render payload: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("payload template produced invalid "+
+			"JSON: %s", buf.String())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// taskState is what the notifier remembers of a task between changes.
+type taskState struct {
+	status  claudeagent.TaskListStatus
+	blocked bool
+}
+
+// listState is what the notifier remembers of a list between changes.
+type listState struct {
+	tasks map[string]taskState
+	done  bool
+}
+
+// Notifier turns events on the event bus into webhook notifications.
+//
+// Task completions, unblocked tasks and completed lists are derived by
+// comparing each list with its state at the previous change. Every webhook
+// has its own queue, so a slow or failing endpoint does not hold up others,
+// and failed deliveries are retried with exponential backoff.
+type Notifier struct {
+	taskStore      claudeagent.TaskStore
+	projectIndexer *ProjectIndexer
+	eventBus       *EventBus
+	webhooks       []*webhook
+	client         *http.Client
+
+	// initialBackoff is the wait before the first retry of a delivery.
+	// It is a field so that tests can shorten it.
+	initialBackoff time.Duration
+
+	// lists is only touched by the event loop.
+	lists map[string]*listState
+
+	// mu guards deliveries and nextID.
+	mu         sync.Mutex
+	deliveries []*Delivery
+	nextID     uint64
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	log btclog.Logger
+}
+
+// NewNotifier creates a notifier delivering to the given webhooks.
+func NewNotifier(configs []WebhookConfig, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, eventBus *EventBus,
+	log btclog.Logger) (*Notifier, error) {
+
+	webhooks := make([]*webhook, 0, len(configs))
+	for _, cfg := range configs {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+
+			return nil, fmt.Errorf("invalid webhook URL %q",
+				cfg.URL)
+		}
+
+		for _, event := range cfg.Events {
+			if !slices.Contains(notifyEvents, event) {
+				return nil, fmt.Errorf("unknown webhook "+
+					"event %q, expected one of %s", event,
+					strings.Join(notifyEvents, ", "))
+			}
+		}
+
+		wh := &webhook{
+			cfg:   cfg,
+			queue: make(chan Notification, webhookQueueSize),
+		}
+		if cfg.Template != "" {
+			wh.tmpl, err = template.New("payload").Funcs(
+				template.FuncMap{"json": jsonQuote},
+			).Parse(cfg.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid payload "+
+					"template for %s: %w",
+					redactURL(cfg.URL), err)
+			}
+		}
+
+		webhooks = append(webhooks, wh)
+	}
+
+	return &Notifier{
+		taskStore:      taskStore,
+		projectIndexer: projectIndexer,
+		eventBus:       eventBus,
+		webhooks:       webhooks,
+		client:         &http.Client{Timeout: webhookTimeout},
+		initialBackoff: webhookInitialBackoff,
+		lists:          make(map[string]*listState),
+		quit:           make(chan struct{}),
+		log:            log,
+	}, nil
+}
+
+// jsonQuote returns v encoded as JSON, for use in payload templates.
+func jsonQuote(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// redactURL strips everything but the scheme and host from a URL.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+
+	redacted := u.Scheme + "://" + u.Host
+	if u.Path != "" && u.Path != "/" {
+		redacted += "/…"
+	}
+
+	return redacted
+}
+
+// Start records the current state of every active list and begins
+// delivering notifications. This method is idempotent.
+func (n *Notifier) Start() error {
+	if !atomic.CompareAndSwapUint32(&n.started, 0, 1) {
+		return nil
+	}
+
+	// Subscribe before taking the baseline, so that no change in between
+	// goes unnoticed.
+	sub, _, _ := n.eventBus.Subscribe("", 0)
+
+	activeLists, _ := n.projectIndexer.ListActiveTaskLists()
+	for _, al := range activeLists {
+		n.checkList(al.SessionID)
+	}
+
+	n.wg.Add(1 + len(n.webhooks))
+	go n.eventLoop(sub)
+	for _, wh := range n.webhooks {
+		go n.deliverLoop(wh)
+	}
+
+	return nil
+}
+
+// Stop abandons pending deliveries. This method is idempotent.
+func (n *Notifier) Stop() error {
+	if !atomic.CompareAndSwapUint32(&n.stopped, 0, 1) {
+		return nil
+	}
+
+	close(n.quit)
+	n.wg.Wait()
+
+	return nil
+}
+
+// eventLoop turns bus events into notifications.
+func (n *Notifier) eventLoop(sub *EventSubscription) {
+	defer n.wg.Done()
+	defer sub.Close()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			n.handleEvent(event)
+
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// handleEvent sends the notifications for a single bus event.
+func (n *Notifier) handleEvent(event Event) {
+	switch {
+	case strings.HasPrefix(event.Type, "task-"):
+		n.checkList(event.ListID)
+
+	case event.Type == EventSessionAppeared:
+		n.checkList(event.ListID)
+
+	case event.Type == EventSessionEnded:
+		delete(n.lists, event.ListID)
+
+		n.notify(Notification{
+			Event:       NotifySessionEnded,
+			ListID:      event.ListID,
+			ProjectName: n.projectName(event.ListID),
+			Message: fmt.Sprintf("Session %s ended",
+				n.describeList(event.ListID)),
+		})
+
+	case event.Type == EventInstanceExited && event.Instance != nil:
+		instance := event.Instance
+		msg := fmt.Sprintf("Claude process %d exited", instance.PID)
+		if instance.ProjectName != "" {
+			msg += " in " + instance.ProjectName
+		}
+
+		n.notify(Notification{
+			Event:       NotifyInstanceExited,
+			ListID:      instance.SessionID,
+			ProjectName: instance.ProjectName,
+			PID:         instance.PID,
+			Message:     msg,
+		})
+
+	case event.Type == EventSessionStalled && event.Stall != nil:
+		stall := event.Stall
+
+		n.notify(Notification{
+			Event:       NotifySessionStalled,
+			ListID:      stall.SessionID,
+			ProjectName: stall.ProjectName,
+			TaskID:      stall.TaskID,
+			TaskSubject: stall.TaskSubject,
+			PID:         stall.PID,
+			Message: fmt.Sprintf("Session %s appears stalled on "+
+				"task #%s %q, in progress since %s",
+				n.describeList(stall.SessionID), stall.TaskID,
+				stall.TaskSubject,
+				stall.InProgressSince.Format(time.Kitchen)),
+		})
+	}
+}
+
+// checkList compares a list with its state at the previous change and sends
+// notifications for what changed. The first look at a list only records
+// its state.
+func (n *Notifier) checkList(listID string) {
+	tasks, err := n.taskStore.List(context.Background(), listID)
+	if err != nil || len(tasks) == 0 {
+		return
+	}
+
+	cur := &listState{
+		tasks: make(map[string]taskState, len(tasks)),
+		done:  true,
+	}
+	graph := AnalyzeGraph(tasks)
+	for _, task := range tasks {
+		cur.tasks[task.ID] = taskState{
+			status:  task.Status,
+			blocked: graph.IsBlocked(task.ID),
+		}
+		if task.Status != claudeagent.TaskListStatusCompleted {
+			cur.done = false
+		}
+	}
+
+	prev, ok := n.lists[listID]
+	n.lists[listID] = cur
+	if !ok {
+		return
+	}
+
+	// Tasks that vanished are deleted rather than finished, as happens
+	// while Claude removes an ending session's task files one by one. A
+	// list that lost unfinished tasks is not reported as completed, and a
+	// task that is only unblocked because its blocker was deleted is not
+	// reported as unblocked.
+	lostUnfinished := false
+	deleted := make(map[string]bool)
+	for id, was := range prev.tasks {
+		if _, ok := cur.tasks[id]; ok {
+			continue
+		}
+
+		deleted[id] = true
+		if was.status != claudeagent.TaskListStatusCompleted {
+			lostUnfinished = true
+		}
+	}
+
+	isDeleted := func(id string) bool {
+		return deleted[id]
+	}
+
+	projectName := n.projectName(listID)
+	for _, task := range tasks {
+		was, existed := prev.tasks[task.ID]
+		now := cur.tasks[task.ID]
+		if !existed {
+			continue
+		}
+
+		switch {
+		case was.status != claudeagent.TaskListStatusCompleted &&
+			now.status == claudeagent.TaskListStatusCompleted:
+
+			n.notify(Notification{
+				Event:       NotifyTaskCompleted,
+				ListID:      listID,
+				ProjectName: projectName,
+				TaskID:      task.ID,
+				TaskSubject: task.Subject,
+				Message: fmt.Sprintf("Task #%s %q completed "+
+					"in %s", task.ID, task.Subject,
+					n.describeList(listID)),
+			})
+
+		case was.blocked && !now.blocked &&
+			now.status != claudeagent.TaskListStatusCompleted &&
+			!slices.ContainsFunc(task.BlockedBy, isDeleted):
+
+			n.notify(Notification{
+				Event:       NotifyTaskUnblocked,
+				ListID:      listID,
+				ProjectName: projectName,
+				TaskID:      task.ID,
+				TaskSubject: task.Subject,
+				Message: fmt.Sprintf("Task #%s %q is "+
+					"unblocked in %s", task.ID,
+					task.Subject, n.describeList(listID)),
+			})
+		}
+	}
+
+	if !prev.done && cur.done && !lostUnfinished {
+		n.notify(Notification{
+			Event:       NotifyListCompleted,
+			ListID:      listID,
+			ProjectName: projectName,
+			Message: fmt.Sprintf("All %d tasks completed in %s",
+				len(tasks), n.describeList(listID)),
+		})
+	}
+}
+
+// projectName returns the name of a session's project, if known.
+func (n *Notifier) projectName(sessionID string) string {
+	session, _, ok := n.projectIndexer.GetSession(sessionID)
+	if !ok || session.ProjectPath == "" {
+		return ""
+	}
+
+	return filepath.Base(session.ProjectPath)
+}
+
+// describeList names a list for a message, preferring its project.
+func (n *Notifier) describeList(listID string) string {
+	short := listID
+	if len(short) > 8 {
+		short = short[:8]
+	}
+
+	if name := n.projectName(listID); name != "" {
+		return name + " (" + short + ")"
+	}
+
+	return short
+}
+
+// notify queues a notification for every webhook that wants it.
+func (n *Notifier) notify(notification Notification) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+
+	for _, wh := range n.webhooks {
+		if !wh.matches(notification.Event) {
+			continue
+		}
+
+		select {
+		case wh.queue <- notification:
+		default:
+			d := n.record(wh, notification, "")
+			n.update(d, func(d *Delivery) {
+				d.Status = DeliveryFailed
+				d.Error = "delivery queue full"
+			})
+		}
+	}
+}
+
+// SendTest queues a test notification for every webhook. It is safe to call
+// on a nil notifier, which has no webhooks.
+func (n *Notifier) SendTest() int {
+	if n == nil {
+		return 0
+	}
+
+	n.notify(Notification{
+		Event:   NotifyTest,
+		Message: "Test notification from the Claude task viewer",
+	})
+
+	return len(n.webhooks)
+}
+
+// deliverLoop delivers a webhook's notifications in order.
+func (n *Notifier) deliverLoop(wh *webhook) {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case notification := <-wh.queue:
+			n.deliver(wh, notification)
+
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// deliver POSTs a notification to a webhook, retrying with backoff on
+// network errors, 429 and 5xx responses.
+func (n *Notifier) deliver(wh *webhook, notification Notification) {
+	payload, err := wh.render(notification)
+	d := n.record(wh, notification, string(payload))
+	if err != nil {
+		n.update(d, func(d *Delivery) {
+			d.Status = DeliveryFailed
+			d.Error = err.Error()
+		})
+		return
+	}
+
+	backoff := n.initialBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		code, err := n.post(wh, payload)
+
+		retry := err != nil || code == http.StatusTooManyRequests ||
+			code >= 500
+		n.update(d, func(d *Delivery) {
+			d.Attempts = attempt
+			d.StatusCode = code
+			d.Error = ""
+			switch {
+			case err != nil:
+				d.Error = err.Error()
+
+			case code < 200 || code > 299:
+				d.Error = http.StatusText(code)
+			}
+
+			switch {
+			case err == nil && code >= 200 && code <= 299:
+				d.Status = DeliveryDelivered
+
+			case !retry || attempt == webhookMaxAttempts:
+				d.Status = DeliveryFailed
+			}
+		})
+		if !retry {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			n.log.Warnf("Giving up on %s notification to %s after "+
+				"%d attempts", notification.Event,
+				redactURL(wh.cfg.URL), attempt)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+
+		case <-n.quit:
+			n.update(d, func(d *Delivery) {
+				d.Status = DeliveryFailed
+				d.Error = "shutting down"
+			})
+			return
+		}
+	}
+}
+
+// post makes a single delivery attempt, returning the response status.
+func (n *Notifier) post(wh *webhook, payload []byte) (int, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-n.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, wh.cfg.URL, bytes.NewReader(payload),
+	)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "taskviewerd")
+	for k, v := range wh.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	return resp.StatusCode, nil
+}
+
+// record adds a pending delivery to the delivery log.
+func (n *Notifier) record(wh *webhook, notification Notification,
+	payload string) *Delivery {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nextID++
+	d := &Delivery{
+		ID:      n.nextID,
+		Time:    time.Now(),
+		Event:   notification.Event,
+		Webhook: redactURL(wh.cfg.URL),
+		Status:  DeliveryPending,
+		Payload: payload,
+	}
+
+	if len(n.deliveries) == deliveryLogSize {
+		copy(n.deliveries, n.deliveries[1:])
+		n.deliveries = n.deliveries[:deliveryLogSize-1]
+	}
+	n.deliveries = append(n.deliveries, d)
+
+	return d
+}
+
+// update modifies a delivery under the log's lock.
+func (n *Notifier) update(d *Delivery, f func(d *Delivery)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f(d)
+}
+
+// Deliveries returns the delivery log, newest first. It is safe to call on a
+// nil notifier.
+func (n *Notifier) Deliveries() []Delivery {
+	if n == nil {
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	deliveries := make([]Delivery, 0, len(n.deliveries))
+	for i := len(n.deliveries) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *n.deliveries[i])
+	}
+
+	return deliveries
+}
+
+// WebhookSummary describes a configured webhook without its secrets.
+type WebhookSummary struct {
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+	Template bool     `json:"template"`
+}
+
+// Webhooks describes the configured webhooks. It is safe to call on a nil
+// notifier.
+func (n *Notifier) Webhooks() []WebhookSummary {
+	if n == nil {
+		return nil
+	}
+
+	summaries := make([]WebhookSummary, 0, len(n.webhooks))
+	for _, wh := range n.webhooks {
+		summaries = append(summaries, WebhookSummary{
+			URL:      redactURL(wh.cfg.URL),
+			Events:   wh.cfg.Events,
+			Template: wh.tmpl != nil,
+		})
+	}
+
+	return summaries
+}
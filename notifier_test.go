@@ -0,0 +1,539 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// webhookServer is a webhook endpoint answering with a scripted sequence of
+// status codes, then 200.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   []string
+}
+
+// newWebhookServer starts a webhook endpoint.
+func newWebhookServer(t *testing.T, codes ...int) *webhookServer {
+	t.Helper()
+
+	s := &webhookServer{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			s.mu.Lock()
+			s.requests = append(s.requests, r)
+			s.bodies = append(s.bodies, string(body))
+			code := http.StatusOK
+			if len(s.codes) > 0 {
+				code, s.codes = s.codes[0], s.codes[1:]
+			}
+			s.mu.Unlock()
+
+			w.WriteHeader(code)
+		},
+	))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// received returns the bodies POSTed so far.
+func (s *webhookServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.bodies...)
+}
+
+// newTestNotifier returns a notifier over a fake task store, with retries
+// shortened.
+func newTestNotifier(t *testing.T, store *fakeTaskStore,
+	configs ...WebhookConfig) *Notifier {
+
+	t.Helper()
+
+	root := t.TempDir()
+	projectIndexer := NewProjectIndexer(
+		filepath.Join(root, "projects"), filepath.Join(root, "tasks"),
+	)
+
+	n, err := NewNotifier(
+		configs, store, projectIndexer, nil, btclog.Disabled,
+	)
+	if err != nil {
+		t.Fatalf("unable to create notifier: %v", err)
+	}
+	n.initialBackoff = time.Millisecond
+	t.Cleanup(func() { _ = n.Stop() })
+
+	return n
+}
+
+// TestNotifierDelivery checks that a notification is POSTed as JSON with
+// the configured headers, and logged as delivered.
+func TestNotifierDelivery(t *testing.T) {
+	server := newWebhookServer(t)
+	n := newTestNotifier(t, newFakeTaskStore(), WebhookConfig{
+		URL:     server.URL + "/hooks/secret",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+
+	sent := Notification{
+		Event:   NotifyTaskCompleted,
+		Time:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		ListID:  "s1",
+		TaskID:  "1",
+		Message: "Task #1 completed",
+	}
+	n.deliver(n.webhooks[0], sent)
+
+	bodies := server.received()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(bodies))
+	}
+	var got Notification
+	if err := json.Unmarshal([]byte(bodies[0]), &got); err != nil {
+		t.Fatalf("unable to decode payload: %v", err)
+	}
+	if !reflect.DeepEqual(got, sent) {
+		t.Fatalf("got payload %+v, want %+v", got, sent)
+	}
+
+	req := server.requests[0]
+	if req.Header.Get("Authorization") != "Bearer token" ||
+		req.Header.Get("Content-Type") != "application/json" {
+
+		t.Fatalf("unexpected headers: %v", req.Header)
+	}
+
+	deliveries := n.Deliveries()
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	d := deliveries[0]
+	if d.Status != DeliveryDelivered || d.Attempts != 1 ||
+		d.StatusCode != http.StatusOK || d.Payload != bodies[0] {
+
+		t.Fatalf("unexpected delivery: %+v", d)
+	}
+	if strings.Contains(d.Webhook, "secret") {
+		t.Fatalf("webhook path not redacted: %s", d.Webhook)
+	}
+}
+
+// TestNotifierRetry checks that deliveries are retried on 429 and 5xx
+// responses only, and given up on after webhookMaxAttempts.
+func TestNotifierRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		codes        []int
+		wantStatus   string
+		wantAttempts int
+		wantCode     int
+	}{
+		{
+			name:         "recovers",
+			codes:        []int{503, 429, 500},
+			wantStatus:   DeliveryDelivered,
+			wantAttempts: 4,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "client error",
+			codes:        []int{400},
+			wantStatus:   DeliveryFailed,
+			wantAttempts: 1,
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name: "gives up",
+			codes: []int{
+				502, 502, 502, 502, 502, 502,
+			},
+			wantStatus:   DeliveryFailed,
+			wantAttempts: webhookMaxAttempts,
+			wantCode:     http.StatusBadGateway,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newWebhookServer(t, test.codes...)
+			n := newTestNotifier(t, newFakeTaskStore(),
+				WebhookConfig{URL: server.URL})
+
+			n.deliver(
+				n.webhooks[0], Notification{Event: NotifyTest},
+			)
+
+			d := n.Deliveries()[0]
+			if d.Status != test.wantStatus ||
+				d.Attempts != test.wantAttempts ||
+				d.StatusCode != test.wantCode {
+
+				t.Fatalf("unexpected delivery: %+v", d)
+			}
+			if len(server.received()) != test.wantAttempts {
+				t.Fatalf("got %d requests, want %d",
+					len(server.received()),
+					test.wantAttempts)
+			}
+		})
+	}
+}
+
+// TestWebhookMatches checks webhook event filters.
+func TestWebhookMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		event  string
+		want   bool
+	}{
+		{
+			name:  "no filter",
+			event: NotifySessionEnded,
+			want:  true,
+		},
+		{
+			name: "listed",
+			events: []string{
+				NotifyTaskCompleted, NotifySessionEnded,
+			},
+			event: NotifySessionEnded,
+			want:  true,
+		},
+		{
+			name:   "not listed",
+			events: []string{NotifyTaskCompleted},
+			event:  NotifySessionStalled,
+		},
+		{
+			name:   "test always sent",
+			events: []string{NotifyTaskCompleted},
+			event:  NotifyTest,
+			want:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wh := &webhook{cfg: WebhookConfig{Events: test.events}}
+			if got := wh.matches(test.event); got != test.want {
+				t.Fatalf("matches(%s) = %v, want %v",
+					test.event, got, test.want)
+			}
+		})
+	}
+}
+
+// TestNotifyFilters checks that notify only queues a notification for the
+// webhooks that want it.
+func TestNotifyFilters(t *testing.T) {
+	n := newTestNotifier(t, newFakeTaskStore(),
+		WebhookConfig{URL: "http://localhost/all"},
+		WebhookConfig{
+			URL:    "http://localhost/completed",
+			Events: []string{NotifyTaskCompleted},
+		},
+	)
+
+	n.notify(Notification{Event: NotifyTaskCompleted})
+	n.notify(Notification{Event: NotifySessionEnded})
+
+	for i, want := range []int{2, 1} {
+		if got := len(n.webhooks[i].queue); got != want {
+			t.Fatalf("webhook %d queued %d, want %d", i, got, want)
+		}
+	}
+}
+
+// TestWebhookRender checks payload template rendering.
+func TestWebhookRender(t *testing.T) {
+	notification := Notification{
+		Event:   NotifyTaskCompleted,
+		Message: `Task "quoted" completed`,
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "slack",
+			template: `{"text": {{json .Message}}}`,
+			want:     `{"text": "Task \"quoted\" completed"}`,
+		},
+		{
+			name: "fields",
+			template: `{"event": {{json .Event}}, ` +
+				`"pid": {{.PID}}}`,
+			want: `{"event": "task-completed", "pid": 0}`,
+		},
+		{
+			name:     "invalid JSON",
+			template: `{"text": {{.Message}}}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := newTestNotifier(t, newFakeTaskStore(),
+				WebhookConfig{
+					URL:      "http://localhost/hook",
+					Template: test.template,
+				})
+
+			got, err := n.webhooks[0].render(notification)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s",
+						got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to render: %v", err)
+			}
+			if string(got) != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// TestNewNotifierValidation checks that bad webhook configurations are
+// refused.
+func TestNewNotifierValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  WebhookConfig
+	}{
+		{
+			name: "relative URL",
+			cfg:  WebhookConfig{URL: "/hook"},
+		},
+		{
+			name: "bad scheme",
+			cfg:  WebhookConfig{URL: "ftp://example.com/hook"},
+		},
+		{
+			name: "unknown event",
+			cfg: WebhookConfig{
+				URL:    "https://example.com/hook",
+				Events: []string{"task-exploded"},
+			},
+		},
+		{
+			name: "bad template",
+			cfg: WebhookConfig{
+				URL:      "https://example.com/hook",
+				Template: "{{.Message",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewNotifier(
+				[]WebhookConfig{test.cfg}, nil, nil, nil,
+				btclog.Disabled,
+			)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
+
+// TestNotifierTaskEvents checks the notifications derived from successive
+// states of a list.
+func TestNotifierTaskEvents(t *testing.T) {
+	store := newFakeTaskStore()
+	n := newTestNotifier(t, store,
+		WebhookConfig{URL: "http://localhost/hook"})
+
+	blocked := testTask("2")
+	blocked.BlockedBy = []string{"1"}
+	waiting := testTask("4")
+	waiting.BlockedBy = []string{"3"}
+
+	done := func(task claudeagent.TaskListItem) claudeagent.TaskListItem {
+		task.Status = claudeagent.TaskListStatusCompleted
+		return task
+	}
+
+	steps := []struct {
+		tasks []claudeagent.TaskListItem
+		want  []string
+	}{
+		{
+			// The first look at a list only records its state.
+			tasks: []claudeagent.TaskListItem{
+				testTask("1"), blocked,
+			},
+		},
+		{
+			tasks: []claudeagent.TaskListItem{
+				done(testTask("1")), blocked,
+			},
+			want: []string{
+				NotifyTaskCompleted + " 1",
+				NotifyTaskUnblocked + " 2",
+			},
+		},
+		{
+			tasks: []claudeagent.TaskListItem{
+				done(testTask("1")), done(blocked),
+			},
+			want: []string{
+				NotifyTaskCompleted + " 2",
+				NotifyListCompleted + " ",
+			},
+		},
+		{
+			// New work reopens the list.
+			tasks: []claudeagent.TaskListItem{
+				done(testTask("1")), done(blocked),
+				testTask("3"), waiting, testTask("5"),
+			},
+		},
+		{
+			// Deleting the blocker unblocks 4 without it being
+			// reported.
+			tasks: []claudeagent.TaskListItem{
+				done(testTask("1")), done(blocked), waiting,
+				testTask("5"),
+			},
+		},
+		{
+			// Deleting the unfinished 5 leaves the list done
+			// without it being completed.
+			tasks: []claudeagent.TaskListItem{
+				done(testTask("1")), done(blocked),
+				done(waiting),
+			},
+			want: []string{
+				NotifyTaskCompleted + " 4",
+			},
+		},
+		{
+			// Deleting finished tasks reports nothing either.
+			tasks: []claudeagent.TaskListItem{
+				done(testTask("1")),
+			},
+		},
+	}
+
+	for i, step := range steps {
+		store.set("s1", step.tasks...)
+		n.checkList("s1")
+
+		var got []string
+		for len(n.webhooks[0].queue) > 0 {
+			notification := <-n.webhooks[0].queue
+			got = append(got,
+				notification.Event+" "+notification.TaskID)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("step %d: got %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+// TestNotifierDeliveryLog checks that the delivery log is returned newest
+// first and capped at deliveryLogSize.
+func TestNotifierDeliveryLog(t *testing.T) {
+	n := newTestNotifier(t, newFakeTaskStore(),
+		WebhookConfig{URL: "http://localhost/hook"})
+
+	for i := 0; i < deliveryLogSize+5; i++ {
+		n.record(n.webhooks[0], Notification{Event: NotifyTest}, "{}")
+	}
+
+	deliveries := n.Deliveries()
+	if len(deliveries) != deliveryLogSize {
+		t.Fatalf("got %d deliveries, want %d", len(deliveries),
+			deliveryLogSize)
+	}
+	first, last := deliveries[0], deliveries[len(deliveries)-1]
+	if first.ID != deliveryLogSize+5 || last.ID != 6 {
+		t.Fatalf("unexpected order: first %d, last %d", first.ID,
+			last.ID)
+	}
+
+	var nilNotifier *Notifier
+	if nilNotifier.Deliveries() != nil {
+		t.Fatalf("expected no deliveries from a nil notifier")
+	}
+}
+
+// TestHandleNotificationTest checks the test endpoint's error responses.
+func TestHandleNotificationTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		origin   string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "no webhooks",
+			wantCode: http.StatusConflict,
+			wantErr:  ErrCodeConflict,
+		},
+		{
+			name:     "cross origin",
+			origin:   "https://evil.example",
+			wantCode: http.StatusForbidden,
+			wantErr:  ErrCodeForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, mux := newTestHTTPServer(t, newFakeTaskStore())
+
+			req := httptest.NewRequest(
+				http.MethodPost, "/api/notifications/test", nil,
+			)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != test.wantCode {
+				t.Fatalf("got status %d, want %d", rec.Code,
+					test.wantCode)
+			}
+			var apiErr APIError
+			err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			if err != nil {
+				t.Fatalf("unable to decode error: %v", err)
+			}
+			if apiErr.Error.Code != test.wantErr {
+				t.Fatalf("got code %q, want %q",
+					apiErr.Error.Code, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package taskviewer
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ProcessInfo describes a running process as seen by a ProcessSource.
+type ProcessInfo struct {
+	// PID is the process ID.
+	PID int
+
+	// Cmdline is the process's argument vector.
+	Cmdline []string
+
+	// WorkingDir is the process's current working directory.
+	WorkingDir string
+
+	// StartTime is when the process started.
+	StartTime time.Time
+
+	// Env holds the process's initial environment. Sources that cannot
+	// read the environment leave it nil.
+	Env map[string]string
+}
+
+// ProcessSource enumerates running Claude Code processes and inspects them.
+// The default source reads /proc on Linux and shells out to pgrep, ps and
+// lsof elsewhere; tests can supply a fake process table.
+type ProcessSource interface {
+	// ClaudePIDs returns the PIDs of all running Claude Code processes.
+	ClaudePIDs() ([]int, error)
+
+	// Process returns details about a single process.
+	Process(pid int) (*ProcessInfo, error)
+
+	// OpenFiles returns the paths of the regular files a process has
+	// open.
+	OpenFiles(pid int) ([]string, error)
+}
+
+// isClaudeCmdline reports whether an argument vector belongs to Claude Code:
+// either the native "claude" binary (possibly version-managed under
+// ~/.local/share/claude), or node running the npm package's cli.js.
+func isClaudeCmdline(argv []string) bool {
+	if len(argv) == 0 {
+		return false
+	}
+
+	if isClaudeExecutable(argv[0]) {
+		return true
+	}
+
+	// npm installs run as "node .../@anthropic-ai/claude-code/cli.js".
+	if filepath.Base(argv[0]) == "node" && len(argv) > 1 {
+		return strings.Contains(argv[1], "@anthropic-ai/claude-code")
+	}
+
+	return false
+}
+
+// isClaudeExecutable reports whether a path names the Claude Code binary.
+func isClaudeExecutable(path string) bool {
+	return filepath.Base(path) == "claude" ||
+		strings.Contains(path, ".local/share/claude") ||
+		strings.Contains(path, "claude/versions")
+}
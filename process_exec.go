@@ -0,0 +1,200 @@
+package taskviewer
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execProcessSource is a ProcessSource that shells out to pgrep, ps and lsof.
+// It is the default on macOS, where there is no /proc.
+type execProcessSource struct{}
+
+// newExecProcessSource creates a ProcessSource backed by external commands.
+func newExecProcessSource() *execProcessSource {
+	return &execProcessSource{}
+}
+
+// ClaudePIDs uses pgrep to find Claude Code process IDs.
+func (e *execProcessSource) ClaudePIDs() ([]int, error) {
+	// Look for processes named exactly "claude" (the CLI binary).
+	cmd := exec.Command("pgrep", "-x", "claude")
+	output, err := cmd.Output()
+	if err != nil {
+		// pgrep returns exit code 1 if no processes found.
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if exitErr.ExitCode() == 1 {
+				return nil, nil
+			}
+		}
+		// Fall back to searching for "claude" in process list.
+		return e.claudePIDsFallback()
+	}
+
+	return parsePIDs(output), nil
+}
+
+// claudePIDsFallback uses ps to find Claude processes.
+func (e *execProcessSource) claudePIDsFallback() ([]int, error) {
+	// Ask for just the PID and full command so we don't depend on the
+	// column layout of "ps aux".
+	cmd := exec.Command("ps", "-axo", "pid=,command=")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		if isClaudeCmdline(fields[1:]) {
+			if pid, err := strconv.Atoi(fields[0]); err == nil {
+				pids = append(pids, pid)
+			}
+		}
+	}
+
+	return pids, nil
+}
+
+// parsePIDs parses newline-separated PIDs from command output.
+func parsePIDs(output []byte) []int {
+	var pids []int
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if pid, err := strconv.Atoi(line); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// Process retrieves the working directory and start time of a process.
+func (e *execProcessSource) Process(pid int) (*ProcessInfo, error) {
+	info := &ProcessInfo{
+		PID: pid,
+	}
+
+	// Get working directory using lsof.
+	cwd, err := e.processCWD(pid)
+	if err == nil && cwd != "" {
+		info.WorkingDir = cwd
+	}
+
+	// Get process start time using ps.
+	if startTime, err := e.processStartTime(pid); err == nil {
+		info.StartTime = startTime
+	}
+
+	// Get the full command line using ps.
+	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "command=")
+	if output, err := cmd.Output(); err == nil {
+		info.Cmdline = strings.Fields(string(output))
+	}
+
+	return info, nil
+}
+
+// OpenFiles lists the files a process has open using lsof.
+func (e *execProcessSource) OpenFiles(pid int) ([]string, error) {
+	cmd := exec.Command("lsof", "-p", strconv.Itoa(pid), "-Fn")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each name field is a line prefixed with 'n'.
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name, ok := strings.CutPrefix(scanner.Text(), "n")
+		if ok && strings.HasPrefix(name, "/") {
+			files = append(files, name)
+		}
+	}
+
+	return files, nil
+}
+
+// processCWD gets the current working directory of a process.
+func (e *execProcessSource) processCWD(pid int) (string, error) {
+	// Use lsof to find the cwd.
+	cmd := exec.Command("lsof", "-a", "-d", "cwd", "-p", strconv.Itoa(pid),
+		"-Fn")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	// Parse lsof output for cwd (field type 'cwd').
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	foundCwd := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "fcwd" {
+			foundCwd = true
+			continue
+		}
+		if foundCwd && strings.HasPrefix(line, "n") {
+			return strings.TrimPrefix(line, "n"), nil
+		}
+	}
+
+	return "", nil
+}
+
+// processStartTime gets the start time of a process from its elapsed time.
+func (e *execProcessSource) processStartTime(pid int) (time.Time, error) {
+	// Use ps to get elapsed time.
+	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "etime=")
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Calculate start time from elapsed time.
+	duration := parseEtime(string(output))
+
+	return time.Now().Add(-duration), nil
+}
+
+// parseEtime parses ps etime format: [[DD-]HH:]MM:SS
+func parseEtime(etime string) time.Duration {
+	etime = strings.TrimSpace(etime)
+
+	var days, hours, minutes, seconds int
+
+	// Check for days component.
+	if strings.Contains(etime, "-") {
+		parts := strings.SplitN(etime, "-", 2)
+		days, _ = strconv.Atoi(parts[0])
+		etime = parts[1]
+	}
+
+	parts := strings.Split(etime, ":")
+	switch len(parts) {
+	case 3: // HH:MM:SS
+		hours, _ = strconv.Atoi(parts[0])
+		minutes, _ = strconv.Atoi(parts[1])
+		seconds, _ = strconv.Atoi(parts[2])
+	case 2: // MM:SS
+		minutes, _ = strconv.Atoi(parts[0])
+		seconds, _ = strconv.Atoi(parts[1])
+	case 1: // SS
+		seconds, _ = strconv.Atoi(parts[0])
+	}
+
+	return time.Duration(days)*24*time.Hour +
+		time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second
+}
@@ -0,0 +1,218 @@
+//go:build linux
+
+package taskviewer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of the start time field in
+// /proc/<pid>/stat. It is 100 on every mainstream Linux architecture.
+const clockTicks = 100
+
+// procProcessSource is a ProcessSource that reads /proc directly, without
+// spawning any processes.
+type procProcessSource struct {
+	// root is the procfs mount point, normally /proc. It is a field so
+	// that a fake process table can be used in its place.
+	root string
+}
+
+// newProcProcessSource creates a ProcessSource reading the procfs at root.
+// An empty root means /proc.
+func newProcProcessSource(root string) *procProcessSource {
+	if root == "" {
+		root = "/proc"
+	}
+
+	return &procProcessSource{root: root}
+}
+
+// defaultProcessSource returns the ProcessSource for this platform.
+func defaultProcessSource() ProcessSource {
+	return newProcProcessSource("")
+}
+
+// ClaudePIDs scans /proc for Claude Code processes.
+func (p *procProcessSource) ClaudePIDs() ([]int, error) {
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.root, err)
+	}
+
+	self := os.Getpid()
+
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		// The process may exit between listing and reading, in which
+		// case it is simply skipped.
+		comm, _ := os.ReadFile(p.path(pid, "comm"))
+		if strings.TrimSpace(string(comm)) == "claude" {
+			pids = append(pids, pid)
+			continue
+		}
+
+		if isClaudeCmdline(p.cmdline(pid)) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// Process reads the command line, working directory, start time and
+// environment of a process.
+func (p *procProcessSource) Process(pid int) (*ProcessInfo, error) {
+	if _, err := os.Stat(p.path(pid)); err != nil {
+		return nil, fmt.Errorf("process %d: %w", pid, err)
+	}
+
+	info := &ProcessInfo{
+		PID:     pid,
+		Cmdline: p.cmdline(pid),
+	}
+
+	// Reading another user's cwd or environ fails with EACCES, which
+	// just leaves those fields empty.
+	if cwd, err := os.Readlink(p.path(pid, "cwd")); err == nil {
+		info.WorkingDir = cwd
+	}
+
+	if startTime, err := p.startTime(pid); err == nil {
+		info.StartTime = startTime
+	}
+
+	if environ, err := os.ReadFile(p.path(pid, "environ")); err == nil {
+		info.Env = parseEnviron(environ)
+	}
+
+	return info, nil
+}
+
+// OpenFiles resolves the file descriptor links in /proc/<pid>/fd.
+func (p *procProcessSource) OpenFiles(pid int) ([]string, error) {
+	fdDir := p.path(pid, "fd")
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fdDir, err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		target, err := os.Readlink(filepath.Join(fdDir, e.Name()))
+		if err != nil || !filepath.IsAbs(target) {
+			// Sockets, pipes and anon inodes look like
+			// "socket:[1234]" and are skipped.
+			continue
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// path joins elements onto the /proc/<pid> directory.
+func (p *procProcessSource) path(pid int, elem ...string) string {
+	return filepath.Join(
+		append([]string{p.root, strconv.Itoa(pid)}, elem...)...,
+	)
+}
+
+// cmdline returns the NUL-separated argument vector of a process.
+func (p *procProcessSource) cmdline(pid int) []string {
+	data, err := os.ReadFile(p.path(pid, "cmdline"))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	data = bytes.TrimRight(data, "\x00")
+
+	return strings.Split(string(data), "\x00")
+}
+
+// startTime computes when a process started from the starttime field of
+// /proc/<pid>/stat, which counts clock ticks since boot.
+func (p *procProcessSource) startTime(pid int) (time.Time, error) {
+	data, err := os.ReadFile(p.path(pid, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The comm field is parenthesized and may itself contain spaces or
+	// parentheses, so fields are counted from the last ')'.
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return time.Time{}, errors.New("malformed stat")
+	}
+
+	// After the comm come state (field 3) onwards; starttime is field
+	// 22, so it is at index 19 of the remainder.
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, errors.New("short stat")
+	}
+
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	bootTime, err := p.bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	since := time.Duration(ticks) * time.Second / clockTicks
+
+	return bootTime.Add(since), nil
+}
+
+// bootTime reads the system boot time from the btime line of /proc/stat.
+func (p *procProcessSource) bootTime() (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(p.root, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(line, "btime ")
+		if !ok {
+			continue
+		}
+
+		secs, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return time.Unix(secs, 0), nil
+	}
+
+	return time.Time{}, errors.New("btime not found")
+}
+
+// parseEnviron parses a NUL-separated KEY=VALUE environment block.
+func parseEnviron(data []byte) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range strings.Split(string(data), "\x00") {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			continue
+		}
+		env[key] = value
+	}
+
+	return env
+}
@@ -0,0 +1,251 @@
+//go:build linux
+
+package taskviewer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBootTime is the btime of the fake process tables.
+const testBootTime = 1700000000
+
+// writeProcFile writes a file into a fake process table, creating its
+// directory.
+func writeProcFile(t *testing.T, root, name, data string) {
+	t.Helper()
+
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("unable to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("unable to write %s: %v", path, err)
+	}
+}
+
+// statLine builds a /proc/<pid>/stat line with the given comm and starttime.
+func statLine(pid int, comm string, startTicks int64) string {
+	fields := []string{strconv.Itoa(pid), "(" + comm + ")", "S"}
+
+	// Fields 4 to 21 are filler up to the starttime in field 22.
+	for i := 4; i <= 21; i++ {
+		fields = append(fields, strconv.Itoa(i))
+	}
+	fields = append(fields, strconv.FormatInt(startTicks, 10), "0", "0")
+
+	return strings.Join(fields, " ") + "\n"
+}
+
+// newTestProcRoot returns a fake process table holding the native binary
+// (100), an npm install (101), an unrelated shell (102), a process that has
+// already exited (103) and this test process.
+func newTestProcRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	writeProcFile(t, root, "stat",
+		"cpu 1 2 3\nbtime "+strconv.Itoa(testBootTime)+"\n")
+
+	writeProcFile(t, root, "100/comm", "claude\n")
+	writeProcFile(t, root, "100/cmdline", "claude\x00--resume\x00")
+
+	writeProcFile(t, root, "101/comm", "node\n")
+	writeProcFile(t, root, "101/cmdline",
+		"node\x00/usr/lib/node_modules/@anthropic-ai/claude-code/"+
+			"cli.js\x00")
+
+	writeProcFile(t, root, "102/comm", "bash\n")
+	writeProcFile(t, root, "102/cmdline", "bash\x00-l\x00")
+
+	if err := os.MkdirAll(filepath.Join(root, "103"), 0o700); err != nil {
+		t.Fatalf("unable to create process dir: %v", err)
+	}
+
+	self := strconv.Itoa(os.Getpid())
+	writeProcFile(t, root, self+"/comm", "claude\n")
+
+	writeProcFile(t, root, "self/comm", "claude\n")
+
+	return root
+}
+
+// TestProcClaudePIDs checks that both install flavours are found, and that
+// other processes, exited processes and the daemon itself are skipped.
+func TestProcClaudePIDs(t *testing.T) {
+	source := newProcProcessSource(newTestProcRoot(t))
+
+	pids, err := source.ClaudePIDs()
+	if err != nil {
+		t.Fatalf("unable to list PIDs: %v", err)
+	}
+	slices.Sort(pids)
+
+	if want := []int{100, 101}; !reflect.DeepEqual(pids, want) {
+		t.Fatalf("got PIDs %v, want %v", pids, want)
+	}
+
+	if _, err := newProcProcessSource(
+		filepath.Join(t.TempDir(), "missing"),
+	).ClaudePIDs(); err == nil {
+		t.Fatalf("expected an error for a missing procfs")
+	}
+}
+
+// TestProcStartTime checks start time parsing from stat, including comm
+// fields that contain spaces and parentheses.
+func TestProcStartTime(t *testing.T) {
+	boot := time.Unix(testBootTime, 0)
+
+	tests := []struct {
+		name    string
+		stat    string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			stat: statLine(200, "claude", 12345),
+			want: boot.Add(123450 * time.Millisecond),
+		},
+		{
+			name: "spaces",
+			stat: statLine(200, "tmux: server", 100),
+			want: boot.Add(time.Second),
+		},
+		{
+			name: "parentheses",
+			stat: statLine(200, "a) S 1 (b", 250),
+			want: boot.Add(2500 * time.Millisecond),
+		},
+		{
+			name:    "no comm",
+			stat:    "200 claude S 1 2 3\n",
+			wantErr: true,
+		},
+		{
+			name:    "short",
+			stat:    "200 (claude) S 1 2 3\n",
+			wantErr: true,
+		},
+		{
+			name: "bad starttime",
+			stat: "200 (c) S " + strings.Repeat("0 ", 18) +
+				"x 0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := newTestProcRoot(t)
+			writeProcFile(t, root, "200/stat", test.stat)
+
+			got, err := newProcProcessSource(root).startTime(200)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v",
+						got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to parse start time: %v", err)
+			}
+			if !got.Equal(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestProcProcess checks that Process assembles a process's details, and
+// that unreadable fields are left empty.
+func TestProcProcess(t *testing.T) {
+	root := newTestProcRoot(t)
+	writeProcFile(t, root, "100/stat", statLine(100, "claude", 100))
+	writeProcFile(t, root, "100/environ",
+		"HOME=/home/me\x00CLAUDE_CONFIG_DIR=/tmp/c\x00")
+	err := os.Symlink("/work/repo", filepath.Join(root, "100", "cwd"))
+	if err != nil {
+		t.Fatalf("unable to link cwd: %v", err)
+	}
+
+	info, err := newProcProcessSource(root).Process(100)
+	if err != nil {
+		t.Fatalf("unable to read process: %v", err)
+	}
+
+	want := &ProcessInfo{
+		PID:        100,
+		Cmdline:    []string{"claude", "--resume"},
+		WorkingDir: "/work/repo",
+		StartTime:  time.Unix(testBootTime+1, 0),
+		Env: map[string]string{
+			"HOME":              "/home/me",
+			"CLAUDE_CONFIG_DIR": "/tmp/c",
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("got %+v, want %+v", info, want)
+	}
+
+	info, err = newProcProcessSource(root).Process(102)
+	if err != nil {
+		t.Fatalf("unable to read process: %v", err)
+	}
+	if info.WorkingDir != "" || !info.StartTime.IsZero() ||
+		info.Env != nil {
+
+		t.Fatalf("unexpected details for bare process: %+v", info)
+	}
+
+	if _, err := newProcProcessSource(root).Process(999); err == nil {
+		t.Fatalf("expected an error for a missing process")
+	}
+}
+
+// TestParseEnviron checks parsing of NUL-separated environment blocks.
+func TestParseEnviron(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			name: "empty",
+			data: "",
+			want: map[string]string{},
+		},
+		{
+			name: "trailing NUL",
+			data: "A=1\x00B=2\x00",
+			want: map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name: "value with equals",
+			data: "OPTS=a=b=c\x00EMPTY=",
+			want: map[string]string{"OPTS": "a=b=c", "EMPTY": ""},
+		},
+		{
+			name: "malformed entries",
+			data: "NOEQUALS\x00=nokey\x00OK=yes",
+			want: map[string]string{"OK": "yes"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseEnviron([]byte(test.data))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package taskviewer
+
+// defaultProcessSource returns the ProcessSource for this platform. Without
+// /proc, process details come from pgrep, ps and lsof.
+func defaultProcessSource() ProcessSource {
+	return newExecProcessSource()
+}
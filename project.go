@@ -2,10 +2,13 @@ package taskviewer
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -49,6 +52,11 @@ type SessionEntry struct {
 	GitBranch    string    `json:"gitBranch"`
 	ProjectPath  string    `json:"projectPath"`
 	IsSidechain  bool      `json:"isSidechain"`
+
+	// ParentSessionID is the session that started this sidechain, as
+	// reconstructed by the indexer. It is empty for main sessions and
+	// for sidechains whose parent could not be found.
+	ParentSessionID string `json:"parentSessionId,omitempty"`
 }
 
 // sessionsIndex is the structure of sessions-index.json.
@@ -58,32 +66,292 @@ type sessionsIndex struct {
 }
 
 // ProjectIndexer scans ~/.claude/projects/ and builds project metadata.
+//
+// The index is built once and then kept current by watching the projects and
+// tasks directories, so the handlers that poll it every few seconds are
+// served from memory instead of re-parsing every sessions-index.json.
 type ProjectIndexer struct {
 	projectsDir string
 	tasksDir    string
+
+	// mu guards the cached index below.
+	mu sync.RWMutex
+
+	// loaded is true once the first full scan has completed.
+	loaded bool
+
+	// projects maps a sanitized project directory name to its project.
+	projects    map[string]Project
+	projectsErr error
+
+	// sessionToProject maps session IDs from sessions-index.json files to
+	// their project.
+	sessionToProject map[string]projectInfo
+
+	// jsonlProjects caches sessions located via findProjectByJSONL that
+	// are not yet in any sessions-index.json.
+	jsonlProjects map[string]projectInfo
+
+	// taskCounts maps session IDs to the number of task files on disk.
+	// Sessions without any task files are omitted.
+	taskCounts map[string]int
+	tasksErr   error
+
+	// sidechainParents caches the parent of each sidechain session found
+	// in its transcript, since a sidechain never changes parent.
+	sidechainMu      sync.Mutex
+	sidechainParents map[string]string
+
+	// scanDuration is how long the last full scan took, and scans is the
+	// number of full scans run.
+	scanDuration time.Duration
+	scans        uint64
+
+	watcher fsWatcher
+
+	// changeSubs receives an IndexChange after each update to the index.
+	changeSubsMu sync.Mutex
+	changeSubs   map[chan IndexChange]struct{}
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
 }
 
-// NewProjectIndexer creates a new project indexer.
-func NewProjectIndexer(claudeDir string) *ProjectIndexer {
-	if claudeDir == "" {
+const (
+	// indexDebounce is how long the indexer waits for a burst of file
+	// events to settle before re-reading the affected directories.
+	indexDebounce = 250 * time.Millisecond
+
+	// indexRescanInterval is how often a full rescan runs as a safety net
+	// for missed watch events and roots that did not exist at startup.
+	indexRescanInterval = time.Minute
+)
+
+// NewProjectIndexer creates a new project indexer over the given projects and
+// tasks directories. Empty arguments default to their locations under
+// ~/.claude.
+func NewProjectIndexer(projectsDir, tasksDir string) *ProjectIndexer {
+	if projectsDir == "" || tasksDir == "" {
 		home, _ := os.UserHomeDir()
-		claudeDir = filepath.Join(home, ".claude")
+		claudeDir := filepath.Join(home, ".claude")
+
+		if projectsDir == "" {
+			projectsDir = filepath.Join(claudeDir, "projects")
+		}
+		if tasksDir == "" {
+			tasksDir = filepath.Join(claudeDir, "tasks")
+		}
 	}
 
 	return &ProjectIndexer{
-		projectsDir: filepath.Join(claudeDir, "projects"),
-		tasksDir:    filepath.Join(claudeDir, "tasks"),
+		projectsDir:      projectsDir,
+		tasksDir:         tasksDir,
+		projects:         make(map[string]Project),
+		sessionToProject: make(map[string]projectInfo),
+		jsonlProjects:    make(map[string]projectInfo),
+		taskCounts:       make(map[string]int),
+		sidechainParents: make(map[string]string),
+		changeSubs:       make(map[chan IndexChange]struct{}),
+		quit:             make(chan struct{}),
 	}
 }
 
-// ListProjects returns all projects with their session metadata.
-func (pi *ProjectIndexer) ListProjects() ([]Project, error) {
+// IndexChange describes an update applied to the cached index.
+type IndexChange struct {
+	// Projects holds the directory names of projects that were added,
+	// modified or removed.
+	Projects []string
+
+	// ListsAppeared holds the sessions whose task lists appeared on
+	// disk.
+	ListsAppeared []string
+
+	// ListsEnded holds the sessions whose task lists were removed.
+	ListsEnded []string
+}
+
+// empty reports whether the change carries no updates.
+func (c *IndexChange) empty() bool {
+	return len(c.Projects) == 0 && len(c.ListsAppeared) == 0 &&
+		len(c.ListsEnded) == 0
+}
+
+// SubscribeChanges returns a channel that receives a notification after each
+// update to the index, and a function that cancels the subscription. Changes
+// are dropped for subscribers that fall behind.
+func (pi *ProjectIndexer) SubscribeChanges() (<-chan IndexChange, func()) {
+	ch := make(chan IndexChange, 64)
+
+	pi.changeSubsMu.Lock()
+	pi.changeSubs[ch] = struct{}{}
+	pi.changeSubsMu.Unlock()
+
+	cancel := func() {
+		pi.changeSubsMu.Lock()
+		delete(pi.changeSubs, ch)
+		pi.changeSubsMu.Unlock()
+	}
+
+	return ch, cancel
+}
+
+// notifyChange delivers a change to every subscriber.
+func (pi *ProjectIndexer) notifyChange(change IndexChange) {
+	if change.empty() {
+		return
+	}
+
+	sort.Strings(change.Projects)
+	sort.Strings(change.ListsAppeared)
+	sort.Strings(change.ListsEnded)
+
+	pi.changeSubsMu.Lock()
+	defer pi.changeSubsMu.Unlock()
+
+	for ch := range pi.changeSubs {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+// projectChanged reports whether two versions of a project differ in a way
+// that is visible in the index.
+func projectChanged(a, b Project) bool {
+	return a.Path != b.Path || a.SessionCount != b.SessionCount ||
+		!a.LastModified.Equal(b.LastModified)
+}
+
+// Start builds the initial index and begins watching the projects and tasks
+// directories for changes. This method is idempotent.
+func (pi *ProjectIndexer) Start() error {
+	if !atomic.CompareAndSwapUint32(&pi.started, 0, 1) {
+		return nil
+	}
+
+	// Start watching before the initial scan so that no change made
+	// during the scan is missed.
+	watcher, err := newFSWatcher(pi.projectsDir, pi.tasksDir)
+	if err != nil {
+		return fmt.Errorf("failed to watch claude dir: %w", err)
+	}
+	pi.watcher = watcher
+
+	pi.Rescan()
+
+	pi.wg.Add(1)
+	go pi.watchLoop()
+
+	return nil
+}
+
+// Stop halts the directory watchers. This method is idempotent.
+func (pi *ProjectIndexer) Stop() error {
+	if !atomic.CompareAndSwapUint32(&pi.stopped, 0, 1) {
+		return nil
+	}
+
+	close(pi.quit)
+
+	var err error
+	if pi.watcher != nil {
+		err = pi.watcher.Close()
+	}
+
+	pi.wg.Wait()
+
+	return err
+}
+
+// Rescan discards the cached index and rebuilds it from disk. It returns the
+// error encountered reading the projects directory, if any.
+func (pi *ProjectIndexer) Rescan() error {
+	start := time.Now()
+	projects, projectsErr := pi.scanProjects()
+	taskCounts, tasksErr := pi.scanTaskCounts()
+
+	pi.mu.Lock()
+
+	// The initial load is not a change anyone can have missed.
+	var change IndexChange
+	if pi.loaded {
+		for dirName, p := range projects {
+			old, ok := pi.projects[dirName]
+			if !ok || projectChanged(old, p) {
+				change.Projects = append(change.Projects, dirName)
+			}
+		}
+		for dirName := range pi.projects {
+			if _, ok := projects[dirName]; !ok {
+				change.Projects = append(change.Projects, dirName)
+			}
+		}
+
+		for sessionID := range taskCounts {
+			if _, ok := pi.taskCounts[sessionID]; !ok {
+				change.ListsAppeared = append(
+					change.ListsAppeared, sessionID,
+				)
+			}
+		}
+		for sessionID := range pi.taskCounts {
+			if _, ok := taskCounts[sessionID]; !ok {
+				change.ListsEnded = append(
+					change.ListsEnded, sessionID,
+				)
+			}
+		}
+	}
+
+	pi.projects = projects
+	pi.projectsErr = projectsErr
+	pi.taskCounts = taskCounts
+	pi.tasksErr = tasksErr
+	pi.rebuildSessionMapLocked()
+	pi.loaded = true
+	pi.scanDuration = time.Since(start)
+	pi.scans++
+
+	pi.mu.Unlock()
+
+	pi.notifyChange(change)
+
+	return projectsErr
+}
+
+// ScanStats returns how long the last full scan took and how many full scans
+// have run.
+func (pi *ProjectIndexer) ScanStats() (time.Duration, uint64) {
+	pi.mu.RLock()
+	defer pi.mu.RUnlock()
+
+	return pi.scanDuration, pi.scans
+}
+
+// ensureLoaded performs the initial scan for indexers that were never
+// started, so that short-lived users get a correct (if unwatched) index.
+func (pi *ProjectIndexer) ensureLoaded() {
+	pi.mu.RLock()
+	loaded := pi.loaded
+	pi.mu.RUnlock()
+
+	if !loaded {
+		pi.Rescan()
+	}
+}
+
+// scanProjects reads every project directory from disk.
+func (pi *ProjectIndexer) scanProjects() (map[string]Project, error) {
+	projects := make(map[string]Project)
+
 	entries, err := os.ReadDir(pi.projectsDir)
 	if err != nil {
-		return nil, err
+		return projects, err
 	}
 
-	var projects []Project
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -95,9 +363,256 @@ func (pi *ProjectIndexer) ListProjects() ([]Project, error) {
 			continue
 		}
 
-		projects = append(projects, project)
+		projects[entry.Name()] = project
+	}
+
+	return projects, nil
+}
+
+// scanTaskCounts counts the task files of every session in the tasks
+// directory.
+func (pi *ProjectIndexer) scanTaskCounts() (map[string]int, error) {
+	counts := make(map[string]int)
+
+	entries, err := os.ReadDir(pi.tasksDir)
+	if err != nil {
+		return counts, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if n := pi.countTaskFiles(entry.Name()); n > 0 {
+			counts[entry.Name()] = n
+		}
+	}
+
+	return counts, nil
+}
+
+// countTaskFiles returns the number of task files on disk for a session.
+func (pi *ProjectIndexer) countTaskFiles(sessionID string) int {
+	taskDir := filepath.Join(pi.tasksDir, sessionID)
+	entries, err := os.ReadDir(taskDir)
+	if err != nil {
+		return 0
+	}
+
+	// Count actual task files (not just .lock).
+	count := 0
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".json") {
+			count++
+		}
+	}
+
+	return count
+}
+
+// rebuildSessionMapLocked derives the session to project map from the cached
+// projects. The caller must hold mu for writing.
+func (pi *ProjectIndexer) rebuildSessionMapLocked() {
+	sessionToProject := make(map[string]projectInfo)
+	for dirName, project := range pi.projects {
+		// Extract a short project name from the directory name.
+		projectName := extractProjectName(dirName)
+
+		for _, entry := range project.Sessions {
+			sessionToProject[entry.SessionID] = projectInfo{
+				name:        projectName,
+				path:        dirName,
+				summary:     entry.Summary,
+				firstPrompt: entry.FirstPrompt,
+				fullPath:    entry.FullPath,
+			}
+		}
+	}
+
+	pi.sessionToProject = sessionToProject
+
+	// Sessions found by their transcript may have since been indexed or
+	// moved, so drop the fallback cache whenever projects change.
+	pi.jsonlProjects = make(map[string]projectInfo)
+}
+
+// watchLoop applies filesystem change notifications to the cached index.
+func (pi *ProjectIndexer) watchLoop() {
+	defer pi.wg.Done()
+
+	var (
+		events        = pi.watcher.Events()
+		dirtyProjects = make(map[string]bool)
+		dirtyTasks    = make(map[string]bool)
+		allProjects   bool
+		allTasks      bool
+		flush         <-chan time.Time
+	)
+
+	rescanTicker := time.NewTicker(indexRescanInterval)
+	defer rescanTicker.Stop()
+
+	for {
+		select {
+		case path, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			root, name, ok := splitWatchPath(
+				[]string{pi.projectsDir, pi.tasksDir}, path,
+			)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case root == pi.projectsDir && name == "":
+				allProjects = true
+
+			case root == pi.projectsDir:
+				// Only the project directory itself and its
+				// index file affect the cached projects.
+				base := filepath.Base(path)
+				if path == filepath.Join(root, name) ||
+					base == "sessions-index.json" {
+
+					dirtyProjects[name] = true
+				}
+
+			case name == "":
+				allTasks = true
+
+			default:
+				dirtyTasks[name] = true
+			}
+
+			if flush == nil {
+				flush = time.After(indexDebounce)
+			}
+
+		case <-flush:
+			flush = nil
+
+			if allProjects || allTasks {
+				pi.Rescan()
+			} else {
+				pi.refreshProjects(dirtyProjects)
+				pi.refreshTaskCounts(dirtyTasks)
+			}
+
+			dirtyProjects = make(map[string]bool)
+			dirtyTasks = make(map[string]bool)
+			allProjects, allTasks = false, false
+
+		case <-rescanTicker.C:
+			pi.Rescan()
+
+		case <-pi.quit:
+			return
+		}
+	}
+}
+
+// refreshProjects re-reads the given project directories.
+func (pi *ProjectIndexer) refreshProjects(dirNames map[string]bool) {
+	if len(dirNames) == 0 {
+		return
+	}
+
+	loaded := make(map[string]*Project, len(dirNames))
+	for dirName := range dirNames {
+		project, err := pi.loadProject(dirName)
+		if err != nil {
+			loaded[dirName] = nil
+			continue
+		}
+		loaded[dirName] = &project
+	}
+
+	var change IndexChange
+
+	pi.mu.Lock()
+	for dirName, project := range loaded {
+		old, existed := pi.projects[dirName]
+
+		if project == nil {
+			if existed {
+				change.Projects = append(change.Projects, dirName)
+			}
+			delete(pi.projects, dirName)
+			continue
+		}
+
+		if !existed || projectChanged(old, *project) {
+			change.Projects = append(change.Projects, dirName)
+		}
+		pi.projects[dirName] = *project
+	}
+	pi.rebuildSessionMapLocked()
+	pi.mu.Unlock()
+
+	pi.notifyChange(change)
+}
+
+// refreshTaskCounts recounts the task files of the given sessions.
+func (pi *ProjectIndexer) refreshTaskCounts(sessionIDs map[string]bool) {
+	if len(sessionIDs) == 0 {
+		return
+	}
+
+	counts := make(map[string]int, len(sessionIDs))
+	for sessionID := range sessionIDs {
+		counts[sessionID] = pi.countTaskFiles(sessionID)
+	}
+
+	var change IndexChange
+
+	pi.mu.Lock()
+	for sessionID, n := range counts {
+		_, existed := pi.taskCounts[sessionID]
+
+		if n == 0 {
+			if existed {
+				change.ListsEnded = append(
+					change.ListsEnded, sessionID,
+				)
+			}
+			delete(pi.taskCounts, sessionID)
+			continue
+		}
+
+		if !existed {
+			change.ListsAppeared = append(
+				change.ListsAppeared, sessionID,
+			)
+		}
+		pi.taskCounts[sessionID] = n
+	}
+	pi.tasksErr = nil
+	pi.mu.Unlock()
+
+	pi.notifyChange(change)
+}
+
+// ListProjects returns all projects with their session metadata.
+func (pi *ProjectIndexer) ListProjects() ([]Project, error) {
+	pi.ensureLoaded()
+
+	pi.mu.RLock()
+	if pi.projectsErr != nil {
+		pi.mu.RUnlock()
+		return nil, pi.projectsErr
 	}
 
+	projects := make([]Project, 0, len(pi.projects))
+	for _, p := range pi.projects {
+		projects = append(projects, p)
+	}
+	pi.mu.RUnlock()
+
 	// Sort by last modified time (most recent first).
 	sort.Slice(projects, func(i, j int) bool {
 		return projects[i].LastModified.After(projects[j].LastModified)
@@ -130,6 +645,8 @@ func (pi *ProjectIndexer) loadProject(dirName string) (Project, error) {
 		return idx.Entries[i].Modified.After(idx.Entries[j].Modified)
 	})
 
+	pi.linkSidechains(dirName, idx.Entries)
+
 	// Get the project path from the first entry (most recently modified).
 	projectPath := idx.Entries[0].ProjectPath
 
@@ -179,6 +696,17 @@ func extractProjectInfo(path string) (name, shortname, org string) {
 
 // GetProject returns a single project by its directory name.
 func (pi *ProjectIndexer) GetProject(dirName string) (Project, error) {
+	pi.ensureLoaded()
+
+	pi.mu.RLock()
+	project, ok := pi.projects[dirName]
+	pi.mu.RUnlock()
+
+	if ok {
+		return project, nil
+	}
+
+	// The watcher may not have caught up with a brand new project yet.
 	return pi.loadProject(dirName)
 }
 
@@ -200,147 +728,122 @@ func (pi *ProjectIndexer) GetProjectByPath(path string) (Project, error) {
 
 // HasTasks checks if a session has any tasks in ~/.claude/tasks/.
 func (pi *ProjectIndexer) HasTasks(sessionID string) bool {
-	taskDir := filepath.Join(pi.tasksDir, sessionID)
-	entries, err := os.ReadDir(taskDir)
-	if err != nil {
+	return pi.GetTaskCount(sessionID) > 0
+}
+
+// HasTaskDir checks if a session's directory in ~/.claude/tasks/ still
+// exists, even if every task in it has been deleted. Claude removes the
+// directory when the session ends.
+func (pi *ProjectIndexer) HasTaskDir(sessionID string) bool {
+	if !validListID(sessionID) {
 		return false
 	}
 
-	// Check for actual task files (not just .lock).
-	for _, e := range entries {
-		if strings.HasSuffix(e.Name(), ".json") {
-			return true
-		}
-	}
+	_, err := os.Stat(filepath.Join(pi.tasksDir, sessionID))
 
-	return false
+	return err == nil
 }
 
 // GetTaskCount returns the number of tasks for a session.
 func (pi *ProjectIndexer) GetTaskCount(sessionID string) int {
-	taskDir := filepath.Join(pi.tasksDir, sessionID)
-	entries, err := os.ReadDir(taskDir)
-	if err != nil {
-		return 0
-	}
+	pi.ensureLoaded()
 
-	count := 0
-	for _, e := range entries {
-		if strings.HasSuffix(e.Name(), ".json") {
-			count++
-		}
-	}
+	pi.mu.RLock()
+	defer pi.mu.RUnlock()
 
-	return count
+	return pi.taskCounts[sessionID]
 }
 
 // ActiveTaskList represents a task list with active tasks.
 type ActiveTaskList struct {
-	SessionID   string
-	TaskCount   int
-	TaskDir     string
-	ProjectName string
-	ProjectPath string
-	Summary     string
-	FirstPrompt string
+	SessionID   string `json:"sessionId"`
+	TaskCount   int    `json:"taskCount"`
+	TaskDir     string `json:"taskDir"`
+	ProjectName string `json:"projectName"`
+	Summary     string `json:"summary"`
+	FirstPrompt string `json:"firstPrompt"`
+
+	// ProjectPath is the sanitized directory name of the session's
+	// project.
+	ProjectPath string `json:"projectDir"`
+
+	// Stall is set by the watchdog if the session appears to have
+	// stalled.
+	Stall *StallInfo `json:"stall,omitempty"`
 }
 
 // ListActiveTaskLists returns all task lists that have actual task files.
 func (pi *ProjectIndexer) ListActiveTaskLists() ([]ActiveTaskList, error) {
-	entries, err := os.ReadDir(pi.tasksDir)
-	if err != nil {
-		return nil, err
-	}
+	pi.ensureLoaded()
 
-	// Build a map of sessionID -> project info by scanning all projects.
-	sessionToProject := pi.buildSessionProjectMap()
+	pi.mu.RLock()
+	if pi.tasksErr != nil {
+		pi.mu.RUnlock()
+		return nil, pi.tasksErr
+	}
 
-	var activeLists []ActiveTaskList
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+	sessionIDs := make([]string, 0, len(pi.taskCounts))
+	for sessionID := range pi.taskCounts {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	sort.Strings(sessionIDs)
+
+	var (
+		activeLists = make([]ActiveTaskList, 0, len(sessionIDs))
+		unresolved  []int
+	)
+	for _, sessionID := range sessionIDs {
+		active := ActiveTaskList{
+			SessionID: sessionID,
+			TaskCount: pi.taskCounts[sessionID],
+			TaskDir:   filepath.Join(pi.tasksDir, sessionID),
 		}
 
-		sessionID := entry.Name()
-		taskCount := pi.GetTaskCount(sessionID)
-		if taskCount > 0 {
-			active := ActiveTaskList{
-				SessionID: sessionID,
-				TaskCount: taskCount,
-				TaskDir:   filepath.Join(pi.tasksDir, sessionID),
-			}
+		// Look up the project for this session.
+		if proj, ok := pi.sessionToProject[sessionID]; ok {
+			active.applyProject(proj)
+		} else if proj, ok := pi.jsonlProjects[sessionID]; ok {
+			active.applyProject(proj)
+		} else {
+			unresolved = append(unresolved, len(activeLists))
+		}
 
-			// Look up the project for this session.
-			if proj, ok := sessionToProject[sessionID]; ok {
-				active.ProjectName = proj.name
-				active.ProjectPath = proj.path
-				active.Summary = proj.summary
-				active.FirstPrompt = proj.firstPrompt
-			} else if proj, ok := pi.findProjectByJSONL(sessionID); ok {
-				// Fallback: search for the JSONL file directly.
-				active.ProjectName = proj.name
-				active.ProjectPath = proj.path
-				active.Summary = proj.summary
-				active.FirstPrompt = proj.firstPrompt
-			}
+		activeLists = append(activeLists, active)
+	}
+	pi.mu.RUnlock()
 
-			activeLists = append(activeLists, active)
+	// Fallback: search for the JSONL file directly, caching any hits.
+	for _, i := range unresolved {
+		proj, ok := pi.findProjectByJSONL(activeLists[i].SessionID)
+		if !ok {
+			continue
 		}
+
+		activeLists[i].applyProject(proj)
+
+		pi.mu.Lock()
+		pi.jsonlProjects[activeLists[i].SessionID] = proj
+		pi.mu.Unlock()
 	}
 
 	return activeLists, nil
 }
 
+// applyProject copies project info into an active task list.
+func (a *ActiveTaskList) applyProject(proj projectInfo) {
+	a.ProjectName = proj.name
+	a.ProjectPath = proj.path
+	a.Summary = proj.summary
+	a.FirstPrompt = proj.firstPrompt
+}
+
 // projectInfo holds minimal project info for session lookups.
 type projectInfo struct {
 	name        string
 	path        string
 	summary     string
 	firstPrompt string
-}
-
-// buildSessionProjectMap scans all projects and builds a map of session IDs
-// to their project info.
-func (pi *ProjectIndexer) buildSessionProjectMap() map[string]projectInfo {
-	result := make(map[string]projectInfo)
-
-	projectDirs, err := os.ReadDir(pi.projectsDir)
-	if err != nil {
-		return result
-	}
-
-	for _, dir := range projectDirs {
-		if !dir.IsDir() {
-			continue
-		}
-
-		indexPath := filepath.Join(
-			pi.projectsDir, dir.Name(), "sessions-index.json",
-		)
-		data, err := os.ReadFile(indexPath)
-		if err != nil {
-			continue
-		}
-
-		var index sessionsIndex
-		if err := json.Unmarshal(data, &index); err != nil {
-			continue
-		}
-
-		// Extract a short project name from the directory name.
-		projectName := extractProjectName(dir.Name())
-
-		for _, entry := range index.Entries {
-			result[entry.SessionID] = projectInfo{
-				name:        projectName,
-				path:        dir.Name(),
-				summary:     entry.Summary,
-				firstPrompt: entry.FirstPrompt,
-			}
-		}
-	}
-
-	return result
+	fullPath    string
 }
 
 // extractProjectName extracts a short project name from a sanitized dir name.
@@ -361,6 +864,10 @@ func extractProjectName(dirName string) string {
 // findProjectByJSONL searches for a session's JSONL file in project directories.
 // This is a fallback when the session isn't in sessions-index.json yet.
 func (pi *ProjectIndexer) findProjectByJSONL(sessionID string) (projectInfo, bool) {
+	if !validListID(sessionID) {
+		return projectInfo{}, false
+	}
+
 	projectDirs, err := os.ReadDir(pi.projectsDir)
 	if err != nil {
 		return projectInfo{}, false
@@ -384,6 +891,208 @@ func (pi *ProjectIndexer) findProjectByJSONL(sessionID string) (projectInfo, boo
 	return projectInfo{}, false
 }
 
+// lookupSession returns the project info of a session, searching for its
+// transcript on disk if it is not in any sessions-index.json yet.
+func (pi *ProjectIndexer) lookupSession(sessionID string) (projectInfo, bool) {
+	pi.ensureLoaded()
+
+	pi.mu.RLock()
+	proj, ok := pi.sessionToProject[sessionID]
+	if !ok {
+		proj, ok = pi.jsonlProjects[sessionID]
+	}
+	pi.mu.RUnlock()
+
+	if ok {
+		return proj, true
+	}
+
+	proj, ok = pi.findProjectByJSONL(sessionID)
+	if ok {
+		pi.mu.Lock()
+		pi.jsonlProjects[sessionID] = proj
+		pi.mu.Unlock()
+	}
+
+	return proj, ok
+}
+
+// GetSession returns a session's index entry and the directory name of its
+// project. The entry is zero if the session has not been indexed yet but its
+// project could be found from its transcript.
+func (pi *ProjectIndexer) GetSession(sessionID string) (SessionEntry, string,
+	bool) {
+
+	proj, ok := pi.lookupSession(sessionID)
+	if !ok {
+		return SessionEntry{}, "", false
+	}
+
+	pi.mu.RLock()
+	defer pi.mu.RUnlock()
+
+	for _, s := range pi.projects[proj.path].Sessions {
+		if s.SessionID == sessionID {
+			return s, proj.path, true
+		}
+	}
+
+	return SessionEntry{SessionID: sessionID}, proj.path, true
+}
+
+// TranscriptPath returns the path of a session's JSONL transcript.
+func (pi *ProjectIndexer) TranscriptPath(sessionID string) (string, bool) {
+	proj, ok := pi.lookupSession(sessionID)
+	if !ok {
+		return "", false
+	}
+
+	// Prefer the path recorded in the index, which survives the project
+	// directory being renamed.
+	if proj.fullPath != "" {
+		if _, err := os.Stat(proj.fullPath); err == nil {
+			return proj.fullPath, true
+		}
+	}
+
+	path := filepath.Join(pi.projectsDir, proj.path, sessionID+".jsonl")
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+
+	return path, true
+}
+
+// sanitizeProjectPath converts a filesystem path to the directory name Claude
+// Code stores its sessions under, replacing every character other than an
+// ASCII letter or digit with a dash.
+// E.g., "/Users/roasbeef/gocode/src/github.com/roasbeef/lnd" ->
+// "-Users-roasbeef-gocode-src-github-com-roasbeef-lnd"
+func sanitizeProjectPath(path string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9':
+
+			return r
+
+		default:
+			return '-'
+		}
+	}, path)
+}
+
+// ProjectDirForPath returns the sanitized project directory name that holds
+// the sessions of a working directory.
+func (pi *ProjectIndexer) ProjectDirForPath(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	path = filepath.Clean(path)
+
+	pi.ensureLoaded()
+
+	dirName := sanitizeProjectPath(path)
+
+	pi.mu.RLock()
+	_, ok := pi.projects[dirName]
+	if !ok {
+		// Older Claude versions only replaced path separators and
+		// dots, so also match on the recorded project path.
+		for name, p := range pi.projects {
+			if p.Path == path {
+				dirName, ok = name, true
+				break
+			}
+		}
+	}
+	pi.mu.RUnlock()
+
+	if ok {
+		return dirName, true
+	}
+
+	// The project may have sessions that are not indexed yet.
+	info, err := os.Stat(filepath.Join(pi.projectsDir, dirName))
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+
+	return dirName, true
+}
+
+// SessionFile is a session transcript on disk.
+type SessionFile struct {
+	SessionID string
+	Path      string
+	ModTime   time.Time
+}
+
+// SessionFiles lists the session transcripts in a project directory, most
+// recently modified first. Unlike the project's sessions index, this
+// includes sessions that have only just started.
+func (pi *ProjectIndexer) SessionFiles(dirName string) ([]SessionFile, error) {
+	if !validListID(dirName) {
+		return nil, os.ErrNotExist
+	}
+
+	dir := filepath.Join(pi.projectsDir, dirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []SessionFile
+	for _, e := range entries {
+		sessionID, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok || e.IsDir() {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, SessionFile{
+			SessionID: sessionID,
+			Path:      filepath.Join(dir, e.Name()),
+			ModTime:   info.ModTime(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+
+	return files, nil
+}
+
+// SessionForTranscript returns the session ID and project directory name of
+// a path, if it is a session transcript inside the projects directory.
+func (pi *ProjectIndexer) SessionForTranscript(path string) (string, string,
+	bool) {
+
+	rel, err := filepath.Rel(pi.projectsDir, path)
+	if err != nil {
+		return "", "", false
+	}
+
+	dirName, file, ok := strings.Cut(rel, string(filepath.Separator))
+	if !ok || !validListID(dirName) || strings.ContainsRune(
+		file, filepath.Separator) {
+
+		return "", "", false
+	}
+
+	sessionID, ok := strings.CutSuffix(file, ".jsonl")
+	if !ok || !validListID(sessionID) {
+		return "", "", false
+	}
+
+	return sessionID, dirName, true
+}
+
 // ProjectSummary provides a condensed view of a project for listing.
 type ProjectSummary struct {
 	Name         string    `json:"name"`
@@ -0,0 +1,244 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeSessionsIndex writes a project's sessions-index.json with a session
+// for each ID.
+func writeSessionsIndex(t *testing.T, projectDir, projectPath string,
+	sessionIDs ...string) {
+
+	t.Helper()
+
+	idx := sessionsIndex{Version: 1}
+	for i, id := range sessionIDs {
+		idx.Entries = append(idx.Entries, SessionEntry{
+			SessionID:   id,
+			ProjectPath: projectPath,
+			Modified:    time.Unix(int64(1000+i), 0),
+		})
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatalf("unable to encode index: %v", err)
+	}
+
+	if err := os.MkdirAll(projectDir, 0o700); err != nil {
+		t.Fatalf("unable to create %s: %v", projectDir, err)
+	}
+	path := filepath.Join(projectDir, "sessions-index.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("unable to write %s: %v", path, err)
+	}
+}
+
+// writeTaskFiles creates a task list directory holding n task files.
+func writeTaskFiles(t *testing.T, listDir string, n int) {
+	t.Helper()
+
+	if err := os.MkdirAll(listDir, 0o700); err != nil {
+		t.Fatalf("unable to create %s: %v", listDir, err)
+	}
+	for i := 1; i <= n; i++ {
+		path := filepath.Join(listDir, fmt.Sprintf("%d.json", i))
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatalf("unable to write %s: %v", path, err)
+		}
+	}
+}
+
+// eventually waits for a condition to hold.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestProjectIndexerCache checks that an index that is not watched keeps
+// serving its cached scan until it is rescanned.
+func TestProjectIndexerCache(t *testing.T) {
+	claudeDir := t.TempDir()
+	projectsDir := filepath.Join(claudeDir, "projects")
+	tasksDir := filepath.Join(claudeDir, "tasks")
+	writeSessionsIndex(
+		t, filepath.Join(projectsDir, "-a-app"), "/a/app", "s1",
+	)
+
+	pi := NewProjectIndexer(projectsDir, tasksDir)
+	projects, err := pi.ListProjects()
+	if err != nil || len(projects) != 1 {
+		t.Fatalf("got %d projects, err %v", len(projects), err)
+	}
+
+	writeSessionsIndex(
+		t, filepath.Join(projectsDir, "-b-lib"), "/b/lib", "s2",
+	)
+	writeTaskFiles(t, filepath.Join(tasksDir, "s2"), 2)
+	if projects, _ := pi.ListProjects(); len(projects) != 1 {
+		t.Fatalf("cache changed without a rescan: %d projects",
+			len(projects))
+	}
+
+	if err := pi.Rescan(); err != nil {
+		t.Fatalf("unable to rescan: %v", err)
+	}
+	if projects, _ := pi.ListProjects(); len(projects) != 2 {
+		t.Fatalf("got %d projects after rescan", len(projects))
+	}
+	lists, err := pi.ListActiveTaskLists()
+	if err != nil || len(lists) != 1 || lists[0].ProjectName != "lib" ||
+		lists[0].TaskCount != 2 {
+
+		t.Fatalf("unexpected lists %+v, err %v", lists, err)
+	}
+}
+
+// TestProjectIndexerWatch checks that a started index follows changes to
+// projects and task lists, including in a tasks directory that is only
+// created after the index started.
+func TestProjectIndexerWatch(t *testing.T) {
+	claudeDir := t.TempDir()
+	projectsDir := filepath.Join(claudeDir, "projects")
+	tasksDir := filepath.Join(claudeDir, "tasks")
+	if err := os.Mkdir(projectsDir, 0o700); err != nil {
+		t.Fatalf("unable to create %s: %v", projectsDir, err)
+	}
+
+	pi := NewProjectIndexer(projectsDir, tasksDir)
+	if err := pi.Start(); err != nil {
+		t.Fatalf("unable to start indexer: %v", err)
+	}
+	defer pi.Stop()
+
+	projectDir := filepath.Join(projectsDir, "-a-app")
+	writeSessionsIndex(t, projectDir, "/a/app", "s1")
+	eventually(t, "new project", func() bool {
+		projects, _ := pi.ListProjects()
+		return len(projects) == 1 && projects[0].SessionCount == 1
+	})
+
+	writeSessionsIndex(t, projectDir, "/a/app", "s1", "s2")
+	eventually(t, "new session", func() bool {
+		project, err := pi.GetProject("-a-app")
+		return err == nil && project.SessionCount == 2
+	})
+
+	writeTaskFiles(t, filepath.Join(tasksDir, "s1"), 3)
+	eventually(t, "tasks in a new tasks directory", func() bool {
+		return pi.GetTaskCount("s1") == 3
+	})
+
+	if err := os.RemoveAll(filepath.Join(tasksDir, "s1")); err != nil {
+		t.Fatalf("unable to remove list: %v", err)
+	}
+	eventually(t, "removed list", func() bool {
+		return !pi.HasTasks("s1")
+	})
+
+	if err := os.RemoveAll(projectDir); err != nil {
+		t.Fatalf("unable to remove project: %v", err)
+	}
+	eventually(t, "removed project", func() bool {
+		projects, _ := pi.ListProjects()
+		return len(projects) == 0
+	})
+}
+
+// nextChange waits for the next index change on a subscription.
+func nextChange(t *testing.T, changes <-chan IndexChange) IndexChange {
+	t.Helper()
+
+	select {
+	case change := <-changes:
+		return change
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for an index change")
+		return IndexChange{}
+	}
+}
+
+// TestProjectIndexerChanges checks that every subscriber is told which
+// projects and task lists a rescan changed, and that a cancelled
+// subscription is not.
+func TestProjectIndexerChanges(t *testing.T) {
+	claudeDir := t.TempDir()
+	projectsDir := filepath.Join(claudeDir, "projects")
+	tasksDir := filepath.Join(claudeDir, "tasks")
+	writeSessionsIndex(
+		t, filepath.Join(projectsDir, "-a-app"), "/a/app", "s1",
+	)
+	writeTaskFiles(t, filepath.Join(tasksDir, "s1"), 1)
+
+	pi := NewProjectIndexer(projectsDir, tasksDir)
+	first, cancelFirst := pi.SubscribeChanges()
+	defer cancelFirst()
+	second, cancelSecond := pi.SubscribeChanges()
+
+	// The initial load is not reported.
+	if _, err := pi.ListProjects(); err != nil {
+		t.Fatalf("unable to list projects: %v", err)
+	}
+	select {
+	case change := <-first:
+		t.Fatalf("initial load reported: %+v", change)
+	default:
+	}
+
+	writeSessionsIndex(
+		t, filepath.Join(projectsDir, "-b-app"), "/b/app", "s2",
+	)
+	writeTaskFiles(t, filepath.Join(tasksDir, "s2"), 1)
+	if err := os.RemoveAll(filepath.Join(tasksDir, "s1")); err != nil {
+		t.Fatalf("unable to remove list: %v", err)
+	}
+	if err := pi.Rescan(); err != nil {
+		t.Fatalf("unable to rescan: %v", err)
+	}
+
+	want := IndexChange{
+		Projects:      []string{"-b-app"},
+		ListsAppeared: []string{"s2"},
+		ListsEnded:    []string{"s1"},
+	}
+	for _, changes := range []<-chan IndexChange{first, second} {
+		change := nextChange(t, changes)
+		if !reflect.DeepEqual(change, want) {
+			t.Fatalf("got change %+v, want %+v", change, want)
+		}
+	}
+
+	// A rescan that finds nothing new is not reported, and a cancelled
+	// subscription hears nothing more.
+	cancelSecond()
+	if err := pi.Rescan(); err != nil {
+		t.Fatalf("unable to rescan: %v", err)
+	}
+	writeTaskFiles(t, filepath.Join(tasksDir, "s3"), 1)
+	if err := pi.Rescan(); err != nil {
+		t.Fatalf("unable to rescan: %v", err)
+	}
+
+	change := nextChange(t, first)
+	if len(change.ListsAppeared) != 1 || change.ListsAppeared[0] != "s3" {
+		t.Fatalf("got change %+v, want s3 appeared", change)
+	}
+	select {
+	case change := <-second:
+		t.Fatalf("cancelled subscription got %+v", change)
+	default:
+	}
+}
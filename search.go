@@ -0,0 +1,1054 @@
+package taskviewer
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// Kinds of searchable document.
+const (
+	SearchKindSession    = "session"
+	SearchKindTask       = "task"
+	SearchKindTranscript = "transcript"
+)
+
+const (
+	// searchDefaultLimit and searchMaxLimit bound the results returned.
+	searchDefaultLimit = 50
+	searchMaxLimit     = 200
+
+	// searchMaxTokenLen skips tokens longer than this, which are almost
+	// always hashes or encoded blobs rather than words.
+	searchMaxTokenLen = 64
+
+	// transcriptIndexLimit caps the text indexed per transcript.
+	transcriptIndexLimit = 512 * 1024
+
+	// transcriptRescanInterval is how often transcripts are checked for
+	// new messages.
+	transcriptRescanInterval = time.Minute
+
+	// snippetContext is roughly how many bytes of text are shown either
+	// side of a snippet's first match.
+	snippetContext = 80
+)
+
+// searchField is a searchable field of a document.
+type searchField struct {
+	name   string
+	text   string
+	weight float64
+}
+
+// searchDoc is a searchable document: a session, a task or a transcript.
+type searchDoc struct {
+	key       string
+	kind      string
+	sessionID string
+	taskID    string
+	title     string
+	url       string
+	status    claudeagent.TaskListStatus
+
+	projectDir  string
+	projectName string
+	branch      string
+	time        time.Time
+
+	// modTime is the transcript modification time that was indexed.
+	modTime time.Time
+
+	fields []searchField
+
+	// terms holds the distinct terms of the document, so that its
+	// postings can be removed when it is reindexed.
+	terms []string
+}
+
+// docField identifies a field of a document in a posting list.
+type docField struct {
+	doc   *searchDoc
+	field int
+}
+
+// tokenSpan is a token and where it was found in its text.
+type tokenSpan struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lowercase words of letters and digits.
+func tokenize(text string) []tokenSpan {
+	var (
+		spans []tokenSpan
+		start = -1
+	)
+	flush := func(end int) {
+		if start >= 0 && end-start <= searchMaxTokenLen*utf8.UTFMax {
+			term := strings.ToLower(text[start:end])
+			if utf8.RuneCountInString(term) <= searchMaxTokenLen {
+				spans = append(
+					spans, tokenSpan{term, start, end},
+				)
+			}
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+
+	return spans
+}
+
+// SearchQuery is a parsed search.
+type SearchQuery struct {
+	// Text is the query: words, which must all match, and "quoted
+	// phrases", which must match in order.
+	Text string
+
+	// Project, if set, restricts results to the project with this name
+	// or directory.
+	Project string
+
+	// Branch, if set, restricts results to sessions on this git branch.
+	Branch string
+
+	// Since and Until, if set, restrict results to documents last
+	// modified in this range.
+	Since time.Time
+	Until time.Time
+
+	// Kinds, if set, restricts results to these kinds of document.
+	Kinds []string
+
+	// Limit caps the number of results.
+	Limit int
+}
+
+// parseClauses splits a query into its clauses: single words and phrases,
+// each as a sequence of terms.
+func parseClauses(text string) [][]string {
+	var clauses [][]string
+	for i, part := range strings.Split(text, `"`) {
+		terms := tokenize(part)
+
+		// Odd parts were between quotes.
+		if i%2 == 1 {
+			phrase := make([]string, 0, len(terms))
+			for _, t := range terms {
+				phrase = append(phrase, t.term)
+			}
+			if len(phrase) > 0 {
+				clauses = append(clauses, phrase)
+			}
+			continue
+		}
+
+		for _, t := range terms {
+			clauses = append(clauses, []string{t.term})
+		}
+	}
+
+	return clauses
+}
+
+// SearchRange is a highlighted byte range of a snippet.
+type SearchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SearchResult is a single matching document.
+type SearchResult struct {
+	Kind      string `json:"kind"`
+	SessionID string `json:"sessionId"`
+	TaskID    string `json:"taskId,omitempty"`
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+
+	// Status is the status of a task result.
+	Status claudeagent.TaskListStatus `json:"status,omitempty"`
+
+	ProjectName string    `json:"projectName,omitempty"`
+	ProjectDir  string    `json:"projectDir,omitempty"`
+	Branch      string    `json:"branch,omitempty"`
+	Time        time.Time `json:"time,omitzero"`
+	Score       float64   `json:"score"`
+
+	// Field is the field the snippet was taken from.
+	Field string `json:"field"`
+
+	// Snippet is an excerpt of the matching text, and Highlights the
+	// byte ranges of the matched words within it.
+	Snippet    string        `json:"snippet"`
+	Highlights []SearchRange `json:"highlights,omitempty"`
+}
+
+// SearchResults is the response to a search.
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchStats describes the search index.
+type SearchStats struct {
+	Documents   int  `json:"documents"`
+	Terms       int  `json:"terms"`
+	Transcripts bool `json:"transcripts"`
+
+	// Building is true until the initial index is complete.
+	Building bool `json:"building"`
+}
+
+// SearchIndex is an in-memory full-text index of session summaries and
+// first prompts, task subjects and descriptions and, optionally, transcript
+// text.
+//
+// The index is built in the background at startup and kept up to date from
+// the event bus: a changed project reindexes its sessions, and a changed
+// task list reindexes its tasks. Transcripts, which change without any
+// event, are rescanned periodically and only reread when modified.
+type SearchIndex struct {
+	taskStore      claudeagent.TaskStore
+	projectIndexer *ProjectIndexer
+	taskArchive    *TaskArchive
+	eventBus       *EventBus
+	transcripts    bool
+
+	// mu guards docs and postings.
+	mu       sync.RWMutex
+	docs     map[string]*searchDoc
+	postings map[string]map[docField][]int
+
+	// building is non-zero until the initial index is complete. It is
+	// accessed atomically.
+	building int32
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	log btclog.Logger
+}
+
+// NewSearchIndex creates a search index. Transcript text is only indexed if
+// transcripts is true, since it is far larger than everything else.
+func NewSearchIndex(taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, taskArchive *TaskArchive,
+	eventBus *EventBus, transcripts bool,
+	log btclog.Logger) *SearchIndex {
+
+	return &SearchIndex{
+		taskStore:      taskStore,
+		projectIndexer: projectIndexer,
+		taskArchive:    taskArchive,
+		eventBus:       eventBus,
+		transcripts:    transcripts,
+		docs:           make(map[string]*searchDoc),
+		postings:       make(map[string]map[docField][]int),
+		building:       1,
+		quit:           make(chan struct{}),
+		log:            log,
+	}
+}
+
+// Start builds the index in the background and keeps it up to date. This
+// method is idempotent.
+func (s *SearchIndex) Start() error {
+	if !atomic.CompareAndSwapUint32(&s.started, 0, 1) {
+		return nil
+	}
+
+	// Subscribe before the initial build so that no change made during
+	// it is missed.
+	sub, _, _ := s.eventBus.Subscribe("", 0)
+
+	s.wg.Add(1)
+	go s.eventLoop(sub)
+
+	if s.transcripts {
+		s.wg.Add(1)
+		go s.transcriptLoop()
+	}
+
+	return nil
+}
+
+// Stop ends the index updates. This method is idempotent.
+func (s *SearchIndex) Stop() error {
+	if !atomic.CompareAndSwapUint32(&s.stopped, 0, 1) {
+		return nil
+	}
+
+	close(s.quit)
+	s.wg.Wait()
+
+	return nil
+}
+
+// eventLoop builds the index of sessions and tasks, then applies changes
+// from the event bus.
+func (s *SearchIndex) eventLoop(sub *EventSubscription) {
+	defer s.wg.Done()
+	defer sub.Close()
+
+	start := time.Now()
+	s.build()
+	atomic.StoreInt32(&s.building, 0)
+
+	stats := s.Stats()
+	s.log.Infof("Search index built in %v: %d documents, %d terms",
+		time.Since(start).Round(time.Millisecond), stats.Documents,
+		stats.Terms)
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			s.handleEvent(event)
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// build indexes every project's sessions and every live or archived task
+// list.
+func (s *SearchIndex) build() {
+	projects, err := s.projectIndexer.ListProjects()
+	if err != nil {
+		s.log.Warnf("Search: unable to list projects: %v", err)
+	}
+	for _, project := range projects {
+		s.indexProject(project.DirName)
+	}
+
+	listIDs := make(map[string]bool)
+	activeLists, _ := s.projectIndexer.ListActiveTaskLists()
+	for _, al := range activeLists {
+		listIDs[al.SessionID] = true
+	}
+	for _, snapshot := range s.taskArchive.List() {
+		listIDs[snapshot.ListID] = true
+	}
+	for listID := range listIDs {
+		s.indexTasks(listID)
+	}
+}
+
+// handleEvent reindexes what an event changed.
+func (s *SearchIndex) handleEvent(event Event) {
+	switch {
+	case event.Type == EventIndexChanged:
+		for _, dirName := range event.Projects {
+			s.indexProject(dirName)
+		}
+
+	case strings.HasPrefix(event.Type, "task-"),
+		event.Type == EventSessionAppeared,
+		event.Type == EventSessionEnded:
+
+		s.indexTasks(event.ListID)
+	}
+}
+
+// transcriptLoop indexes every transcript, then reindexes those that
+// changed on every tick.
+func (s *SearchIndex) transcriptLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(transcriptRescanInterval)
+	defer ticker.Stop()
+
+	for {
+		projects, _ := s.projectIndexer.ListProjects()
+		for _, project := range projects {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+
+			s.indexTranscripts(project)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// indexProject reindexes the sessions of a project, removing those of a
+// project that no longer exists.
+func (s *SearchIndex) indexProject(dirName string) {
+	project, err := s.projectIndexer.GetProject(dirName)
+	if err != nil {
+		s.removeWhere(func(doc *searchDoc) bool {
+			return doc.kind == SearchKindSession &&
+				doc.projectDir == dirName
+		})
+		return
+	}
+
+	seen := make(map[string]bool, len(project.Sessions))
+	for _, session := range project.Sessions {
+		key := SearchKindSession + ":" + session.SessionID
+		seen[key] = true
+
+		s.put(&searchDoc{
+			key:         key,
+			kind:        SearchKindSession,
+			sessionID:   session.SessionID,
+			title:       sessionTitle(session),
+			url:         "/sessions/" + session.SessionID,
+			projectDir:  dirName,
+			projectName: project.Name,
+			branch:      session.GitBranch,
+			time:        session.Modified,
+			fields: []searchField{
+				{
+					name:   "summary",
+					text:   session.Summary,
+					weight: 3,
+				},
+				{
+					name:   "firstPrompt",
+					text:   session.FirstPrompt,
+					weight: 2,
+				},
+			},
+		})
+	}
+
+	s.removeWhere(func(doc *searchDoc) bool {
+		return doc.kind == SearchKindSession &&
+			doc.projectDir == dirName && !seen[doc.key]
+	})
+}
+
+// sessionTitle returns the title a session is listed under.
+func sessionTitle(session SessionEntry) string {
+	switch {
+	case session.Summary != "":
+		return session.Summary
+
+	case session.FirstPrompt != "":
+		return session.FirstPrompt
+
+	default:
+		return "Session " + session.SessionID
+	}
+}
+
+// indexTasks reindexes the tasks of a list, from the archive if its live
+// task files are gone.
+func (s *SearchIndex) indexTasks(listID string) {
+	if listID == "" {
+		return
+	}
+
+	var (
+		projectDir, projectName, branch string
+		updated                         time.Time
+	)
+	tasks, err := s.taskStore.List(context.Background(), listID)
+	if err != nil || len(tasks) == 0 {
+		tasks = nil
+		if snapshot, ok := s.taskArchive.Get(listID); ok {
+			tasks = snapshot.Tasks
+			projectName = snapshot.ProjectName
+			updated = snapshot.SnapshotAt
+			if snapshot.ProjectPath != "" {
+				projectDir = sanitizeProjectPath(
+					snapshot.ProjectPath,
+				)
+			}
+		}
+	}
+
+	if session, dirName, ok := s.projectIndexer.GetSession(
+		listID); ok {
+
+		projectDir = dirName
+		branch = session.GitBranch
+		if session.ProjectPath != "" {
+			projectName = filepath.Base(session.ProjectPath)
+		}
+		if session.Modified.After(updated) {
+			updated = session.Modified
+		}
+	}
+
+	prefix := SearchKindTask + ":" + listID + "/"
+	seen := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		key := prefix + task.ID
+		seen[key] = true
+
+		s.put(&searchDoc{
+			key:         key,
+			kind:        SearchKindTask,
+			sessionID:   listID,
+			taskID:      task.ID,
+			title:       task.Subject,
+			url:         "/lists/" + listID + "/tasks/" + task.ID,
+			status:      task.Status,
+			projectDir:  projectDir,
+			projectName: projectName,
+			branch:      branch,
+			time:        updated,
+			fields: []searchField{
+				{
+					name:   "subject",
+					text:   task.Subject,
+					weight: 3,
+				},
+				{
+					name:   "description",
+					text:   task.Description,
+					weight: 1,
+				},
+			},
+		})
+	}
+
+	s.removeWhere(func(doc *searchDoc) bool {
+		return strings.HasPrefix(doc.key, prefix) && !seen[doc.key]
+	})
+}
+
+// indexTranscripts reindexes the transcripts of a project that changed
+// since they were last indexed.
+func (s *SearchIndex) indexTranscripts(project Project) {
+	files, err := s.projectIndexer.SessionFiles(project.DirName)
+	if err != nil {
+		return
+	}
+
+	sessions := make(map[string]SessionEntry, len(project.Sessions))
+	for _, session := range project.Sessions {
+		sessions[session.SessionID] = session
+	}
+
+	for _, file := range files {
+		key := SearchKindTranscript + ":" + file.SessionID
+
+		s.mu.RLock()
+		doc, ok := s.docs[key]
+		s.mu.RUnlock()
+		if ok && doc.modTime.Equal(file.ModTime) {
+			continue
+		}
+
+		transcript, err := LoadTranscript(file.Path)
+		if err != nil {
+			continue
+		}
+
+		session := sessions[file.SessionID]
+		title := sessionTitle(session)
+		if session.SessionID == "" && transcript.Summary != "" {
+			title = transcript.Summary
+		}
+
+		s.put(&searchDoc{
+			key:         key,
+			kind:        SearchKindTranscript,
+			sessionID:   file.SessionID,
+			title:       title,
+			url:         "/sessions/" + file.SessionID,
+			projectDir:  project.DirName,
+			projectName: project.Name,
+			branch:      transcript.GitBranch,
+			time:        file.ModTime,
+			modTime:     file.ModTime,
+			fields: []searchField{{
+				name:   "transcript",
+				text:   transcriptText(transcript),
+				weight: 1,
+			}},
+		})
+	}
+}
+
+// transcriptText returns the conversation text of a transcript, without tool
+// calls or their output, up to transcriptIndexLimit bytes.
+func transcriptText(t *Transcript) string {
+	var b strings.Builder
+	for _, msg := range t.Messages {
+		for _, block := range msg.Blocks {
+			if block.Kind != BlockText || block.Text == "" {
+				continue
+			}
+			if b.Len()+len(block.Text) > transcriptIndexLimit {
+				return b.String()
+			}
+			b.WriteString(block.Text)
+			b.WriteString("\n\n")
+		}
+	}
+
+	return b.String()
+}
+
+// put adds a document to the index, replacing any previous version.
+func (s *SearchIndex) put(doc *searchDoc) {
+	// Tokenize outside the lock.
+	fieldTerms := make([][]tokenSpan, len(doc.fields))
+	terms := make(map[string]bool)
+	for i, field := range doc.fields {
+		fieldTerms[i] = tokenize(field.text)
+		for _, t := range fieldTerms[i] {
+			terms[t.term] = true
+		}
+	}
+	doc.terms = make([]string, 0, len(terms))
+	for term := range terms {
+		doc.terms = append(doc.terms, term)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.docs[doc.key]; ok {
+		s.removeLocked(old)
+	}
+	s.docs[doc.key] = doc
+
+	for i, spans := range fieldTerms {
+		ref := docField{doc: doc, field: i}
+		for pos, t := range spans {
+			postings, ok := s.postings[t.term]
+			if !ok {
+				postings = make(map[docField][]int)
+				s.postings[t.term] = postings
+			}
+			postings[ref] = append(postings[ref], pos)
+		}
+	}
+}
+
+// removeWhere removes every document matching a predicate.
+func (s *SearchIndex) removeWhere(match func(doc *searchDoc) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range s.docs {
+		if match(doc) {
+			s.removeLocked(doc)
+		}
+	}
+}
+
+// removeLocked removes a document and its postings. The caller must hold mu.
+func (s *SearchIndex) removeLocked(doc *searchDoc) {
+	delete(s.docs, doc.key)
+
+	for _, term := range doc.terms {
+		postings := s.postings[term]
+		for i := range doc.fields {
+			delete(postings, docField{doc: doc, field: i})
+		}
+		if len(postings) == 0 {
+			delete(s.postings, term)
+		}
+	}
+}
+
+// Stats describes the index. It is safe to call on a nil index.
+func (s *SearchIndex) Stats() SearchStats {
+	if s == nil {
+		return SearchStats{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SearchStats{
+		Documents:   len(s.docs),
+		Terms:       len(s.postings),
+		Transcripts: s.transcripts,
+		Building:    atomic.LoadInt32(&s.building) != 0,
+	}
+}
+
+// matches reports whether a document passes a query's filters.
+func (q *SearchQuery) matches(doc *searchDoc) bool {
+	if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, doc.kind) {
+		return false
+	}
+	if q.Project != "" && !strings.EqualFold(q.Project, doc.projectName) &&
+		q.Project != doc.projectDir {
+
+		return false
+	}
+	if q.Branch != "" && !strings.EqualFold(q.Branch, doc.branch) {
+		return false
+	}
+	if !q.Since.IsZero() && doc.time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !doc.time.Before(q.Until) {
+		return false
+	}
+
+	return true
+}
+
+// clauseMatches returns the positions in each field of a document at which a
+// clause starts.
+func (s *SearchIndex) clauseMatches(clause []string) map[docField][]int {
+	first := s.postings[clause[0]]
+	if len(clause) == 1 {
+		return first
+	}
+
+	matches := make(map[docField][]int)
+	for ref, positions := range first {
+	next:
+		for _, pos := range positions {
+			for i, term := range clause[1:] {
+				later := s.postings[term][ref]
+				if _, ok := slices.BinarySearch(
+					later, pos+i+1); !ok {
+
+					continue next
+				}
+			}
+			matches[ref] = append(matches[ref], pos)
+		}
+	}
+
+	return matches
+}
+
+// Search returns the documents matching every word and phrase of a query
+// and its filters, best match first.
+//
+// Documents are ranked by the sum over clauses of field weight times log
+// term frequency times inverse document frequency, with more recently
+// modified documents first among equals. It is safe to call on a nil index,
+// which matches nothing.
+func (s *SearchIndex) Search(q SearchQuery) (SearchResults, error) {
+	if s == nil {
+		return SearchResults{Query: q.Text}, nil
+	}
+
+	clauses := parseClauses(q.Text)
+	if len(clauses) == 0 {
+		return SearchResults{}, fmt.Errorf("empty query")
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+	limit = min(limit, searchMaxLimit)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type scored struct {
+		doc   *searchDoc
+		score float64
+
+		// best is the field and position of the best match, for the
+		// snippet.
+		best      docField
+		bestPos   int
+		bestScore float64
+	}
+
+	// Every clause must match, so each clause narrows the candidates
+	// left by the previous one.
+	n := float64(len(s.docs))
+	var candidates map[*searchDoc]*scored
+	for i, clause := range clauses {
+		matches := s.clauseMatches(clause)
+
+		docs := make(map[*searchDoc]bool)
+		for ref := range matches {
+			docs[ref.doc] = true
+		}
+		idf := math.Log(1 + n/float64(max(len(docs), 1)))
+
+		next := make(map[*searchDoc]*scored)
+		for ref, positions := range matches {
+			if !q.matches(ref.doc) {
+				continue
+			}
+
+			sc, ok := next[ref.doc]
+			switch {
+			case ok:
+
+			case i == 0:
+				sc = &scored{doc: ref.doc}
+
+			default:
+				sc, ok = candidates[ref.doc]
+				if !ok {
+					continue
+				}
+			}
+
+			weight := ref.doc.fields[ref.field].weight
+			tf := 1 + math.Log(float64(len(positions)))
+			score := weight * tf * idf
+			sc.score += score
+			if score > sc.bestScore {
+				sc.best, sc.bestPos, sc.bestScore = ref,
+					positions[0], score
+			}
+			next[ref.doc] = sc
+		}
+		candidates = next
+	}
+
+	ranked := make([]*scored, 0, len(candidates))
+	for _, sc := range candidates {
+		ranked = append(ranked, sc)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].doc.time.After(ranked[j].doc.time)
+	})
+
+	terms := make(map[string]bool)
+	for _, clause := range clauses {
+		for _, term := range clause {
+			terms[term] = true
+		}
+	}
+
+	results := SearchResults{
+		Query:   q.Text,
+		Total:   len(ranked),
+		Results: make([]SearchResult, 0, min(len(ranked), limit)),
+	}
+	for _, sc := range ranked[:min(len(ranked), limit)] {
+		doc := sc.doc
+		field := doc.fields[sc.best.field]
+		snippet, highlights := makeSnippet(
+			field.text, sc.bestPos, terms,
+		)
+
+		results.Results = append(results.Results, SearchResult{
+			Kind:        doc.kind,
+			SessionID:   doc.sessionID,
+			TaskID:      doc.taskID,
+			Title:       doc.title,
+			URL:         doc.url,
+			Status:      doc.status,
+			ProjectName: doc.projectName,
+			ProjectDir:  doc.projectDir,
+			Branch:      doc.branch,
+			Time:        doc.time,
+			Score:       math.Round(sc.score*1000) / 1000,
+			Field:       field.name,
+			Snippet:     snippet,
+			Highlights:  highlights,
+		})
+	}
+
+	return results, nil
+}
+
+// makeSnippet returns an excerpt of text around the token at position pos,
+// with the byte ranges of every query term within it.
+func makeSnippet(text string, pos int,
+	terms map[string]bool) (string, []SearchRange) {
+
+	spans := tokenize(text)
+	if pos >= len(spans) {
+		return "", nil
+	}
+
+	// Start and end the excerpt on word boundaries.
+	start, end := 0, len(text)
+	for i := pos; i >= 0; i-- {
+		if spans[pos].start-spans[i].start > snippetContext {
+			break
+		}
+		start = spans[i].start
+	}
+	if pos == 0 || spans[pos].start <= snippetContext {
+		start = 0
+	}
+	for i := pos; i < len(spans); i++ {
+		if spans[i].end-spans[pos].end > 2*snippetContext {
+			break
+		}
+		end = spans[i].end
+	}
+	if len(text)-end <= snippetContext/4 {
+		end = len(text)
+	}
+
+	var prefix, suffix string
+	if start > 0 {
+		prefix = "… "
+	}
+	if end < len(text) {
+		suffix = " …"
+	}
+
+	// Collapse runs of whitespace, recording where each byte of the
+	// excerpt ends up so that the highlights can be mapped across.
+	excerpt := text[start:end]
+	offsets := make([]int, len(excerpt)+1)
+	b := strings.Builder{}
+	b.WriteString(prefix)
+	space := false
+	for i, r := range excerpt {
+		offsets[i] = b.Len()
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	offsets[len(excerpt)] = b.Len()
+	b.WriteString(suffix)
+
+	var highlights []SearchRange
+	for _, span := range spans {
+		if span.start < start || span.end > end || !terms[span.term] {
+			continue
+		}
+		highlights = append(highlights, SearchRange{
+			Start: offsets[span.start-start],
+			End:   offsets[span.end-start],
+		})
+	}
+
+	return b.String(), highlights
+}
+
+// parseSearchDate parses a YYYY-MM-DD date in local time.
+func parseSearchDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.ParseInLocation(usageDayFormat, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected "+
+			"YYYY-MM-DD", s)
+	}
+
+	return t, nil
+}
+
+// highlightSnippet renders a snippet as HTML with its highlighted ranges
+// wrapped in <mark>.
+func highlightSnippet(snippet string,
+	highlights []SearchRange) template.HTML {
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, hl := range highlights {
+		if hl.Start < last || hl.End > len(snippet) {
+			continue
+		}
+		mark := snippet[hl.Start:hl.End]
+		b.WriteString(template.HTMLEscapeString(snippet[last:hl.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(mark))
+		b.WriteString("</mark>")
+		last = hl.End
+	}
+	b.WriteString(template.HTMLEscapeString(snippet[last:]))
+
+	return template.HTML(b.String())
+}
+
+// parseSearchQuery reads a search from the q, project, branch, since, until,
+// kind and limit query parameters. Since and until are dates, and until is
+// inclusive. Kind may be repeated or comma separated.
+func parseSearchQuery(r *http.Request) (SearchQuery, error) {
+	params := r.URL.Query()
+	q := SearchQuery{
+		Text:    strings.TrimSpace(params.Get("q")),
+		Project: strings.TrimSpace(params.Get("project")),
+		Branch:  strings.TrimSpace(params.Get("branch")),
+	}
+
+	var err error
+	if q.Since, err = parseSearchDate(params.Get("since")); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseSearchDate(params.Get("until")); err != nil {
+		return q, err
+	}
+	if !q.Until.IsZero() {
+		q.Until = q.Until.AddDate(0, 0, 1)
+	}
+
+	for _, kinds := range params["kind"] {
+		for _, kind := range strings.Split(kinds, ",") {
+			switch kind {
+			case "":
+
+			case SearchKindSession, SearchKindTask,
+				SearchKindTranscript:
+
+				q.Kinds = append(q.Kinds, kind)
+
+			default:
+				return q, fmt.Errorf("unknown kind %q", kind)
+			}
+		}
+	}
+
+	if s := params.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > searchMaxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d",
+				searchMaxLimit)
+		}
+		q.Limit = n
+	}
+
+	return q, nil
+}
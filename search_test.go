@@ -0,0 +1,261 @@
+package taskviewer
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btclog/v2"
+)
+
+// TestTokenize checks that text is split into lowercase words with their
+// byte offsets.
+func TestTokenize(t *testing.T) {
+	long := strings.Repeat("é", searchMaxTokenLen)
+
+	tests := []struct {
+		name string
+		text string
+		want []tokenSpan
+	}{
+		{
+			name: "punctuation",
+			text: "Hello, World!",
+			want: []tokenSpan{{"hello", 0, 5}, {"world", 7, 12}},
+		},
+		{
+			name: "digits",
+			text: "v2 fix-123",
+			want: []tokenSpan{
+				{"v2", 0, 2}, {"fix", 3, 6}, {"123", 7, 10},
+			},
+		},
+		{
+			name: "multi-byte",
+			text: "Café ÜBER 日本語",
+			want: []tokenSpan{
+				{"café", 0, 5}, {"über", 6, 11},
+				{"日本語", 12, 21},
+			},
+		},
+		{
+			name: "long tokens",
+			text: strings.Repeat("a", searchMaxTokenLen+1) +
+				" ok " + long,
+			want: []tokenSpan{
+				{"ok", 66, 68},
+				{long, 69, 69 + len(long)},
+			},
+		},
+		{
+			name: "empty",
+			text: " \n\t… ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := tokenize(test.text)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestParseClauses checks that queries are split into words and quoted
+// phrases.
+func TestParseClauses(t *testing.T) {
+	tests := []struct {
+		query string
+		want  [][]string
+	}{
+		{
+			query: `fix "Event Bus" race`,
+			want: [][]string{
+				{"fix"}, {"event", "bus"}, {"race"},
+			},
+		},
+		{
+			query: `"unterminated phrase`,
+			want:  [][]string{{"unterminated", "phrase"}},
+		},
+		{
+			query: `a"b"c`,
+			want:  [][]string{{"a"}, {"b"}, {"c"}},
+		},
+		{
+			query: `"  " naïve`,
+			want:  [][]string{{"naïve"}},
+		},
+		{
+			query: `"" ,.`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			got := parseClauses(test.query)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestMakeSnippet checks snippet excerpts and that their highlights cover
+// exactly the matched words, including after multi-byte text and collapsed
+// whitespace.
+func TestMakeSnippet(t *testing.T) {
+	var words []string
+	for i := 0; i < 100; i++ {
+		words = append(words, fmt.Sprintf("wörd%d", i))
+	}
+	long := strings.Join(words, " ")
+
+	tests := []struct {
+		name       string
+		text       string
+		pos        int
+		terms      []string
+		want       string
+		wantPrefix string
+		wantSuffix string
+		wantMarks  []string
+	}{
+		{
+			name:      "multi-byte",
+			text:      "Naïve café search",
+			pos:       1,
+			terms:     []string{"café", "search"},
+			want:      "Naïve café search",
+			wantMarks: []string{"café", "search"},
+		},
+		{
+			name:      "whitespace",
+			text:      "日本  \n\n bar\tbaz",
+			pos:       1,
+			terms:     []string{"bar"},
+			want:      "日本 bar baz",
+			wantMarks: []string{"bar"},
+		},
+		{
+			name:       "excerpt",
+			text:       long,
+			pos:        50,
+			terms:      []string{"wörd50", "wörd51"},
+			wantPrefix: "… ",
+			wantSuffix: " …",
+			wantMarks:  []string{"wörd50", "wörd51"},
+		},
+		{
+			name: "out of range",
+			text: "one two",
+			pos:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			terms := make(map[string]bool)
+			for _, term := range test.terms {
+				terms[term] = true
+			}
+
+			snippet, highlights := makeSnippet(
+				test.text, test.pos, terms,
+			)
+			if test.want != "" && snippet != test.want {
+				t.Fatalf("got snippet %q, want %q", snippet,
+					test.want)
+			}
+			if !strings.HasPrefix(snippet, test.wantPrefix) ||
+				!strings.HasSuffix(snippet, test.wantSuffix) {
+
+				t.Fatalf("snippet %q not trimmed", snippet)
+			}
+			if !utf8.ValidString(snippet) {
+				t.Fatalf("snippet %q is not valid UTF-8",
+					snippet)
+			}
+
+			var marks []string
+			for _, hl := range highlights {
+				marks = append(marks, snippet[hl.Start:hl.End])
+			}
+			if !reflect.DeepEqual(marks, test.wantMarks) {
+				t.Fatalf("got highlights %q, want %q", marks,
+					test.wantMarks)
+			}
+		})
+	}
+}
+
+// TestSearchPhrases checks that phrases only match their words in order,
+// and that the snippet is taken at the phrase.
+func TestSearchPhrases(t *testing.T) {
+	s := NewSearchIndex(nil, nil, nil, nil, false, btclog.Disabled)
+
+	docs := map[string]string{
+		"ordered":  "Publish to the event bus, then notify",
+		"reversed": "The bus event was never published",
+		"apart":    "An event on the bus",
+		"unicode":  "Übersicht: der Ereignis-Bus läuft",
+	}
+	for key, text := range docs {
+		s.put(&searchDoc{
+			key:    key,
+			kind:   SearchKindTask,
+			title:  key,
+			fields: []searchField{{"description", text, 1}},
+		})
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{
+			query: `"event bus"`,
+			want:  []string{"ordered"},
+		},
+		{
+			query: `event bus`,
+			want:  []string{"apart", "ordered", "reversed"},
+		},
+		{
+			query: `"ereignis bus" übersicht`,
+			want:  []string{"unicode"},
+		},
+		{
+			query: `"bus event" publish`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			results, err := s.Search(SearchQuery{Text: test.query})
+			if err != nil {
+				t.Fatalf("unable to search: %v", err)
+			}
+
+			var got []string
+			for _, result := range results.Results {
+				got = append(got, result.Title)
+
+				// Short texts are shown whole.
+				if result.Snippet != docs[result.Title] {
+					t.Fatalf("got snippet %q",
+						result.Snippet)
+				}
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
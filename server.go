@@ -12,10 +12,18 @@ import (
 
 // Server is the main task viewer daemon that orchestrates all components.
 type Server struct {
-	cfg *Config
+	cfg   *Config
+	paths *StatePaths
 
-	httpServer *HTTPServer
-	taskStore  claudeagent.TaskStore
+	httpServer     *HTTPServer
+	taskStore      claudeagent.TaskStore
+	projectIndexer *ProjectIndexer
+	taskArchive    *TaskArchive
+	eventBus       *EventBus
+	watchdog       *Watchdog
+	notifier       *Notifier
+	searchIndex    *SearchIndex
+	federation     *Federation
 
 	started uint32
 	stopped uint32
@@ -31,41 +39,162 @@ func NewServer(cfg *Config, log btclog.Logger) (*Server, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	// Resolve claude base directory and tasks directory.
-	claudeDir, err := cfg.ResolveClaudeDir()
+	// Resolve every state directory from the same configuration so the
+	// task store, indexer and instance tracker agree on where to look.
+	paths, err := cfg.ResolvePaths()
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve claude dir: %w", err)
+		return nil, err
 	}
-
-	tasksDir, err := cfg.ResolveTasksDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve tasks dir: %w", err)
+	if err := paths.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid state directory: %w", err)
 	}
 
+	log.Infof("Using claude dir %s (projects: %s, tasks: %s)",
+		paths.ClaudeDir, paths.ProjectsDir, paths.TasksDir)
+
 	// Create file task store.
-	taskStore, err := claudeagent.NewFileTaskStore(tasksDir)
+	taskStore, err := claudeagent.NewFileTaskStore(paths.TasksDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create task store: %w", err)
 	}
 
+	// Create the project indexer. It is shared by every handler and kept
+	// current by watching the claude directory.
+	projectIndexer := NewProjectIndexer(paths.ProjectsDir, paths.TasksDir)
+
+	// Open the task list archive unless disabled. A nil archive is valid
+	// and simply holds nothing.
+	var taskArchive *TaskArchive
+	if !cfg.NoArchive {
+		archiveDir, err := cfg.ResolveArchiveDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve archive dir: %w",
+				err)
+		}
+
+		taskArchive, err = NewTaskArchive(
+			archiveDir, taskStore, projectIndexer, log,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Detect running Claude processes, and publish every change across
+	// task lists, the index and instances on a single event bus.
+	instanceTracker := NewInstanceTracker(projectIndexer, nil)
+	eventBus := NewEventBus(taskStore, projectIndexer, instanceTracker, log)
+
+	// Watch running sessions for agents that appear to hang, unless
+	// disabled. A nil watchdog is valid and flags nothing.
+	var watchdog *Watchdog
+	if !cfg.NoWatchdog {
+		watchdog = NewWatchdog(
+			WatchdogConfig{
+				TaskAge: cfg.StallTaskAge,
+				Idle:    cfg.StallIdle,
+			},
+			taskStore, projectIndexer, instanceTracker, taskArchive,
+			eventBus, paths.TasksDir, log,
+		)
+	}
+
+	// Send webhook notifications if any webhooks are configured. A nil
+	// notifier is valid and sends nothing.
+	webhooks, err := cfg.ResolveWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	var notifier *Notifier
+	if len(webhooks) > 0 {
+		notifier, err = NewNotifier(
+			webhooks, taskStore, projectIndexer, eventBus, log,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook config: %w", err)
+		}
+	}
+
+	// Index sessions and tasks, and optionally transcripts, for search.
+	searchIndex := NewSearchIndex(
+		taskStore, projectIndexer, taskArchive, eventBus,
+		cfg.SearchTranscripts, log,
+	)
+
+	// Share this machine's state with hubs, and merge the state of
+	// other machines on a hub. A nil federation is valid and shares
+	// nothing.
+	federationCfg, err := cfg.ResolveFederation()
+	if err != nil {
+		return nil, fmt.Errorf("invalid federation config: %w", err)
+	}
+	federation := NewFederation(
+		federationCfg, taskStore, projectIndexer, instanceTracker,
+		watchdog, log,
+	)
+	if federation != nil {
+		log.Infof("Federating as %s (agent: %v, hub: %v, peers: %d, "+
+			"pushing to: %d)", federationCfg.HostName,
+			federationCfg.Agent, federationCfg.Hub,
+			len(federationCfg.Peers), len(federationCfg.PushTo))
+	}
+
+	prices, err := cfg.ResolvePrices()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create HTTP server.
 	httpCfg := &HTTPConfig{
-		ListenAddr: cfg.ListenAddr,
-		ClaudeDir:  claudeDir,
-		TasksDir:   tasksDir,
-		DebugHTTP:  cfg.DebugHTTP,
+		ListenAddr:  cfg.ListenAddr,
+		ClaudeDir:   paths.ClaudeDir,
+		ProjectsDir: paths.ProjectsDir,
+		TasksDir:    paths.TasksDir,
+		DebugHTTP:   cfg.DebugHTTP,
+		Prices:      prices,
+		AllowWrites: cfg.AllowWrites,
+		Auth:        NewAuthenticator(cfg.AuthPassword, cfg.APITokens),
+		TLSHosts:    cfg.TLSHosts,
+	}
+	if cfg.TLS {
+		httpCfg.TLSCertPath, httpCfg.TLSKeyPath, err =
+			cfg.ResolveTLSPaths()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve TLS "+
+				"paths: %w", err)
+		}
+	}
+	if cfg.AllowWrites {
+		log.Warnf("Task editing is enabled: tasks can be changed " +
+			"from the dashboard and API")
 	}
-	httpServer, err := NewHTTPServer(httpCfg, taskStore, log)
+	if httpCfg.Auth == nil && !isLoopbackAddr(cfg.ListenAddr) {
+		log.Warnf("Listening on %s without authentication: anyone "+
+			"who can reach it can read every prompt and task; "+
+			"set --auth-password", cfg.ListenAddr)
+	}
+	httpServer, err := NewHTTPServer(
+		httpCfg, taskStore, projectIndexer, instanceTracker, taskArchive,
+		eventBus, watchdog, notifier, searchIndex, federation, log,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP server: %w", err)
 	}
 
 	return &Server{
-		cfg:        cfg,
-		httpServer: httpServer,
-		taskStore:  taskStore,
-		quit:       make(chan struct{}),
-		log:        log,
+		cfg:            cfg,
+		paths:          paths,
+		httpServer:     httpServer,
+		taskStore:      taskStore,
+		projectIndexer: projectIndexer,
+		taskArchive:    taskArchive,
+		eventBus:       eventBus,
+		watchdog:       watchdog,
+		notifier:       notifier,
+		searchIndex:    searchIndex,
+		federation:     federation,
+		quit:           make(chan struct{}),
+		log:            log,
 	}, nil
 }
 
@@ -77,12 +206,55 @@ func (s *Server) Start() error {
 
 	s.log.Info("Starting task viewer server")
 
+	// Build the project index before serving any requests.
+	if err := s.projectIndexer.Start(); err != nil {
+		return fmt.Errorf("failed to start project indexer: %w", err)
+	}
+
+	// Start archiving task lists as soon as the index is available.
+	if s.taskArchive != nil {
+		if err := s.taskArchive.Start(); err != nil {
+			return fmt.Errorf("failed to start task archive: %w", err)
+		}
+	}
+
+	if err := s.eventBus.Start(); err != nil {
+		return fmt.Errorf("failed to start event bus: %w", err)
+	}
+
+	if err := s.searchIndex.Start(); err != nil {
+		return fmt.Errorf("failed to start search index: %w", err)
+	}
+
+	if s.watchdog != nil {
+		if err := s.watchdog.Start(); err != nil {
+			return fmt.Errorf("failed to start watchdog: %w", err)
+		}
+	}
+
+	if s.notifier != nil {
+		if err := s.notifier.Start(); err != nil {
+			return fmt.Errorf("failed to start notifier: %w", err)
+		}
+	}
+
+	if s.federation != nil {
+		if err := s.federation.Start(); err != nil {
+			return fmt.Errorf("failed to start federation: %w", err)
+		}
+	}
+
 	// Start HTTP server.
 	if err := s.httpServer.Start(); err != nil {
 		return fmt.Errorf("failed to start HTTP server: %w", err)
 	}
 
-	s.log.Infof("Task viewer listening on %s", s.cfg.ListenAddr)
+	scheme := "http"
+	if s.cfg.TLS {
+		scheme = "https"
+	}
+	s.log.Infof("Task viewer listening on %s://%s", scheme,
+		s.cfg.ListenAddr)
 
 	return nil
 }
@@ -102,6 +274,42 @@ func (s *Server) Stop() error {
 		s.log.Errorf("Error stopping HTTP server: %v", err)
 	}
 
+	if s.federation != nil {
+		if err := s.federation.Stop(); err != nil {
+			s.log.Errorf("Error stopping federation: %v", err)
+		}
+	}
+
+	if s.notifier != nil {
+		if err := s.notifier.Stop(); err != nil {
+			s.log.Errorf("Error stopping notifier: %v", err)
+		}
+	}
+
+	if s.watchdog != nil {
+		if err := s.watchdog.Stop(); err != nil {
+			s.log.Errorf("Error stopping watchdog: %v", err)
+		}
+	}
+
+	if err := s.searchIndex.Stop(); err != nil {
+		s.log.Errorf("Error stopping search index: %v", err)
+	}
+
+	if err := s.eventBus.Stop(); err != nil {
+		s.log.Errorf("Error stopping event bus: %v", err)
+	}
+
+	if s.taskArchive != nil {
+		if err := s.taskArchive.Stop(); err != nil {
+			s.log.Errorf("Error stopping task archive: %v", err)
+		}
+	}
+
+	if err := s.projectIndexer.Stop(); err != nil {
+		s.log.Errorf("Error stopping project indexer: %v", err)
+	}
+
 	s.wg.Wait()
 
 	s.log.Info("Task viewer server stopped")
@@ -52,6 +52,8 @@ function renderGraph(g, data, width, height) {
         return;
     }
 
+    describeAnalysis(data.analysis);
+
     // Create force simulation.
     const simulation = d3.forceSimulation(data.nodes)
         .force('link', d3.forceLink(data.edges)
@@ -67,7 +69,13 @@ function renderGraph(g, data, width, height) {
         .data(data.edges)
         .enter()
         .append('path')
-        .attr('class', 'graph-edge');
+        .attr('class', d => {
+            let cls = 'graph-edge';
+            if (d.satisfied) cls += ' satisfied';
+            if (d.critical) cls += ' critical';
+            return cls;
+        })
+        .attr('marker-end', 'url(#arrowhead)');
 
     // Draw nodes.
     const nodes = g.append('g')
@@ -78,6 +86,9 @@ function renderGraph(g, data, width, height) {
         .attr('class', d => {
             let cls = `graph-node status-${d.status}`;
             if (d.isBlocked) cls += ' blocked';
+            if (d.isReady) cls += ' ready';
+            if (d.critical) cls += ' critical';
+            if (d.inCycle) cls += ' cyclic';
             return cls;
         })
         .call(d3.drag()
@@ -96,7 +107,7 @@ function renderGraph(g, data, width, height) {
 
     // Tooltips.
     nodes.append('title')
-        .text(d => `${d.label}\n${d.description || ''}`);
+        .text(d => `${d.label}\n${describeNode(d)}\n${d.description || ''}`);
 
     // Click to navigate.
     nodes.on('click', (event, d) => {
@@ -133,3 +144,34 @@ function renderGraph(g, data, width, height) {
         d.fy = null;
     }
 }
+
+// describeNode summarizes a node's place in the dependency analysis.
+function describeNode(d) {
+    if (d.inCycle) return 'In a dependency cycle';
+    if (d.status === 'completed') return 'Completed';
+    let text = d.isBlocked ? 'Blocked' : (d.isReady ? 'Ready to start' : 'In progress');
+    if (d.wave >= 0) text += `, wave ${d.wave + 1}`;
+    if (d.critical) text += ', on the critical path';
+    return text;
+}
+
+// describeAnalysis shows the ready set and any problems above the graph.
+function describeAnalysis(analysis) {
+    const info = document.querySelector('.graph-info');
+    if (!info || !analysis) return;
+
+    const parts = [`${analysis.ready.length} ready`];
+    if (analysis.criticalPath.length > 0) {
+        parts.push(`critical path of ${analysis.criticalPath.length}`);
+    }
+    if (analysis.cycles.length > 0) {
+        parts.push(`${analysis.cycles.length} cycle${analysis.cycles.length > 1 ? 's' : ''}`);
+    }
+    if (analysis.dangling.length > 0) {
+        const missing = analysis.dangling.map(r => `#${r.missing}`).join(', ');
+        parts.push(`missing ${missing}`);
+    }
+    info.textContent = parts.join(' · ');
+    info.classList.toggle('graph-info-warning',
+        analysis.cycles.length > 0 || analysis.dangling.length > 0);
+}
@@ -0,0 +1,12 @@
+//go:build darwin
+
+package taskviewer
+
+import "syscall"
+
+// ioctlGetTermios and ioctlSetTermios read and write the attributes of a
+// terminal.
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
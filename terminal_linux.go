@@ -0,0 +1,12 @@
+//go:build linux
+
+package taskviewer
+
+import "syscall"
+
+// ioctlGetTermios and ioctlSetTermios read and write the attributes of a
+// terminal.
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
@@ -0,0 +1,23 @@
+//go:build !linux && !darwin
+
+package taskviewer
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoTerminal is returned on platforms where the terminal UI cannot put
+// the terminal into raw mode.
+var errNoTerminal = errors.New("the terminal UI is not supported on " +
+	"this platform")
+
+// makeRaw always fails on this platform.
+func makeRaw(*os.File) (func(), error) {
+	return nil, errNoTerminal
+}
+
+// terminalSize always fails on this platform.
+func terminalSize(*os.File) (int, int, error) {
+	return 0, 0, errNoTerminal
+}
@@ -0,0 +1,67 @@
+//go:build linux || darwin
+
+package taskviewer
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize mirrors the kernel's struct winsize.
+type winsize struct {
+	rows   uint16
+	cols   uint16
+	xPixel uint16
+	yPixel uint16
+}
+
+// makeRaw puts a terminal into raw mode, so that keys are read as they are
+// pressed, without echo or signals, and returns a function that restores
+// the previous mode.
+func makeRaw(f *os.File) (func(), error) {
+	var old syscall.Termios
+	err := ioctl(f.Fd(), ioctlGetTermios, unsafe.Pointer(&old))
+	if err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK |
+		syscall.ISTRIP | syscall.IXON
+	raw.Cflag |= syscall.CS8
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN |
+		syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	err = ioctl(f.Fd(), ioctlSetTermios, unsafe.Pointer(&raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = ioctl(f.Fd(), ioctlSetTermios, unsafe.Pointer(&old))
+	}, nil
+}
+
+// terminalSize returns the width and height of a terminal in cells.
+func terminalSize(f *os.File) (int, int, error) {
+	var ws winsize
+	err := ioctl(f.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(ws.cols), int(ws.rows), nil
+}
+
+// ioctl issues an ioctl request on a file descriptor.
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,519 @@
+package taskviewer
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// burndownWidth and burndownHeight are the dimensions of the
+	// burndown chart's SVG view box.
+	burndownWidth  = 600
+	burndownHeight = 120
+
+	// minThroughputWindow is the shortest period a throughput figure is
+	// reported for, since rates over a few seconds are meaningless.
+	minThroughputWindow = time.Minute
+)
+
+// TaskTiming reports how long a task has spent in each status.
+type TaskTiming struct {
+	TaskID  string                     `json:"taskId"`
+	Subject string                     `json:"subject"`
+	Status  claudeagent.TaskListStatus `json:"status"`
+
+	// Since is when the task entered its current status.
+	Since time.Time `json:"since"`
+
+	// InStatus is the number of seconds spent in each status so far,
+	// including the current one.
+	InStatus map[claudeagent.TaskListStatus]float64 `json:"inStatusSeconds"`
+
+	// CreatedAt is when the task was first seen, StartedAt when it first
+	// went in progress, and CompletedAt when it was last completed.
+	CreatedAt   time.Time `json:"createdAt,omitzero"`
+	StartedAt   time.Time `json:"startedAt,omitzero"`
+	CompletedAt time.Time `json:"completedAt,omitzero"`
+
+	// CycleSeconds is the time from StartedAt to CompletedAt, and
+	// LeadSeconds the time from CreatedAt to CompletedAt.
+	CycleSeconds float64 `json:"cycleSeconds,omitempty"`
+	LeadSeconds  float64 `json:"leadSeconds,omitempty"`
+
+	// Estimated is true if the task has no recorded history and its
+	// timing was inferred from its file's modification time.
+	Estimated bool `json:"estimated"`
+}
+
+// CurrentSeconds returns the number of seconds spent in the current status.
+func (t TaskTiming) CurrentSeconds() float64 {
+	return t.InStatus[t.Status]
+}
+
+// BurndownPoint is the state of a list at a point in time.
+type BurndownPoint struct {
+	Time      time.Time `json:"time"`
+	Total     int       `json:"total"`
+	Completed int       `json:"completed"`
+	Remaining int       `json:"remaining"`
+}
+
+// ListTiming reports the pace at which a list is being worked through.
+type ListTiming struct {
+	ListID string       `json:"listId"`
+	Tasks  []TaskTiming `json:"tasks"`
+
+	// FirstSeen is the earliest observation of the list.
+	FirstSeen time.Time `json:"firstSeen,omitzero"`
+
+	// Done is true once every task is completed, and CompletedAt is when
+	// the last one was.
+	Done        bool      `json:"done"`
+	CompletedAt time.Time `json:"completedAt,omitzero"`
+
+	// ElapsedSeconds is the time from FirstSeen until the list was done,
+	// or until now if it is not.
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+
+	// TimeToCompleteSeconds is the time from FirstSeen until the list
+	// was done. It is zero while tasks remain.
+	TimeToCompleteSeconds float64 `json:"timeToCompleteSeconds,omitempty"`
+
+	// CompletedPerHour is the rate at which tasks were completed over the
+	// elapsed time.
+	CompletedPerHour float64 `json:"completedPerHour"`
+
+	// MeanCycleSeconds is the average time from a task going in progress
+	// to it being completed.
+	MeanCycleSeconds float64 `json:"meanCycleSeconds,omitempty"`
+
+	// Burndown holds the list's state after every observed change.
+	Burndown []BurndownPoint `json:"burndown"`
+
+	// Estimated is true if any figure was inferred from file
+	// modification times.
+	Estimated bool `json:"estimated"`
+}
+
+// taskClock tracks a task's status over its recorded history.
+type taskClock struct {
+	status      claudeagent.TaskListStatus
+	since       time.Time
+	inStatus    map[claudeagent.TaskListStatus]float64
+	createdAt   time.Time
+	startedAt   time.Time
+	completedAt time.Time
+}
+
+// newTaskClock starts a clock for a task first seen at the given time.
+func newTaskClock(status claudeagent.TaskListStatus,
+	at time.Time) *taskClock {
+
+	c := &taskClock{
+		since:     at,
+		inStatus:  make(map[claudeagent.TaskListStatus]float64),
+		createdAt: at,
+	}
+	c.enter(status, at)
+
+	return c
+}
+
+// enter moves the task into a new status at the given time.
+func (c *taskClock) enter(status claudeagent.TaskListStatus, at time.Time) {
+	if c.status != "" && at.After(c.since) {
+		c.inStatus[c.status] += at.Sub(c.since).Seconds()
+	}
+	c.status = status
+	c.since = at
+
+	switch status {
+	case claudeagent.TaskListStatusInProgress:
+		if c.startedAt.IsZero() {
+			c.startedAt = at
+		}
+		c.completedAt = time.Time{}
+
+	case claudeagent.TaskListStatusCompleted:
+		c.completedAt = at
+
+	default:
+		c.completedAt = time.Time{}
+	}
+}
+
+// computeListTiming derives the timing of a list's current tasks from its
+// recorded history, oldest first. Tasks without history fall back to the
+// modification times of their files. Time in the current status accrues
+// until the given time.
+func computeListTiming(listID string, tasks []claudeagent.TaskListItem,
+	history []TaskChange, modTimes map[string]time.Time,
+	until time.Time) ListTiming {
+
+	timing := ListTiming{
+		ListID:   listID,
+		Tasks:    make([]TaskTiming, 0, len(tasks)),
+		Burndown: []BurndownPoint{},
+	}
+
+	// Replay the history, recording the list's state after each batch
+	// of changes observed at the same time.
+	clocks := make(map[string]*taskClock)
+	for i, change := range history {
+		switch change.Kind {
+		case ChangeCreated:
+			clocks[change.TaskID] = newTaskClock(
+				change.Status, change.Time,
+			)
+
+		case ChangeUpdated:
+			clock, ok := clocks[change.TaskID]
+			if !ok {
+				clocks[change.TaskID] = newTaskClock(
+					change.Status, change.Time,
+				)
+				break
+			}
+			if change.Status != clock.status {
+				clock.enter(change.Status, change.Time)
+			}
+
+		case ChangeDeleted:
+			delete(clocks, change.TaskID)
+		}
+
+		last := i == len(history)-1
+		if last || !history[i+1].Time.Equal(change.Time) {
+			point := burndownPoint(change.Time, clocks)
+			timing.Burndown = append(timing.Burndown, point)
+		}
+	}
+	if len(history) > 0 {
+		timing.FirstSeen = history[0].Time
+	}
+
+	// Fall back to file modification times for the burndown if nothing
+	// was recorded.
+	if len(history) == 0 && len(modTimes) > 0 {
+		timing.Burndown = modTimeBurndown(tasks, modTimes)
+		timing.FirstSeen = timing.Burndown[0].Time
+		timing.Estimated = true
+	}
+
+	var (
+		completed  int
+		cycleTotal float64
+		cycleCount int
+	)
+	for _, task := range tasks {
+		clock, ok := clocks[task.ID]
+		estimated := !ok
+		if !ok {
+			// Without history the file's last change is the best
+			// guess at when the task entered its status.
+			at, ok := modTimes[task.ID]
+			if !ok || at.After(until) {
+				at = until
+			}
+			clock = newTaskClock(task.Status, at)
+			clock.createdAt = time.Time{}
+			clock.startedAt = time.Time{}
+		} else if clock.status != task.Status {
+			// The live task may be ahead of the recorded history.
+			at := until
+			mt, ok := modTimes[task.ID]
+			if ok && mt.After(clock.since) {
+				at = mt
+			}
+			clock.enter(task.Status, at)
+		}
+
+		// Accrue the current status without mutating the clock.
+		inStatus := maps.Clone(clock.inStatus)
+		if until.After(clock.since) {
+			current := until.Sub(clock.since).Seconds()
+			inStatus[clock.status] += current
+		}
+
+		tt := TaskTiming{
+			TaskID:      task.ID,
+			Subject:     task.Subject,
+			Status:      task.Status,
+			Since:       clock.since,
+			InStatus:    inStatus,
+			CreatedAt:   clock.createdAt,
+			StartedAt:   clock.startedAt,
+			CompletedAt: clock.completedAt,
+			Estimated:   estimated,
+		}
+		if !tt.CompletedAt.IsZero() {
+			completed++
+			if tt.CompletedAt.After(timing.CompletedAt) {
+				timing.CompletedAt = tt.CompletedAt
+			}
+			if !tt.StartedAt.IsZero() {
+				tt.CycleSeconds = tt.CompletedAt.Sub(
+					tt.StartedAt,
+				).Seconds()
+				cycleTotal += tt.CycleSeconds
+				cycleCount++
+			}
+			if !tt.CreatedAt.IsZero() {
+				tt.LeadSeconds = tt.CompletedAt.Sub(
+					tt.CreatedAt,
+				).Seconds()
+			}
+		}
+
+		timing.Estimated = timing.Estimated || estimated
+		timing.Tasks = append(timing.Tasks, tt)
+	}
+
+	timing.Done = len(tasks) > 0 && completed == len(tasks)
+	if !timing.Done {
+		timing.CompletedAt = time.Time{}
+	}
+	if cycleCount > 0 {
+		timing.MeanCycleSeconds = cycleTotal / float64(cycleCount)
+	}
+
+	if !timing.FirstSeen.IsZero() {
+		end := until
+		if timing.Done {
+			end = timing.CompletedAt
+		}
+		if end.After(timing.FirstSeen) {
+			elapsed := end.Sub(timing.FirstSeen)
+			timing.ElapsedSeconds = elapsed.Seconds()
+			if timing.Done {
+				timing.TimeToCompleteSeconds = elapsed.Seconds()
+			}
+			if elapsed >= minThroughputWindow {
+				timing.CompletedPerHour = float64(completed) /
+					elapsed.Hours()
+			}
+		}
+	}
+
+	return timing
+}
+
+// burndownPoint summarizes the tracked tasks at a point in time.
+func burndownPoint(at time.Time, clocks map[string]*taskClock) BurndownPoint {
+	point := BurndownPoint{Time: at, Total: len(clocks)}
+	for _, clock := range clocks {
+		if clock.status == claudeagent.TaskListStatusCompleted {
+			point.Completed++
+		}
+	}
+	point.Remaining = point.Total - point.Completed
+
+	return point
+}
+
+// modTimeBurndown approximates a burndown from task file modification times,
+// treating each completed task's last change as its completion. It must only
+// be called with at least one modification time.
+func modTimeBurndown(tasks []claudeagent.TaskListItem,
+	modTimes map[string]time.Time) []BurndownPoint {
+
+	var times []time.Time
+	for _, at := range modTimes {
+		times = append(times, at)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	var points []BurndownPoint
+	for i, at := range times {
+		if i > 0 && at.Equal(times[i-1]) {
+			continue
+		}
+
+		point := BurndownPoint{Time: at, Total: len(tasks)}
+		for _, task := range tasks {
+			if task.Status != claudeagent.TaskListStatusCompleted {
+				continue
+			}
+			if mt, ok := modTimes[task.ID]; ok && !mt.After(at) {
+				point.Completed++
+			}
+		}
+		point.Remaining = point.Total - point.Completed
+		points = append(points, point)
+	}
+
+	return points
+}
+
+// taskModTimes returns the modification time of every task file in a list's
+// task directory, keyed by task ID. A missing directory yields no times.
+func taskModTimes(taskDir string) map[string]time.Time {
+	entries, err := os.ReadDir(taskDir)
+	if err != nil {
+		return nil
+	}
+
+	modTimes := make(map[string]time.Time, len(entries))
+	for _, e := range entries {
+		taskID, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		modTimes[taskID] = info.ModTime()
+	}
+
+	return modTimes
+}
+
+// loadListTiming computes the timing of a list from the archive's history
+// and the list's task files. The timing is still computed, from the task
+// files alone, if the history cannot be read.
+func loadListTiming(archive *TaskArchive, tasksDir, listID string,
+	tasks []claudeagent.TaskListItem, archived bool) (ListTiming, error) {
+
+	history, err := archive.History(listID)
+
+	// An archived list stopped changing when it was last snapshotted.
+	until := time.Now()
+	if snapshot, ok := archive.Get(listID); ok && archived {
+		until = snapshot.SnapshotAt
+	}
+
+	var modTimes map[string]time.Time
+	if validListID(listID) {
+		modTimes = taskModTimes(filepath.Join(tasksDir, listID))
+	}
+
+	timing := computeListTiming(listID, tasks, history, modTimes, until)
+
+	return timing, err
+}
+
+// listTiming computes the timing of a list, logging rather than failing if
+// its history is unreadable.
+func (h *HTTPServer) listTiming(listID string,
+	tasks []claudeagent.TaskListItem, archived bool) ListTiming {
+
+	timing, err := loadListTiming(
+		h.taskArchive, h.cfg.TasksDir, listID, tasks, archived,
+	)
+	if err != nil {
+		h.log.Warnf("Unable to load history of %s: %v", listID, err)
+	}
+
+	return timing
+}
+
+// formatSeconds formats a duration in seconds compactly, e.g. "45s",
+// "12m" or "3h 5m".
+func formatSeconds(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()),
+			int(d.Minutes())%60)
+
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24,
+			int(d.Hours())%24)
+	}
+}
+
+// BurndownChart holds the geometry of a burndown chart for rendering as an
+// SVG polyline.
+type BurndownChart struct {
+	Width  int
+	Height int
+
+	// Remaining and Completed are polyline point lists.
+	Remaining string
+	Completed string
+
+	Start time.Time
+	End   time.Time
+	Max   int
+}
+
+// newBurndownChart scales a burndown series into the chart's view box,
+// extending it as a step line up to end. It returns nil if there is nothing
+// to draw.
+func newBurndownChart(points []BurndownPoint, end time.Time) *BurndownChart {
+	if len(points) == 0 {
+		return nil
+	}
+
+	start := points[0].Time
+	if !end.After(start) {
+		end = start.Add(time.Minute)
+	}
+
+	maxTotal := 1
+	for _, p := range points {
+		maxTotal = max(maxTotal, p.Total)
+	}
+
+	x := func(at time.Time) float64 {
+		return float64(burndownWidth) * at.Sub(start).Seconds() /
+			end.Sub(start).Seconds()
+	}
+	y := func(n int) float64 {
+		return float64(burndownHeight) *
+			(1 - float64(n)/float64(maxTotal))
+	}
+
+	// Each series is drawn as a step line, holding its previous value
+	// until the next change.
+	polyline := func(value func(BurndownPoint) int) string {
+		var coords []string
+		point := func(px, py float64) {
+			coords = append(coords, fmt.Sprintf("%.1f,%.1f", px, py))
+		}
+		for i, p := range points {
+			if i > 0 {
+				point(x(p.Time), y(value(points[i-1])))
+			}
+			point(x(p.Time), y(value(p)))
+		}
+		point(x(end), y(value(points[len(points)-1])))
+
+		return strings.Join(coords, " ")
+	}
+
+	remaining := polyline(func(p BurndownPoint) int {
+		return p.Remaining
+	})
+	completed := polyline(func(p BurndownPoint) int {
+		return p.Completed
+	})
+
+	return &BurndownChart{
+		Width:     burndownWidth,
+		Height:    burndownHeight,
+		Remaining: remaining,
+		Completed: completed,
+		Start:     start,
+		End:       end,
+		Max:       maxTotal,
+	}
+}
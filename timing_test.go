@@ -0,0 +1,256 @@
+package taskviewer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// Shorthands for the task statuses.
+const (
+	pending    = claudeagent.TaskListStatusPending
+	inProgress = claudeagent.TaskListStatusInProgress
+	completed  = claudeagent.TaskListStatusCompleted
+)
+
+// statusSeconds holds the seconds a task spent in each status.
+type statusSeconds = map[claudeagent.TaskListStatus]float64
+
+// testChange returns a recorded change of a task at an offset from t0.
+func testChange(t0 time.Time, offset time.Duration, kind, taskID string,
+	status claudeagent.TaskListStatus) TaskChange {
+
+	return TaskChange{
+		Time:   t0.Add(offset),
+		ListID: "s1",
+		TaskID: taskID,
+		Kind:   kind,
+		Status: status,
+	}
+}
+
+// withStatus returns a test task in the given status.
+func withStatus(id string,
+	status claudeagent.TaskListStatus) claudeagent.TaskListItem {
+
+	task := testTask(id)
+	task.Status = status
+
+	return task
+}
+
+// TestComputeListTiming checks time in status, cycle and lead times, and
+// the burndown replayed from a list's history.
+func TestComputeListTiming(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := time.Minute
+
+	tests := []struct {
+		name     string
+		tasks    []claudeagent.TaskListItem
+		history  []TaskChange
+		modTimes map[string]time.Time
+		until    time.Time
+
+		wantInStatus  map[string]statusSeconds
+		wantCycle     map[string]float64
+		wantBurndown  []BurndownPoint
+		wantDone      bool
+		wantElapsed   float64
+		wantPerHour   float64
+		wantMeanCycle float64
+		wantEstimated bool
+	}{
+		{
+			name: "in progress",
+			tasks: []claudeagent.TaskListItem{
+				withStatus("1", completed),
+				withStatus("2", inProgress),
+			},
+			history: []TaskChange{
+				testChange(t0, 0, ChangeCreated, "1", pending),
+				testChange(t0, 0, ChangeCreated, "2", pending),
+				testChange(t0, 10*m, ChangeUpdated, "1",
+					inProgress),
+				testChange(t0, 30*m, ChangeUpdated, "1",
+					completed),
+				testChange(t0, 40*m, ChangeUpdated, "2",
+					inProgress),
+			},
+			until: t0.Add(60 * m),
+			wantInStatus: map[string]statusSeconds{
+				"1": {pending: 600, inProgress: 1200,
+					completed: 1800},
+				"2": {pending: 2400, inProgress: 1200},
+			},
+			wantCycle: map[string]float64{"1": 1200},
+			wantBurndown: []BurndownPoint{
+				{Time: t0, Total: 2, Remaining: 2},
+				{Time: t0.Add(10 * m), Total: 2, Remaining: 2},
+				{Time: t0.Add(30 * m), Total: 2, Completed: 1,
+					Remaining: 1},
+				{Time: t0.Add(40 * m), Total: 2, Completed: 1,
+					Remaining: 1},
+			},
+			wantElapsed:   3600,
+			wantPerHour:   1,
+			wantMeanCycle: 1200,
+		},
+		{
+			name: "done",
+			tasks: []claudeagent.TaskListItem{
+				withStatus("1", completed),
+				withStatus("2", completed),
+			},
+			history: []TaskChange{
+				testChange(t0, 0, ChangeCreated, "1",
+					inProgress),
+				testChange(t0, 0, ChangeCreated, "2",
+					inProgress),
+				testChange(t0, 0, ChangeCreated, "3", pending),
+				testChange(t0, 20*m, ChangeUpdated, "1",
+					completed),
+				testChange(t0, 20*m, ChangeDeleted, "3",
+					pending),
+				testChange(t0, 40*m, ChangeUpdated, "2",
+					completed),
+			},
+			until: t0.Add(120 * m),
+			wantInStatus: map[string]statusSeconds{
+				"1": {inProgress: 1200, completed: 6000},
+				"2": {inProgress: 2400, completed: 4800},
+			},
+			wantCycle: map[string]float64{"1": 1200, "2": 2400},
+			wantBurndown: []BurndownPoint{
+				{Time: t0, Total: 3, Remaining: 3},
+				{Time: t0.Add(20 * m), Total: 2, Completed: 1,
+					Remaining: 1},
+				{Time: t0.Add(40 * m), Total: 2, Completed: 2},
+			},
+			wantDone:      true,
+			wantElapsed:   2400,
+			wantPerHour:   3,
+			wantMeanCycle: 1800,
+		},
+		{
+			// The live task completed after the last snapshot, so
+			// its file's modification time marks the change.
+			name: "live ahead of history",
+			tasks: []claudeagent.TaskListItem{
+				withStatus("1", completed),
+			},
+			history: []TaskChange{
+				testChange(t0, 0, ChangeCreated, "1",
+					inProgress),
+			},
+			modTimes: map[string]time.Time{"1": t0.Add(15 * m)},
+			until:    t0.Add(30 * m),
+			wantInStatus: map[string]statusSeconds{
+				"1": {inProgress: 900, completed: 900},
+			},
+			wantCycle: map[string]float64{"1": 900},
+			wantBurndown: []BurndownPoint{
+				{Time: t0, Total: 1, Remaining: 1},
+			},
+			wantDone:      true,
+			wantElapsed:   900,
+			wantPerHour:   4,
+			wantMeanCycle: 900,
+		},
+		{
+			name: "modification times",
+			tasks: []claudeagent.TaskListItem{
+				withStatus("1", completed),
+				withStatus("2", pending),
+			},
+			modTimes: map[string]time.Time{
+				"1": t0.Add(10 * m),
+				"2": t0,
+			},
+			until: t0.Add(30 * m),
+			wantInStatus: map[string]statusSeconds{
+				"1": {completed: 1200},
+				"2": {pending: 1800},
+			},
+			wantBurndown: []BurndownPoint{
+				{Time: t0, Total: 2, Remaining: 2},
+				{Time: t0.Add(10 * m), Total: 2, Completed: 1,
+					Remaining: 1},
+			},
+			wantElapsed:   1800,
+			wantPerHour:   2,
+			wantEstimated: true,
+		},
+		{
+			name:         "empty",
+			until:        t0,
+			wantInStatus: map[string]statusSeconds{},
+			wantBurndown: []BurndownPoint{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timing := computeListTiming(
+				"s1", test.tasks, test.history, test.modTimes,
+				test.until,
+			)
+
+			inStatus := make(map[string]statusSeconds)
+			cycle := make(map[string]float64)
+			for _, tt := range timing.Tasks {
+				inStatus[tt.TaskID] = tt.InStatus
+				if tt.CycleSeconds != 0 {
+					cycle[tt.TaskID] = tt.CycleSeconds
+				}
+				if tt.Estimated != test.wantEstimated {
+					t.Fatalf("task %s estimated = %v",
+						tt.TaskID, tt.Estimated)
+				}
+			}
+			if test.wantCycle == nil {
+				test.wantCycle = map[string]float64{}
+			}
+
+			if !reflect.DeepEqual(inStatus, test.wantInStatus) {
+				t.Fatalf("time in status %v, want %v",
+					inStatus, test.wantInStatus)
+			}
+			if !reflect.DeepEqual(cycle, test.wantCycle) {
+				t.Fatalf("cycle times %v, want %v", cycle,
+					test.wantCycle)
+			}
+			if !reflect.DeepEqual(
+				timing.Burndown, test.wantBurndown,
+			) {
+
+				t.Fatalf("burndown %+v, want %+v",
+					timing.Burndown, test.wantBurndown)
+			}
+			if timing.Done != test.wantDone {
+				t.Fatalf("done = %v, want %v", timing.Done,
+					test.wantDone)
+			}
+			if timing.ElapsedSeconds != test.wantElapsed {
+				t.Fatalf("elapsed %v, want %v",
+					timing.ElapsedSeconds, test.wantElapsed)
+			}
+			if timing.CompletedPerHour != test.wantPerHour {
+				t.Fatalf("throughput %v, want %v",
+					timing.CompletedPerHour,
+					test.wantPerHour)
+			}
+			if timing.MeanCycleSeconds != test.wantMeanCycle {
+				t.Fatalf("mean cycle %v, want %v",
+					timing.MeanCycleSeconds,
+					test.wantMeanCycle)
+			}
+			if timing.Estimated != test.wantEstimated {
+				t.Fatalf("estimated = %v, want %v",
+					timing.Estimated, test.wantEstimated)
+			}
+		})
+	}
+}
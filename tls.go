@@ -0,0 +1,147 @@
+package taskviewer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tlsCertValidity is how long a generated certificate is valid for.
+const tlsCertValidity = 365 * 24 * time.Hour
+
+// loadOrCreateTLSCert loads the certificate and key at the given paths. If
+// neither file exists, or the certificate has expired, a self-signed pair
+// is generated for localhost, the machine's host name and the extra hosts,
+// and written to the paths first.
+func loadOrCreateTLSCert(certPath, keyPath string,
+	hosts []string) (tls.Certificate, bool, error) {
+
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+
+	switch {
+	case os.IsNotExist(certErr) && os.IsNotExist(keyErr):
+
+	case certErr != nil:
+		return tls.Certificate{}, false, fmt.Errorf("failed to read "+
+			"TLS certificate: %w", certErr)
+
+	case keyErr != nil:
+		return tls.Certificate{}, false, fmt.Errorf("failed to read "+
+			"TLS key: %w", keyErr)
+
+	default:
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return tls.Certificate{}, false, fmt.Errorf(
+				"failed to load TLS key pair: %w", err,
+			)
+		}
+		if cert.Leaf == nil || time.Now().Before(cert.Leaf.NotAfter) {
+			return cert, false, nil
+		}
+	}
+
+	if err := generateTLSCert(certPath, keyPath, hosts); err != nil {
+		return tls.Certificate{}, false, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, false, fmt.Errorf("failed to load "+
+			"TLS key pair: %w", err)
+	}
+
+	return cert, true, nil
+}
+
+// generateTLSCert writes a self-signed certificate and its key. The
+// certificate is its own CA, so clients can trust it directly.
+func generateTLSCert(certPath, keyPath string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate TLS key: %w", err)
+	}
+
+	serialLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, serialLimit)
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"claude-task-viewer"},
+			CommonName:   "localhost",
+		},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(tlsCertValidity),
+		KeyUsage: x509.KeyUsageDigitalSignature |
+			x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+		},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses: []net.IP{
+			net.IPv4(127, 0, 0, 1), net.IPv6loopback,
+		},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, &template, &template, &key.PublicKey, key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create TLS certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode TLS key: %w", err)
+	}
+
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("failed to create TLS directory: %w",
+				err)
+		}
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: der,
+	})
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write TLS certificate: %w", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: keyDER,
+	})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to write TLS key: %w", err)
+	}
+
+	return nil
+}
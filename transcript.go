@@ -0,0 +1,412 @@
+package taskviewer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxToolOutput caps how much of a single tool output is kept, since
+	// tool results can embed entire files or base64 images.
+	maxToolOutput = 20000
+
+	// longOutputChars and longOutputLines decide when a block is long
+	// enough to be collapsed by default in the transcript view.
+	longOutputChars = 1500
+	longOutputLines = 20
+)
+
+// Transcript block kinds.
+const (
+	BlockText       = "text"
+	BlockThinking   = "thinking"
+	BlockToolUse    = "tool_use"
+	BlockToolResult = "tool_result"
+)
+
+// Transcript is a parsed session transcript ({sessionID}.jsonl).
+type Transcript struct {
+	SessionID string              `json:"sessionId"`
+	Path      string              `json:"path"`
+	Summary   string              `json:"summary,omitempty"`
+	CWD       string              `json:"cwd,omitempty"`
+	GitBranch string              `json:"gitBranch,omitempty"`
+	StartedAt time.Time           `json:"startedAt"`
+	EndedAt   time.Time           `json:"endedAt"`
+	Messages  []TranscriptMessage `json:"messages"`
+}
+
+// TranscriptMessage is a single user or assistant turn.
+type TranscriptMessage struct {
+	UUID        string            `json:"uuid"`
+	Role        string            `json:"role"`
+	Model       string            `json:"model,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+	IsSidechain bool              `json:"isSidechain,omitempty"`
+	Blocks      []TranscriptBlock `json:"blocks"`
+}
+
+// TranscriptBlock is one content block of a message. Tool results are folded
+// into the tool_use block that produced them.
+type TranscriptBlock struct {
+	Kind      string `json:"kind"`
+	Text      string `json:"text,omitempty"`
+	ToolName  string `json:"toolName,omitempty"`
+	ToolUseID string `json:"toolUseId,omitempty"`
+	ToolInput string `json:"toolInput,omitempty"`
+	Output    string `json:"output,omitempty"`
+	HasOutput bool   `json:"hasOutput,omitempty"`
+	IsError   bool   `json:"isError,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// isLongText reports whether s exceeds the collapse thresholds.
+func isLongText(s string) bool {
+	return len(s) > longOutputChars ||
+		strings.Count(s, "\n") > longOutputLines
+}
+
+// transcriptLine is the raw structure of a line in a session JSONL file.
+// Only the fields the viewer uses are decoded.
+type transcriptLine struct {
+	Type        string             `json:"type"`
+	UUID        string             `json:"uuid"`
+	ParentUUID  string             `json:"parentUuid"`
+	SessionID   string             `json:"sessionId"`
+	Timestamp   time.Time          `json:"timestamp"`
+	IsSidechain bool               `json:"isSidechain"`
+	IsMeta      bool               `json:"isMeta"`
+	CWD         string             `json:"cwd"`
+	GitBranch   string             `json:"gitBranch"`
+	Summary     string             `json:"summary"`
+	Message     *transcriptMessage `json:"message"`
+}
+
+// transcriptMessage is the API message embedded in a transcript line.
+type transcriptMessage struct {
+	ID      string          `json:"id"`
+	Role    string          `json:"role"`
+	Model   string          `json:"model"`
+	Content json.RawMessage `json:"content"`
+}
+
+// contentBlock is a raw API content block.
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// LoadTranscript reads and parses the transcript at path.
+func LoadTranscript(path string) (*Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := ParseTranscript(f)
+	if err != nil {
+		return nil, err
+	}
+	t.Path = path
+
+	return t, nil
+}
+
+// transcriptStartScanLines bounds how many lines transcriptStartTime reads
+// looking for a timestamp.
+const transcriptStartScanLines = 16
+
+// transcriptStartTime returns the timestamp of the first timestamped line of
+// the transcript at path, which is when its session was created.
+func transcriptStartTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for i := 0; i < transcriptStartScanLines; i++ {
+		line, err := reader.ReadBytes('\n')
+
+		var raw struct {
+			Timestamp time.Time `json:"timestamp"`
+		}
+		jsonErr := json.Unmarshal(line, &raw)
+		if jsonErr == nil && !raw.Timestamp.IsZero() {
+			return raw.Timestamp, nil
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return time.Time{}, errors.New("no timestamp in transcript")
+}
+
+// ParseTranscript parses a session transcript. Malformed lines are skipped,
+// since the file may be mid-write while the session is running.
+func ParseTranscript(r io.Reader) (*Transcript, error) {
+	p := &transcriptParser{
+		t:        &Transcript{},
+		toolUses: make(map[string]blockRef),
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		// Lines can be arbitrarily long (embedded files and images),
+		// so read whole lines rather than using a bounded Scanner.
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var raw transcriptLine
+			if jsonErr := json.Unmarshal(line, &raw); jsonErr == nil {
+				p.apply(&raw)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.t, nil
+}
+
+// blockRef locates a block within a transcript.
+type blockRef struct {
+	msg   int
+	block int
+}
+
+// transcriptParser accumulates transcript lines into a Transcript.
+type transcriptParser struct {
+	t *Transcript
+
+	// toolUses locates tool_use blocks by ID so that their results,
+	// which arrive in a later user message, can be attached to them.
+	toolUses map[string]blockRef
+
+	// lastMessageID is the API message ID of the last appended message.
+	lastMessageID string
+}
+
+// apply folds a single raw line into the transcript.
+func (p *transcriptParser) apply(raw *transcriptLine) {
+	t := p.t
+
+	if raw.Type == "summary" {
+		if raw.Summary != "" {
+			t.Summary = raw.Summary
+		}
+		return
+	}
+
+	if raw.Message == nil || raw.IsMeta ||
+		(raw.Type != "user" && raw.Type != "assistant") {
+
+		return
+	}
+
+	if t.SessionID == "" {
+		t.SessionID = raw.SessionID
+	}
+	if raw.CWD != "" {
+		t.CWD = raw.CWD
+	}
+	if raw.GitBranch != "" {
+		t.GitBranch = raw.GitBranch
+	}
+	if !raw.Timestamp.IsZero() {
+		if t.StartedAt.IsZero() {
+			t.StartedAt = raw.Timestamp
+		}
+		t.EndedAt = raw.Timestamp
+	}
+
+	msg := TranscriptMessage{
+		UUID:        raw.UUID,
+		Role:        raw.Message.Role,
+		Model:       raw.Message.Model,
+		Timestamp:   raw.Timestamp,
+		IsSidechain: raw.IsSidechain,
+	}
+	if msg.Role == "" {
+		msg.Role = raw.Type
+	}
+
+	// Streaming writes each assistant content block as its own line with
+	// the same message ID, so fragments are merged into one message.
+	msgIdx := len(t.Messages)
+	merge := msg.Role == "assistant" && raw.Message.ID != "" &&
+		raw.Message.ID == p.lastMessageID && msgIdx > 0
+	if merge {
+		msgIdx--
+	}
+
+	blocks := p.parseContent(raw.Message.Content)
+	if len(blocks) == 0 {
+		return
+	}
+
+	if merge {
+		prev := &t.Messages[msgIdx]
+		for _, b := range blocks {
+			prev.Blocks = append(prev.Blocks, b)
+			if b.Kind == BlockToolUse {
+				p.toolUses[b.ToolUseID] = blockRef{
+					msg: msgIdx, block: len(prev.Blocks) - 1,
+				}
+			}
+		}
+		return
+	}
+
+	msg.Blocks = blocks
+	for i, b := range blocks {
+		if b.Kind == BlockToolUse {
+			p.toolUses[b.ToolUseID] = blockRef{msg: msgIdx, block: i}
+		}
+	}
+
+	p.lastMessageID = raw.Message.ID
+	t.Messages = append(t.Messages, msg)
+}
+
+// parseContent converts message content, which is either a plain string or
+// an array of blocks, into transcript blocks. Tool results whose call is
+// known are attached to that call instead of being returned.
+func (p *transcriptParser) parseContent(content json.RawMessage) []TranscriptBlock {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []TranscriptBlock{{Kind: BlockText, Text: text}}
+	}
+
+	var raw []contentBlock
+	_ = json.Unmarshal(content, &raw)
+
+	var blocks []TranscriptBlock
+	for _, b := range raw {
+		switch b.Type {
+		case BlockText:
+			if strings.TrimSpace(b.Text) == "" {
+				continue
+			}
+			blocks = append(blocks, TranscriptBlock{
+				Kind: BlockText,
+				Text: b.Text,
+			})
+
+		case BlockThinking:
+			if strings.TrimSpace(b.Thinking) == "" {
+				continue
+			}
+			blocks = append(blocks, TranscriptBlock{
+				Kind: BlockThinking,
+				Text: b.Thinking,
+			})
+
+		case BlockToolUse:
+			blocks = append(blocks, TranscriptBlock{
+				Kind:      BlockToolUse,
+				ToolName:  b.Name,
+				ToolUseID: b.ID,
+				ToolInput: prettyJSON(b.Input),
+			})
+
+		case BlockToolResult:
+			output, truncated := toolResultText(b.Content)
+
+			if ref, ok := p.toolUses[b.ToolUseID]; ok {
+				use := &p.t.Messages[ref.msg].Blocks[ref.block]
+				use.Output = output
+				use.HasOutput = true
+				use.IsError = b.IsError
+				use.Truncated = truncated
+				continue
+			}
+
+			// The call was not seen, so keep the result as a
+			// standalone block.
+			blocks = append(blocks, TranscriptBlock{
+				Kind:      BlockToolResult,
+				ToolUseID: b.ToolUseID,
+				Output:    output,
+				HasOutput: true,
+				IsError:   b.IsError,
+				Truncated: truncated,
+			})
+		}
+	}
+
+	return blocks
+}
+
+// prettyJSON indents a raw JSON value for display.
+func prettyJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+
+	return buf.String()
+}
+
+// toolResultText flattens a tool_result content value, which is either a
+// string or an array of text/image blocks, and caps its length.
+func toolResultText(raw json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		var blocks []contentBlock
+		_ = json.Unmarshal(raw, &blocks)
+
+		var parts []string
+		for _, b := range blocks {
+			switch b.Type {
+			case BlockText:
+				parts = append(parts, b.Text)
+
+			case "image":
+				parts = append(parts, "[image]")
+			}
+		}
+		text = strings.Join(parts, "\n")
+	}
+
+	if len(text) > maxToolOutput {
+		// Cut on a rune boundary so multi-byte output stays valid.
+		n := maxToolOutput
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+
+		return text[:n], true
+	}
+
+	return text, false
+}
@@ -0,0 +1,177 @@
+package taskviewer
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestToolResultTextTruncation checks that long tool output is capped on a
+// rune boundary.
+func TestToolResultTextTruncation(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantLen   int
+		truncated bool
+	}{
+		{
+			name:    "short",
+			text:    "héllo",
+			wantLen: len("héllo"),
+		},
+		{
+			name:      "ascii",
+			text:      strings.Repeat("a", maxToolOutput+10),
+			wantLen:   maxToolOutput,
+			truncated: true,
+		},
+		{
+			// The three-byte rune straddles the cap.
+			name: "multi-byte",
+			text: strings.Repeat("a", maxToolOutput-1) +
+				strings.Repeat("世", 4),
+			wantLen:   maxToolOutput - 1,
+			truncated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := json.Marshal(test.text)
+			if err != nil {
+				t.Fatalf("unable to encode: %v", err)
+			}
+
+			text, truncated := toolResultText(raw)
+			if truncated != test.truncated {
+				t.Fatalf("truncated = %v, want %v", truncated,
+					test.truncated)
+			}
+			if len(text) != test.wantLen {
+				t.Fatalf("got %d bytes, want %d", len(text),
+					test.wantLen)
+			}
+			if !utf8.ValidString(text) {
+				t.Fatalf("truncated text is not valid UTF-8")
+			}
+		})
+	}
+}
+
+// transcriptLines joins JSONL lines into a transcript body.
+func transcriptLines(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// TestParseTranscript checks how transcript lines are folded into messages.
+func TestParseTranscript(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+
+		// want lists each message as its role followed by a summary
+		// of each of its blocks.
+		want [][]string
+	}{
+		{
+			name: "split assistant message",
+			body: transcriptLines(
+				`{"type":"user","message":{"role":"user",`+
+					`"content":"hi"}}`,
+				`{"type":"assistant","message":{"id":"m1",`+
+					`"role":"assistant","content":[`+
+					`{"type":"thinking","thinking":"hmm"}]}}`,
+				`{"type":"assistant","message":{"id":"m1",`+
+					`"role":"assistant","content":[`+
+					`{"type":"text","text":"hello"}]}}`,
+				`{"type":"assistant","message":{"id":"m2",`+
+					`"role":"assistant","content":[`+
+					`{"type":"text","text":"again"}]}}`,
+			),
+			want: [][]string{
+				{"user", "text:hi"},
+				{"assistant", "thinking:hmm", "text:hello"},
+				{"assistant", "text:again"},
+			},
+		},
+		{
+			name: "out of order tool results",
+			body: transcriptLines(
+				`{"type":"assistant","message":{"id":"m1",`+
+					`"role":"assistant","content":[`+
+					`{"type":"tool_use","id":"a",`+
+					`"name":"Read"}]}}`,
+				`{"type":"assistant","message":{"id":"m1",`+
+					`"role":"assistant","content":[`+
+					`{"type":"tool_use","id":"b",`+
+					`"name":"Grep"}]}}`,
+				`{"type":"user","message":{"role":"user",`+
+					`"content":[{"type":"tool_result",`+
+					`"tool_use_id":"b","content":"B"}]}}`,
+				`{"type":"user","message":{"role":"user",`+
+					`"content":[{"type":"tool_result",`+
+					`"tool_use_id":"a","content":"A"}]}}`,
+				`{"type":"user","message":{"role":"user",`+
+					`"content":[{"type":"tool_result",`+
+					`"tool_use_id":"z","content":"Z"}]}}`,
+			),
+			want: [][]string{
+				{"assistant", "tool_use:Read=A",
+					"tool_use:Grep=B"},
+				{"user", "tool_result:Z"},
+			},
+		},
+		{
+			name: "truncated last line",
+			body: `{"type":"user","message":{"role":"user",` +
+				`"content":"hi"}}` + "\n" +
+				`{"type":"assistant","message":{"id":"m1",` +
+				`"role":"assistant","content":[{"type":"te`,
+			want: [][]string{
+				{"user", "text:hi"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transcript, err := ParseTranscript(
+				strings.NewReader(test.body),
+			)
+			if err != nil {
+				t.Fatalf("unable to parse: %v", err)
+			}
+
+			var got [][]string
+			for _, msg := range transcript.Messages {
+				got = append(got, summarizeMessage(msg))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// summarizeMessage describes a message as its role followed by each block.
+func summarizeMessage(msg TranscriptMessage) []string {
+	summary := []string{msg.Role}
+	for _, b := range msg.Blocks {
+		switch b.Kind {
+		case BlockToolUse:
+			summary = append(summary,
+				b.Kind+":"+b.ToolName+"="+b.Output)
+
+		case BlockToolResult:
+			summary = append(summary, b.Kind+":"+b.Output)
+
+		default:
+			summary = append(summary, b.Kind+":"+b.Text)
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,1018 @@
+package taskviewer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+const (
+	// tuiResizeInterval is how often the terminal size is checked.
+	tuiResizeInterval = time.Second
+
+	// tuiReloadInterval is how often the dashboard is reloaded without
+	// any event, to keep uptimes current.
+	tuiReloadInterval = 5 * time.Second
+)
+
+// ANSI escape sequences used to draw the terminal UI.
+const (
+	ansiEnterScreen = "\x1b[?1049h\x1b[?25l"
+	ansiLeaveScreen = "\x1b[?25h\x1b[?1049l"
+	ansiHome        = "\x1b[H"
+	ansiClearLine   = "\x1b[K"
+	ansiClearBelow  = "\x1b[J"
+
+	sgrBold    = "1"
+	sgrDim     = "2"
+	sgrReverse = "7"
+	sgrRed     = "31"
+	sgrYellow  = "33"
+	sgrCyan    = "36"
+)
+
+// tuiView is a screen of the terminal UI.
+type tuiView int
+
+const (
+	// tuiDashboard shows the running instances and active sessions.
+	tuiDashboard tuiView = iota
+
+	// tuiBoard shows the tasks of one session as a Kanban board.
+	tuiBoard
+
+	// tuiTask shows one task in full.
+	tuiTask
+)
+
+// tuiPane is a pane of the dashboard that can hold the cursor.
+type tuiPane int
+
+const (
+	tuiInstances tuiPane = iota
+	tuiSessions
+)
+
+// boardColumns are the statuses of the board's columns, in order.
+var boardColumns = []claudeagent.TaskListStatus{
+	claudeagent.TaskListStatusPending,
+	claudeagent.TaskListStatusInProgress,
+	claudeagent.TaskListStatusCompleted,
+}
+
+// TUI is a live dashboard of the running instances, the active sessions and
+// their tasks, drawn in a terminal. All of its state is owned by the
+// goroutine calling Run.
+type TUI struct {
+	taskStore       claudeagent.TaskStore
+	projectIndexer  *ProjectIndexer
+	instanceTracker *InstanceTracker
+	eventBus        *EventBus
+	watchdog        *Watchdog
+
+	in  *os.File
+	out *os.File
+
+	width  int
+	height int
+
+	view  tuiView
+	focus tuiPane
+
+	instances []ClaudeInstance
+	lists     []SnapshotList
+
+	instanceRow int
+	listRow     int
+
+	// listID is the session shown on the board, with its tasks and
+	// their dependency analysis.
+	listID   string
+	tasks    []claudeagent.TaskListItem
+	analysis *GraphAnalysis
+
+	// column and rows hold the board's cursor: the selected column and
+	// the selected row of each column.
+	column int
+	rows   [3]int
+
+	// taskID is the task shown in full, scrolled down by scroll lines.
+	taskID string
+	scroll int
+
+	// message is shown in the footer until the next key press.
+	message string
+	updated time.Time
+
+	log btclog.Logger
+}
+
+// NewTUI creates a terminal UI reading keys from in and drawing to out,
+// which must both be the terminal. The watchdog may be nil.
+func NewTUI(taskStore claudeagent.TaskStore, projectIndexer *ProjectIndexer,
+	instanceTracker *InstanceTracker, eventBus *EventBus,
+	watchdog *Watchdog, in, out *os.File, log btclog.Logger) *TUI {
+
+	return &TUI{
+		taskStore:       taskStore,
+		projectIndexer:  projectIndexer,
+		instanceTracker: instanceTracker,
+		eventBus:        eventBus,
+		watchdog:        watchdog,
+		in:              in,
+		out:             out,
+		log:             log,
+	}
+}
+
+// RunTUI shows the terminal UI on the process's terminal until the user
+// quits. It reads Claude's state directly, so no daemon needs to run.
+func RunTUI(cfg *Config, log btclog.Logger) error {
+	local, stop, err := startLocal(
+		cfg, localOptions{watchdog: true}, log,
+	)
+	defer stop()
+	if err != nil {
+		return err
+	}
+
+	tui := NewTUI(
+		local.taskStore, local.projectIndexer, local.instanceTracker,
+		local.eventBus, local.watchdog, os.Stdin, os.Stdout, log,
+	)
+
+	return tui.Run()
+}
+
+// Run draws the UI and handles keys and events until the user quits.
+func (t *TUI) Run() error {
+	restore, err := makeRaw(t.in)
+	if err != nil {
+		return fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer restore()
+
+	fmt.Fprint(t.out, ansiEnterScreen)
+	defer fmt.Fprint(t.out, ansiLeaveScreen)
+
+	sub, _, _ := t.eventBus.Subscribe("", 0)
+	defer sub.Close()
+
+	// The reader blocks on the terminal and cannot be interrupted, so it
+	// is left to end with the process.
+	keys := make(chan []byte)
+	go t.readKeys(keys)
+
+	resize := time.NewTicker(tuiResizeInterval)
+	defer resize.Stop()
+	reload := time.NewTicker(tuiReloadInterval)
+	defer reload.Stop()
+
+	t.resize()
+	t.reload()
+	t.draw()
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			t.message = ""
+			if t.handleKey(parseKey(key)) {
+				return nil
+			}
+
+		case _, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+
+			// Events come in bursts, so reload once for all that
+			// are waiting.
+			t.drainEvents(sub)
+			t.reload()
+
+		case <-resize.C:
+			if !t.resize() {
+				continue
+			}
+
+		case <-reload.C:
+			t.reload()
+		}
+
+		t.draw()
+	}
+}
+
+// readKeys sends every chunk read from the terminal, which holds one key or
+// escape sequence, until the terminal is closed.
+func (t *TUI) readKeys(keys chan<- []byte) {
+	defer close(keys)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := t.in.Read(buf)
+		if n > 0 {
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// drainEvents discards the events already waiting on a subscription.
+func (t *TUI) drainEvents(sub *EventSubscription) {
+	for {
+		select {
+		case _, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// resize reads the terminal size and reports whether it changed.
+func (t *TUI) resize() bool {
+	width, height, err := terminalSize(t.out)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	if width == t.width && height == t.height {
+		return false
+	}
+	t.width, t.height = width, height
+
+	return true
+}
+
+// reload reads the instances, the active sessions and the tasks of the
+// session on the board.
+func (t *TUI) reload() {
+	ctx := context.Background()
+
+	instances, err := t.instanceTracker.ListRunningInstances()
+	if err != nil {
+		t.message = fmt.Sprintf("Unable to list instances: %v", err)
+	} else {
+		t.watchdog.annotateInstances(instances)
+		t.instances = instances
+	}
+
+	lists, err := t.projectIndexer.ListActiveTaskLists()
+	if err != nil {
+		t.message = fmt.Sprintf("Unable to list sessions: %v", err)
+	} else {
+		t.watchdog.annotateLists(lists)
+		t.lists = t.lists[:0]
+		for _, list := range lists {
+			tasks, err := t.taskStore.List(ctx, list.SessionID)
+			if err != nil {
+				continue
+			}
+			t.lists = append(t.lists, SnapshotList{
+				ActiveTaskList: list,
+				Counts:         countTasks(tasks),
+			})
+		}
+	}
+
+	if t.listID != "" {
+		tasks, err := t.taskStore.List(ctx, t.listID)
+		if err != nil {
+			t.message = fmt.Sprintf("Unable to load tasks: %v", err)
+			tasks = nil
+		}
+		t.tasks = tasks
+		t.analysis = AnalyzeGraph(tasks)
+	}
+
+	// A task shown in full that was deleted leaves nothing to show.
+	if t.view == tuiTask && t.task() == nil {
+		t.view = tuiBoard
+		t.message = fmt.Sprintf("Task #%s was deleted", t.taskID)
+	}
+
+	t.updated = time.Now()
+}
+
+// task returns the task shown in full, or nil if it no longer exists.
+func (t *TUI) task() *claudeagent.TaskListItem {
+	for i := range t.tasks {
+		if t.tasks[i].ID == t.taskID {
+			return &t.tasks[i]
+		}
+	}
+
+	return nil
+}
+
+// columnTasks returns the tasks of a board column.
+func (t *TUI) columnTasks(column int) []claudeagent.TaskListItem {
+	var tasks []claudeagent.TaskListItem
+	for _, task := range t.tasks {
+		if task.Status == boardColumns[column] {
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks
+}
+
+// list returns the active session with the given ID, or nil.
+func (t *TUI) list(listID string) *SnapshotList {
+	for i := range t.lists {
+		if t.lists[i].SessionID == listID {
+			return &t.lists[i]
+		}
+	}
+
+	return nil
+}
+
+// openBoard shows the tasks of a session.
+func (t *TUI) openBoard(listID string) {
+	t.view = tuiBoard
+	t.listID = listID
+	t.column = 0
+	t.rows = [3]int{}
+	t.reload()
+
+	// Start on the first column with any tasks.
+	for i := range boardColumns {
+		if len(t.columnTasks(i)) > 0 {
+			t.column = i
+			break
+		}
+	}
+}
+
+// handleKey acts on a key and reports whether the user quit.
+func (t *TUI) handleKey(key string) bool {
+	switch key {
+	case "q", "ctrl-c":
+		return true
+
+	case "r":
+		// Anything the reload reports replaces the confirmation.
+		t.message = "Reloaded"
+		t.reload()
+		return false
+	}
+
+	switch t.view {
+	case tuiDashboard:
+		t.dashboardKey(key)
+
+	case tuiBoard:
+		t.boardKey(key)
+
+	case tuiTask:
+		t.taskKey(key)
+	}
+
+	return false
+}
+
+// dashboardKey acts on a key on the dashboard.
+func (t *TUI) dashboardKey(key string) {
+	row, count := &t.instanceRow, len(t.instances)
+	if t.focus == tuiSessions {
+		row, count = &t.listRow, len(t.lists)
+	}
+
+	switch key {
+	case "tab", "backtab", "left", "right", "h", "l":
+		if t.focus == tuiInstances {
+			t.focus = tuiSessions
+		} else {
+			t.focus = tuiInstances
+		}
+
+	case "up", "k":
+		*row = clampRow(*row-1, count)
+
+	case "down", "j":
+		*row = clampRow(*row+1, count)
+
+	case "home", "g":
+		*row = 0
+
+	case "end", "G":
+		*row = clampRow(count-1, count)
+
+	case "enter":
+		if count == 0 {
+			return
+		}
+		*row = clampRow(*row, count)
+
+		if t.focus == tuiSessions {
+			t.openBoard(t.lists[*row].SessionID)
+			return
+		}
+
+		instance := t.instances[*row]
+		if instance.SessionID == "" || !instance.HasTasks {
+			t.message = "This instance has no task list"
+			return
+		}
+		t.openBoard(instance.SessionID)
+	}
+}
+
+// boardKey acts on a key on the board.
+func (t *TUI) boardKey(key string) {
+	count := len(t.columnTasks(t.column))
+	row := &t.rows[t.column]
+
+	switch key {
+	case "esc", "backspace":
+		t.view = tuiDashboard
+		t.listID = ""
+		t.tasks = nil
+
+	case "left", "h", "backtab":
+		t.column = (t.column + len(boardColumns) - 1) %
+			len(boardColumns)
+
+	case "right", "l", "tab":
+		t.column = (t.column + 1) % len(boardColumns)
+
+	case "up", "k":
+		*row = clampRow(*row-1, count)
+
+	case "down", "j":
+		*row = clampRow(*row+1, count)
+
+	case "home", "g":
+		*row = 0
+
+	case "end", "G":
+		*row = clampRow(count-1, count)
+
+	case "enter":
+		if count == 0 {
+			return
+		}
+		*row = clampRow(*row, count)
+
+		t.view = tuiTask
+		t.taskID = t.columnTasks(t.column)[*row].ID
+		t.scroll = 0
+	}
+}
+
+// taskKey acts on a key on the task view.
+func (t *TUI) taskKey(key string) {
+	switch key {
+	case "esc", "backspace", "enter":
+		t.view = tuiBoard
+
+	case "up", "k":
+		t.scroll = max(t.scroll-1, 0)
+
+	case "down", "j":
+		t.scroll++
+
+	case "home", "g":
+		t.scroll = 0
+	}
+}
+
+// parseKey names the key or escape sequence in a chunk read from the
+// terminal.
+func parseKey(b []byte) string {
+	switch string(b) {
+	case "\x1b[A", "\x1bOA":
+		return "up"
+	case "\x1b[B", "\x1bOB":
+		return "down"
+	case "\x1b[C", "\x1bOC":
+		return "right"
+	case "\x1b[D", "\x1bOD":
+		return "left"
+	case "\x1b[H", "\x1bOH", "\x1b[1~":
+		return "home"
+	case "\x1b[F", "\x1bOF", "\x1b[4~":
+		return "end"
+	case "\x1b[Z":
+		return "backtab"
+	case "\x1b":
+		return "esc"
+	case "\r", "\n":
+		return "enter"
+	case "\t":
+		return "tab"
+	case "\x7f", "\b":
+		return "backspace"
+	case "\x03":
+		return "ctrl-c"
+	}
+
+	return string(b)
+}
+
+// clampRow keeps a row within a list of count rows.
+func clampRow(row, count int) int {
+	return max(min(row, count-1), 0)
+}
+
+// draw renders the current view to the terminal.
+func (t *TUI) draw() {
+	var lines []string
+	switch t.view {
+	case tuiDashboard:
+		lines = t.drawDashboard()
+
+	case tuiBoard:
+		lines = t.drawBoard()
+
+	case tuiTask:
+		lines = t.drawTask()
+	}
+
+	// Every view fills the screen above the footer, and is cut to fit.
+	body := t.height - 1
+	for len(lines) < body {
+		lines = append(lines, "")
+	}
+	lines = append(lines[:body], t.footer())
+
+	var buf bytes.Buffer
+	buf.WriteString(ansiHome)
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(line)
+		buf.WriteString(ansiClearLine)
+	}
+	buf.WriteString(ansiClearBelow)
+
+	_, _ = t.out.Write(buf.Bytes())
+}
+
+// drawDashboard renders the instances pane above the sessions pane.
+func (t *TUI) drawDashboard() []string {
+	lines := []string{t.titleBar(fmt.Sprintf(
+		"Claude Task Viewer · %d instance%s · %d active session%s",
+		len(t.instances), plural(len(t.instances)),
+		len(t.lists), plural(len(t.lists)),
+	))}
+
+	// The instances pane takes what it needs, up to half the screen.
+	body := t.height - 2
+	instanceRows := min(max(len(t.instances), 1), max(body/2-2, 1))
+	listRows := max(body-instanceRows-5, 1)
+
+	// Instances.
+	const pidW, uptimeW, tasksW, sessionW = 7, 9, 6, 10
+	projectW := max(t.width-pidW-uptimeW-tasksW-sessionW-6, 8)
+
+	lines = append(lines, t.paneTitle(
+		fmt.Sprintf("Instances (%d)", len(t.instances)),
+		t.focus == tuiInstances,
+	))
+	lines = append(lines, styled(sgrDim, tableRow(t.width,
+		fit("PID", pidW), fit("PROJECT", projectW),
+		fit("UPTIME", uptimeW), fit("TASKS", tasksW),
+		fit("SESSION", sessionW),
+	)))
+
+	if len(t.instances) == 0 {
+		lines = append(lines, styled(sgrDim, fit(
+			" No Claude instances are running", t.width,
+		)))
+	}
+	start := scrollStart(t.instanceRow, len(t.instances), instanceRows)
+	end := min(start+instanceRows, len(t.instances))
+	for i := start; i < end; i++ {
+		instance := t.instances[i]
+
+		project := instance.ProjectName
+		if instance.Stall != nil {
+			project += " [stalled]"
+		}
+		tasks := "-"
+		if instance.HasTasks {
+			tasks = strconv.Itoa(instance.TaskCount)
+		}
+		session := "-"
+		if instance.SessionID != "" {
+			session = shortID(instance.SessionID, sessionW)
+		}
+
+		row := tableRow(t.width,
+			fit(strconv.Itoa(instance.PID), pidW),
+			fit(project, projectW), fit(instance.Uptime, uptimeW),
+			fit(tasks, tasksW), fit(session, sessionW),
+		)
+
+		lines = append(lines, t.rowStyle(
+			row, t.focus == tuiInstances && i == t.instanceRow,
+			instance.Stall != nil,
+		))
+	}
+	lines = append(lines, "")
+
+	// Active sessions.
+	const countsW, progressW = 14, 15
+	titleW := max(t.width-countsW-progressW-3, 8) * 2 / 3
+	listProjectW := max(t.width-countsW-progressW-titleW-4, 4)
+
+	lines = append(lines, t.paneTitle(
+		fmt.Sprintf("Active sessions (%d)", len(t.lists)),
+		t.focus == tuiSessions,
+	))
+	lines = append(lines, styled(sgrDim, tableRow(t.width,
+		fit("SESSION", titleW), fit("PROJECT", listProjectW),
+		fit("TODO/WIP/DONE", countsW), fit("PROGRESS", progressW),
+	)))
+
+	if len(t.lists) == 0 {
+		lines = append(lines, styled(sgrDim, fit(
+			" No session has a task list", t.width,
+		)))
+	}
+	start = scrollStart(t.listRow, len(t.lists), listRows)
+	end = min(start+listRows, len(t.lists))
+	for i := start; i < end; i++ {
+		list := t.lists[i]
+
+		title := listTitle(list.ActiveTaskList)
+		if list.Stall != nil {
+			title = "[stalled] " + title
+		}
+		counts := fmt.Sprintf("%d / %d / %d", list.Counts.Pending,
+			list.Counts.InProgress, list.Counts.Completed)
+
+		row := tableRow(t.width,
+			fit(title, titleW), fit(list.ProjectName, listProjectW),
+			fit(counts, countsW),
+			progressBar(list.Counts, progressW),
+		)
+
+		lines = append(lines, t.rowStyle(
+			row, t.focus == tuiSessions && i == t.listRow,
+			list.Stall != nil,
+		))
+	}
+
+	return lines
+}
+
+// drawBoard renders the tasks of a session in one column per status.
+func (t *TUI) drawBoard() []string {
+	title := "Session " + shortID(t.listID, 12)
+	counts := countTasks(t.tasks)
+	if list := t.list(t.listID); list != nil {
+		title = listTitle(list.ActiveTaskList)
+		if list.ProjectName != "" {
+			title = list.ProjectName + " · " + title
+		}
+	}
+
+	lines := []string{
+		t.titleBar(title),
+		fit(fmt.Sprintf(" %d tasks · %d pending · %d in progress · "+
+			"%d completed", counts.Total, counts.Pending,
+			counts.InProgress, counts.Completed), t.width),
+		"",
+	}
+
+	colW := max((t.width-len(boardColumns)+1)/len(boardColumns), 8)
+	columns := make([][]claudeagent.TaskListItem, len(boardColumns))
+	headers := make([]string, len(boardColumns))
+	for i, status := range boardColumns {
+		columns[i] = t.columnTasks(i)
+
+		header := fit(fmt.Sprintf(" %s (%d)", statusLabel(status),
+			len(columns[i])), colW)
+		code := sgrBold
+		if i == t.column {
+			code = sgrBold + ";" + sgrCyan
+		}
+		headers[i] = styled(code, header)
+	}
+	lines = append(lines, strings.Join(headers, " "))
+
+	rows := max(t.height-len(lines)-1, 1)
+	starts := make([]int, len(boardColumns))
+	for i := range boardColumns {
+		starts[i] = scrollStart(t.rows[i], len(columns[i]), rows)
+	}
+
+	for r := 0; r < rows; r++ {
+		cells := make([]string, len(boardColumns))
+		for i, status := range boardColumns {
+			idx := starts[i] + r
+			if idx >= len(columns[i]) {
+				cells[i] = fit("", colW)
+				continue
+			}
+			task := columns[i][idx]
+
+			mark := "  "
+			if status != claudeagent.TaskListStatusCompleted &&
+				t.analysis.IsBlocked(task.ID) {
+
+				mark = "⊘ "
+			}
+			cell := fit(mark+"#"+task.ID+" "+task.Subject, colW)
+
+			switch {
+			case i == t.column && idx == t.rows[i]:
+				cell = styled(sgrReverse, cell)
+
+			case mark != "  ":
+				cell = styled(sgrRed, cell)
+
+			case status == claudeagent.TaskListStatusCompleted:
+				cell = styled(sgrDim, cell)
+			}
+			cells[i] = cell
+		}
+		lines = append(lines, strings.Join(cells, " "))
+	}
+
+	return lines
+}
+
+// drawTask renders one task in full.
+func (t *TUI) drawTask() []string {
+	task := t.task()
+	if task == nil {
+		return []string{t.titleBar("Task #" + t.taskID)}
+	}
+
+	lines := []string{t.titleBar("#" + task.ID + " " + task.Subject)}
+
+	field := func(name, value string) {
+		if value == "" {
+			return
+		}
+		lines = append(lines, styled(sgrDim, fit(" "+name, 13))+" "+
+			fit(value, t.width-14))
+	}
+
+	status := statusLabel(task.Status)
+	switch {
+	case task.Status == claudeagent.TaskListStatusCompleted:
+	case t.analysis.IsBlocked(task.ID):
+		status += " (blocked)"
+	case t.analysis.IsReady(task.ID):
+		status += " (ready)"
+	}
+
+	lines = append(lines, "")
+	field("Status", status)
+	field("Owner", task.Owner)
+	if task.Status == claudeagent.TaskListStatusInProgress {
+		field("Working on", task.ActiveForm)
+	}
+	field("Blocked by", t.taskRefs(task.BlockedBy))
+	field("Blocks", t.taskRefs(task.Blocks))
+	lines = append(lines, "")
+
+	// The description scrolls below the fixed fields.
+	description := wrapText(task.Description, max(t.width-2, 10))
+	if len(description) == 0 {
+		description = []string{styled(sgrDim, "No description")}
+	}
+	rows := max(t.height-len(lines)-1, 1)
+	t.scroll = min(t.scroll, max(len(description)-rows, 0))
+	for _, line := range description[t.scroll:] {
+		lines = append(lines, " "+line)
+	}
+
+	return lines
+}
+
+// taskRefs describes a list of task IDs with their subjects and status.
+func (t *TUI) taskRefs(ids []string) string {
+	refs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ref := "#" + id
+		for _, task := range t.tasks {
+			if task.ID == id {
+				ref += " " + task.Subject + " (" +
+					statusLabel(task.Status) + ")"
+				break
+			}
+		}
+		refs = append(refs, ref)
+	}
+
+	return strings.Join(refs, ", ")
+}
+
+// footer renders the key help of the current view and the status message.
+func (t *TUI) footer() string {
+	var help string
+	switch t.view {
+	case tuiDashboard:
+		help = " ↑↓ move  tab switch pane  enter open  r reload  " +
+			"q quit"
+
+	case tuiBoard:
+		help = " ←→ column  ↑↓ move  enter task  esc back  " +
+			"q quit"
+
+	case tuiTask:
+		help = " ↑↓ scroll  esc back  q quit"
+	}
+
+	status := t.message
+	if status == "" && !t.updated.IsZero() {
+		status = "Updated " + t.updated.Format("15:04:05")
+	}
+
+	// The status is right aligned, and the help gives way to it.
+	status += " "
+	helpW := max(t.width-utf8.RuneCountInString(status), 0)
+
+	return styled(sgrReverse, fit(help, helpW)+fit(status, t.width-helpW))
+}
+
+// titleBar renders a full width title.
+func (t *TUI) titleBar(title string) string {
+	return styled(sgrBold+";"+sgrReverse, fit(" "+title, t.width))
+}
+
+// paneTitle renders the title of a dashboard pane, highlighted if the pane
+// has the cursor.
+func (t *TUI) paneTitle(title string, focused bool) string {
+	if focused {
+		return styled(sgrBold+";"+sgrCyan, fit("▸ "+title, t.width))
+	}
+
+	return styled(sgrBold, fit("  "+title, t.width))
+}
+
+// rowStyle highlights a table row if it is selected or stalled.
+func (t *TUI) rowStyle(row string, selected, stalled bool) string {
+	switch {
+	case selected:
+		return styled(sgrReverse, row)
+
+	case stalled:
+		return styled(sgrYellow, row)
+
+	default:
+		return row
+	}
+}
+
+// listTitle returns the best title for a task list.
+func listTitle(list ActiveTaskList) string {
+	switch {
+	case list.Summary != "":
+		return list.Summary
+
+	case list.FirstPrompt != "":
+		return strings.Join(strings.Fields(list.FirstPrompt), " ")
+
+	default:
+		return "Session " + shortID(list.SessionID, 12)
+	}
+}
+
+// statusLabel returns the display name of a task status.
+func statusLabel(status claudeagent.TaskListStatus) string {
+	switch status {
+	case claudeagent.TaskListStatusPending:
+		return "Pending"
+
+	case claudeagent.TaskListStatusInProgress:
+		return "In progress"
+
+	case claudeagent.TaskListStatusCompleted:
+		return "Completed"
+
+	default:
+		return string(status)
+	}
+}
+
+// progressBar renders the share of completed tasks in width cells.
+func progressBar(counts TaskCounts, width int) string {
+	percent := 0
+	if counts.Total > 0 {
+		percent = counts.Completed * 100 / counts.Total
+	}
+
+	label := fmt.Sprintf(" %3d%%", percent)
+	cells := max(width-len(label), 1)
+	filled := cells * percent / 100
+
+	return strings.Repeat("█", filled) +
+		strings.Repeat("░", cells-filled) + label
+}
+
+// tableRow joins the cells of a table row, indented by one cell and cut
+// to width.
+func tableRow(width int, cells ...string) string {
+	return fit(" "+strings.Join(cells, " "), width)
+}
+
+// styled wraps text in an SGR escape sequence.
+func styled(code, text string) string {
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// fit cuts text to width cells, marking the cut with an ellipsis, or pads
+// it with spaces. Every rune is taken to be one cell wide.
+func fit(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	n := utf8.RuneCountInString(text)
+	if n <= width {
+		return text + strings.Repeat(" ", width-n)
+	}
+
+	runes := []rune(text)
+
+	return string(runes[:width-1]) + "…"
+}
+
+// shortID shortens an ID to at most n characters.
+func shortID(id string, n int) string {
+	if len(id) <= n {
+		return id
+	}
+
+	return id[:n]
+}
+
+// plural returns "s" unless n is one.
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+
+	return "s"
+}
+
+// scrollStart returns the first of count rows to show in a window of rows
+// rows, so that the selected row stays in view.
+func scrollStart(selected, count, rows int) int {
+	return max(min(selected-rows/2, count-rows), 0)
+}
+
+// wrapText breaks text into lines of at most width runes, at spaces where
+// possible, keeping the text's own line breaks.
+func wrapText(text string, width int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+
+			switch {
+			case line == "":
+				line = word
+
+			case utf8.RuneCountInString(line)+1+
+				utf8.RuneCountInString(word) <= width:
+
+				line += " " + word
+
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,337 @@
+package taskviewer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// newTestTUI returns a terminal UI over a fake store, without a terminal.
+// The tasks directory holds a live list s1.
+func newTestTUI(t *testing.T, store *fakeTaskStore) *TUI {
+	t.Helper()
+
+	root := t.TempDir()
+	projectsDir := filepath.Join(root, "projects")
+	if err := os.MkdirAll(projectsDir, 0o700); err != nil {
+		t.Fatalf("unable to create %s: %v", projectsDir, err)
+	}
+	tasksDir := filepath.Join(root, "tasks")
+	writeTaskFiles(t, filepath.Join(tasksDir, "s1"), 1)
+
+	projectIndexer := NewProjectIndexer(projectsDir, tasksDir)
+	if err := projectIndexer.Rescan(); err != nil {
+		t.Fatalf("unable to rescan: %v", err)
+	}
+	instanceTracker := NewInstanceTracker(
+		projectIndexer, &fakeProcessSource{},
+	)
+
+	tui := NewTUI(
+		store, projectIndexer, instanceTracker, nil, nil, nil, nil,
+		btclog.Disabled,
+	)
+	tui.reload()
+
+	return tui
+}
+
+// tuiState is the part of the terminal UI's state that keys move.
+type tuiState struct {
+	view    tuiView
+	focus   tuiPane
+	listRow int
+	listID  string
+	column  int
+	row     int
+	taskID  string
+	scroll  int
+	message string
+}
+
+// state returns the key-driven state of the terminal UI that the current
+// view shows, with the board's row of the selected column.
+func (t *TUI) state() tuiState {
+	state := tuiState{
+		view:    t.view,
+		focus:   t.focus,
+		listRow: t.listRow,
+		message: t.message,
+	}
+	if t.view == tuiDashboard {
+		return state
+	}
+
+	state.listID = t.listID
+	state.column = t.column
+	state.row = t.rows[t.column]
+	if t.view == tuiTask {
+		state.taskID = t.taskID
+		state.scroll = t.scroll
+	}
+
+	return state
+}
+
+// TestParseKey checks that the escape sequences of common terminals are
+// named, and anything else is passed through.
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "\x1b[A", want: "up"},
+		{in: "\x1bOA", want: "up"},
+		{in: "\x1b[B", want: "down"},
+		{in: "\x1bOC", want: "right"},
+		{in: "\x1b[D", want: "left"},
+		{in: "\x1b[H", want: "home"},
+		{in: "\x1b[1~", want: "home"},
+		{in: "\x1bOF", want: "end"},
+		{in: "\x1b[4~", want: "end"},
+		{in: "\x1b[Z", want: "backtab"},
+		{in: "\x1b", want: "esc"},
+		{in: "\r", want: "enter"},
+		{in: "\n", want: "enter"},
+		{in: "\t", want: "tab"},
+		{in: "\x7f", want: "backspace"},
+		{in: "\b", want: "backspace"},
+		{in: "\x03", want: "ctrl-c"},
+		{in: "j", want: "j"},
+		{in: "\x1b[5~", want: "\x1b[5~"},
+	}
+
+	for _, test := range tests {
+		if got := parseKey([]byte(test.in)); got != test.want {
+			t.Errorf("parseKey(%q) = %q, want %q", test.in, got,
+				test.want)
+		}
+	}
+}
+
+// TestTUIKeys walks the dashboard, the board and the task view with the
+// keyboard.
+func TestTUIKeys(t *testing.T) {
+	done := func(id string) claudeagent.TaskListItem {
+		task := testTask(id)
+		task.Status = claudeagent.TaskListStatusCompleted
+
+		return task
+	}
+	running := testTask("2")
+	running.Status = claudeagent.TaskListStatusInProgress
+
+	store := newFakeTaskStore()
+	store.set("s1", testTask("1"), running, done("3"), done("4"))
+	tui := newTestTUI(t, store)
+
+	dashboard := tuiState{view: tuiDashboard, focus: tuiSessions}
+	board := tuiState{
+		view: tuiBoard, focus: tuiSessions, listID: "s1",
+	}
+	doneColumn := board
+	doneColumn.column = 2
+	lastDone := doneColumn
+	lastDone.row = 1
+	task := lastDone
+	task.view = tuiTask
+	task.taskID = "4"
+	scrolled := task
+	scrolled.scroll = 1
+
+	steps := []struct {
+		key  string
+		want tuiState
+	}{
+		// Without instances, the instance pane has nothing to open.
+		{key: "enter", want: tuiState{}},
+		{key: "down", want: tuiState{}},
+		{key: "tab", want: dashboard},
+		{key: "down", want: dashboard},
+		{key: "up", want: dashboard},
+
+		// The board opens on the first column with tasks, and the
+		// cursor wraps around the columns.
+		{key: "enter", want: board},
+		{key: "left", want: doneColumn},
+		{key: "j", want: lastDone},
+		{key: "down", want: lastDone},
+		{key: "g", want: doneColumn},
+		{key: "G", want: lastDone},
+		{key: "tab", want: board},
+		{key: "backtab", want: lastDone},
+
+		{key: "enter", want: task},
+		{key: "down", want: scrolled},
+		{key: "k", want: task},
+		{key: "up", want: task},
+		{key: "esc", want: lastDone},
+
+		{key: "backspace", want: dashboard},
+	}
+
+	for i, step := range steps {
+		if tui.handleKey(step.key) {
+			t.Fatalf("step %d: %q quit", i, step.key)
+		}
+		if got := tui.state(); got != step.want {
+			t.Fatalf("step %d: %q left %+v, want %+v", i,
+				step.key, got, step.want)
+		}
+	}
+
+	// A task deleted while shown sends the view back to its board.
+	tui.handleKey("enter")
+	tui.handleKey("l")
+	tui.handleKey("l")
+	tui.handleKey("G")
+	tui.handleKey("enter")
+	store.set("s1", testTask("1"), running, done("3"))
+	tui.handleKey("r")
+	if tui.view != tuiBoard {
+		t.Fatalf("got view %v after deletion, want board", tui.view)
+	}
+	if want := "Task #4 was deleted"; tui.message != want {
+		t.Fatalf("got message %q, want %q", tui.message, want)
+	}
+
+	for _, key := range []string{"q", "ctrl-c"} {
+		if !tui.handleKey(key) {
+			t.Fatalf("%q did not quit", key)
+		}
+	}
+}
+
+// TestTUIInstanceWithoutTasks checks that an instance without a task list
+// is not opened.
+func TestTUIInstanceWithoutTasks(t *testing.T) {
+	tui := newTestTUI(t, newFakeTaskStore())
+	tui.instances = []ClaudeInstance{{PID: 1}}
+
+	tui.dashboardKey("enter")
+	if tui.view != tuiDashboard {
+		t.Fatalf("got view %v, want dashboard", tui.view)
+	}
+	if want := "This instance has no task list"; tui.message != want {
+		t.Fatalf("got message %q, want %q", tui.message, want)
+	}
+}
+
+// TestFit checks that text is padded or cut to a number of runes, never
+// splitting a multi-byte rune.
+func TestFit(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{text: "abc", width: 5, want: "abc  "},
+		{text: "abc", width: 3, want: "abc"},
+		{text: "abcdef", width: 4, want: "abc…"},
+		{text: "abc", width: 1, want: "…"},
+		{text: "abc", width: 0, want: ""},
+		{text: "abc", width: -1, want: ""},
+		{text: "héllo wörld", width: 5, want: "héll…"},
+		{text: "日本語テキスト", width: 4, want: "日本語…"},
+		{text: "日本", width: 3, want: "日本 "},
+	}
+
+	for _, test := range tests {
+		got := fit(test.text, test.width)
+		if got != test.want {
+			t.Errorf("fit(%q, %d) = %q, want %q", test.text,
+				test.width, got, test.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("fit(%q, %d) split a rune", test.text,
+				test.width)
+		}
+	}
+}
+
+// TestWrapText checks that text is broken at spaces, that words too long
+// for a line are split between runes, and that line breaks are kept.
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{
+			name:  "blank",
+			text:  " \n ",
+			width: 10,
+		},
+		{
+			name:  "short",
+			text:  "fix it",
+			width: 10,
+			want:  []string{"fix it"},
+		},
+		{
+			name:  "spaces",
+			text:  "the quick  brown fox",
+			width: 10,
+			want:  []string{"the quick", "brown fox"},
+		},
+		{
+			name:  "long word",
+			text:  "xy abcdefghij",
+			width: 4,
+			want:  []string{"xy", "abcd", "efgh", "ij"},
+		},
+		{
+			name:  "multi-byte word",
+			text:  "ünïcödé",
+			width: 3,
+			want:  []string{"ünï", "cöd", "é"},
+		},
+		{
+			name:  "line breaks",
+			text:  "one\n\ntwo",
+			width: 10,
+			want:  []string{"one", "", "two"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := wrapText(test.text, test.width)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestScrollStart checks that the selected row stays in view, centred
+// where the rows allow it.
+func TestScrollStart(t *testing.T) {
+	tests := []struct {
+		selected int
+		count    int
+		rows     int
+		want     int
+	}{
+		{selected: 0, count: 10, rows: 4, want: 0},
+		{selected: 5, count: 10, rows: 4, want: 3},
+		{selected: 9, count: 10, rows: 4, want: 6},
+		{selected: 2, count: 3, rows: 10, want: 0},
+		{selected: 0, count: 0, rows: 5, want: 0},
+	}
+
+	for _, test := range tests {
+		got := scrollStart(test.selected, test.count, test.rows)
+		if got != test.want {
+			t.Errorf("scrollStart(%d, %d, %d) = %d, want %d",
+				test.selected, test.count, test.rows, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,657 @@
+package taskviewer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageDayFormat is the format of the day a usage bucket covers, in local
+// time.
+const usageDayFormat = "2006-01-02"
+
+// syntheticModel is the model Claude Code records for messages it generates
+// itself, such as API error notices. They carry no real usage.
+const syntheticModel = "<synthetic>"
+
+// TokenUsage counts the tokens of one or more API responses.
+type TokenUsage struct {
+	InputTokens         int64 `json:"inputTokens"`
+	OutputTokens        int64 `json:"outputTokens"`
+	CacheCreationTokens int64 `json:"cacheCreationTokens"`
+	CacheReadTokens     int64 `json:"cacheReadTokens"`
+
+	// Messages is the number of API responses counted.
+	Messages int64 `json:"messages"`
+}
+
+// Total returns every token counted, cached or not.
+func (u TokenUsage) Total() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens +
+		u.CacheReadTokens
+}
+
+// add adds o to u.
+func (u *TokenUsage) add(o TokenUsage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheCreationTokens += o.CacheCreationTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.Messages += o.Messages
+}
+
+// sub subtracts o from u.
+func (u *TokenUsage) sub(o TokenUsage) {
+	u.InputTokens -= o.InputTokens
+	u.OutputTokens -= o.OutputTokens
+	u.CacheCreationTokens -= o.CacheCreationTokens
+	u.CacheReadTokens -= o.CacheReadTokens
+	u.Messages -= o.Messages
+}
+
+// ModelPrice is the price of a model in US dollars per million tokens.
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cacheWrite"`
+	CacheRead  float64 `json:"cacheRead"`
+}
+
+// cost returns the cost of usage at this price.
+func (p ModelPrice) cost(u TokenUsage) float64 {
+	return (float64(u.InputTokens)*p.Input +
+		float64(u.OutputTokens)*p.Output +
+		float64(u.CacheCreationTokens)*p.CacheWrite +
+		float64(u.CacheReadTokens)*p.CacheRead) / 1e6
+}
+
+// PriceTable maps a model name, or any part of one, to its price. A model
+// is priced by the longest key it contains, so "opus" prices every Opus
+// model that has no more specific entry.
+type PriceTable map[string]ModelPrice
+
+// DefaultPriceTable returns the published list prices of the Claude models
+// at the time of writing. Cache writes are priced at the 5 minute rate.
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		"opus": {
+			Input: 15, Output: 75, CacheWrite: 18.75,
+			CacheRead: 1.50,
+		},
+		"opus-4-5": {
+			Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50,
+		},
+		"sonnet": {
+			Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30,
+		},
+		"haiku": {
+			Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08,
+		},
+		"haiku-4-5": {
+			Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10,
+		},
+	}
+}
+
+// LoadPriceTable reads a JSON price table and merges it over the default
+// table, so that it only needs to list the prices that differ.
+func LoadPriceTable(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price table: %w", err)
+	}
+
+	var prices PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse price table %s: %w",
+			path, err)
+	}
+
+	table := DefaultPriceTable()
+	maps.Copy(table, prices)
+
+	return table, nil
+}
+
+// lookup returns the price of a model.
+func (pt PriceTable) lookup(model string) (ModelPrice, bool) {
+	var (
+		best  string
+		price ModelPrice
+		found bool
+	)
+	for key, p := range pt {
+		if strings.Contains(model, key) && len(key) > len(best) {
+			best, price, found = key, p, true
+		}
+	}
+
+	return price, found
+}
+
+// UsageSummary is token usage with its estimated cost.
+type UsageSummary struct {
+	TokenUsage
+
+	// CostUSD is the estimated cost in US dollars.
+	CostUSD float64 `json:"costUsd"`
+
+	// UnpricedModels lists the models without a price, whose tokens are
+	// counted but not costed.
+	UnpricedModels []string `json:"unpricedModels,omitempty"`
+}
+
+// combine adds the usage and cost of o to s.
+func (s *UsageSummary) combine(o UsageSummary) {
+	s.add(o.TokenUsage)
+	s.CostUSD += o.CostUSD
+
+	for _, model := range o.UnpricedModels {
+		if !slices.Contains(s.UnpricedModels, model) {
+			s.UnpricedModels = append(s.UnpricedModels, model)
+		}
+	}
+	slices.Sort(s.UnpricedModels)
+}
+
+// DailyUsage is the usage of a single day.
+type DailyUsage struct {
+	// Date is the local date, formatted as YYYY-MM-DD.
+	Date string `json:"date"`
+
+	UsageSummary
+}
+
+// UsageReport is the usage of a set of transcripts, broken down by model
+// and by day.
+type UsageReport struct {
+	Total   UsageSummary            `json:"total"`
+	ByModel map[string]UsageSummary `json:"byModel"`
+
+	// ByDay holds the days with any usage, oldest first.
+	ByDay []DailyUsage `json:"byDay"`
+}
+
+// ProjectUsage is the usage of a project and of each of its sessions.
+type ProjectUsage struct {
+	UsageReport
+
+	// Sessions maps the ID of every session with usage to its summary.
+	Sessions map[string]UsageSummary `json:"sessions"`
+}
+
+// NamedUsage is the usage of a project or project group.
+type NamedUsage struct {
+	// Key is the project's directory name or the group's base repo.
+	Key  string `json:"key"`
+	Name string `json:"name"`
+
+	UsageSummary
+}
+
+// UsageOverview is the usage across every project.
+type UsageOverview struct {
+	UsageReport
+
+	// Projects and Groups are sorted by cost, highest first.
+	Projects []NamedUsage `json:"projects"`
+	Groups   []NamedUsage `json:"groups"`
+}
+
+// usageKey identifies a usage bucket.
+type usageKey struct {
+	day   string
+	model string
+}
+
+// usageBuckets holds usage by day and model.
+type usageBuckets map[usageKey]TokenUsage
+
+// merge adds every bucket of o to b.
+func (b usageBuckets) merge(o usageBuckets) {
+	for key, u := range o {
+		sum := b[key]
+		sum.add(u)
+		b[key] = sum
+	}
+}
+
+// summarize returns the total usage of the buckets and its cost.
+func (pt PriceTable) summarize(b usageBuckets) UsageSummary {
+	var (
+		summary  UsageSummary
+		unpriced = make(map[string]bool)
+	)
+	for key, u := range b {
+		summary.add(u)
+
+		price, ok := pt.lookup(key.model)
+		if !ok {
+			unpriced[key.model] = true
+			continue
+		}
+		summary.CostUSD += price.cost(u)
+	}
+
+	if len(unpriced) > 0 {
+		summary.UnpricedModels = slices.Sorted(maps.Keys(unpriced))
+	}
+
+	return summary
+}
+
+// report breaks the buckets down by model and day.
+func (pt PriceTable) report(b usageBuckets) UsageReport {
+	byModel := make(map[string]usageBuckets)
+	byDay := make(map[string]usageBuckets)
+	for key, u := range b {
+		if byModel[key.model] == nil {
+			byModel[key.model] = make(usageBuckets)
+		}
+		byModel[key.model][key] = u
+
+		if byDay[key.day] == nil {
+			byDay[key.day] = make(usageBuckets)
+		}
+		byDay[key.day][key] = u
+	}
+
+	report := UsageReport{
+		Total:   pt.summarize(b),
+		ByModel: make(map[string]UsageSummary, len(byModel)),
+		ByDay:   make([]DailyUsage, 0, len(byDay)),
+	}
+	for model, buckets := range byModel {
+		report.ByModel[model] = pt.summarize(buckets)
+	}
+	for _, day := range slices.Sorted(maps.Keys(byDay)) {
+		report.ByDay = append(report.ByDay, DailyUsage{
+			Date:         day,
+			UsageSummary: pt.summarize(byDay[day]),
+		})
+	}
+
+	return report
+}
+
+// usageLine is the part of a transcript line that carries usage.
+type usageLine struct {
+	Type      string        `json:"type"`
+	Timestamp time.Time     `json:"timestamp"`
+	Message   *usageMessage `json:"message"`
+}
+
+// usageMessage is the part of an API response that carries usage.
+type usageMessage struct {
+	ID    string    `json:"id"`
+	Model string    `json:"model"`
+	Usage *apiUsage `json:"usage"`
+}
+
+// apiUsage is the usage of an API response.
+type apiUsage struct {
+	InputTokens         int64 `json:"input_tokens"`
+	OutputTokens        int64 `json:"output_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// transcriptUsage is the usage parsed so far from a single transcript.
+type transcriptUsage struct {
+	// mu guards the fields below while the transcript is read.
+	mu sync.Mutex
+
+	// offset is the end of the last complete line parsed.
+	offset int64
+
+	// lastID, lastKey and last describe the last API response counted.
+	// Streaming writes every content block of a response as its own line
+	// repeating the response's usage, so a line with the same ID replaces
+	// the previous count rather than adding to it.
+	lastID  string
+	lastKey usageKey
+	last    TokenUsage
+
+	buckets usageBuckets
+}
+
+// reset forgets everything parsed, for a transcript that was rewritten.
+func (t *transcriptUsage) reset() {
+	t.offset = 0
+	t.lastID = ""
+	t.lastKey = usageKey{}
+	t.last = TokenUsage{}
+	t.buckets = make(usageBuckets)
+}
+
+// refresh parses the lines appended to the transcript since the last read.
+// A trailing line that is still being written is left for the next read.
+func (t *transcriptUsage) refresh(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < t.offset {
+		t.reset()
+	}
+	if info.Size() == t.offset {
+		return nil
+	}
+
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		t.offset += int64(len(line))
+		t.apply(line)
+	}
+}
+
+// apply counts the usage of a single line, if it has any.
+func (t *transcriptUsage) apply(line []byte) {
+	// Most lines carry no usage, and lines with tool output can be
+	// large, so skip them without decoding.
+	if !bytes.Contains(line, []byte(`"usage"`)) {
+		return
+	}
+
+	var raw usageLine
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return
+	}
+	msg := raw.Message
+	if raw.Type != "assistant" || msg == nil || msg.Usage == nil ||
+		msg.Model == syntheticModel {
+
+		return
+	}
+
+	key := usageKey{
+		day:   raw.Timestamp.Local().Format(usageDayFormat),
+		model: msg.Model,
+	}
+	u := TokenUsage{
+		InputTokens:         msg.Usage.InputTokens,
+		OutputTokens:        msg.Usage.OutputTokens,
+		CacheCreationTokens: msg.Usage.CacheCreationTokens,
+		CacheReadTokens:     msg.Usage.CacheReadTokens,
+		Messages:            1,
+	}
+
+	if msg.ID != "" && msg.ID == t.lastID {
+		prev := t.buckets[t.lastKey]
+		prev.sub(t.last)
+		t.buckets[t.lastKey] = prev
+	}
+
+	sum := t.buckets[key]
+	sum.add(u)
+	t.buckets[key] = sum
+
+	t.lastID, t.lastKey, t.last = msg.ID, key, u
+}
+
+// UsageTracker aggregates token usage and estimated cost from session
+// transcripts.
+//
+// Every transcript is parsed incrementally: the tracker remembers how far it
+// has read each file and only parses what was appended since, so repeated
+// queries over large projects stay cheap.
+type UsageTracker struct {
+	projectIndexer *ProjectIndexer
+	prices         PriceTable
+
+	// mu guards files.
+	mu    sync.Mutex
+	files map[string]*transcriptUsage
+}
+
+// NewUsageTracker creates a usage tracker costing usage with the given
+// prices, or the default prices if nil.
+func NewUsageTracker(projectIndexer *ProjectIndexer,
+	prices PriceTable) *UsageTracker {
+
+	if prices == nil {
+		prices = DefaultPriceTable()
+	}
+
+	return &UsageTracker{
+		projectIndexer: projectIndexer,
+		prices:         prices,
+		files:          make(map[string]*transcriptUsage),
+	}
+}
+
+// fileUsage returns the up to date usage buckets of a transcript.
+func (ut *UsageTracker) fileUsage(path string) (usageBuckets, error) {
+	ut.mu.Lock()
+	t, ok := ut.files[path]
+	if !ok {
+		t = &transcriptUsage{buckets: make(usageBuckets)}
+		ut.files[path] = t
+	}
+	ut.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.refresh(path); err != nil {
+		// Forget transcripts that were removed.
+		if errors.Is(err, os.ErrNotExist) {
+			ut.mu.Lock()
+			delete(ut.files, path)
+			ut.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	return maps.Clone(t.buckets), nil
+}
+
+// sessionBuckets returns the usage of a session's transcript together with
+// the transcripts of the subagents it ran.
+func (ut *UsageTracker) sessionBuckets(path,
+	sessionID string) (usageBuckets, error) {
+
+	buckets, err := ut.fileUsage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	subagents, _ := filepath.Glob(filepath.Join(
+		filepath.Dir(path), sessionID, "subagents", "*.jsonl",
+	))
+	for _, subagent := range subagents {
+		sub, err := ut.fileUsage(subagent)
+		if err != nil {
+			continue
+		}
+		buckets.merge(sub)
+	}
+
+	return buckets, nil
+}
+
+// SessionUsage returns the usage of a single session.
+func (ut *UsageTracker) SessionUsage(sessionID string) (UsageReport, error) {
+	path, ok := ut.projectIndexer.TranscriptPath(sessionID)
+	if !ok {
+		return UsageReport{}, os.ErrNotExist
+	}
+
+	buckets, err := ut.sessionBuckets(path, sessionID)
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("failed to read usage of "+
+			"%s: %w", sessionID, err)
+	}
+
+	return ut.prices.report(buckets), nil
+}
+
+// projectBuckets returns the usage of every session of a project, by
+// session.
+func (ut *UsageTracker) projectBuckets(
+	dirName string) (map[string]usageBuckets, error) {
+
+	files, err := ut.projectIndexer.SessionFiles(dirName)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]usageBuckets, len(files))
+	for _, file := range files {
+		buckets, err := ut.sessionBuckets(file.Path, file.SessionID)
+		if err != nil || len(buckets) == 0 {
+			continue
+		}
+		sessions[file.SessionID] = buckets
+	}
+
+	return sessions, nil
+}
+
+// ProjectUsage returns the usage of a project and of each of its sessions.
+func (ut *UsageTracker) ProjectUsage(dirName string) (ProjectUsage, error) {
+	sessions, err := ut.projectBuckets(dirName)
+	if err != nil {
+		return ProjectUsage{}, fmt.Errorf("failed to read usage of "+
+			"project %s: %w", dirName, err)
+	}
+
+	total := make(usageBuckets)
+	usage := ProjectUsage{
+		Sessions: make(map[string]UsageSummary, len(sessions)),
+	}
+	for sessionID, buckets := range sessions {
+		total.merge(buckets)
+		usage.Sessions[sessionID] = ut.prices.summarize(buckets)
+	}
+	usage.UsageReport = ut.prices.report(total)
+
+	return usage, nil
+}
+
+// Overview returns the usage across every project, by project, project
+// group and day.
+func (ut *UsageTracker) Overview() (UsageOverview, error) {
+	groups, err := ut.projectIndexer.ListProjectGroups()
+	if err != nil {
+		return UsageOverview{}, fmt.Errorf("failed to list "+
+			"projects: %w", err)
+	}
+
+	total := make(usageBuckets)
+	var overview UsageOverview
+	for _, group := range groups {
+		groupTotal := make(usageBuckets)
+		for _, project := range group.Projects {
+			sessions, err := ut.projectBuckets(project.DirName)
+			if err != nil {
+				continue
+			}
+
+			projectTotal := make(usageBuckets)
+			for _, buckets := range sessions {
+				projectTotal.merge(buckets)
+			}
+			if len(projectTotal) == 0 {
+				continue
+			}
+			groupTotal.merge(projectTotal)
+
+			overview.Projects = append(
+				overview.Projects, NamedUsage{
+					Key:  project.DirName,
+					Name: project.Name,
+					UsageSummary: ut.prices.summarize(
+						projectTotal,
+					),
+				},
+			)
+		}
+		if len(groupTotal) == 0 {
+			continue
+		}
+		total.merge(groupTotal)
+
+		overview.Groups = append(overview.Groups, NamedUsage{
+			Key:          group.BaseRepo,
+			Name:         group.BaseRepo,
+			UsageSummary: ut.prices.summarize(groupTotal),
+		})
+	}
+	overview.UsageReport = ut.prices.report(total)
+
+	byCost := func(a, b NamedUsage) int {
+		switch {
+		case a.CostUSD > b.CostUSD:
+			return -1
+		case a.CostUSD < b.CostUSD:
+			return 1
+		default:
+			return strings.Compare(a.Key, b.Key)
+		}
+	}
+	slices.SortFunc(overview.Projects, byCost)
+	slices.SortFunc(overview.Groups, byCost)
+
+	return overview, nil
+}
+
+// formatTokens formats a token count compactly, e.g. "950", "12.3k" or
+// "4.1M".
+func formatTokens(n int64) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+
+	case n < 1000000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	}
+}
+
+// formatCost formats a cost in US dollars.
+func formatCost(usd float64) string {
+	if usd > 0 && usd < 0.01 {
+		return "<$0.01"
+	}
+
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// recentDays returns the last n days of a report, newest first.
+func recentDays(days []DailyUsage, n int) []DailyUsage {
+	recent := slices.Clone(days[max(len(days)-n, 0):])
+	slices.Reverse(recent)
+
+	return recent
+}
@@ -0,0 +1,186 @@
+package taskviewer
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usageTranscriptLine returns an assistant transcript line with usage.
+func usageTranscriptLine(at time.Time, id, model string, input,
+	output int64) string {
+
+	return fmt.Sprintf(`{"type":"assistant","timestamp":%q,`+
+		`"message":{"id":%q,"model":%q,"usage":{"input_tokens":%d,`+
+		`"output_tokens":%d,"cache_read_input_tokens":10}}}`+"\n",
+		at.Format(time.RFC3339), id, model, input, output)
+}
+
+// TestTranscriptUsage checks that streamed responses repeating a message ID
+// are counted once, with their final usage, and that synthetic and partial
+// lines are skipped.
+func TestTranscriptUsage(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	model := "claude-sonnet-4-5"
+	key := usageKey{day: at.Local().Format(usageDayFormat), model: model}
+
+	lines := []string{
+		`{"type":"user","message":{"content":"hi"}}` + "\n",
+
+		// Every content block of msg1 repeats its usage, and the last
+		// has the final output count.
+		usageTranscriptLine(at, "msg1", model, 100, 1),
+		usageTranscriptLine(at, "msg1", model, 100, 20),
+		usageTranscriptLine(at, "msg2", model, 50, 5),
+
+		// Responses without an ID cannot be deduplicated.
+		usageTranscriptLine(at, "", model, 1, 1),
+		usageTranscriptLine(at, "", model, 1, 1),
+
+		usageTranscriptLine(at, "err", syntheticModel, 0, 0),
+		"not json\n",
+	}
+
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600)
+	if err != nil {
+		t.Fatalf("unable to write transcript: %v", err)
+	}
+
+	tu := &transcriptUsage{buckets: make(usageBuckets)}
+	if err := tu.refresh(path); err != nil {
+		t.Fatalf("unable to read transcript: %v", err)
+	}
+
+	want := TokenUsage{
+		InputTokens:     152,
+		OutputTokens:    27,
+		CacheReadTokens: 40,
+		Messages:        4,
+	}
+	if got := tu.buckets[key]; got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// A line still being written is left for the next read, and a
+	// further block of the last message still replaces its count.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("unable to open transcript: %v", err)
+	}
+	defer f.Close()
+
+	next := usageTranscriptLine(at, "", model, 1, 1)
+	next = next + usageTranscriptLine(at, "msg3", model, 7, 3)
+	partial := usageTranscriptLine(at, "msg3", model, 7, 9)
+	if _, err := f.WriteString(next + partial[:20]); err != nil {
+		t.Fatalf("unable to append: %v", err)
+	}
+	if err := tu.refresh(path); err != nil {
+		t.Fatalf("unable to read transcript: %v", err)
+	}
+	if _, err := f.WriteString(partial[20:]); err != nil {
+		t.Fatalf("unable to append: %v", err)
+	}
+	if err := tu.refresh(path); err != nil {
+		t.Fatalf("unable to read transcript: %v", err)
+	}
+
+	want.InputTokens += 8
+	want.OutputTokens += 10
+	want.CacheReadTokens += 20
+	want.Messages += 2
+	if got := tu.buckets[key]; got != want {
+		t.Fatalf("after append got %+v, want %+v", got, want)
+	}
+}
+
+// TestPriceTableLookup checks that models are priced by the longest key
+// they contain.
+func TestPriceTableLookup(t *testing.T) {
+	prices := DefaultPriceTable()
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{model: "claude-opus-4-5-20251101", want: "opus-4-5"},
+		{model: "claude-opus-4-1-20250805", want: "opus"},
+		{model: "claude-sonnet-4-5-20250929", want: "sonnet"},
+		{model: "claude-haiku-4-5-20251001", want: "haiku-4-5"},
+		{model: "claude-3-5-haiku-20241022", want: "haiku"},
+		{model: "gpt-4o"},
+		{model: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.model, func(t *testing.T) {
+			price, ok := prices.lookup(test.model)
+			if ok != (test.want != "") {
+				t.Fatalf("found = %v, want %v", ok, !ok)
+			}
+			if ok && price != prices[test.want] {
+				t.Fatalf("got %+v, want %s price %+v", price,
+					test.want, prices[test.want])
+			}
+		})
+	}
+}
+
+// TestPriceTableSummarize checks that usage of unknown models is counted
+// but not costed, and is reported as unpriced.
+func TestPriceTableSummarize(t *testing.T) {
+	prices := PriceTable{
+		"sonnet": {Input: 3, Output: 15, CacheWrite: 3.75},
+	}
+	buckets := usageBuckets{
+		{day: "2025-03-01", model: "claude-sonnet-4-5"}: {
+			InputTokens: 1_000_000, OutputTokens: 100_000,
+			CacheCreationTokens: 200_000, Messages: 3,
+		},
+		{day: "2025-03-02", model: "claude-sonnet-4-5"}: {
+			InputTokens: 500_000, Messages: 1,
+		},
+		{day: "2025-03-02", model: "mystery-1"}: {
+			InputTokens: 123, OutputTokens: 45, Messages: 2,
+		},
+		{day: "2025-03-02", model: "another-2"}: {
+			OutputTokens: 1, Messages: 1,
+		},
+	}
+
+	summary := prices.summarize(buckets)
+
+	// 1.5M input at $3, 100k output at $15 and 200k cache writes at
+	// $3.75 per million.
+	if want := 4.5 + 1.5 + 0.75; math.Abs(summary.CostUSD-want) > 1e-9 {
+		t.Fatalf("got cost %v, want %v", summary.CostUSD, want)
+	}
+	if summary.InputTokens != 1_500_123 || summary.Messages != 7 {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	wantUnpriced := []string{"another-2", "mystery-1"}
+	if !reflect.DeepEqual(summary.UnpricedModels, wantUnpriced) {
+		t.Fatalf("got unpriced %v, want %v", summary.UnpricedModels,
+			wantUnpriced)
+	}
+
+	report := prices.report(buckets)
+	if len(report.ByDay) != 2 || report.ByDay[0].Date != "2025-03-01" {
+		t.Fatalf("unexpected days: %+v", report.ByDay)
+	}
+	if report.ByDay[0].UnpricedModels != nil {
+		t.Fatalf("first day has unpriced models: %v",
+			report.ByDay[0].UnpricedModels)
+	}
+	if got := report.ByModel["mystery-1"]; got.CostUSD != 0 ||
+		got.OutputTokens != 45 {
+
+		t.Fatalf("unexpected unknown model usage: %+v", got)
+	}
+}
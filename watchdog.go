@@ -0,0 +1,329 @@
+package taskviewer
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// Watchdog event types published on the event bus.
+const (
+	// EventSessionStalled is published when a session is first flagged
+	// as stalled.
+	EventSessionStalled = "session-stalled"
+
+	// EventSessionRecovered is published when a stalled session is no
+	// longer stalled, either because it made progress or because its
+	// process exited.
+	EventSessionRecovered = "session-recovered"
+)
+
+// watchdogInterval is how often the watchdog checks running sessions.
+const watchdogInterval = 30 * time.Second
+
+// StallInfo describes why a session was flagged as stalled.
+type StallInfo struct {
+	SessionID   string `json:"sessionId"`
+	PID         int    `json:"pid"`
+	ProjectName string `json:"projectName,omitempty"`
+
+	// TaskID and TaskSubject identify the longest running in-progress
+	// task, and InProgressSince is when it went in progress.
+	TaskID          string    `json:"taskId"`
+	TaskSubject     string    `json:"taskSubject"`
+	InProgressSince time.Time `json:"inProgressSince"`
+
+	// LastActivity is when the session's transcript was last written, if
+	// it has one.
+	LastActivity time.Time `json:"lastActivity,omitzero"`
+
+	// DetectedAt is when the session was first flagged.
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// WatchdogConfig holds the thresholds after which a session is stalled.
+type WatchdogConfig struct {
+	// TaskAge is how long a task must have been in progress.
+	TaskAge time.Duration
+
+	// Idle is how long the session's transcript must have gone without
+	// being written.
+	Idle time.Duration
+}
+
+// Watchdog flags sessions whose agent appears to hang: the Claude process is
+// still running, a task has been in progress for longer than the configured
+// age, and the session's transcript has not been written for longer than the
+// configured idle time.
+type Watchdog struct {
+	cfg WatchdogConfig
+
+	taskStore       claudeagent.TaskStore
+	projectIndexer  *ProjectIndexer
+	instanceTracker *InstanceTracker
+	taskArchive     *TaskArchive
+	eventBus        *EventBus
+	tasksDir        string
+
+	// mu guards stalled.
+	mu      sync.RWMutex
+	stalled map[string]StallInfo
+
+	started uint32
+	stopped uint32
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	log btclog.Logger
+}
+
+// NewWatchdog creates a watchdog. Task timing is read from the archive's
+// history when available and from the task files in tasksDir otherwise.
+func NewWatchdog(cfg WatchdogConfig, taskStore claudeagent.TaskStore,
+	projectIndexer *ProjectIndexer, instanceTracker *InstanceTracker,
+	taskArchive *TaskArchive, eventBus *EventBus, tasksDir string,
+	log btclog.Logger) *Watchdog {
+
+	return &Watchdog{
+		cfg:             cfg,
+		taskStore:       taskStore,
+		projectIndexer:  projectIndexer,
+		instanceTracker: instanceTracker,
+		taskArchive:     taskArchive,
+		eventBus:        eventBus,
+		tasksDir:        tasksDir,
+		stalled:         make(map[string]StallInfo),
+		quit:            make(chan struct{}),
+		log:             log,
+	}
+}
+
+// Start begins checking sessions periodically. This method is idempotent.
+func (w *Watchdog) Start() error {
+	if !atomic.CompareAndSwapUint32(&w.started, 0, 1) {
+		return nil
+	}
+
+	w.wg.Add(1)
+	go w.checkLoop()
+
+	return nil
+}
+
+// Stop ends the checks. This method is idempotent.
+func (w *Watchdog) Stop() error {
+	if !atomic.CompareAndSwapUint32(&w.stopped, 0, 1) {
+		return nil
+	}
+
+	close(w.quit)
+	w.wg.Wait()
+
+	return nil
+}
+
+// checkLoop runs a check immediately and then on every tick.
+func (w *Watchdog) checkLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		w.check(time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// check re-evaluates every running session and publishes the sessions that
+// became stalled or recovered since the last check.
+func (w *Watchdog) check(now time.Time) {
+	instances, err := w.instanceTracker.ListRunningInstances()
+	if err != nil {
+		w.log.Debugf("Watchdog: unable to list instances: %v", err)
+		return
+	}
+
+	w.mu.RLock()
+	prev := w.stalled
+	w.mu.RUnlock()
+
+	stalled := make(map[string]StallInfo)
+	for _, instance := range instances {
+		if instance.SessionID == "" || !instance.HasTasks {
+			continue
+		}
+
+		info, ok := w.checkSession(instance, now)
+		if !ok {
+			continue
+		}
+
+		// Keep reporting when the stall was first noticed.
+		if old, ok := prev[info.SessionID]; ok {
+			info.DetectedAt = old.DetectedAt
+		}
+		stalled[info.SessionID] = info
+	}
+
+	w.mu.Lock()
+	w.stalled = stalled
+	w.mu.Unlock()
+
+	for sessionID, info := range stalled {
+		if _, ok := prev[sessionID]; ok {
+			continue
+		}
+
+		w.log.Infof("Session %s appears stalled: task %s in progress "+
+			"since %v", sessionID, info.TaskID,
+			info.InProgressSince.Format(time.RFC3339))
+
+		w.eventBus.publish(Event{
+			Type:   EventSessionStalled,
+			ListID: sessionID,
+			Stall:  &info,
+		})
+	}
+
+	for sessionID, info := range prev {
+		if _, ok := stalled[sessionID]; ok {
+			continue
+		}
+
+		w.log.Infof("Session %s is no longer stalled", sessionID)
+
+		w.eventBus.publish(Event{
+			Type:   EventSessionRecovered,
+			ListID: sessionID,
+			Stall:  &info,
+		})
+	}
+}
+
+// checkSession reports whether a running instance's session is stalled.
+func (w *Watchdog) checkSession(instance ClaudeInstance,
+	now time.Time) (StallInfo, bool) {
+
+	sessionID := instance.SessionID
+
+	tasks, err := w.taskStore.List(context.Background(), sessionID)
+	if err != nil || len(tasks) == 0 {
+		return StallInfo{}, false
+	}
+
+	timing, err := loadListTiming(
+		w.taskArchive, w.tasksDir, sessionID, tasks, false,
+	)
+	if err != nil {
+		w.log.Debugf("Watchdog: %v", err)
+	}
+
+	// Find the longest running task that is past the threshold.
+	var oldest *TaskTiming
+	for i := range timing.Tasks {
+		tt := &timing.Tasks[i]
+		if tt.Status != claudeagent.TaskListStatusInProgress ||
+			now.Sub(tt.Since) < w.cfg.TaskAge {
+
+			continue
+		}
+		if oldest == nil || tt.Since.Before(oldest.Since) {
+			oldest = tt
+		}
+	}
+	if oldest == nil {
+		return StallInfo{}, false
+	}
+
+	// A transcript that is still being written means the agent is busy,
+	// however long the task takes.
+	var lastActivity time.Time
+	if path, ok := w.projectIndexer.TranscriptPath(sessionID); ok {
+		info, err := os.Stat(path)
+		if err == nil {
+			lastActivity = info.ModTime()
+			if now.Sub(lastActivity) < w.cfg.Idle {
+				return StallInfo{}, false
+			}
+		}
+	}
+
+	return StallInfo{
+		SessionID:       sessionID,
+		PID:             instance.PID,
+		ProjectName:     instance.ProjectName,
+		TaskID:          oldest.TaskID,
+		TaskSubject:     oldest.Subject,
+		InProgressSince: oldest.Since,
+		LastActivity:    lastActivity,
+		DetectedAt:      now,
+	}, true
+}
+
+// Stall returns the stall info of a session, if it is stalled. It is safe to
+// call on a nil watchdog, which flags nothing.
+func (w *Watchdog) Stall(sessionID string) (StallInfo, bool) {
+	if w == nil {
+		return StallInfo{}, false
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	info, ok := w.stalled[sessionID]
+
+	return info, ok
+}
+
+// Stalled returns every stalled session, longest stalled first. It is safe
+// to call on a nil watchdog.
+func (w *Watchdog) Stalled() []StallInfo {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.RLock()
+	stalled := make([]StallInfo, 0, len(w.stalled))
+	for _, info := range w.stalled {
+		stalled = append(stalled, info)
+	}
+	w.mu.RUnlock()
+
+	sort.Slice(stalled, func(i, j int) bool {
+		return stalled[i].InProgressSince.Before(
+			stalled[j].InProgressSince,
+		)
+	})
+
+	return stalled
+}
+
+// annotateInstances sets the stall info of every stalled instance.
+func (w *Watchdog) annotateInstances(instances []ClaudeInstance) {
+	for i := range instances {
+		if info, ok := w.Stall(instances[i].SessionID); ok {
+			instances[i].Stall = &info
+		}
+	}
+}
+
+// annotateLists sets the stall info of every stalled task list.
+func (w *Watchdog) annotateLists(lists []ActiveTaskList) {
+	for i := range lists {
+		if info, ok := w.Stall(lists[i].SessionID); ok {
+			lists[i].Stall = &info
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package taskviewer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog/v2"
+	claudeagent "github.com/roasbeef/claude-agent-sdk-go"
+)
+
+// TestWatchdogCheck drives the watchdog with an injected clock through a
+// session that is busy, then stalled, then gone, checking that each change
+// is published once.
+func TestWatchdogCheck(t *testing.T) {
+	const workDir = "/repo/app"
+
+	root := t.TempDir()
+	projectsDir := filepath.Join(root, "projects")
+	tasksDir := filepath.Join(root, "tasks")
+	projectDir := filepath.Join(projectsDir, sanitizeProjectPath(workDir))
+	if err := os.MkdirAll(projectDir, 0o700); err != nil {
+		t.Fatalf("unable to create project: %v", err)
+	}
+
+	// The task went in progress at base, and the process started an
+	// hour earlier.
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	started := base.Add(-time.Hour)
+
+	transcript := filepath.Join(projectDir, "s1.jsonl")
+	writeTranscript(t, transcript, started.Add(time.Second), base)
+
+	writeTaskFiles(t, filepath.Join(tasksDir, "s1"), 1)
+	taskFile := filepath.Join(tasksDir, "s1", "1.json")
+	if err := os.Chtimes(taskFile, base, base); err != nil {
+		t.Fatalf("unable to touch task: %v", err)
+	}
+
+	store := newFakeTaskStore()
+	task := testTask("1")
+	task.Status = claudeagent.TaskListStatusInProgress
+	store.set("s1", task)
+
+	procs := &fakeProcessSource{
+		procs: map[int]*ProcessInfo{
+			1: {PID: 1, WorkingDir: workDir, StartTime: started},
+		},
+	}
+
+	projectIndexer := NewProjectIndexer(projectsDir, tasksDir)
+	instanceTracker := NewInstanceTracker(projectIndexer, procs)
+	eventBus := NewEventBus(
+		store, projectIndexer, instanceTracker, btclog.Disabled,
+	)
+	defer eventBus.Stop()
+	sub, _, _ := eventBus.Subscribe("", 0)
+	defer sub.Close()
+
+	cfg := WatchdogConfig{TaskAge: 30 * time.Minute, Idle: 10 * time.Minute}
+	w := NewWatchdog(
+		cfg, store, projectIndexer, instanceTracker, nil, eventBus,
+		tasksDir, btclog.Disabled,
+	)
+
+	// touch sets when the transcript was last written.
+	touch := func(at time.Time) {
+		t.Helper()
+
+		if err := os.Chtimes(transcript, at, at); err != nil {
+			t.Fatalf("unable to touch transcript: %v", err)
+		}
+	}
+
+	// expectEvent checks the next published event, if any.
+	expectEvent := func(want string) {
+		t.Helper()
+
+		select {
+		case ev := <-sub.Events():
+			if want == "" || ev.Type != want {
+				t.Fatalf("got event %s, want %q", ev.Type, want)
+			}
+			if ev.ListID != "s1" || ev.Stall == nil {
+				t.Fatalf("event for %q without stall info",
+					ev.ListID)
+			}
+
+		default:
+			if want != "" {
+				t.Fatalf("no %s event", want)
+			}
+		}
+	}
+
+	steps := []struct {
+		name string
+
+		// at is the time of the check, and written is when the
+		// transcript was last written, both relative to base.
+		at      time.Duration
+		written time.Duration
+
+		// exited removes the process before the check.
+		exited bool
+
+		wantStalled bool
+		wantEvent   string
+	}{
+		{
+			// The task is not old enough, though the session
+			// is idle.
+			name:    "young task",
+			at:      20 * time.Minute,
+			written: 0,
+		},
+		{
+			// The task is old, but the agent is still writing.
+			name:    "busy agent",
+			at:      time.Hour,
+			written: 55 * time.Minute,
+		},
+		{
+			name:        "stalled",
+			at:          2 * time.Hour,
+			written:     55 * time.Minute,
+			wantStalled: true,
+			wantEvent:   EventSessionStalled,
+		},
+		{
+			// Checking again reports nothing new.
+			name:        "still stalled",
+			at:          3 * time.Hour,
+			written:     55 * time.Minute,
+			wantStalled: true,
+		},
+		{
+			name:      "process exited",
+			at:        4 * time.Hour,
+			written:   55 * time.Minute,
+			exited:    true,
+			wantEvent: EventSessionRecovered,
+		},
+	}
+
+	for _, step := range steps {
+		touch(base.Add(step.written))
+		if step.exited {
+			delete(procs.procs, 1)
+		}
+
+		now := base.Add(step.at)
+		w.check(now)
+
+		info, stalled := w.Stall("s1")
+		if stalled != step.wantStalled {
+			t.Fatalf("%s: stalled = %v, want %v", step.name,
+				stalled, step.wantStalled)
+		}
+		if stalled {
+			detected := base.Add(2 * time.Hour)
+			if !info.DetectedAt.Equal(detected) {
+				t.Fatalf("%s: detected at %v, want %v",
+					step.name, info.DetectedAt, detected)
+			}
+			if !info.InProgressSince.Equal(base) || info.PID != 1 {
+				t.Fatalf("%s: unexpected stall %+v", step.name,
+					info)
+			}
+		}
+
+		expectEvent(step.wantEvent)
+	}
+
+	if n := len(w.Stalled()); n != 0 {
+		t.Fatalf("%d sessions still stalled", n)
+	}
+}
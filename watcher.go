@@ -0,0 +1,46 @@
+package taskviewer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// fsWatcher reports paths that changed beneath a set of watched root
+// directories. Only the roots and their immediate subdirectories are watched,
+// which matches the two-level layout of ~/.claude/projects and
+// ~/.claude/tasks.
+//
+// A root path itself is delivered when the watcher may have missed events
+// (e.g. on a kernel queue overflow), signalling that the whole root should be
+// rescanned.
+type fsWatcher interface {
+	// Events returns the channel on which changed paths are delivered.
+	Events() <-chan string
+
+	// Close stops the watcher and closes the events channel.
+	Close() error
+}
+
+// splitWatchPath splits a changed path into the watched root it belongs to
+// and the first path component beneath that root. The name is empty if the
+// path is the root itself.
+func splitWatchPath(roots []string, path string) (root, name string,
+	ok bool) {
+
+	for _, r := range roots {
+		rel, err := filepath.Rel(r, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		if rel == "." {
+			return r, "", true
+		}
+
+		parts := strings.SplitN(rel, string(filepath.Separator), 2)
+
+		return r, parts[0], true
+	}
+
+	return "", "", false
+}
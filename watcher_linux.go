@@ -0,0 +1,238 @@
+//go:build linux
+
+package taskviewer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// inotifyMask is the set of events we care about. IN_MODIFY is deliberately
+// omitted: session transcripts are appended to constantly, and every writer
+// we care about either closes the file or renames it into place.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_DELETE_SELF | syscall.IN_ONLYDIR
+
+// watchErrorBackoff is how long the read loop waits after a failed read
+// before trying again.
+const watchErrorBackoff = time.Second
+
+// inotifyWatcher is an fsWatcher backed by Linux inotify.
+type inotifyWatcher struct {
+	file  *os.File
+	fd    int
+	roots map[string]bool
+
+	// pending holds the roots that do not exist yet. Their parent
+	// directories are watched instead, so that a root is picked up as
+	// soon as it is created.
+	pending map[string]bool
+
+	// watches maps watch descriptors to the directory they watch.
+	watches   map[int32]string
+	watchesMu sync.Mutex
+
+	events chan string
+	wg     sync.WaitGroup
+}
+
+// newFSWatcher creates an inotify watcher over the given roots and each of
+// their immediate subdirectories. Roots that do not exist yet are watched
+// from their parent directory until they are created.
+func newFSWatcher(roots ...string) (fsWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+
+	// Wrapping the non-blocking fd in an os.File registers it with the
+	// runtime poller, so Close will unblock a pending Read.
+	w := &inotifyWatcher{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		roots:   make(map[string]bool),
+		pending: make(map[string]bool),
+		watches: make(map[int32]string),
+		events:  make(chan string, 256),
+	}
+
+	for _, root := range roots {
+		if !w.addRoot(root) {
+			w.awaitRoot(root)
+		}
+	}
+
+	w.wg.Add(1)
+	go w.readLoop()
+
+	return w, nil
+}
+
+// addWatch adds an inotify watch for a single directory.
+func (w *inotifyWatcher) addWatch(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+
+	w.watchesMu.Lock()
+	w.watches[int32(wd)] = dir
+	w.watchesMu.Unlock()
+
+	return nil
+}
+
+// addRoot watches a root and each of its immediate subdirectories. It
+// reports whether the root itself could be watched.
+func (w *inotifyWatcher) addRoot(root string) bool {
+	if err := w.addWatch(root); err != nil {
+		return false
+	}
+	w.roots[root] = true
+	delete(w.pending, root)
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return true
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			_ = w.addWatch(filepath.Join(root, e.Name()))
+		}
+	}
+
+	return true
+}
+
+// awaitRoot watches the parent directory of a root that does not exist, so
+// that the root is added once it is created. A root whose parent is missing
+// too is left to the indexer's periodic rescan.
+func (w *inotifyWatcher) awaitRoot(root string) {
+	delete(w.roots, root)
+	w.pending[root] = true
+	_ = w.addWatch(filepath.Dir(root))
+
+	// The root may have been created before its parent was watched.
+	w.addRoot(root)
+}
+
+// readLoop decodes raw inotify events and forwards their paths.
+func (w *inotifyWatcher) readLoop() {
+	defer w.wg.Done()
+	defer close(w.events)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+
+			// Events may have been lost, so every root must be
+			// rescanned. Back off rather than spin on an error
+			// that persists.
+			for root := range w.roots {
+				w.events <- root
+			}
+			time.Sleep(watchErrorBackoff)
+			continue
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(raw.Len)
+			offset = nameEnd
+
+			// The kernel dropped events, so every root must be
+			// rescanned.
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				for root := range w.roots {
+					w.events <- root
+				}
+				continue
+			}
+
+			w.watchesMu.Lock()
+			dir, ok := w.watches[raw.Wd]
+			ignored := raw.Mask&syscall.IN_IGNORED != 0
+			if ignored {
+				delete(w.watches, raw.Wd)
+			}
+			w.watchesMu.Unlock()
+			if !ok {
+				continue
+			}
+
+			// A root that is removed is watched for again, and
+			// the indexer drops what it had under it.
+			if ignored && w.roots[dir] {
+				w.awaitRoot(dir)
+				w.events <- dir
+				continue
+			}
+
+			path := dir
+			if raw.Len > 0 {
+				name := buf[nameStart:nameEnd]
+				for i, c := range name {
+					if c == 0 {
+						name = name[:i]
+						break
+					}
+				}
+				path = filepath.Join(dir, string(name))
+			}
+
+			// New subdirectories of a root need their own watch so
+			// that files created inside them are seen.
+			isDir := raw.Mask&syscall.IN_ISDIR != 0
+			created := raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0
+			if isDir && created && w.roots[dir] {
+				_ = w.addWatch(path)
+			}
+
+			// A pending root that appears is watched from now on,
+			// and scanned in full for whatever it already holds.
+			// Nothing else in its parent is of interest.
+			if w.pending[path] {
+				if isDir && created && w.addRoot(path) {
+					w.events <- path
+				}
+				continue
+			}
+			if !w.roots[dir] && !w.roots[filepath.Dir(dir)] {
+				continue
+			}
+
+			w.events <- path
+		}
+	}
+}
+
+// Events returns the channel on which changed paths are delivered.
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+// Close stops the watcher and closes the events channel.
+func (w *inotifyWatcher) Close() error {
+	err := w.file.Close()
+
+	// Drain any pending sends so the read loop can observe the close.
+	go func() {
+		for range w.events {
+		}
+	}()
+	w.wg.Wait()
+
+	return err
+}
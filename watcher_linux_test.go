@@ -0,0 +1,107 @@
+//go:build linux
+
+package taskviewer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForPath waits for a watcher to deliver a path, skipping any other
+// paths delivered before it.
+func waitForPath(t *testing.T, w fsWatcher, want string) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case path, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("watcher closed waiting for %s", want)
+			}
+			if path == want {
+				return
+			}
+
+		case <-timeout:
+			t.Fatalf("no event for %s", want)
+		}
+	}
+}
+
+// drainEvents discards events until the watcher has been quiet for a
+// while.
+func drainEvents(w fsWatcher) {
+	for {
+		select {
+		case <-w.Events():
+		case <-time.After(200 * time.Millisecond):
+			return
+		}
+	}
+}
+
+// TestInotifyWatcherPendingRoot checks that a root missing when the watcher
+// starts is watched once it is created, and again after it is removed, and
+// that nothing else in its parent is reported.
+func TestInotifyWatcherPendingRoot(t *testing.T) {
+	claudeDir := t.TempDir()
+	root := filepath.Join(claudeDir, "tasks")
+
+	w, err := newFSWatcher(root)
+	if err != nil {
+		t.Fatalf("unable to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	mkdir := func(dir string) {
+		t.Helper()
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatalf("unable to create %s: %v", dir, err)
+		}
+	}
+	write := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatalf("unable to write %s: %v", path, err)
+		}
+	}
+
+	mkdir(root)
+	waitForPath(t, w, root)
+
+	// Files in the parent are not reported, so the next event is the
+	// new list.
+	write(filepath.Join(claudeDir, "settings.json"))
+	list := filepath.Join(root, "s1")
+	mkdir(list)
+	select {
+	case path := <-w.Events():
+		if path != list {
+			t.Fatalf("got %s, want %s", path, list)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event for %s", list)
+	}
+
+	task := filepath.Join(list, "1.json")
+	write(task)
+	waitForPath(t, w, task)
+
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatalf("unable to remove %s: %v", root, err)
+	}
+	waitForPath(t, w, root)
+	drainEvents(w)
+
+	// The new list is only watched once its creation has been read,
+	// which the indexer covers by recounting the list on that event.
+	mkdir(root)
+	waitForPath(t, w, root)
+	mkdir(list)
+	waitForPath(t, w, list)
+	write(task)
+	waitForPath(t, w, task)
+}
@@ -0,0 +1,131 @@
+//go:build !linux
+
+package taskviewer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often the polling watcher stats the watched trees.
+const pollInterval = 2 * time.Second
+
+// pollWatcher is an fsWatcher for platforms without inotify. It periodically
+// stats each root's subdirectories and the .json files inside them, and
+// reports any path whose modification time changed, appeared or vanished.
+type pollWatcher struct {
+	roots []string
+	state map[string]time.Time
+
+	events chan string
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newFSWatcher creates a polling watcher over the given roots.
+func newFSWatcher(roots ...string) (fsWatcher, error) {
+	w := &pollWatcher{
+		roots:  roots,
+		events: make(chan string, 256),
+		quit:   make(chan struct{}),
+	}
+	w.state = w.snapshot()
+
+	w.wg.Add(1)
+	go w.pollLoop()
+
+	return w, nil
+}
+
+// snapshot collects the modification times of everything we watch.
+func (w *pollWatcher) snapshot() map[string]time.Time {
+	state := make(map[string]time.Time)
+	for _, root := range w.roots {
+		dirs, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+
+		for _, d := range dirs {
+			if !d.IsDir() {
+				continue
+			}
+
+			dirPath := filepath.Join(root, d.Name())
+			if info, err := d.Info(); err == nil {
+				state[dirPath] = info.ModTime()
+			}
+
+			files, err := os.ReadDir(dirPath)
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				if !strings.HasSuffix(f.Name(), ".json") {
+					continue
+				}
+				if info, err := f.Info(); err == nil {
+					state[filepath.Join(dirPath, f.Name())] =
+						info.ModTime()
+				}
+			}
+		}
+	}
+
+	return state
+}
+
+// pollLoop diffs successive snapshots and reports changed paths.
+func (w *pollWatcher) pollLoop() {
+	defer w.wg.Done()
+	defer close(w.events)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.quit:
+			return
+		}
+
+		next := w.snapshot()
+		var changed []string
+		for path, mtime := range next {
+			if prev, ok := w.state[path]; !ok || !prev.Equal(mtime) {
+				changed = append(changed, path)
+			}
+		}
+		for path := range w.state {
+			if _, ok := next[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		w.state = next
+
+		for _, path := range changed {
+			select {
+			case w.events <- path:
+			case <-w.quit:
+				return
+			}
+		}
+	}
+}
+
+// Events returns the channel on which changed paths are delivered.
+func (w *pollWatcher) Events() <-chan string {
+	return w.events
+}
+
+// Close stops the watcher and closes the events channel.
+func (w *pollWatcher) Close() error {
+	close(w.quit)
+	w.wg.Wait()
+
+	return nil
+}